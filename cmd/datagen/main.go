@@ -0,0 +1,218 @@
+// Command datagen fills a store backend with a large, realistic dataset of
+// todos for benchmarking: creation times spread over a period, a mix of
+// open, completed and archived todos, descriptions of varying length and a
+// share of imported todos with external IDs. The same seed and flags
+// produce the same dataset.
+//
+//	go run ./cmd/datagen -store sqlite -store-dsn bench.db -count 100000
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+	_ "github.com/efrem/windsurf/internal/todo/boltstore"
+	_ "github.com/efrem/windsurf/internal/todo/pgstore"
+	_ "github.com/efrem/windsurf/internal/todo/redisstore"
+	_ "github.com/efrem/windsurf/internal/todo/sqlitestore"
+)
+
+// config is the shape of a generated dataset.
+type config struct {
+	count int
+	// completed and archived are the shares of todos that are completed
+	// and, among all todos, archived. Archived todos are completed first,
+	// so archived is at most completed.
+	completed float64
+	archived  float64
+	// imported is the share of todos with a source and external ID.
+	imported float64
+	// spread is the period before end that creation times are spread
+	// over.
+	spread time.Duration
+	end    time.Time
+	seed   uint64
+}
+
+func main() {
+	storeBackend := flag.String("store", todo.MemoryBackend, fmt.Sprintf("store backend to fill, one of %v", todo.Backends()))
+	storeDSN := flag.String("store-dsn", "", "backend-specific data source (file path or connection URL)")
+	count := flag.Int("count", 10000, "number of todos to generate")
+	completed := flag.Float64("completed", 0.4, "share of todos that are completed, from 0 to 1")
+	archived := flag.Float64("archived", 0.1, "share of todos that are archived, at most -completed")
+	imported := flag.Float64("imported", 0.2, "share of todos imported with a source and external ID")
+	spread := flag.Duration("spread", 365*24*time.Hour, "period before now that creation times are spread over")
+	seed := flag.Uint64("seed", 1, "random seed; the same seed and flags generate the same dataset")
+	flag.Parse()
+
+	cfg := config{
+		count:     *count,
+		completed: *completed,
+		archived:  *archived,
+		imported:  *imported,
+		spread:    *spread,
+		end:       time.Now().Truncate(time.Second),
+		seed:      *seed,
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "datagen: %v\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The store reads every timestamp from the clock, which the generator
+	// moves to the time of each change.
+	clock := todo.NewManualClock(cfg.end)
+	store, err := todo.NewStoreFromConfig(todo.StoreConfig{Backend: *storeBackend, DSN: *storeDSN, Clock: clock})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	start := time.Now()
+	stats, err := generate(context.Background(), store, clock, cfg, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("generated %d todos (%d completed, %d archived, %d imported) in %s\n",
+		cfg.count, stats.completed, stats.archived, stats.imported, time.Since(start).Round(time.Millisecond))
+}
+
+// validate checks that the shares are consistent.
+func (c config) validate() error {
+	switch {
+	case c.count <= 0:
+		return fmt.Errorf("-count must be positive")
+	case c.completed < 0 || c.completed > 1 || c.archived < 0 || c.imported < 0 || c.imported > 1:
+		return fmt.Errorf("-completed, -archived and -imported must be between 0 and 1")
+	case c.archived > c.completed:
+		return fmt.Errorf("-archived must not exceed -completed, as only completed todos are archived")
+	case c.spread <= 0:
+		return fmt.Errorf("-spread must be positive")
+	}
+	return nil
+}
+
+// stats counts the todos generate moved past their initial state.
+type stats struct {
+	completed, archived, imported int
+}
+
+// generate creates cfg.count todos in store in the order of their creation
+// times, writing progress to out. Completions and archivals happen a while
+// after creation, but never after cfg.end. It stops at the first failure
+// of the store.
+func generate(ctx context.Context, store todo.Store, clock *todo.ManualClock, cfg config, out io.Writer) (stats, error) {
+	rng := rand.New(rand.NewPCG(cfg.seed, cfg.seed))
+
+	created := make([]time.Time, cfg.count)
+	for i := range created {
+		created[i] = cfg.end.Add(-time.Duration(rng.Int64N(int64(cfg.spread))))
+	}
+	slices.SortFunc(created, time.Time.Compare)
+
+	var s stats
+	for i, at := range created {
+		input := todo.TodoInput{Title: title(rng), Description: description(rng)}
+		if rng.Float64() < cfg.imported {
+			input.Source = sources[rng.IntN(len(sources))]
+			input.ExternalID = fmt.Sprintf("DG-%d", i+1)
+			s.imported++
+		}
+		clock.Set(at)
+		t, err := store.Create(ctx, input)
+		if err != nil {
+			return s, err
+		}
+
+		roll := rng.Float64()
+		if roll < cfg.completed {
+			at = later(rng, at, cfg.end)
+			clock.Set(at)
+			if _, err := store.Complete(ctx, t.ID); err != nil {
+				return s, err
+			}
+			s.completed++
+		}
+		if roll < cfg.archived {
+			clock.Set(later(rng, at, cfg.end))
+			if _, err := store.SetState(ctx, t.ID, todo.StateArchived); err != nil {
+				return s, err
+			}
+			s.archived++
+		}
+
+		if n := i + 1; n%max(cfg.count/10, 1) == 0 {
+			fmt.Fprintf(out, "%d/%d todos\n", n, cfg.count)
+		}
+	}
+	return s, nil
+}
+
+// later returns a time up to two weeks after at, but not after end.
+func later(rng *rand.Rand, at, end time.Time) time.Time {
+	next := at.Add(time.Duration(rng.Int64N(int64(14 * 24 * time.Hour))))
+	if next.After(end) {
+		return end
+	}
+	return next
+}
+
+var (
+	verbs = []string{
+		"Review", "Write", "Update", "Fix", "Plan", "Prepare", "Call about", "Email", "Schedule",
+		"Refactor", "Test", "Deploy", "Clean up", "Organize", "Research", "Book", "Order", "Pay",
+		"Renew", "Draft",
+	}
+	objects = []string{
+		"quarterly budget", "release notes", "onboarding docs", "login bug", "team offsite",
+		"dentist appointment", "insurance policy", "garage", "API pagination", "search index",
+		"CI pipeline", "grocery list", "vendor contract", "blog post", "database backup",
+		"conference talk", "tax return", "flight to Berlin", "birthday gift", "car service",
+	}
+	sentences = []string{
+		"Check with the team before Friday.",
+		"The last version is in the shared drive.",
+		"Ask for a second opinion if the numbers look off.",
+		"Blocked until the new credentials arrive.",
+		"Keep it short; nobody reads past the first page.",
+		"Compare the three offers and pick the cheapest that covers everything.",
+		"Follow up if there is no answer within a week.",
+		"Remember to attach the receipts.",
+		"Split this up if it takes longer than a day.",
+		"Notes from the last meeting are linked in the ticket.",
+	}
+	sources = []string{"jira", "github", "trello"}
+)
+
+// title returns a random title such as "Review quarterly budget".
+func title(rng *rand.Rand) string {
+	return verbs[rng.IntN(len(verbs))] + " " + objects[rng.IntN(len(objects))]
+}
+
+// description returns a random description: empty for two in five todos, a
+// sentence for most others and a few paragraphs for the rest.
+func description(rng *rand.Rand) string {
+	switch roll := rng.Float64(); {
+	case roll < 0.4:
+		return ""
+	case roll < 0.85:
+		return sentences[rng.IntN(len(sentences))]
+	}
+	paragraphs := make([]string, 2+rng.IntN(3))
+	for i := range paragraphs {
+		paragraphs[i] = sentences[rng.IntN(len(sentences))] + " " + sentences[rng.IntN(len(sentences))]
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
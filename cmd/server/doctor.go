@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+)
+
+// Clock skew between the server and its database above these thresholds is
+// reported as a warning or a failure by the doctor command.
+const (
+	skewWarning = time.Second
+	skewFailure = time.Minute
+)
+
+// doctorTimeout bounds each network check of the doctor command.
+const doctorTimeout = 5 * time.Second
+
+// minAdminTokenLength is the shortest admin token the doctor accepts
+// without a warning.
+const minAdminTokenLength = 16
+
+// doctorConfig is the server configuration checked by the doctor command.
+type doctorConfig struct {
+	addr               string
+	grpcAddr           string
+	adminAddr          string
+	store              todo.StoreConfig
+	seedFile           string
+	seedProfile        string
+	seedCount          int
+	adminToken         string
+	backupRecipient    string
+	backupIdentityFile string
+	telemetryEndpoint  string
+}
+
+// checkStatus is the outcome of one doctor check.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkWarn:
+		return "⚠"
+	case checkFail:
+		return "✖"
+	}
+	return "✔"
+}
+
+// doctor runs the self-checks of a server configuration and writes one
+// line per finding, with a hint on how to fix warnings and failures.
+type doctor struct {
+	out    io.Writer
+	failed bool
+}
+
+// report writes a finding. The hint is only shown for warnings and failures.
+func (d *doctor) report(status checkStatus, name, detail, hint string) {
+	fmt.Fprintf(d.out, "%s %-10s %s\n", status, name, detail)
+	if status != checkOK && hint != "" {
+		fmt.Fprintf(d.out, "  → %s\n", hint)
+	}
+	if status == checkFail {
+		d.failed = true
+	}
+}
+
+// runDoctor checks the configuration, the store, its schema, the clock skew
+// between the server and the database and the reachability of outbound
+// endpoints. It returns the process exit code: 1 if any check failed.
+func runDoctor(cfg doctorConfig, out io.Writer) int {
+	d := &doctor{out: out}
+
+	d.checkConfig(cfg)
+	d.checkListen("http", cfg.addr, "in -container mode the port is read from PORT")
+	if cfg.grpcAddr != "" {
+		d.checkListen("grpc", cfg.grpcAddr, "pass -grpc-addr with a free port, or an empty value to disable gRPC")
+	}
+	if cfg.adminToken != "" && cfg.adminAddr != "" {
+		d.checkListen("admin", cfg.adminAddr, "pass -admin-addr with a free port, or an empty value to serve /admin on the public listener")
+	}
+	if store := d.checkStore(cfg.store); store != nil {
+		d.checkMigrations(store)
+		d.checkClockSkew(store)
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	d.checkEndpoint("telemetry", cfg.telemetryEndpoint)
+
+	if d.failed {
+		fmt.Fprintln(out, "\nSome checks failed.")
+		return 1
+	}
+	fmt.Fprintln(out, "\nAll checks passed.")
+	return 0
+}
+
+// checkConfig validates the seed data and the admin and backup settings.
+func (d *doctor) checkConfig(cfg doctorConfig) {
+	if seed, err := loadSeed(cfg.seedFile, cfg.seedProfile, cfg.seedCount); err != nil {
+		d.report(checkFail, "seed", err.Error(), "fix the -seed-file fixture or pick a profile listed in -help")
+	} else {
+		d.report(checkOK, "seed", fmt.Sprintf("%d todos", len(seed.Todos)), "")
+	}
+
+	switch {
+	case cfg.adminToken == "":
+		d.report(checkOK, "admin", "admin endpoints disabled", "")
+	case len(cfg.adminToken) < minAdminTokenLength:
+		d.report(checkWarn, "admin", fmt.Sprintf("admin token is only %d characters", len(cfg.adminToken)),
+			fmt.Sprintf("use a random token of at least %d characters, e.g. openssl rand -hex 32", minAdminTokenLength))
+	default:
+		d.report(checkOK, "admin", "admin endpoints enabled", "")
+	}
+
+	if cfg.backupRecipient == "" && cfg.backupIdentityFile == "" {
+		if cfg.adminToken != "" {
+			d.report(checkWarn, "backup", "backups are not encrypted", "set -backup-recipient and -backup-identity-file to encrypt backups with age")
+		}
+		return
+	}
+	recipients, identities, err := loadBackupKeys(cfg.backupRecipient, cfg.backupIdentityFile)
+	switch {
+	case err != nil:
+		d.report(checkFail, "backup", err.Error(), "check the age keys given by -backup-recipient and -backup-identity-file")
+	case len(identities) == 0:
+		d.report(checkWarn, "backup", "encrypted backups cannot be restored", "set -backup-identity-file to the identity matching -backup-recipient")
+	case len(recipients) == 0:
+		d.report(checkWarn, "backup", "backups are not encrypted, but restores require encryption", "set -backup-recipient to the public key of -backup-identity-file")
+	default:
+		d.report(checkOK, "backup", fmt.Sprintf("encrypted to %d recipients", len(recipients)), "")
+	}
+}
+
+// checkListen reports whether addr is free to listen on.
+func (d *doctor) checkListen(name, addr, hint string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		d.report(checkFail, name, fmt.Sprintf("cannot listen on %s: %v", addr, err), "stop the process using the port or use another one; "+hint)
+		return
+	}
+	lis.Close()
+	d.report(checkOK, name, "can listen on "+addr, "")
+}
+
+// checkStore opens the configured store and reads from it. It returns nil
+// if the store is unusable.
+func (d *doctor) checkStore(cfg todo.StoreConfig) todo.Store {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = todo.MemoryBackend
+	}
+
+	store, err := todo.NewStoreFromConfig(cfg)
+	if err != nil {
+		d.report(checkFail, "store", err.Error(), "check -store and -store-dsn, and that the database is running and reachable")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	todos, err := store.GetAll(ctx)
+	if err != nil {
+		d.report(checkFail, "store", fmt.Sprintf("%s store cannot be read: %v", backend, err), "check the database logs and the permissions of the -store-dsn user")
+		return nil
+	}
+	count := len(todos)
+
+	if backend == todo.MemoryBackend {
+		d.report(checkWarn, "store", "memory store: todos are lost on restart", "pass -store with a persistent backend, such as sqlite")
+		return store
+	}
+	d.report(checkOK, "store", fmt.Sprintf("%s store holds %d todos", backend, count), "")
+	return store
+}
+
+// checkMigrations applies pending schema migrations of store.
+func (d *doctor) checkMigrations(store todo.Store) {
+	migrating, ok := store.(todo.MigratingStore)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	applied, err := migrating.Migrate(ctx)
+	switch {
+	case err != nil:
+		d.report(checkFail, "schema", err.Error(), "run the server with -migrate and check the database logs")
+	case applied > 0:
+		d.report(checkOK, "schema", fmt.Sprintf("applied %d pending migrations", applied), "")
+	default:
+		d.report(checkOK, "schema", "up to date", "")
+	}
+}
+
+// checkClockSkew compares the local clock with the database server's.
+func (d *doctor) checkClockSkew(store todo.Store) {
+	source, ok := store.(todo.ClockSource)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	before := time.Now()
+	serverTime, err := source.ServerTime(ctx)
+	if err != nil {
+		d.report(checkFail, "clock", "cannot read the database time: "+err.Error(), "check the database connection")
+		return
+	}
+	// Compare with the middle of the round trip.
+	local := before.Add(time.Since(before) / 2)
+	skew := serverTime.Sub(local).Abs()
+
+	detail := fmt.Sprintf("database clock differs by %s", skew.Round(time.Millisecond))
+	const hint = "synchronise the clocks of both hosts with NTP; timestamps and snapshot expiry depend on them"
+	switch {
+	case skew > skewFailure:
+		d.report(checkFail, "clock", detail, hint)
+	case skew > skewWarning:
+		d.report(checkWarn, "clock", detail, hint)
+	default:
+		d.report(checkOK, "clock", detail, "")
+	}
+}
+
+// checkEndpoint reports whether the host of the endpoint URL accepts TCP
+// connections. An empty endpoint is disabled and not checked.
+func (d *doctor) checkEndpoint(name, endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		d.report(checkFail, name, fmt.Sprintf("invalid endpoint %q", endpoint), "use an absolute http or https URL")
+		return
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, doctorTimeout)
+	if err != nil {
+		d.report(checkFail, name, fmt.Sprintf("%s is unreachable: %v", endpoint, err), "check the URL, DNS and outbound firewall rules")
+		return
+	}
+	conn.Close()
+	d.report(checkOK, name, endpoint+" is reachable", "")
+}
@@ -1,25 +1,359 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"filippo.io/age"
+	"github.com/efrem/windsurf/internal/fixtures"
+	"github.com/efrem/windsurf/internal/telemetry"
 	"github.com/efrem/windsurf/internal/todo"
+	_ "github.com/efrem/windsurf/internal/todo/boltstore"
+	todogrpc "github.com/efrem/windsurf/internal/todo/grpc"
+	_ "github.com/efrem/windsurf/internal/todo/pgstore"
+	_ "github.com/efrem/windsurf/internal/todo/redisstore"
+	_ "github.com/efrem/windsurf/internal/todo/sqlitestore"
+	grpclib "google.golang.org/grpc"
 )
 
+// version is the server version reported by telemetry. Release builds set
+// it with -ldflags "-X main.version=...".
+var version = "dev"
+
+// defaultPort is the listen port unless container mode reads PORT.
+const defaultPort = "8000"
+
 // main is the entrypoint for the Todo API HTTP server.
-// It configures the listen port and base URL, builds the router,
-// and starts the HTTP server on port 8000.
+// It configures the listen address and base URL, loads the seed data
+// from a fixture file or a built-in profile, opens the configured store,
+// builds the router, starts the opt-in telemetry reporter and the gRPC
+// server, and starts the HTTP server. On SIGINT or SIGTERM it shuts down
+// gracefully and logs a shutdown report.
+//
+// "server doctor [flags]" checks the configuration given by the same flags
+// instead of starting the server, "server smoke -against URL" tests a
+// running deployment, and "server pitr -to TIME" restores the wal store to
+// a point in time.
 func main() {
-	port := ":8000"
-	baseURL := "http://localhost:8000"
+	if len(os.Args) > 1 && os.Args[1] == "smoke" {
+		os.Exit(runSmoke(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "pitr" {
+		os.Exit(runPITR(os.Args[2:], os.Stdout))
+	}
+	doctorMode := len(os.Args) > 1 && os.Args[1] == "doctor"
+	if doctorMode {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	container := flag.Bool("container", false, "zero-config mode for containers: listen on 0.0.0.0:$PORT, derive links from X-Forwarded-* headers, and default to SQLite in -data-dir")
+	dataDir := flag.String("data-dir", "/data", "directory of the default SQLite database in -container mode")
+	storeBackend := flag.String("store", todo.MemoryBackend, fmt.Sprintf("store backend, one of %v", todo.Backends()))
+	storeDSN := flag.String("store-dsn", "", "backend-specific data source (file path or connection URL)")
+	storeFlush := flag.Duration("store-flush-interval", 0, "batch writes of the json store to this interval (0 writes on every change)")
+	slowStore := flag.Duration("slow-store-threshold", 200*time.Millisecond, "log store operations slower than this with their parameters (0 disables)")
+	seedFile := flag.String("seed-file", "", "YAML or JSON fixture file to seed the store with (overrides -seed-profile)")
+	seedProfile := flag.String("seed-profile", "", fmt.Sprintf("built-in seed profile, one of %v (default demo for the memory store, empty otherwise)", fixtures.Profiles()))
+	seedCount := flag.Int("seed-count", fixtures.DefaultLoadTestCount, "number of todos generated by the load-test seed profile")
+	maxTitle := flag.Int("max-title-length", todo.DefaultMaxTitleLength, "maximum length of todo titles, in characters")
+	maxDescription := flag.Int("max-description-length", todo.DefaultMaxDescriptionLength, "maximum length of todo descriptions, in characters")
+	longTitle := flag.Int("long-title-length", todo.DefaultLongTitleLength, "length above which todo titles are flagged as very long, in characters")
+	strictness := flag.String("strictness", string(todo.StrictnessLenient), "how very long titles are handled: lenient accepts them with a warning, strict rejects them")
+	subtaskPolicy := flag.String("subtask-policy", string(todo.SubtaskPolicyBlock), "how completing a todo with open subtasks is handled: block rejects it, cascade completes the subtasks too")
+	migrateOnly := flag.Bool("migrate", false, "apply pending schema migrations of the selected store and exit")
+	telemetryEndpoint := flag.String("telemetry-endpoint", "", "opt in to anonymous usage reports sent to this URL (disabled when empty)")
+	telemetryInterval := flag.Duration("telemetry-interval", telemetry.DefaultInterval, "interval between usage reports")
+	adminToken := flag.String("admin-token", os.Getenv("TODO_ADMIN_TOKEN"), "bearer token enabling the /admin backup and restore endpoints (defaults to $TODO_ADMIN_TOKEN; disabled when empty)")
+	adminAddr := flag.String("admin-addr", "127.0.0.1:9091", "address of the separate listener serving /admin and /debug/pprof/ (when empty, /admin is served on the public listener and /debug is disabled)")
+	backupRecipient := flag.String("backup-recipient", os.Getenv("TODO_BACKUP_RECIPIENT"), "age recipients (comma-separated public keys) to encrypt admin backups to (defaults to $TODO_BACKUP_RECIPIENT)")
+	backupIdentityFile := flag.String("backup-identity-file", os.Getenv("TODO_BACKUP_IDENTITY_FILE"), "age identity file used to decrypt backups on restore; plaintext restores are then rejected (defaults to $TODO_BACKUP_IDENTITY_FILE)")
+	grpcAddr := flag.String("grpc-addr", "127.0.0.1:9090", "address of the unauthenticated gRPC server sharing the HTTP API's store, loopback only by default (disabled when empty)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "time to let open requests finish on shutdown before their connections are closed")
+	shutdownReportFile := flag.String("shutdown-report", "", "file to write the JSON shutdown report to (only logged when empty)")
+	flag.Parse()
+
+	addr, baseURL := listenAddress(*container)
+	if *container && !flagSet("store") {
+		*storeBackend = "sqlite"
+		if !flagSet("store-dsn") {
+			*storeDSN = filepath.Join(*dataDir, "todos.db")
+		}
+	}
+	if *seedProfile == "" {
+		*seedProfile = defaultSeedProfile(*storeBackend)
+	}
+
+	storeConfig := todo.StoreConfig{
+		Backend:       *storeBackend,
+		DSN:           *storeDSN,
+		FlushInterval: *storeFlush,
+	}
+
+	if doctorMode {
+		os.Exit(runDoctor(doctorConfig{
+			addr:               addr,
+			grpcAddr:           *grpcAddr,
+			adminAddr:          *adminAddr,
+			store:              storeConfig,
+			seedFile:           *seedFile,
+			seedProfile:        *seedProfile,
+			seedCount:          *seedCount,
+			adminToken:         *adminToken,
+			backupRecipient:    *backupRecipient,
+			backupIdentityFile: *backupIdentityFile,
+			telemetryEndpoint:  *telemetryEndpoint,
+		}, os.Stdout))
+	}
+
+	strictnessLevel, ok := todo.ParseStrictness(*strictness)
+	if !ok {
+		log.Fatalf("-strictness must be %s or %s", todo.StrictnessLenient, todo.StrictnessStrict)
+	}
+	subtaskPolicyValue, ok := todo.ParseSubtaskPolicy(*subtaskPolicy)
+	if !ok {
+		log.Fatalf("-subtask-policy must be %s or %s", todo.SubtaskPolicyBlock, todo.SubtaskPolicyCascade)
+	}
+
+	seed, err := loadSeed(*seedFile, *seedProfile, *seedCount)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := todo.NewStoreFromConfig(storeConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *migrateOnly {
+		runMigrations(store)
+		return
+	}
+
+	opts := []todo.RouterOption{
+		todo.WithStore(store),
+		todo.WithSeeder(seedIfNew(seed)),
+		todo.WithLimits(todo.Limits{
+			Title:       *maxTitle,
+			Description: *maxDescription,
+			LongTitle:   *longTitle,
+			Strictness:  strictnessLevel,
+		}),
+		todo.WithSubtaskPolicy(subtaskPolicyValue),
+	}
+	if *slowStore > 0 {
+		opts = append(opts, todo.WithSlowStoreLog(*slowStore, nil))
+	}
+	if *container {
+		opts = append(opts, todo.WithForwardedBaseURL())
+	}
+	var admin http.Handler
+	if *adminToken != "" {
+		opts = append(opts, todo.WithAdminToken(*adminToken))
+		if *adminAddr != "" {
+			opts = append(opts, todo.WithAdminListener(func(h http.Handler) { admin = h }))
+		}
+	}
+	if *backupRecipient != "" || *backupIdentityFile != "" {
+		recipients, identities, err := loadBackupKeys(*backupRecipient, *backupIdentityFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, todo.WithBackupEncryption(recipients, identities))
+	}
+	var service todo.Service
+	opts = append(opts, todo.WithServiceHook(func(s todo.Service) { service = s }))
+	r := todo.NewRouter(baseURL, opts...)
+
+	errs := make(chan error, 3)
+	tracker := newConnTracker()
+	var servers []*http.Server
+
+	var grpcServer *grpclib.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("🔌 gRPC server listening on %s\n", lis.Addr())
+		grpcServer = todogrpc.Register(service)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	if admin != nil {
+		lis, err := net.Listen("tcp", *adminAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("🔒 Admin endpoints listening on %s\n", lis.Addr())
+		srv := &http.Server{Handler: admin, ConnState: tracker.track}
+		servers = append(servers, srv)
+		go serve(srv, lis, errs)
+	}
+
+	reporter := telemetry.New(telemetry.Config{
+		Endpoint: *telemetryEndpoint,
+		Interval: *telemetryInterval,
+		Version:  version,
+		Store:    *storeBackend,
+
+		RelationHeader: todo.HeaderLinkRel,
+		Relations:      todo.LinkRelations(),
+	})
+	ctx, stopReporter := context.WithCancel(context.Background())
+	go reporter.Run(ctx)
 
-	r := todo.NewRouter(baseURL)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &http.Server{Handler: reporter.Middleware(r), ConnState: tracker.track}
+	servers = append(servers, srv)
+	go serve(srv, lis, errs)
 
-	fmt.Printf("🚀 HATEOAS Todo API server starting on %s\n", port)
+	fmt.Printf("🚀 HATEOAS Todo API server starting on %s\n", addr)
 	fmt.Printf("📖 Try: curl %s\n", baseURL)
 	fmt.Printf("📝 Try: curl %s/todos\n", baseURL)
 
-	log.Fatal(http.ListenAndServe(port, r))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	var reason string
+	var failure error
+	select {
+	case sig := <-signals:
+		reason = sig.String()
+	case failure = <-errs:
+		reason = failure.Error()
+		log.Print(failure)
+	}
+
+	stopReporter()
+	report := shutdown(reason, *shutdownTimeout, servers, tracker, grpcServer, reporter, store)
+	report.log()
+	if *shutdownReportFile != "" {
+		if err := report.write(*shutdownReportFile); err != nil {
+			log.Printf("write shutdown report: %v", err)
+		}
+	}
+	if failure != nil {
+		os.Exit(1)
+	}
+}
+
+// listenAddress returns the address to listen on and the default base URL.
+// In container mode the port is read from the PORT environment variable and
+// the server binds all IPv4 interfaces explicitly.
+func listenAddress(container bool) (addr, baseURL string) {
+	port := defaultPort
+	host := ""
+	if container {
+		if p := os.Getenv("PORT"); p != "" {
+			port = p
+		}
+		host = "0.0.0.0"
+	}
+	return host + ":" + port, "http://localhost:" + port
+}
+
+// runMigrations makes sure the schema of store is up to date. SQL stores
+// apply pending migrations when they are opened, so this lets deployment
+// scripts migrate (and fail on errors) without starting the server.
+func runMigrations(store todo.Store) {
+	migrating, ok := store.(todo.MigratingStore)
+	if !ok {
+		fmt.Println("store has no schema to migrate")
+		return
+	}
+	if _, err := migrating.Migrate(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("schema is up to date")
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// loadBackupKeys parses the comma-separated age recipients and the identity
+// file used for backup encryption. Either may be empty.
+func loadBackupKeys(recipientList, identityFile string) ([]age.Recipient, []age.Identity, error) {
+	var recipients []age.Recipient
+	if recipientList != "" {
+		parsed, err := age.ParseRecipients(strings.NewReader(strings.ReplaceAll(recipientList, ",", "\n")))
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse backup recipients: %w", err)
+		}
+		recipients = parsed
+	}
+
+	var identities []age.Identity
+	if identityFile != "" {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open backup identity file: %w", err)
+		}
+		defer f.Close()
+		if identities, err = age.ParseIdentities(f); err != nil {
+			return nil, nil, fmt.Errorf("parse backup identity file: %w", err)
+		}
+	}
+	return recipients, identities, nil
+}
+
+// loadSeed returns the seed data from the fixture file if one is given,
+// or from the named built-in profile otherwise.
+func loadSeed(file, profile string, count int) (*fixtures.Set, error) {
+	if file != "" {
+		return fixtures.Load(file)
+	}
+	return fixtures.Profile(profile, count)
+}
+
+// defaultSeedProfile returns the seed profile used unless -seed-profile is
+// given: the demo todos for the memory store, which starts empty on every
+// run, and none for the persistent stores, which hold the users' own todos.
+func defaultSeedProfile(backend string) string {
+	if backend == todo.MemoryBackend {
+		return "demo"
+	}
+	return "empty"
+}
+
+// seedIfNew returns a seeder that applies the seed data only to a store that
+// has never held a todo, so persistent backends are neither re-seeded on
+// every restart nor after their users deleted every todo.
+func seedIfNew(seed *fixtures.Set) func(todo.Service) {
+	return func(service todo.Service) {
+		if len(seed.Todos) == 0 {
+			return
+		}
+		seeded, err := seed.Apply(context.Background(), service)
+		if err != nil {
+			log.Printf("seed: %v", err)
+			return
+		}
+		if seeded {
+			log.Printf("seed: added %d todos", len(seed.Todos))
+		}
+	}
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+)
+
+// runPITR parses the pitr command's flags from args and restores the
+// write-ahead log store to a point in time, or with -dry-run only reports
+// what the restore would do. The restore fails while a server has the same
+// log open. It returns the process exit code: 1 if the restore failed, 2 on
+// invalid usage.
+func runPITR(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("pitr", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dir := fs.String("store-dsn", todo.DefaultWALDir, "log directory of the wal store")
+	to := fs.String("to", "", "RFC 3339 time to restore the store to, e.g. 2024-03-05T12:00:00Z (required)")
+	dryRun := fs.Bool("dry-run", false, "report what the restore would lose and recover without changing the store")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	at, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		fmt.Fprintln(out, "pitr: -to must be an RFC 3339 time")
+		fs.Usage()
+		return 2
+	}
+
+	var report todo.PointInTimeReport
+	if *dryRun {
+		_, report, err = todo.PlanPointInTime(*dir, at)
+	} else {
+		report, err = restorePointInTime(*dir, at)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "✖ %v\n", err)
+		return 1
+	}
+
+	printPITRReport(out, *dir, report, *dryRun)
+	return 0
+}
+
+// restorePointInTime opens the log in dir, restores it to at and closes it.
+func restorePointInTime(dir string, at time.Time) (todo.PointInTimeReport, error) {
+	store, err := todo.RecoverFrom(dir, nil)
+	if err != nil {
+		return todo.PointInTimeReport{}, err
+	}
+	report, err := store.RestorePointInTime(context.Background(), at)
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	return report, err
+}
+
+// printPITRReport writes report in a human-readable form.
+func printPITRReport(out io.Writer, dir string, report todo.PointInTimeReport, dryRun bool) {
+	verb := "Restored"
+	if dryRun {
+		verb = "Dry run: would restore"
+	}
+	fmt.Fprintf(out, "%s %s to %s\n", verb, dir, report.At.Format(time.RFC3339))
+	if report.Base.IsZero() {
+		fmt.Fprintf(out, "  rebuilt from the empty store and %d log records\n", report.Replayed)
+	} else {
+		fmt.Fprintf(out, "  rebuilt from the snapshot of %s and %d log records\n", report.Base.Format(time.RFC3339), report.Replayed)
+	}
+	fmt.Fprintf(out, "  lost: %d log records written later\n", report.Discarded)
+	fmt.Fprintf(out, "    removed (created later): %s\n", idList(report.Removed))
+	fmt.Fprintf(out, "    reverted (changed later): %s\n", idList(report.Reverted))
+	fmt.Fprintf(out, "  recovered (deleted or merged later): %s\n", idList(report.Recovered))
+}
+
+// idList formats todo IDs for printPITRReport.
+func idList(ids []int) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	formatted := make([]string, len(ids))
+	for i, id := range ids {
+		formatted[i] = fmt.Sprint(id)
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/efrem/windsurf/internal/telemetry"
+	grpclib "google.golang.org/grpc"
+)
+
+// shutdownReport summarizes a shutdown, so a deploy that crashed or was
+// killed can be checked for lost requests or unsynced data.
+type shutdownReport struct {
+	Reason      string           `json:"reason"`
+	At          time.Time        `json:"at"`
+	Duration    string           `json:"duration"`
+	Connections connectionReport `json:"connections"`
+	GRPC        string           `json:"grpc"`
+	Telemetry   string           `json:"telemetry"`
+	Store       string           `json:"store"`
+	// Clean is false if any connection had to be cut or any step failed.
+	Clean bool `json:"clean"`
+}
+
+// connectionReport counts the HTTP connections open when shutdown began.
+type connectionReport struct {
+	Open    int `json:"open"`
+	Drained int `json:"drained"`
+	Closed  int `json:"closed"`
+	// WebSockets are hijacked connections the HTTP server cannot drain;
+	// they are cut when the process exits.
+	WebSockets int `json:"websockets"`
+}
+
+// connTracker follows the state of the connections of HTTP servers.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]http.ConnState)}
+}
+
+// track is the ConnState hook of the tracked servers.
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state == http.StateClosed {
+		delete(t.conns, conn)
+		return
+	}
+	t.conns[conn] = state
+}
+
+// count returns the number of open connections, and how many of them are
+// hijacked.
+func (t *connTracker) count() (open, hijacked int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, state := range t.conns {
+		if state == http.StateHijacked {
+			hijacked++
+		} else {
+			open++
+		}
+	}
+	return open, hijacked
+}
+
+// shutdown stops the servers within timeout, flushes the telemetry reporter
+// and syncs the store, and returns the report. Connections still busy when
+// the timeout expires are closed.
+func shutdown(reason string, timeout time.Duration, servers []*http.Server, tracker *connTracker, grpcServer *grpclib.Server, reporter *telemetry.Reporter, store any) shutdownReport {
+	start := time.Now()
+	report := shutdownReport{Reason: reason, At: start.UTC(), Clean: true}
+	report.Connections.Open, report.Connections.WebSockets = tracker.count()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close()
+			}
+		}()
+	}
+	report.GRPC = "disabled"
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.GRPC = stopGRPC(ctx, grpcServer)
+		}()
+	}
+	wg.Wait()
+
+	remaining, _ := tracker.count()
+	report.Connections.Closed = remaining
+	report.Connections.Drained = max(report.Connections.Open-remaining, 0)
+
+	report.Telemetry = "disabled"
+	if reporter != nil {
+		report.Telemetry = "flushed"
+		if err := reporter.Send(ctx); err != nil {
+			report.Telemetry = err.Error()
+			report.Clean = false
+		}
+	}
+
+	report.Store = syncStore(store)
+	report.Clean = report.Clean && remaining == 0 && report.Connections.WebSockets == 0 &&
+		report.GRPC != "forced" && (report.Store == "synced" || report.Store == "nothing to sync")
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report
+}
+
+// stopGRPC stops s gracefully, or forcibly once ctx is done. It reports
+// which of the two happened.
+func stopGRPC(ctx context.Context, s *grpclib.Server) string {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return "drained"
+	case <-ctx.Done():
+		s.Stop()
+		return "forced"
+	}
+}
+
+// syncStore closes store if it holds resources, which writes buffered
+// changes of file-based stores, and reports the outcome.
+func syncStore(store any) string {
+	switch s := store.(type) {
+	case interface{ Close() error }:
+		if err := s.Close(); err != nil {
+			return fmt.Sprintf("sync failed: %v", err)
+		}
+		return "synced"
+	case interface{ Close() }:
+		s.Close()
+		return "synced"
+	}
+	return "nothing to sync"
+}
+
+// log writes the report as a summary to the server log.
+func (r shutdownReport) log() {
+	log.Printf("shutdown (%s) finished in %s; clean: %t", r.Reason, r.Duration, r.Clean)
+	log.Printf("  connections: %d open, %d drained, %d closed, %d websockets cut",
+		r.Connections.Open, r.Connections.Drained, r.Connections.Closed, r.Connections.WebSockets)
+	log.Printf("  grpc: %s; telemetry: %s; store: %s", r.GRPC, r.Telemetry, r.Store)
+}
+
+// write writes the report as JSON to path.
+func (r shutdownReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// serve runs srv on lis and reports an unexpected stop on errs.
+func serve(srv *http.Server, lis net.Listener, errs chan<- error) {
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs <- err
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+)
+
+// smokeTitle is the title prefix of the todo the smoke test creates, so it
+// can be recognised if a failed run leaves it behind.
+const smokeTitle = "smoke test"
+
+// smoke runs a scripted create, list, update, complete and delete cycle
+// against a live deployment. Every request after the first follows a link
+// of a previous response, so the check covers the hypermedia controls and
+// not only the routes.
+type smoke struct {
+	client  *http.Client
+	out     io.Writer
+	created *todo.Todo
+}
+
+// runSmoke parses the smoke command's flags from args and runs the smoke
+// test. It returns the process exit code: 1 if any step failed, 2 on
+// invalid usage.
+func runSmoke(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("smoke", flag.ContinueOnError)
+	fs.SetOutput(out)
+	against := fs.String("against", "", "base URL of the deployment to test, e.g. https://todos.example.com (required)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *against == "" {
+		fmt.Fprintln(out, "smoke: -against is required")
+		fs.Usage()
+		return 2
+	}
+
+	s := &smoke{client: &http.Client{Timeout: *timeout}, out: out}
+	if err := s.run(strings.TrimSuffix(*against, "/")); err != nil {
+		fmt.Fprintf(out, "✖ %v\n", err)
+		s.cleanup()
+		fmt.Fprintln(out, "\nSmoke test failed.")
+		return 1
+	}
+	fmt.Fprintln(out, "\nSmoke test passed.")
+	return 0
+}
+
+// run performs the steps of the smoke test against baseURL.
+func (s *smoke) run(baseURL string) error {
+	var root todo.APIRoot
+	if err := s.step("root", http.MethodGet, baseURL, nil, http.StatusOK, &root); err != nil {
+		return err
+	}
+	if root.Links.Todos == nil {
+		return errors.New("root: no todos link")
+	}
+
+	var before todo.TodoCollection
+	if err := s.step("list", http.MethodGet, root.Links.Todos.Href, nil, http.StatusOK, &before); err != nil {
+		return err
+	}
+	if before.Links.Create == nil {
+		return errors.New("list: no create link")
+	}
+
+	title := fmt.Sprintf("%s %s", smokeTitle, time.Now().UTC().Format(time.RFC3339))
+	var created todo.Todo
+	input := todo.TodoInput{Title: title, Description: "Created by server smoke; safe to delete."}
+	if err := s.step("create", before.Links.Create.Method, before.Links.Create.Href, input, http.StatusCreated, &created); err != nil {
+		return err
+	}
+	s.created = &created
+	if created.Title != title || created.Links.Self == nil {
+		return fmt.Errorf("create: unexpected todo %+v", created)
+	}
+
+	var after todo.TodoCollection
+	if err := s.step("list", http.MethodGet, root.Links.Todos.Href, nil, http.StatusOK, &after); err != nil {
+		return err
+	}
+	if after.Meta.Total != before.Meta.Total+1 {
+		return fmt.Errorf("list: expected %d todos after create, got %d", before.Meta.Total+1, after.Meta.Total)
+	}
+
+	var fetched todo.Todo
+	if err := s.step("get", http.MethodGet, created.Links.Self.Href, nil, http.StatusOK, &fetched); err != nil {
+		return err
+	}
+	if fetched.ID != created.ID || fetched.Title != title {
+		return fmt.Errorf("get: expected todo %d %q, got %d %q", created.ID, title, fetched.ID, fetched.Title)
+	}
+
+	if created.Links.Update == nil {
+		return errors.New("get: no update link")
+	}
+	var updated todo.Todo
+	input.Title = title + " (updated)"
+	if err := s.step("update", created.Links.Update.Method, created.Links.Update.Href, input, http.StatusOK, &updated); err != nil {
+		return err
+	}
+	if updated.Title != input.Title {
+		return fmt.Errorf("update: expected title %q, got %q", input.Title, updated.Title)
+	}
+
+	if updated.Links.Complete == nil {
+		return errors.New("update: no complete link on an open todo")
+	}
+	var completed todo.Todo
+	if err := s.step("complete", updated.Links.Complete.Method, updated.Links.Complete.Href, nil, http.StatusOK, &completed); err != nil {
+		return err
+	}
+	if !completed.Completed {
+		return errors.New("complete: todo is not completed")
+	}
+	if completed.Links.Complete != nil {
+		return errors.New("complete: completed todo still has a complete link")
+	}
+
+	if err := s.delete(completed); err != nil {
+		return err
+	}
+	s.created = nil
+	return s.step("gone", http.MethodGet, completed.Links.Self.Href, nil, http.StatusNotFound, nil)
+}
+
+// delete deletes t through its delete link.
+func (s *smoke) delete(t todo.Todo) error {
+	if t.Links.Delete == nil {
+		return errors.New("delete: no delete link")
+	}
+	return s.step("delete", t.Links.Delete.Method, t.Links.Delete.Href, nil, http.StatusNoContent, nil)
+}
+
+// cleanup deletes the todo created by a failed run, if any.
+func (s *smoke) cleanup() {
+	if s.created == nil {
+		return
+	}
+	if err := s.delete(*s.created); err != nil {
+		fmt.Fprintf(s.out, "⚠ could not delete todo %d (%q): %v\n", s.created.ID, s.created.Title, err)
+	}
+}
+
+// step sends a request with the JSON encoding of body, if any, checks that
+// the response has the wanted status and decodes it into result, if any.
+func (s *smoke) step(name, method, url string, body any, want int, result any) error {
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	req.Header.Set("Accept", todo.MediaTypeJSON)
+	if body != nil {
+		req.Header.Set("Content-Type", todo.MediaTypeJSON)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: read response: %w", name, err)
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s: %s %s returned %s, expected %d: %s", name, method, url, resp.Status, want, bytes.TrimSpace(data))
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("%s: decode response: %w", name, err)
+		}
+	}
+
+	fmt.Fprintf(s.out, "✔ %-8s %s %s → %d in %s\n", name, method, url, resp.StatusCode, elapsed)
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package events defines the domain events emitted when todos change and the
+// Publisher and Subscriber interfaces that connect their producers and
+// consumers. Features that react to changes subscribe here instead of each
+// observing the store on its own.
+//
+// The package has no dependencies on the todo package, so events carry
+// their own copy of the affected todo.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type names an event kind. Values are stable and safe to persist or send
+// to external systems.
+type Type string
+
+const (
+	TypeTodoCreated    Type = "todo.created"
+	TypeTodoUpdated    Type = "todo.updated"
+	TypeTodoCompleted  Type = "todo.completed"
+	TypeTodoReopened   Type = "todo.reopened"
+	TypeTodoArchived   Type = "todo.archived"
+	TypeTodoUnarchived Type = "todo.unarchived"
+	TypeTodoDeleted    Type = "todo.deleted"
+	TypeTodoMerged     Type = "todo.merged"
+	TypeTodosRestored  Type = "todos.restored"
+)
+
+// Event is implemented by every domain event.
+type Event interface {
+	// Type returns the kind of the event.
+	Type() Type
+	// Time returns when the change happened.
+	Time() time.Time
+}
+
+// Todo is the state of a todo carried by an event.
+type Todo struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed"`
+	Archived    bool      `json:"archived,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+	Source      string    `json:"source,omitempty"`
+	ExternalID  string    `json:"external_id,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	ParentID    int       `json:"parent_id,omitempty"`
+}
+
+// TodoCreated is published after a todo has been created.
+type TodoCreated struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoUpdated is published after the title or description of a todo changed.
+type TodoUpdated struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoCompleted is published after a todo has been marked as completed.
+type TodoCompleted struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoReopened is published after a completed todo has been reopened.
+type TodoReopened struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoArchived is published after a completed todo has been archived.
+type TodoArchived struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoUnarchived is published after an archived todo has been restored to
+// completed.
+type TodoUnarchived struct {
+	At   time.Time `json:"at"`
+	Todo Todo      `json:"todo"`
+}
+
+// TodoDeleted is published after a todo has been deleted.
+type TodoDeleted struct {
+	At time.Time `json:"at"`
+	ID int       `json:"id"`
+}
+
+// TodoMerged is published after the todo SourceID was folded into Target.
+type TodoMerged struct {
+	At       time.Time `json:"at"`
+	Target   Todo      `json:"target"`
+	SourceID int       `json:"source_id"`
+}
+
+// TodosRestored is published after the whole store was replaced by a
+// backup. Consumers that keep derived state should rebuild it.
+type TodosRestored struct {
+	At    time.Time `json:"at"`
+	Count int       `json:"count"`
+}
+
+func (e TodoCreated) Type() Type    { return TypeTodoCreated }
+func (e TodoUpdated) Type() Type    { return TypeTodoUpdated }
+func (e TodoCompleted) Type() Type  { return TypeTodoCompleted }
+func (e TodoReopened) Type() Type   { return TypeTodoReopened }
+func (e TodoArchived) Type() Type   { return TypeTodoArchived }
+func (e TodoUnarchived) Type() Type { return TypeTodoUnarchived }
+func (e TodoDeleted) Type() Type    { return TypeTodoDeleted }
+func (e TodoMerged) Type() Type     { return TypeTodoMerged }
+func (e TodosRestored) Type() Type  { return TypeTodosRestored }
+
+func (e TodoCreated) Time() time.Time    { return e.At }
+func (e TodoUpdated) Time() time.Time    { return e.At }
+func (e TodoCompleted) Time() time.Time  { return e.At }
+func (e TodoReopened) Time() time.Time   { return e.At }
+func (e TodoArchived) Time() time.Time   { return e.At }
+func (e TodoUnarchived) Time() time.Time { return e.At }
+func (e TodoDeleted) Time() time.Time    { return e.At }
+func (e TodoMerged) Time() time.Time     { return e.At }
+func (e TodosRestored) Time() time.Time  { return e.At }
+
+// Publisher accepts events from producers.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Handler consumes an event.
+type Handler func(e Event)
+
+// Subscriber lets consumers register for events.
+type Subscriber interface {
+	// Subscribe registers h for every event published afterwards and
+	// returns a function that removes the subscription.
+	Subscribe(h Handler) (unsubscribe func())
+}
+
+// Bus is an in-process Publisher and Subscriber. Publish calls every handler
+// synchronously, in subscription order, before it returns, and events are
+// delivered one at a time, so handlers see them in publish order. Handlers
+// may subscribe and unsubscribe, but must not block or publish themselves;
+// consumers with slow work should hand events off to their own goroutine.
+//
+// The zero value is ready to use.
+type Bus struct {
+	publishMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   int
+	handlers []subscription
+}
+
+type subscription struct {
+	id int
+	h  Handler
+}
+
+var (
+	_ Publisher  = (*Bus)(nil)
+	_ Subscriber = (*Bus)(nil)
+)
+
+// NewBus returns an empty Bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Publish delivers e to all current subscribers.
+func (b *Bus) Publish(e Event) {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	b.mu.Lock()
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, s := range handlers {
+		s.h(e)
+	}
+}
+
+// Subscribe registers h and returns a function that removes it. Calling the
+// returned function more than once has no further effect.
+func (b *Bus) Subscribe(h Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.handlers = append(b.handlers, subscription{id: id, h: h})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			for i, s := range b.handlers {
+				if s.id == id {
+					b.handlers = append(b.handlers[:i:i], b.handlers[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
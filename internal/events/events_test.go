@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusDeliversInOrder(t *testing.T) {
+	bus := NewBus()
+	at := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	var first, second []Type
+	bus.Subscribe(func(e Event) { first = append(first, e.Type()) })
+	unsubscribe := bus.Subscribe(func(e Event) { second = append(second, e.Type()) })
+
+	bus.Publish(TodoCreated{At: at, Todo: Todo{ID: 1, Title: "a"}})
+	bus.Publish(TodoCompleted{At: at, Todo: Todo{ID: 1, Title: "a", Completed: true}})
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(TodoDeleted{At: at, ID: 1})
+
+	if len(first) != 3 || first[0] != TypeTodoCreated || first[1] != TypeTodoCompleted || first[2] != TypeTodoDeleted {
+		t.Fatalf("unexpected events for the first subscriber: %v", first)
+	}
+	if len(second) != 2 {
+		t.Fatalf("expected no events after unsubscribing, got %v", second)
+	}
+}
+
+func TestBusHandlerMayUnsubscribe(t *testing.T) {
+	var bus Bus
+	calls := 0
+	var unsubscribe func()
+	unsubscribe = bus.Subscribe(func(e Event) {
+		calls++
+		unsubscribe()
+	})
+
+	bus.Publish(TodosRestored{Count: 2})
+	bus.Publish(TodosRestored{Count: 3})
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, got %d", calls)
+	}
+}
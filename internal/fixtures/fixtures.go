@@ -0,0 +1,108 @@
+// Package fixtures loads declarative seed data for the Todo API from YAML
+// or JSON files so demo and test environments are reproducible.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed demo.yaml
+var demoYAML []byte
+
+// Todo is a single todo entry in a fixture file.
+type Todo struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description" yaml:"description"`
+	Completed   bool   `json:"completed" yaml:"completed"`
+}
+
+// Set is the content of a fixture file.
+type Set struct {
+	Todos []Todo `json:"todos" yaml:"todos"`
+}
+
+// Load reads and validates a fixture file. The format is selected by the
+// file extension: .yaml and .yml are parsed as YAML, .json as JSON.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixtures: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	case ".json":
+		return ParseJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file extension %q", ext)
+	}
+}
+
+// ParseYAML parses and validates YAML fixture data. Unknown fields are rejected.
+func ParseYAML(data []byte) (*Set, error) {
+	var set Set
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("parse YAML fixtures: %w", err)
+	}
+	return &set, set.Validate()
+}
+
+// ParseJSON parses and validates JSON fixture data. Unknown fields are rejected.
+func ParseJSON(data []byte) (*Set, error) {
+	var set Set
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("parse JSON fixtures: %w", err)
+	}
+	return &set, set.Validate()
+}
+
+// Demo returns the built-in sample data.
+func Demo() *Set {
+	set, err := ParseYAML(demoYAML)
+	if err != nil {
+		panic(fmt.Sprintf("fixtures: invalid embedded demo data: %v", err))
+	}
+	return set
+}
+
+// Validate checks that every todo in the set can be created.
+func (s *Set) Validate() error {
+	for i, t := range s.Todos {
+		if t.Title == "" {
+			return fmt.Errorf("todos[%d]: title is required", i)
+		}
+	}
+	return nil
+}
+
+// Apply seeds the store behind the given service with the todos of the set,
+// numbered from 1, if the store has never handed out a todo ID, and reports
+// whether it did. Seeding is atomic, so a store is seeded at most once even
+// by servers starting together.
+func (s *Set) Apply(ctx context.Context, service todo.Service) (bool, error) {
+	todos := make([]todo.BackupTodo, 0, len(s.Todos))
+	for i, t := range s.Todos {
+		todos = append(todos, todo.BackupTodo{
+			ID:          i + 1,
+			Title:       t.Title,
+			Description: t.Description,
+			Completed:   t.Completed,
+		})
+	}
+	return service.SeedTodos(ctx, todos)
+}
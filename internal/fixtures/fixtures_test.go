@@ -0,0 +1,115 @@
+package fixtures
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efrem/windsurf/internal/todo"
+)
+
+func TestLoadYAMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "todos.yaml")
+	yamlData := "todos:\n  - title: From YAML\n    completed: true\n"
+	if err := os.WriteFile(yamlPath, []byte(yamlData), 0o600); err != nil {
+		t.Fatalf("failed to write YAML fixture: %v", err)
+	}
+
+	jsonPath := filepath.Join(dir, "todos.json")
+	jsonData := `{"todos":[{"title":"From JSON","description":"desc"}]}`
+	if err := os.WriteFile(jsonPath, []byte(jsonData), 0o600); err != nil {
+		t.Fatalf("failed to write JSON fixture: %v", err)
+	}
+
+	set, err := Load(yamlPath)
+	if err != nil {
+		t.Fatalf("expected YAML fixture to load, got %v", err)
+	}
+	if len(set.Todos) != 1 || set.Todos[0].Title != "From YAML" || !set.Todos[0].Completed {
+		t.Fatalf("unexpected YAML fixture content: %+v", set)
+	}
+
+	set, err = Load(jsonPath)
+	if err != nil {
+		t.Fatalf("expected JSON fixture to load, got %v", err)
+	}
+	if len(set.Todos) != 1 || set.Todos[0].Description != "desc" {
+		t.Fatalf("unexpected JSON fixture content: %+v", set)
+	}
+
+	if _, err := Load(filepath.Join(dir, "todos.txt")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestParseRejectsInvalidFixtures(t *testing.T) {
+	if _, err := ParseYAML([]byte("users:\n  - name: alice\n")); err == nil {
+		t.Fatalf("expected unknown YAML section to be rejected")
+	}
+	if _, err := ParseJSON([]byte(`{"todos":[{"title":"x","priority":1}]}`)); err == nil {
+		t.Fatalf("expected unknown JSON field to be rejected")
+	}
+	if _, err := ParseYAML([]byte("todos:\n  - description: no title\n")); err == nil {
+		t.Fatalf("expected todo without title to be rejected")
+	}
+}
+
+func TestApplySeedsService(t *testing.T) {
+	service := todo.NewService(todo.NewTodoStore())
+	set := &Set{Todos: []Todo{
+		{Title: "Open"},
+		{Title: "Done", Completed: true},
+	}}
+
+	if seeded, err := set.Apply(t.Context(), service); err != nil || !seeded {
+		t.Fatalf("expected a new store to be seeded, got %v, %v", seeded, err)
+	}
+
+	todos, err := service.ListTodos(t.Context())
+	if err != nil || len(todos) != 2 {
+		t.Fatalf("expected 2 seeded todos, got %d", len(todos))
+	}
+	if todos[0].Completed || !todos[1].Completed {
+		t.Fatalf("expected only the second todo to be completed, got %+v %+v", todos[0], todos[1])
+	}
+	service.DeleteTodo(t.Context(), todos[0].ID)
+	service.DeleteTodo(t.Context(), todos[1].ID)
+	if seeded, err := set.Apply(t.Context(), service); err != nil || seeded {
+		t.Fatalf("expected a store emptied by its users not to be seeded again, got %v, %v", seeded, err)
+	}
+
+	if len(Demo().Todos) != 3 {
+		t.Fatalf("expected built-in demo data to contain 3 todos")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	empty, err := Profile("empty", 0)
+	if err != nil || len(empty.Todos) != 0 {
+		t.Fatalf("expected empty profile without todos, got %+v, %v", empty, err)
+	}
+
+	demo, err := Profile("demo", 0)
+	if err != nil || len(demo.Todos) != len(Demo().Todos) {
+		t.Fatalf("expected demo profile to match demo data, got %+v, %v", demo, err)
+	}
+
+	load, err := Profile("load-test", 25)
+	if err != nil || len(load.Todos) != 25 {
+		t.Fatalf("expected 25 generated todos, got %+v, %v", load, err)
+	}
+	if err := load.Validate(); err != nil {
+		t.Fatalf("expected generated todos to be valid, got %v", err)
+	}
+
+	defaults, err := Profile("load-test", 0)
+	if err != nil || len(defaults.Todos) != DefaultLoadTestCount {
+		t.Fatalf("expected %d generated todos by default, got %d, %v", DefaultLoadTestCount, len(defaults.Todos), err)
+	}
+
+	if _, err := Profile("nope", 0); err == nil {
+		t.Fatalf("expected unknown profile to be rejected")
+	}
+}
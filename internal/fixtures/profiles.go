@@ -0,0 +1,56 @@
+package fixtures
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultLoadTestCount is the number of todos generated by the load-test
+// profile when no count is given.
+const DefaultLoadTestCount = 5000
+
+// profiles maps profile names to the functions building their seed data.
+// The count argument is only used by generated profiles.
+var profiles = map[string]func(count int) *Set{
+	"empty":     func(int) *Set { return &Set{} },
+	"demo":      func(int) *Set { return Demo() },
+	"load-test": Generate,
+}
+
+// Profiles returns the names of the available seed profiles in sorted order.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the seed data of the named profile. For generated profiles
+// count selects how many todos are produced; values <= 0 use
+// DefaultLoadTestCount.
+func Profile(name string, count int) (*Set, error) {
+	build, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown seed profile %q (available: %v)", name, Profiles())
+	}
+	if count <= 0 {
+		count = DefaultLoadTestCount
+	}
+	return build(count), nil
+}
+
+// Generate deterministically produces count todos for load testing.
+// Every third todo is marked as completed.
+func Generate(count int) *Set {
+	set := &Set{Todos: make([]Todo, 0, count)}
+	for i := 1; i <= count; i++ {
+		set.Todos = append(set.Todos, Todo{
+			Title:       fmt.Sprintf("Generated todo #%d", i),
+			Description: fmt.Sprintf("Load-test item %d of %d", i, count),
+			Completed:   i%3 == 0,
+		})
+	}
+	return set
+}
@@ -0,0 +1,163 @@
+// Package migrate applies versioned SQL schema migrations that store
+// backends embed in the binary, so schema changes ship with the server
+// instead of requiring external tooling.
+//
+// Migrations are files named NNNN_description.sql. Applied versions are
+// recorded in the schema_migrations table; each migration runs in its own
+// transaction together with that record.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect selects the SQL flavor of the target database.
+type Dialect int
+
+const (
+	// SQLite uses ? placeholders and relies on SQLite's single writer.
+	SQLite Dialect = iota
+	// Postgres uses $n placeholders and an advisory lock so concurrent
+	// replicas do not apply the same migration twice.
+	Postgres
+)
+
+// postgresLockID identifies the advisory lock held while migrating.
+const postgresLockID = 7_420_211
+
+const createVersionTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT   NOT NULL,
+	applied_at TEXT   NOT NULL
+)`
+
+// Migration is a single schema change.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Load reads the migrations in dir of fsys, ordered by version. Files
+// without the .sql extension are ignored.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+
+		prefix, _, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version number and an underscore", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Apply runs the migrations not yet recorded in db, in version order, and
+// returns how many were applied.
+func Apply(ctx context.Context, db *sql.DB, dialect Dialect, migrations []Migration) (int, error) {
+	if _, err := db.ExecContext(ctx, createVersionTable); err != nil {
+		return 0, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ran, err := apply(ctx, db, dialect, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+		if ran {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// apply runs m unless it has already been recorded. The boolean reports
+// whether it ran.
+func apply(ctx context.Context, db *sql.DB, dialect Dialect, m Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if dialect == Postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresLockID); err != nil {
+			return false, fmt.Errorf("acquire migration lock: %w", err)
+		}
+	}
+
+	var exists int
+	err = tx.QueryRowContext(ctx, dialect.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), m.Version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check version: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx,
+		dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, fmt.Errorf("record version: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// rebind rewrites ? placeholders for the dialect.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
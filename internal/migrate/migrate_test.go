@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLoadOrdersAndValidates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.sql": {Data: []byte("CREATE INDEX idx ON items (name);")},
+		"m/0001_create.sql":    {Data: []byte("CREATE TABLE items (name TEXT);")},
+		"m/README.md":          {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys, "m")
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("expected migrations 1 and 2 in order, got %+v", migrations)
+	}
+
+	fsys["m/create.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := Load(fsys, "m"); err == nil {
+		t.Fatalf("expected an error for a migration without a version")
+	}
+	delete(fsys, "m/create.sql")
+
+	fsys["m/0001_again.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := Load(fsys, "m"); err == nil {
+		t.Fatalf("expected an error for duplicate versions")
+	}
+}
+
+func TestApplyRunsPendingMigrationsOnce(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	first := []Migration{{Version: 1, Name: "0001_create.sql", SQL: "CREATE TABLE items (name TEXT);"}}
+	if n, err := Apply(ctx, db, SQLite, first); err != nil || n != 1 {
+		t.Fatalf("expected 1 migration applied, got %d, %v", n, err)
+	}
+
+	second := append(first, Migration{Version: 2, Name: "0002_seed.sql", SQL: "INSERT INTO items (name) VALUES ('a');"})
+	if n, err := Apply(ctx, db, SQLite, second); err != nil || n != 1 {
+		t.Fatalf("expected only the new migration to run, got %d, %v", n, err)
+	}
+	if n, err := Apply(ctx, db, SQLite, second); err != nil || n != 0 {
+		t.Fatalf("expected no pending migrations, got %d, %v", n, err)
+	}
+
+	broken := append(second, Migration{Version: 3, Name: "0003_broken.sql", SQL: "INSERT INTO missing VALUES (1);"})
+	if _, err := Apply(ctx, db, SQLite, broken); err == nil {
+		t.Fatalf("expected a failing migration to return an error")
+	}
+
+	var versions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions); err != nil {
+		t.Fatalf("failed to count versions: %v", err)
+	}
+	if versions != 2 {
+		t.Fatalf("expected the failed migration not to be recorded, got %d versions", versions)
+	}
+}
+
+func TestPostgresRebind(t *testing.T) {
+	got := Postgres.rebind(`INSERT INTO t (a, b) VALUES (?, ?)`)
+	if got != `INSERT INTO t (a, b) VALUES ($1, $2)` {
+		t.Fatalf("unexpected rebound query %q", got)
+	}
+	if SQLite.rebind(`SELECT ?`) != `SELECT ?` {
+		t.Fatalf("expected SQLite queries to be unchanged")
+	}
+}
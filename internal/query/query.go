@@ -0,0 +1,148 @@
+// Package query binds URL query parameters to typed values, collecting every
+// invalid parameter so handlers can report them together in one response.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the accepted layouts for date parameters, tried in order.
+var dateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// FieldError describes a single invalid query parameter.
+type FieldError struct {
+	Param   string
+	Message string
+}
+
+// Errors aggregates all invalid query parameters of a request.
+type Errors []FieldError
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", fe.Param, fe.Message)
+	}
+	return "invalid query parameters: " + strings.Join(msgs, "; ")
+}
+
+// Binder reads typed values from url.Values and records every failure.
+// Absent parameters fall back to their defaults and are never errors.
+type Binder struct {
+	values url.Values
+	errs   Errors
+}
+
+// New returns a Binder reading from the given query values.
+func New(values url.Values) *Binder {
+	return &Binder{values: values}
+}
+
+// Err returns the aggregated errors as an Errors value, or nil if every
+// parameter was valid.
+func (b *Binder) Err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return b.errs
+}
+
+func (b *Binder) fail(param, format string, args ...any) {
+	b.errs = append(b.errs, FieldError{Param: param, Message: fmt.Sprintf(format, args...)})
+}
+
+// String returns the named parameter, or def if it is absent.
+func (b *Binder) String(name, def string) string {
+	if v := b.values.Get(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns the named parameter as an integer within [min, max], or def if
+// it is absent.
+func (b *Binder) Int(name string, def, min, max int) int {
+	raw := b.values.Get(name)
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		b.fail(name, "must be an integer")
+		return def
+	}
+	if v < min || v > max {
+		b.fail(name, "must be between %d and %d", min, max)
+		return def
+	}
+	return v
+}
+
+// Bool returns the named parameter as a boolean, or def if it is absent.
+func (b *Binder) Bool(name string, def bool) bool {
+	raw := b.values.Get(name)
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		b.fail(name, "must be true or false")
+		return def
+	}
+	return v
+}
+
+// Enum returns the named parameter if it is one of allowed, or def if it is
+// absent.
+func (b *Binder) Enum(name, def string, allowed ...string) string {
+	raw := b.values.Get(name)
+	if raw == "" {
+		return def
+	}
+
+	for _, a := range allowed {
+		if raw == a {
+			return raw
+		}
+	}
+	b.fail(name, "must be one of %s", strings.Join(allowed, ", "))
+	return def
+}
+
+// Date returns the named parameter parsed as an RFC 3339 timestamp or a
+// YYYY-MM-DD date. The boolean is false if the parameter is absent or invalid.
+func (b *Binder) Date(name string) (time.Time, bool) {
+	raw := b.values.Get(name)
+	if raw == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	b.fail(name, "must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	return time.Time{}, false
+}
+
+// List returns the named parameter split on commas, with whitespace trimmed
+// and empty entries dropped. Repeated parameters are concatenated.
+func (b *Binder) List(name string) []string {
+	var items []string
+	for _, raw := range b.values[name] {
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
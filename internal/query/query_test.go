@@ -0,0 +1,59 @@
+package query
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestBinderValidValues(t *testing.T) {
+	values, _ := url.ParseQuery("page=3&done=true&sort=title&due=2024-05-01&tag=a,b&tag=c")
+	b := New(values)
+
+	if got := b.Int("page", 1, 1, 100); got != 3 {
+		t.Fatalf("expected page 3, got %d", got)
+	}
+	if got := b.Int("per_page", 10, 1, 100); got != 10 {
+		t.Fatalf("expected default per_page 10, got %d", got)
+	}
+	if got := b.Bool("done", false); !got {
+		t.Fatalf("expected done to be true")
+	}
+	if got := b.Enum("sort", "id", "id", "title"); got != "title" {
+		t.Fatalf("expected sort title, got %q", got)
+	}
+	if due, ok := b.Date("due"); !ok || due.Day() != 1 {
+		t.Fatalf("expected due date to parse, got %v, %v", due, ok)
+	}
+	if got := b.List("tag"); len(got) != 3 || got[2] != "c" {
+		t.Fatalf("expected tags [a b c], got %v", got)
+	}
+	if got := b.String("q", "none"); got != "none" {
+		t.Fatalf("expected default string, got %q", got)
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+}
+
+func TestBinderAggregatesErrors(t *testing.T) {
+	values, _ := url.ParseQuery("page=abc&per_page=500&done=maybe&sort=random&due=tomorrow")
+	b := New(values)
+
+	b.Int("page", 1, 1, 1000)
+	b.Int("per_page", 10, 1, 100)
+	b.Bool("done", false)
+	b.Enum("sort", "id", "id", "title")
+	b.Date("due")
+
+	var errs Errors
+	if !errors.As(b.Err(), &errs) {
+		t.Fatalf("expected Errors, got %v", b.Err())
+	}
+	if len(errs) != 5 {
+		t.Fatalf("expected 5 aggregated errors, got %d: %v", len(errs), errs)
+	}
+	if errs[1].Param != "per_page" {
+		t.Fatalf("expected second error for per_page, got %+v", errs[1])
+	}
+}
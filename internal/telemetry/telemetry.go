@@ -0,0 +1,227 @@
+// Package telemetry implements the opt-in anonymous usage reporter. It only
+// ever sends aggregate counts and configuration facts (request counts per
+// method and per link relation followed, store backend, version) — never
+// todo content, paths, or addresses.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often reports are sent when no interval is configured.
+const DefaultInterval = 24 * time.Hour
+
+// Config configures a Reporter. Telemetry is disabled unless Endpoint is set.
+type Config struct {
+	// Endpoint is the URL reports are POSTed to. Empty disables telemetry.
+	Endpoint string
+	// Interval between reports; DefaultInterval if zero.
+	Interval time.Duration
+	// Version of the running server.
+	Version string
+	// Store is the name of the configured store backend.
+	Store string
+	// Client sends the reports; http.DefaultClient if nil.
+	Client *http.Client
+	// RelationHeader is the request header in which clients name the link
+	// relation they followed to make the request. Requests without it are
+	// not counted by relation.
+	RelationHeader string
+	// Relations lists the link relations counted. Other values of
+	// RelationHeader are counted as OtherRelation, so reports never carry
+	// arbitrary client input.
+	Relations []string
+}
+
+// OtherRelation counts followed link relations that are not in
+// Config.Relations.
+const OtherRelation = "other"
+
+// OtherMethod counts requests whose method is not a standard HTTP method.
+const OtherMethod = "OTHER"
+
+// methods are the request methods counted under their own name.
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Report is the payload sent to the telemetry endpoint.
+type Report struct {
+	Version   string         `json:"version"`
+	Store     string         `json:"store"`
+	GoVersion string         `json:"go_version"`
+	OS        string         `json:"os"`
+	Arch      string         `json:"arch"`
+	Requests  map[string]int `json:"requests"`
+	// Links counts requests by the link relation followed.
+	Links map[string]int `json:"links,omitempty"`
+}
+
+// Reporter counts requests and periodically sends them to the configured
+// endpoint. A nil *Reporter is valid and does nothing, which is what New
+// returns when telemetry is disabled.
+type Reporter struct {
+	cfg       Config
+	relations map[string]bool
+	mu        sync.Mutex
+	requests  map[string]int
+	links     map[string]int
+}
+
+// New returns a Reporter for cfg, or nil if telemetry is disabled.
+func New(cfg Config) *Reporter {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	relations := make(map[string]bool, len(cfg.Relations))
+	for _, rel := range cfg.Relations {
+		relations[rel] = true
+	}
+	return &Reporter{cfg: cfg, relations: relations, requests: make(map[string]int), links: make(map[string]int)}
+}
+
+// Middleware counts every request by HTTP method, or as OtherMethod if the
+// method is not a standard one, and by the link relation it followed if it
+// names one.
+func (r *Reporter) Middleware(next http.Handler) http.Handler {
+	if r == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rel := ""
+		if r.cfg.RelationHeader != "" {
+			rel = req.Header.Get(r.cfg.RelationHeader)
+		}
+		if rel != "" && !r.relations[rel] {
+			rel = OtherRelation
+		}
+		method := req.Method
+		if !methods[method] {
+			method = OtherMethod
+		}
+
+		r.mu.Lock()
+		r.requests[method]++
+		if rel != "" {
+			r.links[rel]++
+		}
+		r.mu.Unlock()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// snapshot returns the current report and resets the request counts.
+func (r *Reporter) snapshot() Report {
+	r.mu.Lock()
+	requests, links := r.requests, r.links
+	r.requests = make(map[string]int)
+	r.links = make(map[string]int)
+	r.mu.Unlock()
+
+	return Report{
+		Version:   r.cfg.Version,
+		Store:     r.cfg.Store,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Requests:  requests,
+		Links:     links,
+	}
+}
+
+// restore adds the counts of an unsent report back.
+func (r *Reporter) restore(report Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for method, n := range report.Requests {
+		r.requests[method] += n
+	}
+	for rel, n := range report.Links {
+		r.links[rel] += n
+	}
+}
+
+// Send posts the counts collected since the last successful report. On
+// failure the counts are kept for the next attempt.
+func (r *Reporter) Send(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	report := r.snapshot()
+	if err := r.post(ctx, report); err != nil {
+		r.restore(report)
+		return err
+	}
+	return nil
+}
+
+func (r *Reporter) post(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send telemetry report: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("send telemetry report: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Run sends a report every interval until ctx is cancelled. Failures are
+// logged and retried on the next tick.
+func (r *Reporter) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Send(ctx); err != nil {
+				log.Printf("telemetry: %v", err)
+			}
+		}
+	}
+}
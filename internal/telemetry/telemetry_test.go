@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDisabledByDefault(t *testing.T) {
+	reporter := New(Config{})
+	if reporter != nil {
+		t.Fatalf("expected telemetry to be disabled without an endpoint")
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	wrapped := reporter.Middleware(handler)
+	wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("expected disabled reporter to be a no-op, got %v", err)
+	}
+}
+
+func TestSendReportsCountsOnly(t *testing.T) {
+	var reports []Report
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+		reports = append(reports, report)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	reporter := New(Config{Endpoint: server.URL, Version: "1.2.3", Store: "sqlite"})
+	handler := reporter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodPost, "X-SECRET-1", "X-SECRET-2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/todos/1?secret=x", nil))
+	}
+
+	if err := reporter.Send(context.Background()); err == nil {
+		t.Fatalf("expected an error for a failing endpoint")
+	}
+
+	status = http.StatusNoContent
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("expected report to be sent, got %v", err)
+	}
+
+	last := reports[len(reports)-1]
+	if last.Version != "1.2.3" || last.Store != "sqlite" {
+		t.Fatalf("unexpected report metadata: %+v", last)
+	}
+	if last.Requests[http.MethodGet] != 2 || last.Requests[http.MethodPost] != 1 {
+		t.Fatalf("expected counts from the failed report to be retried, got %v", last.Requests)
+	}
+	if len(last.Requests) != 3 || last.Requests[OtherMethod] != 2 {
+		t.Fatalf("expected non-standard methods to be counted as %s, got %v", OtherMethod, last.Requests)
+	}
+
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("expected empty report to be sent, got %v", err)
+	}
+	if n := len(reports[len(reports)-1].Requests); n != 0 {
+		t.Fatalf("expected counts to reset after a successful report, got %d methods", n)
+	}
+}
+
+func TestSendReportsFollowedRelations(t *testing.T) {
+	var report Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	reporter := New(Config{Endpoint: server.URL, RelationHeader: "X-Link-Rel", Relations: []string{"next", "complete"}})
+	handler := reporter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, rel := range []string{"next", "next", "complete", "my secret todo", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		if rel != "" {
+			req.Header.Set("X-Link-Rel", rel)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("expected report to be sent, got %v", err)
+	}
+	want := map[string]int{"next": 2, "complete": 1, OtherRelation: 1}
+	if len(report.Links) != len(want) {
+		t.Fatalf("expected relation counts %v, got %v", want, report.Links)
+	}
+	for rel, n := range want {
+		if report.Links[rel] != n {
+			t.Fatalf("expected relation counts %v, got %v", want, report.Links)
+		}
+	}
+	if report.Requests[http.MethodGet] != 5 {
+		t.Fatalf("expected every request to be counted by method, got %v", report.Requests)
+	}
+}
@@ -0,0 +1,43 @@
+package todo
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// adminRoutes returns the routes of the /admin endpoints, which require
+// token as a bearer token.
+func (api *TodoAPI) adminRoutes(token string) func(chi.Router) {
+	return func(r chi.Router) {
+		r.Use(api.requireAdminToken(token))
+
+		r.Get("/backup", api.GetBackup)
+		r.Post("/restore", api.RestoreBackup)
+		r.Post("/compact", api.CreateCompactJob)
+		r.Get("/compact/{jobID}", api.GetCompactJob)
+	}
+}
+
+// adminRouter returns the router of the separate admin listener: the /admin
+// endpoints and the runtime profiler under /debug/pprof/, both behind the
+// admin token. It has no CORS headers, as browsers have no business there.
+func (api *TodoAPI) adminRouter(token string) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(api.allowMethods)
+	r.NotFound(api.NotFound)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(api.negotiate)
+		api.adminRoutes(token)(r)
+	})
+	r.Route("/debug", func(r chi.Router) {
+		r.Use(api.requireAdminToken(token))
+		r.Mount("/", middleware.Profiler())
+	})
+	return r
+}
@@ -0,0 +1,441 @@
+package todo
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backup is a full dump of a store: every todo, the merge aliases, the
+// next ID to allocate and the milestones. It is the format of GET
+// /admin/backup and of the json store file.
+type Backup struct {
+	NextID     int               `json:"next_id"`
+	Todos      []BackupTodo      `json:"todos"`
+	Merged     map[int]int       `json:"merged,omitempty"`
+	Milestones []BackupMilestone `json:"milestones,omitempty"`
+}
+
+// BackupTodo is a todo in a Backup.
+type BackupTodo struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+	Archived    bool   `json:"archived,omitempty"`
+	// Version is 0 in backups taken before todos were versioned, and
+	// restored as 1.
+	Version   int       `json:"version,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is zero in backups taken before updates were tracked, and
+	// restored as CreatedAt.
+	UpdatedAt  time.Time `json:"updated_at,omitzero"`
+	Source     string    `json:"source,omitempty"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	ParentID   int       `json:"parent_id,omitempty"`
+}
+
+// BackupMilestone is a milestone in a Backup with the IDs of its todos,
+// which may include todos deleted since they were assigned.
+type BackupMilestone struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	TodoIDs   []int  `json:"todo_ids,omitempty"`
+}
+
+// BackupStore is implemented by stores that can be dumped and atomically
+// replaced, which the admin backup and restore endpoints require.
+type BackupStore interface {
+	Store
+	// Backup returns a consistent dump of the store.
+	Backup(ctx context.Context) (Backup, error)
+	// Restore replaces the whole content of the store with b. Readers
+	// observe either the old or the new content. IDs handed out before the
+	// restore stay allocated, even if b predates them.
+	Restore(ctx context.Context, b Backup) error
+}
+
+var _ BackupStore = (*TodoStore)(nil)
+
+// Validate checks that the backup can be restored: IDs are positive and
+// unique, titles are present, external IDs are unique per source, parents
+// are todos of the backup without cycles, merge aliases point from IDs
+// that are not live todos, and milestones are valid and share no todo.
+func (b Backup) Validate() error {
+	ids := make(map[int]bool, len(b.Todos))
+	external := make(map[externalKey]bool)
+	for i, t := range b.Todos {
+		if t.ID <= 0 {
+			return fmt.Errorf("todos[%d]: id must be positive", i)
+		}
+		if ids[t.ID] {
+			return fmt.Errorf("todos[%d]: duplicate id %d", i, t.ID)
+		}
+		if t.Title == "" {
+			return fmt.Errorf("todos[%d]: title is required", i)
+		}
+		if t.Archived && !t.Completed {
+			return fmt.Errorf("todos[%d]: archived todo must be completed", i)
+		}
+		if err := ValidateExternalID(t.Source, t.ExternalID); err != nil {
+			return fmt.Errorf("todos[%d]: %w", i, err)
+		}
+		if t.ExternalID != "" {
+			key := externalKey{Source: t.Source, ExternalID: t.ExternalID}
+			if external[key] {
+				return fmt.Errorf("todos[%d]: duplicate external_id %q for source %q", i, t.ExternalID, t.Source)
+			}
+			external[key] = true
+		}
+		ids[t.ID] = true
+	}
+	if err := b.validateParents(ids); err != nil {
+		return err
+	}
+	for id, survivor := range b.Merged {
+		if id <= 0 || survivor <= 0 || id == survivor {
+			return fmt.Errorf("merged[%d]: invalid alias to %d", id, survivor)
+		}
+		if ids[id] {
+			return fmt.Errorf("merged[%d]: id is also a live todo", id)
+		}
+	}
+	return b.validateMilestones()
+}
+
+// validateMilestones checks that milestone IDs are positive and unique,
+// that the milestones are valid input, and that no todo is in two of them.
+func (b Backup) validateMilestones() error {
+	ids := make(map[int]bool, len(b.Milestones))
+	assigned := make(map[int]bool)
+	for i, m := range b.Milestones {
+		if m.ID <= 0 {
+			return fmt.Errorf("milestones[%d]: id must be positive", i)
+		}
+		if ids[m.ID] {
+			return fmt.Errorf("milestones[%d]: duplicate id %d", i, m.ID)
+		}
+		ids[m.ID] = true
+		if err := validateMilestoneInput(MilestoneInput{Name: m.Name, StartDate: m.StartDate, EndDate: m.EndDate}); err != nil {
+			return fmt.Errorf("milestones[%d]: %w", i, err)
+		}
+		for _, todoID := range m.TodoIDs {
+			if todoID <= 0 {
+				return fmt.Errorf("milestones[%d]: todo id must be positive", i)
+			}
+			if assigned[todoID] {
+				return fmt.Errorf("milestones[%d]: todo %d is in another milestone", i, todoID)
+			}
+			assigned[todoID] = true
+		}
+	}
+	return nil
+}
+
+// validateParents checks that the parents of the todos are among ids and
+// that no todo is its own ancestor.
+func (b Backup) validateParents(ids map[int]bool) error {
+	parents := make(map[int]int, len(b.Todos))
+	for i, t := range b.Todos {
+		if t.ParentID != 0 && !ids[t.ParentID] {
+			return fmt.Errorf("todos[%d]: parent_id %d is not a todo of the backup", i, t.ParentID)
+		}
+		parents[t.ID] = t.ParentID
+	}
+	for i, t := range b.Todos {
+		// A chain longer than the number of todos must repeat one.
+		steps := 0
+		for id := t.ParentID; id != 0; id = parents[id] {
+			if id == t.ID || steps > len(b.Todos) {
+				return fmt.Errorf("todos[%d]: parent_id %d makes the todo its own subtask", i, t.ParentID)
+			}
+			steps++
+		}
+	}
+	return nil
+}
+
+// nextID returns the ID to allocate after restoring b: its next_id, but
+// never an ID already used by a todo or a merge alias.
+func (b Backup) nextID() int {
+	next := max(b.NextID, 1)
+	for _, t := range b.Todos {
+		next = max(next, t.ID+1)
+	}
+	for id := range b.Merged {
+		next = max(next, id+1)
+	}
+	return next
+}
+
+// Backup returns a dump of the store.
+func (s *TodoStore) Backup(ctx context.Context) (Backup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b := Backup{NextID: s.nextID, Todos: []BackupTodo{}, Merged: make(map[int]int, len(s.merged))}
+	for _, todo := range s.sortedTodos() {
+		b.Todos = append(b.Todos, BackupTodo{
+			ID:          todo.ID,
+			Title:       todo.Title,
+			Description: todo.Description,
+			Completed:   todo.Completed,
+			Archived:    todo.Archived,
+			Version:     todo.Version,
+			CreatedAt:   todo.CreatedAt,
+			UpdatedAt:   todo.UpdatedAt,
+			Source:      todo.Source,
+			ExternalID:  todo.ExternalID,
+			Tags:        todo.Tags,
+			ParentID:    todo.ParentID,
+		})
+	}
+	for id, survivor := range s.merged {
+		b.Merged[id] = survivor
+	}
+	for _, m := range s.sortedMilestones() {
+		b.Milestones = append(b.Milestones, BackupMilestone{
+			ID:        m.ID,
+			Name:      m.Name,
+			StartDate: m.StartDate,
+			EndDate:   m.EndDate,
+			TodoIDs:   m.TodoIDs,
+		})
+	}
+	return b, nil
+}
+
+// Restore replaces the content of the store with b. Snapshots taken before
+// the restore expire.
+func (s *TodoStore) Restore(ctx context.Context, b Backup) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.todos = make(map[int]*Todo)
+	s.merged = make(map[int]int)
+	s.external = make(map[externalKey]int)
+	s.createdSeq = make(map[int]int)
+	s.tombstones = nil
+	s.milestones = make(map[int]Milestone)
+	s.assigned = make(map[int]int)
+	s.seq++
+	s.horizon = s.seq
+	s.loadLocked(b)
+	return nil
+}
+
+// load adds the todos, merge aliases and milestones of b to the store.
+func (s *TodoStore) load(b Backup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadLocked(b)
+}
+
+// Seed adds todos if the store has never handed out an ID.
+func (s *TodoStore) Seed(ctx context.Context, todos []BackupTodo) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nextID != 1 {
+		return false, nil
+	}
+	s.loadLocked(Backup{Todos: todos})
+	return true, nil
+}
+
+// loadLocked is load with the write lock held.
+func (s *TodoStore) loadLocked(b Backup) {
+	for _, t := range b.Todos {
+		s.seq++
+		s.todos[t.ID] = &Todo{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Completed:   t.Completed,
+			Archived:    t.Archived,
+			Version:     max(t.Version, 1),
+			CreatedAt:   t.CreatedAt,
+			UpdatedAt:   t.UpdatedAt,
+			Source:      t.Source,
+			ExternalID:  t.ExternalID,
+			Tags:        t.Tags,
+			ParentID:    t.ParentID,
+		}
+		if t.UpdatedAt.IsZero() {
+			s.todos[t.ID].UpdatedAt = t.CreatedAt
+		}
+		s.createdSeq[t.ID] = s.seq
+		if key, ok := externalKeyOf(s.todos[t.ID]); ok {
+			s.external[key] = t.ID
+		}
+	}
+	for id, survivor := range b.Merged {
+		s.merged[id] = survivor
+	}
+	for _, m := range b.Milestones {
+		s.milestones[m.ID] = Milestone{ID: m.ID, Name: m.Name, StartDate: m.StartDate, EndDate: m.EndDate}
+		for _, todoID := range m.TodoIDs {
+			s.assigned[todoID] = m.ID
+		}
+		s.nextMilestoneID = max(s.nextMilestoneID, m.ID+1)
+	}
+	s.nextID = max(s.nextID, b.nextID())
+}
+
+// requireAdminToken is a middleware that only lets requests through that
+// carry the admin token as a bearer token.
+func (api *TodoAPI) requireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// CORS preflight requests carry no credentials.
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				api.sendError(w, r, http.StatusUnauthorized, "Unauthorized", "A valid admin token is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetBackup handles GET /admin/backup and streams a JSON dump of the store.
+// With backup encryption configured, the dump is sealed with its manifest
+// and encrypted to the configured age recipients instead.
+func (api *TodoAPI) GetBackup(w http.ResponseWriter, r *http.Request) {
+	backup, ok, err := api.service.BackupTodos(r.Context())
+	if !ok {
+		api.sendBackupUnsupported(w, r)
+		return
+	}
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+
+	now := api.clock.Now().UTC()
+	filename := fmt.Sprintf("todos-%s.json", now.Format("20060102T150405Z"))
+
+	if len(api.backupRecipients) > 0 {
+		sealed, err := sealBackup(backup, now, api.backupRecipients)
+		if err != nil {
+			log.Printf("todo: seal backup: %v", err)
+			api.sendError(w, r, http.StatusInternalServerError, "Backup failed", "The backup could not be encrypted")
+			return
+		}
+		sum := sha256.Sum256(sealed)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.age"`, filename))
+		w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		if _, err := w.Write(sealed); err != nil {
+			log.Printf("todo: send backup: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", MediaTypeJSON)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		// Headers are already sent, so the response can only be cut off,
+		// which tells the client the backup is incomplete.
+		log.Printf("todo: stream backup: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// sendBackupUnsupported writes the 501 response for stores without backup support.
+func (api *TodoAPI) sendBackupUnsupported(w http.ResponseWriter, r *http.Request) {
+	api.sendError(w, r, http.StatusNotImplemented, "Not implemented", "The configured store does not support backup and restore")
+}
+
+// RestoreResult is the response of POST /admin/restore.
+type RestoreResult struct {
+	Restored int   `json:"restored"`
+	Links    Links `json:"_links"`
+}
+
+// RestoreBackup handles POST /admin/restore and replaces the content of the
+// store with the uploaded dump. Encrypted dumps are decrypted and verified
+// against their manifest; with backup identities configured, plaintext
+// dumps are rejected. Plaintext dumps are sent as JSON, encrypted ones as
+// application/octet-stream.
+func (api *TodoAPI) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/octet-stream" && !jsonContentType(contentType) {
+		api.sendError(w, r, http.StatusUnsupportedMediaType, "Unsupported media type",
+			"Backups must be sent as "+MediaTypeJSON+", or as application/octet-stream if encrypted")
+		return
+	}
+
+	var backup Backup
+	body := bufio.NewReader(r.Body)
+	switch {
+	case isSealed(body):
+		if len(api.backupIdentities) == 0 {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid backup", "Encrypted backups cannot be restored: no backup identity is configured")
+			return
+		}
+		b, err := openSealedBackup(body, api.backupIdentities)
+		if err != nil {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid backup", err.Error())
+			return
+		}
+		backup = b
+	case len(api.backupIdentities) > 0:
+		api.sendError(w, r, http.StatusBadRequest, "Invalid backup", "Only encrypted backups can be restored")
+		return
+	default:
+		dec := json.NewDecoder(body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&backup); err != nil {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be a backup produced by GET /admin/backup")
+			return
+		}
+	}
+	if err := backup.Validate(); err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Validation error", err.Error())
+		return
+	}
+
+	supported, err := api.service.RestoreTodos(r.Context(), backup)
+	if !supported {
+		api.sendBackupUnsupported(w, r)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		api.sendDeadlineExceeded(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("todo: restore backup: %v", err)
+		api.sendError(w, r, http.StatusInternalServerError, "Restore failed", "The backup could not be restored")
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, RestoreResult{
+		Restored: len(backup.Todos),
+		Links:    buildErrorLinks(api.base(r)),
+	})
+}
@@ -0,0 +1,876 @@
+// Package boltstore implements todo.Store on top of a bbolt embedded
+// key-value file, a durable option that needs no external database or cgo.
+// Importing the package registers the "bolt" backend.
+package boltstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backend is the name under which the store is registered with todo.RegisterStore.
+const Backend = "bolt"
+
+// DefaultPath is the database file used when no DSN is configured.
+const DefaultPath = "todos.bolt"
+
+// Bucket names. Todos are keyed by their big-endian ID so cursor order is ID
+// order; IDs are allocated from the todos bucket sequence, which never goes
+// backwards, so deleted IDs are not reused. The external IDs bucket maps
+// source and external ID, joined by a NUL byte, to the todo imported from them.
+// Milestones are keyed and allocated like todos, and the milestone todos
+// bucket maps the ID of each todo in a milestone to the milestone's.
+var (
+	todosBucket          = []byte("todos")
+	mergedBucket         = []byte("merged_todos")
+	externalBucket       = []byte("external_ids")
+	milestonesBucket     = []byte("milestones")
+	milestoneTodosBucket = []byte("milestone_todos")
+)
+
+func init() {
+	todo.RegisterStore(Backend, func(cfg todo.StoreConfig) (todo.Store, error) {
+		path := cfg.DSN
+		if path == "" {
+			path = DefaultPath
+		}
+		return Open(path, cfg.Clock)
+	})
+}
+
+// record is the stored form of a todo.
+type record struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed"`
+	Archived    bool      `json:"archived,omitempty"`
+	Version     int       `json:"version,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+	Source      string    `json:"source,omitempty"`
+	ExternalID  string    `json:"external_id,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	ParentID    int       `json:"parent_id,omitempty"`
+}
+
+// milestoneRecord is the stored form of a milestone.
+type milestoneRecord struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// Store is a todo.Store backed by a bbolt database. Database failures are
+// returned as errors wrapping the failed operation.
+type Store struct {
+	path  string
+	clock todo.Clock
+
+	// mu guards db, which Compact replaces with a compacted copy.
+	mu sync.RWMutex
+	db *bolt.DB
+}
+
+var (
+	_ todo.BackupStore  = (*Store)(nil)
+	_ todo.CompactStore = (*Store)(nil)
+)
+
+// Open opens (creating if necessary) the bbolt database at path and ensures
+// the buckets exist. A database a crashed compaction left moved aside is
+// put back first. A nil clock selects todo.SystemClock.
+func Open(path string, clock todo.Clock) (*Store, error) {
+	if clock == nil {
+		clock = todo.SystemClock{}
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := os.Rename(path+".old", path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("restore bolt database: %w", err)
+		}
+	}
+
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{todosBucket, mergedBucket, externalBucket, milestonesBucket, milestoneTodosBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create bolt buckets: %w", err)
+	}
+
+	return &Store{path: path, clock: clock, db: db}, nil
+}
+
+// openDB opens the bbolt database at path.
+func openDB(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt database: %w", err)
+	}
+	return db, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Close()
+}
+
+// view runs fn in a read-only transaction.
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.View(fn)
+}
+
+// update runs fn in a read-write transaction.
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Update(fn)
+}
+
+// Compact copies the database into a new file without its free pages and
+// replaces the database with it. Requests wait until the copy is done. The
+// old file is kept until the copy opens; if it cannot be swapped in, the
+// old file is put back and reopened, so the store stays usable.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".compact"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove stale compaction: %w", err)
+	}
+	dst, err := openDB(tmp)
+	if err != nil {
+		return err
+	}
+	err = bolt.Compact(dst, s.db, 0)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compact bolt database: %w", err)
+	}
+
+	if err := s.db.Close(); err != nil {
+		// The file was not moved yet; reopen it so s.db is usable again.
+		return s.reopen("", tmp, fmt.Errorf("close bolt database: %w", err))
+	}
+	old := s.path + ".old"
+	if err := os.Rename(s.path, old); err != nil {
+		return s.reopen(old, tmp, fmt.Errorf("replace bolt database: %w", err))
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return s.reopen(old, tmp, fmt.Errorf("replace bolt database: %w", err))
+	}
+	db, err := openDB(s.path)
+	if err != nil {
+		return s.reopen(old, tmp, err)
+	}
+	s.db = db
+	if err := os.Remove(old); err != nil {
+		return fmt.Errorf("remove old bolt database: %w", err)
+	}
+	return nil
+}
+
+// reopen puts the database file moved to old, if any, back in place after a
+// failed compaction, discards the copy in tmp and reopens the database. It returns
+// cause, joined with the error of the reopen if that fails too. The caller
+// must hold s.mu.
+func (s *Store) reopen(old, tmp string, cause error) error {
+	if _, err := os.Stat(old); old != "" && err == nil {
+		if err := os.Rename(old, s.path); err != nil {
+			return errors.Join(cause, fmt.Errorf("restore bolt database: %w", err))
+		}
+	}
+	os.Remove(tmp)
+	db, err := openDB(s.path)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	s.db = db
+	return cause
+}
+
+// DiskSize returns the size of the database file.
+func (s *Store) DiskSize() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func itob(id int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func btoi(b []byte) int {
+	return int(binary.BigEndian.Uint64(b))
+}
+
+func decode(key, value []byte) (*todo.Todo, error) {
+	var r record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("decode todo %d: %w", btoi(key), err)
+	}
+	t := &todo.Todo{
+		ID:          btoi(key),
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   r.Completed,
+		Archived:    r.Archived,
+		// Records written before todos were versioned have no version.
+		Version:    max(r.Version, 1),
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Source:     r.Source,
+		ExternalID: r.ExternalID,
+		Tags:       r.Tags,
+		ParentID:   r.ParentID,
+	}
+	// Records written before updates were tracked have no update time.
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = t.CreatedAt
+	}
+	return t, nil
+}
+
+func put(b *bolt.Bucket, t *todo.Todo) error {
+	value, err := json.Marshal(record{
+		Title:       t.Title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		Archived:    t.Archived,
+		Version:     t.Version,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		Source:      t.Source,
+		ExternalID:  t.ExternalID,
+		Tags:        t.Tags,
+		ParentID:    t.ParentID,
+	})
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(t.ID), value)
+}
+
+// externalKey returns the external IDs bucket key for source and externalID,
+// or nil if externalID is empty.
+func externalKey(source, externalID string) []byte {
+	if externalID == "" {
+		return nil
+	}
+	return []byte(source + "\x00" + externalID)
+}
+
+// index records t in the external IDs bucket of tx if it has an external ID.
+func index(tx *bolt.Tx, t *todo.Todo) error {
+	key := externalKey(t.Source, t.ExternalID)
+	if key == nil {
+		return nil
+	}
+	return tx.Bucket(externalBucket).Put(key, itob(t.ID))
+}
+
+// unindex removes t from the external IDs bucket of tx.
+func unindex(tx *bolt.Tx, t *todo.Todo) error {
+	key := externalKey(t.Source, t.ExternalID)
+	if key == nil {
+		return nil
+	}
+	return tx.Bucket(externalBucket).Delete(key)
+}
+
+// get loads a todo from the todos bucket of tx; it returns nil if absent.
+func get(tx *bolt.Tx, id int) (*todo.Todo, error) {
+	key := itob(id)
+	value := tx.Bucket(todosBucket).Get(key)
+	if value == nil {
+		return nil, nil
+	}
+	return decode(key, value)
+}
+
+// GetAll returns all todos ordered by ID.
+func (s *Store) GetAll(ctx context.Context) ([]*todo.Todo, error) {
+	todos := []*todo.Todo{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(k, v []byte) error {
+			t, err := decode(k, v)
+			if err != nil {
+				return err
+			}
+			todos = append(todos, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+	return todos, nil
+}
+
+// ListSubtasks returns the todos whose parent is parentID, ordered by ID.
+// There is no index on the parent, so it scans the todos in a single read
+// transaction.
+func (s *Store) ListSubtasks(ctx context.Context, parentID int) ([]*todo.Todo, error) {
+	subtasks := []*todo.Todo{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(k, v []byte) error {
+			t, err := decode(k, v)
+			if err != nil {
+				return err
+			}
+			if t.ParentID == parentID {
+				subtasks = append(subtasks, t)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list subtasks: %w", err)
+	}
+	return subtasks, nil
+}
+
+// GetByID returns a todo by its ID, or todo.ErrNotFound.
+func (s *Store) GetByID(ctx context.Context, id int) (*todo.Todo, error) {
+	var t *todo.Todo
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		t, err = get(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get todo: %w", err)
+	}
+	if t == nil {
+		return nil, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+// Create adds a new todo using the provided input.
+func (s *Store) Create(ctx context.Context, input todo.TodoInput) (*todo.Todo, error) {
+	now := s.clock.Now().UTC()
+	t := &todo.Todo{
+		Title:       input.Title,
+		Description: input.Description,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
+	}
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(todosBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		t.ID = int(id)
+		if err := put(b, t); err != nil {
+			return err
+		}
+		return index(tx, t)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+	return t, nil
+}
+
+// modify applies fn to the stored todo with the given ID inside a write
+// transaction, increments its version and stamps its update time. It
+// returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) modify(op string, id int, fn func(t *todo.Todo)) (*todo.Todo, error) {
+	var t *todo.Todo
+	err := s.update(func(tx *bolt.Tx) error {
+		var err error
+		if t, err = get(tx, id); err != nil || t == nil {
+			return err
+		}
+		fn(t)
+		t.Version++
+		t.UpdatedAt = s.clock.Now().UTC()
+		return put(tx.Bucket(todosBucket), t)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if t == nil {
+		return nil, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+// Update modifies an existing todo identified by id.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Update(ctx context.Context, id int, input todo.TodoInput) (*todo.Todo, error) {
+	return s.modify("update todo", id, func(t *todo.Todo) {
+		t.Title = input.Title
+		t.Description = input.Description
+		t.Tags = input.Tags
+		t.ParentID = input.Parent()
+	})
+}
+
+// Complete marks the todo with the given ID as completed.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Complete(ctx context.Context, id int) (*todo.Todo, error) {
+	return s.modify("complete todo", id, func(t *todo.Todo) {
+		t.Completed = true
+	})
+}
+
+// SetState moves the todo with the given ID to state.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) SetState(ctx context.Context, id int, state todo.State) (*todo.Todo, error) {
+	return s.modify("set todo state", id, func(t *todo.Todo) {
+		t.Completed = state != todo.StateOpen
+		t.Archived = state == todo.StateArchived
+	})
+}
+
+// Delete removes the todo with the given ID.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Delete(ctx context.Context, id int) error {
+	deleted := false
+	err := s.update(func(tx *bolt.Tx) error {
+		t, err := get(tx, id)
+		if err != nil || t == nil {
+			return err
+		}
+		deleted = true
+		if err := unindex(tx, t); err != nil {
+			return err
+		}
+		return tx.Bucket(todosBucket).Delete(itob(id))
+	})
+	if err != nil {
+		return fmt.Errorf("delete todo: %w", err)
+	}
+	if !deleted {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// Merge folds the todo sourceID into the todo targetID, appending the
+// source description to the target's, adding the source tags to the
+// target's and recording the source ID as an
+// alias of the target. It returns todo.ErrNotFound if either todo does not
+// exist and todo.ErrMergeIntoItself if they are the same.
+func (s *Store) Merge(ctx context.Context, targetID, sourceID int) (*todo.Todo, error) {
+	if targetID == sourceID {
+		return nil, todo.ErrMergeIntoItself
+	}
+
+	var target *todo.Todo
+	err := s.update(func(tx *bolt.Tx) error {
+		t, err := get(tx, targetID)
+		if err != nil || t == nil {
+			return err
+		}
+		source, err := get(tx, sourceID)
+		if err != nil || source == nil {
+			return err
+		}
+
+		t.Description = todo.MergeDescriptions(t.Description, source.Description)
+		t.Tags = todo.MergeTags(t.Tags, source.Tags)
+		t.Version++
+		t.UpdatedAt = s.clock.Now().UTC()
+
+		todos := tx.Bucket(todosBucket)
+		if err := put(todos, t); err != nil {
+			return err
+		}
+		if err := todos.Delete(itob(sourceID)); err != nil {
+			return err
+		}
+		if err := unindex(tx, source); err != nil {
+			return err
+		}
+		if err := tx.Bucket(mergedBucket).Put(itob(sourceID), itob(targetID)); err != nil {
+			return err
+		}
+		target = t
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	if target == nil {
+		return nil, todo.ErrNotFound
+	}
+	return target, nil
+}
+
+// MergedInto returns the ID of the todo that id was merged into, following
+// chains of merges. It returns todo.ErrNotFound if id was never merged or
+// its survivor no longer exists.
+func (s *Store) MergedInto(ctx context.Context, id int) (int, error) {
+	survivor := 0
+	err := s.view(func(tx *bolt.Tx) error {
+		merged := tx.Bucket(mergedBucket)
+		for next := merged.Get(itob(id)); next != nil; next = merged.Get(next) {
+			survivor = btoi(next)
+		}
+		if survivor != 0 && tx.Bucket(todosBucket).Get(itob(survivor)) == nil {
+			survivor = 0
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("resolve merge: %w", err)
+	}
+	if survivor == 0 {
+		return 0, todo.ErrNotFound
+	}
+	return survivor, nil
+}
+
+// FindByExternalID returns the todo imported from source with the given
+// external ID, or todo.ErrNotFound.
+func (s *Store) FindByExternalID(ctx context.Context, source, externalID string) (*todo.Todo, error) {
+	key := externalKey(source, externalID)
+	if key == nil {
+		return nil, todo.ErrNotFound
+	}
+
+	var t *todo.Todo
+	err := s.view(func(tx *bolt.Tx) error {
+		id := tx.Bucket(externalBucket).Get(key)
+		if id == nil {
+			return nil
+		}
+		var err error
+		t, err = get(tx, btoi(id))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find todo by external id: %w", err)
+	}
+	if t == nil {
+		return nil, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+// listMilestones reads the milestones and their todos from tx.
+func listMilestones(tx *bolt.Tx) ([]todo.Milestone, error) {
+	milestones := []todo.Milestone{}
+	err := tx.Bucket(milestonesBucket).ForEach(func(k, v []byte) error {
+		var r milestoneRecord
+		if err := json.Unmarshal(v, &r); err != nil {
+			return fmt.Errorf("decode milestone %d: %w", btoi(k), err)
+		}
+		milestones = append(milestones, todo.Milestone{ID: btoi(k), Name: r.Name, StartDate: r.StartDate, EndDate: r.EndDate})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	assigned := map[int]int{}
+	err = tx.Bucket(milestoneTodosBucket).ForEach(func(k, v []byte) error {
+		assigned[btoi(k)] = btoi(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	todo.AttachTodoIDs(milestones, assigned)
+	return milestones, nil
+}
+
+// putMilestone stores the milestone m, without its todos, in b.
+func putMilestone(b *bolt.Bucket, m todo.Milestone) error {
+	value, err := json.Marshal(milestoneRecord{Name: m.Name, StartDate: m.StartDate, EndDate: m.EndDate})
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(m.ID), value)
+}
+
+// Milestones returns all milestones ordered by ID.
+func (s *Store) Milestones(ctx context.Context) ([]todo.Milestone, error) {
+	var milestones []todo.Milestone
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		milestones, err = listMilestones(tx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list milestones: %w", err)
+	}
+	return milestones, nil
+}
+
+// CreateMilestone adds a milestone. The input must already be validated.
+func (s *Store) CreateMilestone(ctx context.Context, input todo.MilestoneInput) (todo.Milestone, error) {
+	m := todo.Milestone{Name: input.Name, StartDate: input.StartDate, EndDate: input.EndDate, TodoIDs: []int{}}
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(milestonesBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		m.ID = int(id)
+		return putMilestone(b, m)
+	})
+	if err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	return m, nil
+}
+
+// AssignMilestone moves the todo into the milestone, out of any other.
+// It returns todo.ErrNotFound if the milestone does not exist.
+func (s *Store) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo("assign todo", milestoneID, func(b *bolt.Bucket) error {
+		return b.Put(itob(todoID), itob(milestoneID))
+	})
+}
+
+// UnassignMilestone removes the todo from the milestone. It returns
+// todo.ErrNotFound if the milestone does not exist.
+func (s *Store) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo("unassign todo", milestoneID, func(b *bolt.Bucket) error {
+		if current := b.Get(itob(todoID)); current == nil || btoi(current) != milestoneID {
+			return nil
+		}
+		return b.Delete(itob(todoID))
+	})
+}
+
+// moveTodo applies fn, which moves a todo into or out of the milestone, to
+// the milestone todos bucket inside a write transaction. It returns
+// todo.ErrNotFound if the milestone does not exist.
+func (s *Store) moveTodo(op string, milestoneID int, fn func(b *bolt.Bucket) error) error {
+	found := false
+	err := s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(milestonesBucket).Get(itob(milestoneID)) == nil {
+			return nil
+		}
+		found = true
+		return fn(tx.Bucket(milestoneTodosBucket))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !found {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// Backup returns a dump of the database, read in a single transaction.
+func (s *Store) Backup(ctx context.Context) (todo.Backup, error) {
+	b := todo.Backup{Todos: []todo.BackupTodo{}, Merged: map[int]int{}}
+	err := s.view(func(tx *bolt.Tx) error {
+		todos := tx.Bucket(todosBucket)
+		b.NextID = int(todos.Sequence()) + 1
+
+		err := todos.ForEach(func(k, v []byte) error {
+			t, err := decode(k, v)
+			if err != nil {
+				return err
+			}
+			b.Todos = append(b.Todos, todo.BackupTodo{
+				ID:          t.ID,
+				Title:       t.Title,
+				Description: t.Description,
+				Completed:   t.Completed,
+				Archived:    t.Archived,
+				Version:     t.Version,
+				CreatedAt:   t.CreatedAt,
+				UpdatedAt:   t.UpdatedAt,
+				Source:      t.Source,
+				ExternalID:  t.ExternalID,
+				Tags:        t.Tags,
+				ParentID:    t.ParentID,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(mergedBucket).ForEach(func(k, v []byte) error {
+			b.Merged[btoi(k)] = btoi(v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		milestones, err := listMilestones(tx)
+		if err != nil {
+			return err
+		}
+		for _, m := range milestones {
+			b.Milestones = append(b.Milestones, todo.BackupMilestone{
+				ID:        m.ID,
+				Name:      m.Name,
+				StartDate: m.StartDate,
+				EndDate:   m.EndDate,
+				TodoIDs:   m.TodoIDs,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup: %w", err)
+	}
+	return b, nil
+}
+
+// Restore replaces the content of the database with b in one transaction.
+func (s *Store) Restore(ctx context.Context, b todo.Backup) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		// Keep the highest IDs handed out so far, even if b predates them.
+		current := int(tx.Bucket(todosBucket).Sequence())
+		currentMilestone := int(tx.Bucket(milestonesBucket).Sequence())
+		for _, name := range [][]byte{todosBucket, mergedBucket, externalBucket, milestonesBucket, milestoneTodosBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return fmt.Errorf("clear bucket %s: %w", name, err)
+			}
+		}
+		todos, err := tx.CreateBucket(todosBucket)
+		if err != nil {
+			return err
+		}
+		merged, err := tx.CreateBucket(mergedBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(externalBucket); err != nil {
+			return err
+		}
+		milestones, err := tx.CreateBucket(milestonesBucket)
+		if err != nil {
+			return err
+		}
+		milestoneTodos, err := tx.CreateBucket(milestoneTodosBucket)
+		if err != nil {
+			return err
+		}
+
+		last := max(b.NextID-1, current)
+		for _, bt := range b.Todos {
+			if err := restoreTodo(tx, bt); err != nil {
+				return fmt.Errorf("restore todo %d: %w", bt.ID, err)
+			}
+			last = max(last, bt.ID)
+		}
+		for id, survivor := range b.Merged {
+			if err := merged.Put(itob(id), itob(survivor)); err != nil {
+				return fmt.Errorf("restore merge %d: %w", id, err)
+			}
+			last = max(last, id)
+		}
+		lastMilestone := currentMilestone
+		for _, m := range b.Milestones {
+			if err := putMilestone(milestones, todo.Milestone{ID: m.ID, Name: m.Name, StartDate: m.StartDate, EndDate: m.EndDate}); err != nil {
+				return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+			}
+			for _, todoID := range m.TodoIDs {
+				if err := milestoneTodos.Put(itob(todoID), itob(m.ID)); err != nil {
+					return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+				}
+			}
+			lastMilestone = max(lastMilestone, m.ID)
+		}
+		if err := milestones.SetSequence(uint64(lastMilestone)); err != nil {
+			return err
+		}
+		return todos.SetSequence(uint64(max(last, 0)))
+	})
+}
+
+// Seed adds todos in one transaction if no todo ID has been handed out yet.
+func (s *Store) Seed(ctx context.Context, todos []todo.BackupTodo) (bool, error) {
+	seeded := false
+	err := s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(todosBucket)
+		if bucket.Sequence() != 0 {
+			return nil
+		}
+		last := 0
+		for _, bt := range todos {
+			if err := restoreTodo(tx, bt); err != nil {
+				return fmt.Errorf("seed todo %d: %w", bt.ID, err)
+			}
+			last = max(last, bt.ID)
+		}
+		seeded = true
+		return bucket.SetSequence(uint64(last))
+	})
+	if err != nil {
+		return false, err
+	}
+	return seeded, nil
+}
+
+// restoreTodo puts the todo bt with its ID into the todos bucket of tx and
+// indexes its external ID.
+func restoreTodo(tx *bolt.Tx, bt todo.BackupTodo) error {
+	t := &todo.Todo{
+		ID:          bt.ID,
+		Title:       bt.Title,
+		Description: bt.Description,
+		Completed:   bt.Completed,
+		Archived:    bt.Archived,
+		Version:     bt.Version,
+		CreatedAt:   bt.CreatedAt,
+		UpdatedAt:   bt.UpdatedAt,
+		Source:      bt.Source,
+		ExternalID:  bt.ExternalID,
+		Tags:        bt.Tags,
+		ParentID:    bt.ParentID,
+	}
+	if err := put(tx.Bucket(todosBucket), t); err != nil {
+		return err
+	}
+	return index(tx, t)
+}
@@ -0,0 +1,101 @@
+package boltstore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/storetest"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("failed to open bolt store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return openTestStore(t, filepath.Join(t.TempDir(), "todos.bolt"))
+	})
+}
+
+func TestTodosSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.bolt")
+
+	first := openTestStore(t, path)
+	created := storetest.Create(t, first, todo.TodoInput{Title: "Persisted", Description: "across restarts"})
+	first.Complete(t.Context(), created.ID)
+	deleted := storetest.Create(t, first, todo.TodoInput{Title: "Deleted"})
+	first.Delete(t.Context(), deleted.ID)
+	first.Close()
+
+	second := openTestStore(t, path)
+	fetched, err := second.GetByID(t.Context(), created.ID)
+	if err != nil {
+		t.Fatalf("expected todo %d to survive reopening the database", created.ID)
+	}
+	if fetched.Title != "Persisted" || !fetched.Completed {
+		t.Fatalf("unexpected todo after reopen: %+v", fetched)
+	}
+	if next := storetest.Create(t, second, todo.TodoInput{Title: "Next"}); next.ID != deleted.ID+1 {
+		t.Fatalf("expected deleted ID %d not to be reused, got %d", deleted.ID, next.ID)
+	}
+}
+
+func TestCompactFallsBackToOldFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.bolt")
+	store := openTestStore(t, path)
+	created := storetest.Create(t, store, todo.TodoInput{Title: "Kept"})
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if _, err := os.Stat(path + ".old"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the old file to be removed after compacting, got %v", err)
+	}
+
+	// A copy that cannot be opened is replaced by the old file again.
+	store.db.Close()
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cause := errors.New("open compacted copy")
+	if err := store.reopen(path+".old", path+".compact", cause); !errors.Is(err, cause) {
+		t.Fatalf("expected the cause to be returned, got %v", err)
+	}
+	if got, err := store.GetByID(t.Context(), created.ID); err != nil || got.Title != "Kept" {
+		t.Fatalf("expected the old file to be reopened, got %+v, %v", got, err)
+	}
+
+	// A crash after moving the database aside leaves it to the next Open.
+	store.Close()
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	reopened := openTestStore(t, path)
+	if got, err := reopened.GetByID(t.Context(), created.ID); err != nil || got.Title != "Kept" {
+		t.Fatalf("expected Open to put the old file back, got %+v, %v", got, err)
+	}
+}
+
+func TestRegisteredBackend(t *testing.T) {
+	store, err := todo.NewStoreFromConfig(todo.StoreConfig{
+		Backend: Backend,
+		DSN:     filepath.Join(t.TempDir(), "todos.bolt"),
+	})
+	if err != nil {
+		t.Fatalf("expected bolt backend to be registered, got %v", err)
+	}
+	defer store.(*Store).Close()
+}
@@ -0,0 +1,199 @@
+package todo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/efrem/windsurf/internal/events"
+	"github.com/efrem/windsurf/internal/query"
+)
+
+// changeLogSize is the number of changes the router keeps for GET /changes.
+// Clients that fall further behind must resync.
+const changeLogSize = 10000
+
+// Change is one entry of the change feed.
+type Change struct {
+	Seq      int         `json:"seq"`
+	Type     events.Type `json:"type"`
+	At       time.Time   `json:"at"`
+	TodoID   int         `json:"todo_id,omitempty"`
+	SourceID int         `json:"source_id,omitempty"`
+	Todo     *Todo       `json:"todo,omitempty"`
+}
+
+// ChangeFeed is the response of GET /changes.
+type ChangeFeed struct {
+	Changes []Change        `json:"changes"`
+	Meta    ChangeFeedMeta  `json:"_meta"`
+	Links   ChangeFeedLinks `json:"_links"`
+}
+
+type ChangeFeedMeta struct {
+	Since  int  `json:"since"`
+	Latest int  `json:"latest"`
+	Count  int  `json:"count"`
+	More   bool `json:"more"`
+}
+
+type ChangeFeedLinks struct {
+	Self  *Link `json:"self"`
+	Next  *Link `json:"next"`
+	Todos *Link `json:"todos"`
+}
+
+// loggedChange is a recorded event before presentation.
+type loggedChange struct {
+	seq   int
+	event events.Event
+}
+
+// changeLog assigns a monotonically increasing sequence number to every
+// event it records and keeps the most recent ones in memory. Its record
+// method is subscribed to the events published by the router's Service.
+// Sequence numbers restart with every log, so each log has an epoch that
+// tells its consistency tokens apart from those of earlier logs.
+type changeLog struct {
+	mu       sync.RWMutex
+	capacity int
+	epoch    string
+	seq      int
+	changes  []loggedChange
+}
+
+// newChangeLog returns an empty changeLog that retains up to capacity changes.
+func newChangeLog(capacity int) *changeLog {
+	return &changeLog{capacity: max(capacity, 1), epoch: strconv.FormatInt(time.Now().UnixNano(), 36)}
+}
+
+// record appends e to the log with the next sequence number.
+func (l *changeLog) record(e events.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	l.changes = append(l.changes, loggedChange{seq: l.seq, event: e})
+	if drop := len(l.changes) - l.capacity; drop > 0 {
+		l.changes = append(l.changes[:0:0], l.changes[drop:]...)
+	}
+}
+
+// latest returns the sequence number of the most recent change.
+func (l *changeLog) latest() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.seq
+}
+
+// since returns up to limit changes with a sequence number greater than seq,
+// and the latest sequence number. The boolean is false if changes after seq
+// are no longer retained, or if seq is ahead of the log, which happens when
+// the server restarted since the client last synced.
+func (l *changeLog) since(seq, limit int) ([]loggedChange, int, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if seq > l.seq {
+		return nil, l.seq, false
+	}
+	oldest := l.seq - len(l.changes)
+	if seq < oldest {
+		return nil, l.seq, false
+	}
+
+	start := seq - oldest
+	end := min(start+limit, len(l.changes))
+	return append([]loggedChange(nil), l.changes[start:end]...), l.seq, true
+}
+
+// presentChange converts a logged event to its change feed representation.
+func (api *TodoAPI) presentChange(r *http.Request, c loggedChange) Change {
+	change := Change{Seq: c.seq, Type: c.event.Type(), At: c.event.Time()}
+
+	var todo *events.Todo
+	switch e := c.event.(type) {
+	case events.TodoCreated:
+		todo = &e.Todo
+	case events.TodoUpdated:
+		todo = &e.Todo
+	case events.TodoCompleted:
+		todo = &e.Todo
+	case events.TodoReopened:
+		todo = &e.Todo
+	case events.TodoArchived:
+		todo = &e.Todo
+	case events.TodoUnarchived:
+		todo = &e.Todo
+	case events.TodoMerged:
+		todo = &e.Target
+		change.SourceID = e.SourceID
+	case events.TodoDeleted:
+		change.TodoID = e.ID
+	}
+	if todo != nil {
+		// The todo is shown as it was, without the current progress of
+		// its subtasks or its current milestone.
+		presented := api.presentWith(r, &Todo{
+			ID:          todo.ID,
+			Title:       todo.Title,
+			Description: todo.Description,
+			Completed:   todo.Completed,
+			Archived:    todo.Archived,
+			CreatedAt:   todo.CreatedAt,
+			UpdatedAt:   todo.UpdatedAt,
+			Source:      todo.Source,
+			ExternalID:  todo.ExternalID,
+			Tags:        todo.Tags,
+			ParentID:    todo.ParentID,
+		}, nil, nil)
+		change.TodoID = todo.ID
+		change.Todo = &presented
+	}
+	return change
+}
+
+// GetChanges handles GET /changes and returns the changes recorded after
+// the sequence number given by since, oldest first.
+func (api *TodoAPI) GetChanges(w http.ResponseWriter, r *http.Request) {
+	params := query.New(r.URL.Query())
+	since := params.Int("since", 0, 0, math.MaxInt32)
+	limit := params.Int("limit", 100, 1, 1000)
+	if err := params.Err(); err != nil {
+		api.sendQueryError(w, r, err)
+		return
+	}
+
+	logged, latest, ok := api.changes.since(since, limit)
+	if !ok {
+		api.sendError(w, r, http.StatusGone, "Changes unavailable",
+			fmt.Sprintf("Changes after sequence %d are no longer available; re-fetch the todos and continue from sequence %d", since, latest))
+		return
+	}
+
+	feed := ChangeFeed{
+		Changes: make([]Change, 0, len(logged)),
+		Meta:    ChangeFeedMeta{Since: since, Latest: latest},
+		Links: ChangeFeedLinks{
+			Self: &Link{Href: fmt.Sprintf("%s/changes?since=%d&limit=%d", api.base(r), since, limit)},
+			Todos: &Link{
+				Href:   fmt.Sprintf("%s/todos", api.base(r)),
+				Method: "GET",
+			},
+		},
+	}
+	next := since
+	for _, c := range logged {
+		feed.Changes = append(feed.Changes, api.presentChange(r, c))
+		next = c.seq
+	}
+	feed.Meta.Count = len(feed.Changes)
+	feed.Meta.More = next < latest
+	feed.Links.Next = &Link{Href: fmt.Sprintf("%s/changes?since=%d&limit=%d", api.base(r), next, limit)}
+
+	api.respond(w, r, http.StatusOK, feed)
+}
@@ -0,0 +1,199 @@
+package todo
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// compactJobTTL is how long a finished compaction can still be fetched.
+const compactJobTTL = time.Hour
+
+// CompactStore is implemented by stores keeping their todos in local files
+// that can reclaim the space left by deleted and rewritten todos, which
+// POST /admin/compact requires.
+type CompactStore interface {
+	Store
+	// Compact rewrites the files of the store without unused space. The
+	// store stays usable, though requests may wait while it runs.
+	Compact() error
+	// DiskSize returns the number of bytes the files of the store occupy.
+	DiskSize() (int64, error)
+}
+
+var _ CompactStore = (*WALStore)(nil)
+
+// CompactJobStatus is the state of a compaction.
+type CompactJobStatus string
+
+const (
+	CompactJobRunning   CompactJobStatus = "running"
+	CompactJobCompleted CompactJobStatus = "completed"
+	CompactJobFailed    CompactJobStatus = "failed"
+)
+
+// CompactJob reports a compaction running in the background. SizeAfter and
+// Reclaimed are set once it completed.
+type CompactJob struct {
+	ID         string           `json:"id"`
+	Status     CompactJobStatus `json:"status"`
+	SizeBefore int64            `json:"size_before"`
+	SizeAfter  *int64           `json:"size_after,omitempty"`
+	Reclaimed  *int64           `json:"reclaimed,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Links      CompactJobLinks  `json:"_links"`
+}
+
+type CompactJobLinks struct {
+	Self *Link `json:"self"`
+}
+
+// compactJob is the state of a compaction, guarded by compactions.mu.
+type compactJob struct {
+	status     CompactJobStatus
+	sizeBefore int64
+	sizeAfter  int64
+	err        string
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// compactions keeps the running compaction, and finished ones until they
+// expire. At most one compaction runs at a time.
+type compactions struct {
+	mu   sync.Mutex
+	jobs map[string]*compactJob
+}
+
+func newCompactions() *compactions {
+	return &compactions{jobs: make(map[string]*compactJob)}
+}
+
+// add stores j under a new random ID. The boolean is false if a compaction
+// is running already.
+func (c *compactions) add(j *compactJob, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, job := range c.jobs {
+		switch {
+		case job.status == CompactJobRunning:
+			return "", false
+		case !now.Before(job.finishedAt.Add(compactJobTTL)):
+			delete(c.jobs, id)
+		}
+	}
+	id := newImportID()
+	c.jobs[id] = j
+	return id, true
+}
+
+// get returns a copy of the job with the given ID, unless it expired.
+func (c *compactions) get(id string, now time.Time) (compactJob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	j, ok := c.jobs[id]
+	if !ok || j.status != CompactJobRunning && !now.Before(j.finishedAt.Add(compactJobTTL)) {
+		return compactJob{}, false
+	}
+	return *j, true
+}
+
+// finish records the outcome of j.
+func (c *compactions) finish(j *compactJob, sizeAfter int64, err error, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	j.status = CompactJobCompleted
+	j.sizeAfter = sizeAfter
+	if err != nil {
+		j.status = CompactJobFailed
+		j.err = "The store could not be compacted"
+	}
+	j.finishedAt = at
+}
+
+// runCompaction compacts the store and measures its size afterwards.
+func (api *TodoAPI) runCompaction(j *compactJob) {
+	err := api.compactor.Compact()
+	var size int64
+	if err == nil {
+		size, err = api.compactor.DiskSize()
+	}
+	if err != nil {
+		log.Printf("todo: compact store: %v", err)
+	}
+	api.compactions.finish(j, size, err, api.clock.Now().UTC())
+}
+
+// presentCompactJob builds the response for the job with the given ID.
+func (api *TodoAPI) presentCompactJob(r *http.Request, id string, j compactJob) CompactJob {
+	job := CompactJob{
+		ID:         id,
+		Status:     j.status,
+		SizeBefore: j.sizeBefore,
+		Error:      j.err,
+		StartedAt:  j.startedAt,
+		Links: CompactJobLinks{
+			Self: &Link{Href: fmt.Sprintf("%s/admin/compact/%s", api.base(r), id), Method: "GET"},
+		},
+	}
+	if j.status != CompactJobRunning {
+		finishedAt := j.finishedAt
+		job.FinishedAt = &finishedAt
+	}
+	if j.status == CompactJobCompleted {
+		sizeAfter, reclaimed := j.sizeAfter, j.sizeBefore-j.sizeAfter
+		job.SizeAfter = &sizeAfter
+		job.Reclaimed = &reclaimed
+	}
+	return job
+}
+
+// CreateCompactJob handles POST /admin/compact and compacts the store in
+// the background. The response is the job, whose self link reports the
+// sizes of the store before and after.
+func (api *TodoAPI) CreateCompactJob(w http.ResponseWriter, r *http.Request) {
+	if api.compactor == nil {
+		api.sendError(w, r, http.StatusNotImplemented, "Not implemented", "The configured store does not support compaction")
+		return
+	}
+
+	size, err := api.compactor.DiskSize()
+	if err != nil {
+		log.Printf("todo: measure store: %v", err)
+		api.sendError(w, r, http.StatusInternalServerError, "Compaction failed", "The size of the store could not be measured")
+		return
+	}
+	j := &compactJob{status: CompactJobRunning, sizeBefore: size, startedAt: api.clock.Now().UTC()}
+	id, ok := api.compactions.add(j, j.startedAt)
+	if !ok {
+		api.sendError(w, r, http.StatusConflict, "Compaction running", "The store is being compacted already; try again once it finished")
+		return
+	}
+	started := *j
+	go api.runCompaction(j)
+
+	job := api.presentCompactJob(r, id, started)
+	w.Header().Set("Location", job.Links.Self.Href)
+	api.respond(w, r, http.StatusAccepted, job)
+}
+
+// GetCompactJob handles GET /admin/compact/{jobID}.
+func (api *TodoAPI) GetCompactJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "jobID")
+	j, ok := api.compactions.get(id, api.clock.Now())
+	if !ok {
+		api.sendError(w, r, http.StatusNotFound, "Compaction not found", fmt.Sprintf("Compaction %s does not exist or has expired", id))
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentCompactJob(r, id, j))
+}
@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HeaderConsistencyToken is the header successful mutations return a
+// consistency token in. Clients send the token back with later GETs to
+// read their own writes: the responses vary on the header, so caches keyed
+// on it never answer with a representation from before the write.
+const HeaderConsistencyToken = "X-Consistency-Token"
+
+// unverifiedTokenWarning is the Warning header of reads whose consistency
+// token could not be checked.
+const unverifiedTokenWarning = `199 - "Consistency token not verified"`
+
+// token returns the consistency token of the latest change: the epoch of
+// the log and the change's sequence number.
+func (l *changeLog) token() string {
+	return l.epoch + "." + strconv.Itoa(l.latest())
+}
+
+// checkToken returns an error if token is malformed or refers to a change
+// the log has not recorded. It reports whether the token was verified:
+// tokens of another epoch, issued before a restart or by another instance,
+// name changes this log never saw, so whether the store holds them cannot
+// be told.
+func (l *changeLog) checkToken(token string) (bool, error) {
+	epoch, seq, ok := strings.Cut(token, ".")
+	n, err := strconv.Atoi(seq)
+	if !ok || epoch == "" || err != nil || n < 0 {
+		return false, fmt.Errorf("Consistency token %q is malformed; send back the %s header of a response", token, HeaderConsistencyToken)
+	}
+	if epoch != l.epoch {
+		return false, nil
+	}
+	if n > l.latest() {
+		return false, fmt.Errorf("Consistency token %q was not issued by this server", token)
+	}
+	return true, nil
+}
+
+// consistent is a middleware implementing read-after-write consistency
+// tokens. Responses to successful mutations carry the token of the latest
+// change in HeaderConsistencyToken; reads that send a token back are
+// checked against the change log and rejected with 400 Bad Request if the
+// token is invalid. Reads with a token the log cannot verify are served
+// with a Warning header saying so.
+func (api *TodoAPI) consistent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", HeaderConsistencyToken)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if token := r.Header.Get(HeaderConsistencyToken); token != "" {
+				verified, err := api.changes.checkToken(token)
+				if err != nil {
+					api.sendError(w, r, http.StatusBadRequest, "Invalid consistency token", err.Error())
+					return
+				}
+				if !verified {
+					w.Header().Add("Warning", unverifiedTokenWarning)
+				}
+			}
+		default:
+			w = &tokenWriter{ResponseWriter: w, changes: api.changes}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenWriter sets the consistency token header on 2xx responses, once the
+// handler has applied its change and sends the status.
+type tokenWriter struct {
+	http.ResponseWriter
+	changes     *changeLog
+	wroteHeader bool
+}
+
+func (w *tokenWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= 200 && status < 300 {
+		w.Header().Set(HeaderConsistencyToken, w.changes.token())
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tokenWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *tokenWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
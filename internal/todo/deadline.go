@@ -0,0 +1,94 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers through which clients bound how long they wait for a
+// response. HeaderRequestDeadline carries an absolute time, as RFC 3339 or
+// an HTTP date; HeaderRequestTimeout carries a number of seconds.
+const (
+	HeaderRequestDeadline = "X-Request-Deadline"
+	HeaderRequestTimeout  = "Request-Timeout"
+)
+
+// maxRequestTimeout bounds the timeouts clients set. Longer ones would
+// never pass anyway.
+const maxRequestTimeout = 24 * time.Hour
+
+// requestDeadline returns the deadline the headers of r set, relative to
+// now. With both headers the earlier deadline applies. The boolean is false
+// if r sets none.
+func requestDeadline(r *http.Request, now time.Time) (time.Time, bool, error) {
+	var deadline time.Time
+	if value := r.Header.Get(HeaderRequestDeadline); value != "" {
+		at, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			if at, err = http.ParseTime(value); err != nil {
+				return time.Time{}, false, fmt.Errorf("%s must be an RFC 3339 timestamp or an HTTP date", HeaderRequestDeadline)
+			}
+		}
+		deadline = at
+	}
+	if value := r.Header.Get(HeaderRequestTimeout); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+			return time.Time{}, false, fmt.Errorf("%s must be a non-negative number of seconds", HeaderRequestTimeout)
+		}
+		at := now.Add(time.Duration(math.Min(seconds, maxRequestTimeout.Seconds()) * float64(time.Second)))
+		if deadline.IsZero() || at.Before(deadline) {
+			deadline = at
+		}
+	}
+	return deadline, !deadline.IsZero(), nil
+}
+
+// deadline is a middleware honoring the deadline clients set with
+// HeaderRequestDeadline or HeaderRequestTimeout. Requests whose deadline
+// passed already are answered with 504 Gateway Timeout without being
+// served. Others are served with the deadline on their context: stores
+// querying a backend give up once it passes, and the service makes no
+// further change to the store after it, so these requests fail with 504
+// too rather than complete behind the client's back. Responses are not
+// buffered, so downloads such as backups stream as usual. Invalid headers
+// are rejected with 400, and WebSocket upgrades, which outlive any
+// deadline, ignore them.
+func (api *TodoAPI) deadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := api.clock.Now()
+		deadline, ok, err := requestDeadline(r, now)
+		if err != nil {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid request deadline", err.Error())
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		remaining := deadline.Sub(now)
+		if remaining <= 0 {
+			api.sendDeadlineExceeded(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), remaining)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// sendDeadlineExceeded answers r with 504 Gateway Timeout because its
+// deadline passed.
+func (api *TodoAPI) sendDeadlineExceeded(w http.ResponseWriter, r *http.Request) {
+	api.sendTodoError(w, r, 0, context.DeadlineExceeded)
+}
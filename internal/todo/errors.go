@@ -0,0 +1,206 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/efrem/windsurf/internal/validate"
+)
+
+// Errors returned by Service and Store methods. Callers test for them with
+// errors.Is; handlers map them to status codes in sendTodoError.
+var (
+	// ErrNotFound is returned for todos that do not exist.
+	ErrNotFound = errors.New("todo not found")
+	// ErrValidation is matched by errors reporting invalid input.
+	ErrValidation = errors.New("invalid todo")
+	// ErrConflict is matched by errors reporting a change the current
+	// state of a todo does not allow, such as a *TransitionError.
+	ErrConflict = errors.New("conflicting todo change")
+	// ErrUnavailable is matched by errors reporting that the backend of
+	// the store is down.
+	ErrUnavailable = errors.New("todo store unavailable")
+	// ErrTooLong is matched by validation errors reporting a field longer
+	// than its limit. Such errors also match ErrValidation.
+	ErrTooLong = errors.New("todo field too long")
+)
+
+// ValidationError reports invalid input with a message for the client. It
+// matches ErrValidation. Fields lists the invalid fields of the input, if
+// the error was found by checking them.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+func (e *ValidationError) Is(target error) bool {
+	if target == ErrTooLong {
+		return slices.ContainsFunc(e.Fields, func(fe FieldError) bool {
+			return fe.Rule == validate.RuleMaxLength || fe.Rule == validate.RuleMaxBytes
+		})
+	}
+	return target == ErrValidation
+}
+
+// Default limits of the text fields of todos, in characters.
+const (
+	DefaultMaxTitleLength       = 200
+	DefaultMaxDescriptionLength = 10000
+	DefaultLongTitleLength      = 100
+)
+
+// Strictness selects how findings that do not make a todo invalid, such as
+// a very long title, are handled.
+type Strictness string
+
+const (
+	// StrictnessLenient accepts the todo and reports the findings as
+	// warnings in the response. It is the default.
+	StrictnessLenient Strictness = "lenient"
+	// StrictnessStrict rejects the todo, reporting the findings as
+	// validation errors.
+	StrictnessStrict Strictness = "strict"
+)
+
+// ParseStrictness returns the strictness named s, which is either lenient
+// or strict.
+func ParseStrictness(s string) (Strictness, bool) {
+	switch Strictness(s) {
+	case StrictnessLenient, StrictnessStrict:
+		return Strictness(s), true
+	}
+	return "", false
+}
+
+// Limits bounds the length of the text fields of todos, in characters.
+type Limits struct {
+	Title       int
+	Description int
+	// LongTitle is the length above which a title is flagged as very
+	// long, which Strictness makes a warning or an error.
+	LongTitle  int
+	Strictness Strictness
+}
+
+// DefaultLimits returns the limits used unless the server configures
+// others.
+func DefaultLimits() Limits {
+	return Limits{
+		Title:       DefaultMaxTitleLength,
+		Description: DefaultMaxDescriptionLength,
+		LongTitle:   DefaultLongTitleLength,
+		Strictness:  StrictnessLenient,
+	}
+}
+
+// validationError converts the violations found by validate.Check to a
+// *ValidationError. Its message names every invalid field, so a single
+// violation reads as a sentence such as "Title is required".
+func validationError(err error) error {
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	msgs := make([]string, len(errs))
+	for i, fe := range errs {
+		msgs[i] = fmt.Sprintf("%s %s", fe.Field, fe.Message)
+	}
+	message := strings.Join(msgs, "; ")
+	first, size := utf8.DecodeRuneInString(message)
+	return &ValidationError{Message: string(unicode.ToUpper(first)) + message[size:], Fields: fieldErrors(errs)}
+}
+
+// editFields declares the rules of the fields an edit sets: a title, a
+// description that may span lines, within limits, and tags. All are
+// trimmed. Strict limits also reject very long titles.
+func editFields(input *TodoInput, limits Limits) []validate.Field {
+	title := []validate.Rule{validate.Required(), validate.MaxLength(limits.Title), validate.NoControlCharacters("")}
+	if limits.Strictness == StrictnessStrict && limits.LongTitle > 0 {
+		title = append(title, longTitle(limits))
+	}
+	return append([]validate.Field{
+		{Name: "title", Value: &input.Title, Trim: true, Rules: title},
+		{Name: "description", Value: &input.Description, Trim: true, Rules: []validate.Rule{
+			validate.MaxLength(limits.Description), validate.NoControlCharacters("\t\n\r"),
+		}},
+	}, tagFields(input.Tags)...)
+}
+
+// longTitle flags titles longer than limits.LongTitle. Such titles are
+// valid unless the limits are strict.
+func longTitle(limits Limits) validate.Rule {
+	return validate.Rule{Name: validate.RuleMaxLength, Check: func(value string) string {
+		if utf8.RuneCountInString(value) > limits.LongTitle {
+			return fmt.Sprintf("should be at most %d characters", limits.LongTitle)
+		}
+		return ""
+	}}
+}
+
+// ValidateInput checks the fields a new todo needs, reporting every invalid
+// one: a title, a title and description within limits, tags that are single
+// words, and a source and external ID that are either both set or both
+// empty. In strict mode a very long title is invalid too. The title,
+// description and tags are trimmed in place, and the tags normalized.
+func ValidateInput(input *TodoInput, limits Limits) error {
+	fields := append(editFields(input, limits), externalIDFields(&input.Source, &input.ExternalID)...)
+	if err := validate.Check(fields...); err != nil {
+		return validationError(err)
+	}
+	input.Tags = normalizeTags(input.Tags)
+	return nil
+}
+
+// validateEdit checks and trims the title, description and tags of an
+// edit, and normalizes the tags.
+func validateEdit(input *TodoInput, limits Limits) error {
+	if err := validate.Check(editFields(input, limits)...); err != nil {
+		return validationError(err)
+	}
+	input.Tags = normalizeTags(input.Tags)
+	return nil
+}
+
+// validateDescription checks that a description built by the service, such
+// as the joined descriptions of merged todos, is within limits.
+func validateDescription(description string, limits Limits) error {
+	field := validate.Field{Name: "description", Value: &description, Rules: []validate.Rule{validate.MaxLength(limits.Description)}}
+	if err := validate.Check(field); err != nil {
+		return validationError(err)
+	}
+	return nil
+}
+
+// todoWarnings returns the findings about todo that lenient limits report
+// as warnings rather than errors, or nil if there are none.
+func todoWarnings(todo *Todo, limits Limits) []FieldError {
+	if limits.Strictness == StrictnessStrict || limits.LongTitle <= 0 {
+		return nil
+	}
+	title := todo.Title
+	var errs validate.Errors
+	if !errors.As(validate.Check(validate.Field{Name: "title", Value: &title, Rules: []validate.Rule{longTitle(limits)}}), &errs) {
+		return nil
+	}
+	return fieldErrors(errs)
+}
+
+// fieldErrors converts violations found by validate.Check to the field
+// errors of responses.
+func fieldErrors(errs validate.Errors) []FieldError {
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		fields[i] = FieldError{Field: fe.Field, Message: fe.Message, Rule: fe.Rule}
+	}
+	return fields
+}
+
+// ErrMergeIntoItself is returned by Store.Merge and Service.MergeTodos when
+// a todo is merged into itself.
+var ErrMergeIntoItself error = &ValidationError{Message: "A todo cannot be merged into itself"}
@@ -0,0 +1,73 @@
+package todo
+
+import "github.com/efrem/windsurf/internal/validate"
+
+// maxExternalIDLength limits the length of sources and external IDs.
+const maxExternalIDLength = 255
+
+// externalKey identifies a todo in the system it was imported from.
+type externalKey struct {
+	Source     string
+	ExternalID string
+}
+
+// externalKeyOf returns the external key of todo. The boolean is false if
+// the todo was not imported with an external ID.
+func externalKeyOf(todo *Todo) (externalKey, bool) {
+	if todo.ExternalID == "" {
+		return externalKey{}, false
+	}
+	return externalKey{Source: todo.Source, ExternalID: todo.ExternalID}, true
+}
+
+// externalIDFields declares the rules of a source and external ID: both
+// are set or both are empty, and neither is too long.
+func externalIDFields(source, externalID *string) []validate.Field {
+	together := validate.Rule{Name: "required_with", Check: func(value string) string {
+		if (value == "") != (*externalID == "") {
+			return "must be given together with external_id"
+		}
+		return ""
+	}}
+	limits := []validate.Rule{validate.MaxBytes(maxExternalIDLength), validate.NoControlCharacters("")}
+	return []validate.Field{
+		{Name: "source", Value: source, Rules: append([]validate.Rule{together}, limits...)},
+		{Name: "external_id", Value: externalID, Rules: limits},
+	}
+}
+
+// ValidateExternalID checks that source and externalID are either both set
+// or both empty, and not too long.
+func ValidateExternalID(source, externalID string) error {
+	return validationError(validate.Check(externalIDFields(&source, &externalID)...))
+}
+
+// ConflictStrategy selects what an import does with a row whose source and
+// external ID match an existing todo.
+type ConflictStrategy string
+
+const (
+	// ConflictSkip leaves the existing todo unchanged.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the title and description of the existing
+	// todo, and its tags if the row lists them, and moves it to the status of
+	// the row if it has one.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictMerge keeps the existing title and appends the row's
+	// description to the existing one, as merging todos does, unless the
+	// existing description already contains it. The todo gains the row's
+	// tags.
+	ConflictMerge ConflictStrategy = "merge"
+)
+
+// ConflictStrategies lists the valid conflict strategies.
+var ConflictStrategies = []string{string(ConflictSkip), string(ConflictOverwrite), string(ConflictMerge)}
+
+// UpsertOutcome reports what an upsert did.
+type UpsertOutcome string
+
+const (
+	UpsertCreated UpsertOutcome = "created"
+	UpsertUpdated UpsertOutcome = "updated"
+	UpsertSkipped UpsertOutcome = "skipped"
+)
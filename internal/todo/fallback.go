@@ -0,0 +1,335 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// staleWarning is the Warning header of responses served from the
+// fallback snapshot while the store is unavailable.
+const staleWarning = `110 - "Response is Stale"`
+
+// fallbackStore wraps a Store and keeps the todos it last read and wrote in
+// memory, so reads can still be answered while the backend is down.
+// Failures of the backend, errors other than those the Store documents, are
+// returned as errors matching ErrUnavailable. Reads then serve the
+// remembered todos and mark the request as stale, while writes fail. Calls
+// cut short by their context, whose deadline passed, are not outages: their
+// errors are returned as they are. Milestones are not remembered, so they
+// cannot be read during an outage.
+type fallbackStore struct {
+	store Store
+
+	mu    sync.Mutex
+	todos map[int]*Todo
+}
+
+// withFallback wraps store in a fallbackStore. Like instrumentStore, the
+// result implements SnapshotStore and BackupStore exactly when store does.
+func withFallback(store Store) Store {
+	s := &fallbackStore{store: store, todos: make(map[int]*Todo)}
+
+	snapshots, isSnapshot := store.(SnapshotStore)
+	backups, isBackup := store.(BackupStore)
+	switch {
+	case isSnapshot && isBackup:
+		return struct {
+			*fallbackStore
+			fallbackSnapshots
+			fallbackBackups
+		}{s, fallbackSnapshots{s, snapshots}, fallbackBackups{s, backups}}
+	case isSnapshot:
+		return struct {
+			*fallbackStore
+			fallbackSnapshots
+		}{s, fallbackSnapshots{s, snapshots}}
+	case isBackup:
+		return struct {
+			*fallbackStore
+			fallbackBackups
+		}{s, fallbackBackups{s, backups}}
+	}
+	return s
+}
+
+// unavailable returns err, returned by the store, as an error matching
+// ErrUnavailable if it reports a failure of the backend rather than one of
+// the errors the Store documents.
+func unavailable(err error) error {
+	switch {
+	case err == nil, errors.Is(err, ErrUnavailable),
+		errors.Is(err, ErrNotFound), errors.Is(err, ErrValidation), errors.Is(err, ErrConflict),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+// remember records todos as the current state of the store.
+func (s *fallbackStore) remember(todos ...*Todo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, todo := range todos {
+		copied := *todo
+		s.todos[todo.ID] = &copied
+	}
+}
+
+// forget drops the todos with the given IDs.
+func (s *fallbackStore) forget(ids ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.todos, id)
+	}
+}
+
+// replace records todos as the whole content of the store.
+func (s *fallbackStore) replace(todos []*Todo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.todos = make(map[int]*Todo, len(todos))
+	for _, todo := range todos {
+		copied := *todo
+		s.todos[todo.ID] = &copied
+	}
+}
+
+// remembered returns copies of the remembered todos ordered by ID.
+func (s *fallbackStore) remembered() []*Todo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todos := make([]*Todo, 0, len(s.todos))
+	for _, id := range slices.Sorted(maps.Keys(s.todos)) {
+		copied := *s.todos[id]
+		todos = append(todos, &copied)
+	}
+	return todos
+}
+
+func (s *fallbackStore) GetAll(ctx context.Context) ([]*Todo, error) {
+	todos, err := s.store.GetAll(ctx)
+	if err = unavailable(err); errors.Is(err, ErrUnavailable) {
+		markStale(ctx)
+		return s.remembered(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.replace(todos)
+	return todos, nil
+}
+
+func (s *fallbackStore) ListSubtasks(ctx context.Context, parentID int) ([]*Todo, error) {
+	subtasks, err := s.store.ListSubtasks(ctx, parentID)
+	if err = unavailable(err); errors.Is(err, ErrUnavailable) {
+		markStale(ctx)
+		remembered := []*Todo{}
+		for _, todo := range s.remembered() {
+			if todo.ParentID == parentID {
+				remembered = append(remembered, todo)
+			}
+		}
+		return remembered, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.remember(subtasks...)
+	return subtasks, nil
+}
+
+func (s *fallbackStore) GetByID(ctx context.Context, id int) (*Todo, error) {
+	todo, err := s.store.GetByID(ctx, id)
+	if err = unavailable(err); errors.Is(err, ErrUnavailable) {
+		s.mu.Lock()
+		cached, ok := s.todos[id]
+		s.mu.Unlock()
+		if !ok {
+			return nil, err
+		}
+		markStale(ctx)
+		copied := *cached
+		return &copied, nil
+	}
+	s.track(todo, err, id)
+	return todo, err
+}
+
+// track records the outcome of a call for the todo with the given ID: the
+// todo it returned, or that it does not exist.
+func (s *fallbackStore) track(todo *Todo, err error, id int) {
+	switch {
+	case err == nil:
+		s.remember(todo)
+	case errors.Is(err, ErrNotFound):
+		s.forget(id)
+	}
+}
+
+func (s *fallbackStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
+	todo, err := s.store.Create(ctx, input)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	s.remember(todo)
+	return todo, nil
+}
+
+// mutate runs call, a change of the todo with the given ID, and records the
+// todo it returned.
+func (s *fallbackStore) mutate(id int, call func() (*Todo, error)) (*Todo, error) {
+	todo, err := call()
+	err = unavailable(err)
+	s.track(todo, err, id)
+	return todo, err
+}
+
+func (s *fallbackStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	return s.mutate(id, func() (*Todo, error) { return s.store.Update(ctx, id, input) })
+}
+
+func (s *fallbackStore) Complete(ctx context.Context, id int) (*Todo, error) {
+	return s.mutate(id, func() (*Todo, error) { return s.store.Complete(ctx, id) })
+}
+
+func (s *fallbackStore) SetState(ctx context.Context, id int, state State) (*Todo, error) {
+	return s.mutate(id, func() (*Todo, error) { return s.store.SetState(ctx, id, state) })
+}
+
+func (s *fallbackStore) Delete(ctx context.Context, id int) error {
+	err := unavailable(s.store.Delete(ctx, id))
+	if err == nil || errors.Is(err, ErrNotFound) {
+		s.forget(id)
+	}
+	return err
+}
+
+func (s *fallbackStore) Merge(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	todo, err := s.mutate(targetID, func() (*Todo, error) { return s.store.Merge(ctx, targetID, sourceID) })
+	if err == nil {
+		s.forget(sourceID)
+	}
+	return todo, err
+}
+
+func (s *fallbackStore) MergedInto(ctx context.Context, id int) (int, error) {
+	survivor, err := s.store.MergedInto(ctx, id)
+	return survivor, unavailable(err)
+}
+
+func (s *fallbackStore) FindByExternalID(ctx context.Context, source, externalID string) (*Todo, error) {
+	todo, err := s.store.FindByExternalID(ctx, source, externalID)
+	if err == nil {
+		s.remember(todo)
+	}
+	return todo, unavailable(err)
+}
+
+func (s *fallbackStore) Milestones(ctx context.Context) ([]Milestone, error) {
+	milestones, err := s.store.Milestones(ctx)
+	return milestones, unavailable(err)
+}
+
+func (s *fallbackStore) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	m, err := s.store.CreateMilestone(ctx, input)
+	return m, unavailable(err)
+}
+
+func (s *fallbackStore) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return unavailable(s.store.AssignMilestone(ctx, milestoneID, todoID))
+}
+
+func (s *fallbackStore) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return unavailable(s.store.UnassignMilestone(ctx, milestoneID, todoID))
+}
+
+func (s *fallbackStore) Seed(ctx context.Context, todos []BackupTodo) (bool, error) {
+	seeded, err := s.store.Seed(ctx, todos)
+	return seeded, unavailable(err)
+}
+
+// fallbackSnapshots adds the SnapshotStore methods to a fallbackStore.
+// Snapshots are taken in memory and cannot fail, so Snapshot only records
+// the todos it returns.
+type fallbackSnapshots struct {
+	s     *fallbackStore
+	store SnapshotStore
+}
+
+func (s fallbackSnapshots) Snapshot(ctx context.Context) ([]*Todo, int) {
+	todos, seq := s.store.Snapshot(ctx)
+	s.s.replace(todos)
+	return todos, seq
+}
+
+func (s fallbackSnapshots) ListAt(ctx context.Context, seq int) ([]*Todo, bool) {
+	return s.store.ListAt(ctx, seq)
+}
+
+// fallbackBackups adds the BackupStore methods to a fallbackStore. Backups
+// are never served from the remembered todos, which may be incomplete.
+type fallbackBackups struct {
+	s     *fallbackStore
+	store BackupStore
+}
+
+func (s fallbackBackups) Backup(ctx context.Context) (Backup, error) {
+	b, err := s.store.Backup(ctx)
+	return b, unavailable(err)
+}
+
+func (s fallbackBackups) Restore(ctx context.Context, b Backup) error {
+	err := unavailable(s.store.Restore(ctx, b))
+	if err == nil {
+		s.s.replace(nil)
+	}
+	return err
+}
+
+type staleKey struct{}
+
+// withStaleFlag returns a context in which fallbackStore can mark the
+// request as served from the fallback snapshot.
+func withStaleFlag(ctx context.Context) context.Context {
+	return context.WithValue(ctx, staleKey{}, new(atomic.Bool))
+}
+
+// markStale marks the request of ctx as served from the fallback snapshot.
+func markStale(ctx context.Context) {
+	if stale, ok := ctx.Value(staleKey{}).(*atomic.Bool); ok {
+		stale.Store(true)
+	}
+}
+
+// staleResponse reports whether the response to r is built from the
+// fallback snapshot, and if so sets the Warning header on w.
+func staleResponse(w http.ResponseWriter, r *http.Request) bool {
+	stale, ok := r.Context().Value(staleKey{}).(*atomic.Bool)
+	if !ok || !stale.Load() {
+		return false
+	}
+	// Other warnings, such as that of an unverified consistency token, stay.
+	if !slices.Contains(w.Header().Values("Warning"), staleWarning) {
+		w.Header().Add("Warning", staleWarning)
+	}
+	return true
+}
+
+// degrade is a middleware for serving through store outages. It lets
+// fallbackStore mark requests it answered from the fallback snapshot.
+func (api *TodoAPI) degrade(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withStaleFlag(r.Context())))
+	})
+}
@@ -0,0 +1,308 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JSONFileBackend is the name of the in-memory backend that persists its
+// state to a JSON file.
+const JSONFileBackend = "json"
+
+// DefaultJSONFilePath is the state file used when no DSN is configured.
+const DefaultJSONFilePath = "todos.json"
+
+// FileStore is an in-memory TodoStore whose state is written to a JSON file
+// and reloaded from it on open. Writes go to a temporary file that is then
+// renamed over the state file, so a crash never leaves a partial file.
+//
+// With a zero flush interval the file is written after every mutation;
+// otherwise it is written at most once per interval and on Close. A failed
+// write after a mutation is returned as its error; the mutation stays
+// applied in memory and is written with the next one.
+type FileStore struct {
+	*TodoStore
+
+	path     string
+	interval time.Duration
+	saveMu   sync.Mutex
+
+	dirtyMu sync.Mutex
+	dirty   bool
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+var _ SnapshotStore = (*FileStore)(nil)
+
+// OpenFileStore loads the store state from path, starting empty if the file
+// does not exist. A positive interval batches writes instead of writing on
+// every mutation. A nil clock selects SystemClock.
+func OpenFileStore(path string, clock Clock, interval time.Duration) (*FileStore, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	s := &FileStore{
+		TodoStore: NewTodoStoreWithClock(clock),
+		path:      path,
+		interval:  interval,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read store file: %w", err)
+	default:
+		var state Backup
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("parse store file %s: %w", path, err)
+		}
+		s.load(state)
+	}
+
+	if interval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.flushLoop()
+	}
+
+	return s, nil
+}
+
+// Save writes the current state to the file atomically.
+func (s *FileStore) Save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	b, err := s.Backup(context.Background())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode store file: %w", err)
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// Close stops the background flush, if any, and writes pending changes.
+// It is safe to call more than once.
+func (s *FileStore) Close() error {
+	s.closing.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+	})
+	if s.takeDirty() {
+		return s.Save()
+	}
+	return nil
+}
+
+func (s *FileStore) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.takeDirty() {
+				continue
+			}
+			if err := s.Save(); err != nil {
+				s.markDirty()
+			}
+		}
+	}
+}
+
+func (s *FileStore) markDirty() {
+	s.dirtyMu.Lock()
+	s.dirty = true
+	s.dirtyMu.Unlock()
+}
+
+func (s *FileStore) takeDirty() bool {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	dirty := s.dirty
+	s.dirty = false
+	return dirty
+}
+
+// persist records a mutation, writing the file immediately when no flush
+// interval is configured.
+func (s *FileStore) persist() error {
+	if s.interval > 0 {
+		s.markDirty()
+		return nil
+	}
+	if err := s.Save(); err != nil {
+		return fmt.Errorf("write store file: %w", err)
+	}
+	return nil
+}
+
+// Create adds a new todo and persists the store.
+func (s *FileStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
+	todo, err := s.TodoStore.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persist(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// Update modifies an existing todo and persists the store.
+// It returns ErrNotFound if the todo does not exist.
+func (s *FileStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	todo, err := s.TodoStore.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persist(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// Complete marks the todo as completed and persists the store.
+// It returns ErrNotFound if the todo does not exist.
+func (s *FileStore) Complete(ctx context.Context, id int) (*Todo, error) {
+	todo, err := s.TodoStore.Complete(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persist(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// SetState moves the todo to state and persists the store.
+// It returns ErrNotFound if the todo does not exist.
+func (s *FileStore) SetState(ctx context.Context, id int, state State) (*Todo, error) {
+	todo, err := s.TodoStore.SetState(ctx, id, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persist(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// Delete removes the todo and persists the store.
+// It returns ErrNotFound if the todo does not exist.
+func (s *FileStore) Delete(ctx context.Context, id int) error {
+	if err := s.TodoStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// Merge folds sourceID into targetID and persists the store.
+// It fails like TodoStore.Merge.
+func (s *FileStore) Merge(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	todo, err := s.TodoStore.Merge(ctx, targetID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persist(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// CreateMilestone adds a milestone and persists the store.
+func (s *FileStore) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	m, err := s.TodoStore.CreateMilestone(ctx, input)
+	if err != nil {
+		return Milestone{}, err
+	}
+	if err := s.persist(); err != nil {
+		return Milestone{}, err
+	}
+	return m, nil
+}
+
+// AssignMilestone moves the todo into the milestone and persists the
+// store. It returns ErrNotFound if the milestone does not exist.
+func (s *FileStore) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	if err := s.TodoStore.AssignMilestone(ctx, milestoneID, todoID); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// UnassignMilestone removes the todo from the milestone and persists the
+// store. It returns ErrNotFound if the milestone does not exist.
+func (s *FileStore) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	if err := s.TodoStore.UnassignMilestone(ctx, milestoneID, todoID); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+// Seed adds todos if the store has never handed out an ID, and persists
+// the store if it did.
+func (s *FileStore) Seed(ctx context.Context, todos []BackupTodo) (bool, error) {
+	seeded, err := s.TodoStore.Seed(ctx, todos)
+	if !seeded || err != nil {
+		return seeded, err
+	}
+	return true, s.persist()
+}
+
+// Restore replaces the content of the store with b and persists it.
+func (s *FileStore) Restore(ctx context.Context, b Backup) error {
+	if err := s.TodoStore.Restore(ctx, b); err != nil {
+		return err
+	}
+	return s.persist()
+}
@@ -0,0 +1,104 @@
+package todo
+
+import "fmt"
+
+// Template is a HAL-FORMS template describing how to submit a state
+// transition for a resource: the method, content type, and input fields.
+type Template struct {
+	Title       string             `json:"title,omitempty"`
+	Method      string             `json:"method"`
+	ContentType string             `json:"contentType,omitempty"`
+	Target      string             `json:"target,omitempty"`
+	Properties  []TemplateProperty `json:"properties"`
+}
+
+// TemplateProperty describes a single input field of a Template. Value
+// pre-fills the field with the current state of the resource.
+type TemplateProperty struct {
+	Name      string `json:"name"`
+	Prompt    string `json:"prompt,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Required  bool   `json:"required,omitempty"`
+	MaxLength int    `json:"maxLength,omitempty"`
+	Value     string `json:"value,omitempty"`
+}
+
+// Templates maps template keys to HAL-FORMS templates. The "default" key
+// identifies the primary form of a resource.
+type Templates map[string]Template
+
+// todoInputProperties describes the fields of TodoInput accepted by create
+// and update requests, within limits.
+func todoInputProperties(limits Limits) []TemplateProperty {
+	return []TemplateProperty{
+		{Name: "title", Prompt: "Title", Type: "text", Required: true, MaxLength: limits.Title},
+		{Name: "description", Prompt: "Description", Type: "textarea", MaxLength: limits.Description},
+	}
+}
+
+// buildTodoTemplates constructs the HAL-FORMS templates for a single todo
+// resource. The update form is pre-filled with the current values, and the
+// complete form is only offered while the todo is open, like its link.
+func buildTodoTemplates(todo *Todo, baseURL string, limits Limits) Templates {
+	update := todoInputProperties(limits)
+	update[0].Value = todo.Title
+	update[1].Value = todo.Description
+
+	templates := Templates{
+		"default": {
+			Title:       "Update todo",
+			Method:      "PUT",
+			ContentType: MediaTypeJSON,
+			Target:      fmt.Sprintf("%s/todos/%d", baseURL, todo.ID),
+			Properties:  update,
+		},
+		"delete": {
+			Title:      "Delete todo",
+			Method:     "DELETE",
+			Target:     fmt.Sprintf("%s/todos/%d", baseURL, todo.ID),
+			Properties: []TemplateProperty{},
+		},
+		"merge": {
+			Title:       "Merge another todo into this one",
+			Method:      "POST",
+			ContentType: MediaTypeJSON,
+			Target:      fmt.Sprintf("%s/todos/%d/merge", baseURL, todo.ID),
+			Properties: []TemplateProperty{
+				{Name: "source_id", Prompt: "Todo to merge", Type: "number", Required: true},
+			},
+		},
+	}
+	for _, transition := range AllowedTransitions(todo.State()) {
+		templates[string(transition)] = Template{
+			Title:      transitionTitles[transition],
+			Method:     "PATCH",
+			Target:     fmt.Sprintf("%s/todos/%d/%s", baseURL, todo.ID, transition),
+			Properties: []TemplateProperty{},
+		}
+	}
+	return templates
+}
+
+// transitionTitles holds the template title of each lifecycle transition.
+var transitionTitles = map[Transition]string{
+	TransitionComplete:  "Mark todo as completed",
+	TransitionReopen:    "Reopen todo",
+	TransitionArchive:   "Archive todo",
+	TransitionUnarchive: "Unarchive todo",
+}
+
+// buildCollectionTemplates constructs the HAL-FORMS templates for the todos collection.
+func buildCollectionTemplates(baseURL string, limits Limits) Templates {
+	return Templates{
+		"default": {
+			Title:       "Create todo",
+			Method:      "POST",
+			ContentType: MediaTypeJSON,
+			Target:      fmt.Sprintf("%s/todos", baseURL),
+			Properties: append(todoInputProperties(limits),
+				TemplateProperty{Name: "source", Prompt: "Source system", Type: "text", MaxLength: maxExternalIDLength},
+				TemplateProperty{Name: "external_id", Prompt: "ID in the source system", Type: "text", MaxLength: maxExternalIDLength},
+			),
+		},
+	}
+}
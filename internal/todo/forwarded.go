@@ -0,0 +1,61 @@
+package todo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type baseURLKey struct{}
+
+// base returns the external base URL used for links in the response to r:
+// the URL derived by resolveBaseURL if enabled, the configured one otherwise.
+func (api *TodoAPI) base(r *http.Request) string {
+	if base, ok := r.Context().Value(baseURLKey{}).(string); ok {
+		return base
+	}
+	return api.baseURL
+}
+
+// resolveBaseURL is a middleware that derives the external base URL of each
+// request from the X-Forwarded-Proto, X-Forwarded-Host and
+// X-Forwarded-Prefix headers set by a reverse proxy, falling back to the
+// request's own scheme and Host. It must only be enabled behind a proxy
+// that overwrites these headers.
+func resolveBaseURL(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), baseURLKey{}, forwardedBaseURL(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// forwardedBaseURL builds the base URL for r. Malformed header values are
+// ignored in favor of the request's own values.
+func forwardedBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(firstForwarded(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := r.Host
+	if fwd := firstForwarded(r.Header.Get("X-Forwarded-Host")); fwd != "" && !strings.ContainsAny(fwd, "/\\ @") {
+		host = fwd
+	}
+
+	prefix := ""
+	if fwd := firstForwarded(r.Header.Get("X-Forwarded-Prefix")); strings.HasPrefix(fwd, "/") && !strings.HasPrefix(fwd, "//") {
+		prefix = strings.TrimRight(fwd, "/")
+	}
+
+	return scheme + "://" + host + prefix
+}
+
+// firstForwarded returns the first element of a comma-separated forwarding
+// header, which is the value set by the proxy closest to the client.
+func firstForwarded(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
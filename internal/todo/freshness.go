@@ -0,0 +1,191 @@
+package todo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/events"
+)
+
+// etag returns the strong entity tag of a response body: two responses
+// share a tag exactly when their bodies are byte-for-byte equal, so each
+// media type has its own.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// todoETag returns the strong entity tag of todo, a representation built
+// by present, served as mediaType. Its first part is the todoState of the
+// todo; its second covers what the representation adds to the todo: the
+// progress of subtasks, the milestone and the creation date formatted for
+// the client's language. Relative times, which age by the minute, are left
+// out, so clients sending Accept-Language keep getting 304 Not Modified.
+func todoETag(todo *Todo, mediaType string) string {
+	var milestone, createdAt string
+	if todo.Links.Milestone != nil {
+		milestone = todo.Links.Milestone.Href
+	}
+	if todo.Display != nil {
+		createdAt = todo.Display.CreatedAt
+	}
+	var progress SubtaskProgress
+	if todo.Subtasks != nil {
+		progress = *todo.Subtasks
+	}
+	view := sha256.Sum256(fmt.Appendf(nil, "%d/%d/%q/%q", progress.Total, progress.Completed, milestone, createdAt))
+	return `"` + todoState(todo, mediaType) + "-" + hex.EncodeToString(view[:8]) + `"`
+}
+
+// todoState identifies todo served as mediaType by its ID, version and
+// stored content. The content counts because a restore can bring back the
+// same version of a todo with other content.
+func todoState(todo *Todo, mediaType string) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "todo/%d/%d/%s/%q/%q/%t/%t/%q/%d/%q/%q/%s",
+		todo.ID, todo.Version, mediaType, todo.Title, todo.Description, todo.Completed, todo.Archived,
+		todo.Tags, todo.ParentID, todo.Source, todo.ExternalID, todo.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// tagState returns the todoState part of a strong todo ETag, or "" if tag
+// is none.
+func tagState(tag string) string {
+	inner, ok := strings.CutPrefix(tag, `"`)
+	if !ok {
+		return ""
+	}
+	state, _, _ := strings.Cut(inner, "-")
+	return state
+}
+
+// noneMatch reports whether the If-None-Match header of r matches tag, so
+// the client's copy is current and 304 Not Modified can be sent instead of
+// the body. As RFC 9110 requires, tags are compared weakly and "*" matches
+// any tag.
+func noneMatch(r *http.Request, tag string) bool {
+	for _, value := range r.Header.Values("If-None-Match") {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether the If-Modified-Since header of r is at
+// or after the Last-Modified header of w, so 304 Not Modified can be sent.
+// As RFC 9110 requires, it is ignored when r carries If-None-Match, and
+// when either time is missing or invalid.
+func notModifiedSince(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// modifiedSince reports whether at is after the If-Unmodified-Since header
+// of r, in which case the request's precondition fails. Last-Modified only
+// has second precision, so at is truncated to the second first. The header
+// is ignored when r carries If-Match, or when it is invalid.
+func modifiedSince(r *http.Request, at time.Time) bool {
+	if len(r.Header.Values("If-Match")) > 0 {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	return err == nil && at.Truncate(time.Second).After(since)
+}
+
+// precondition returns the precondition expressed by the If-Match header of
+// r or, without one, by its If-Unmodified-Since header; it returns nil if r
+// has neither.
+func (api *TodoAPI) precondition(r *http.Request) Precondition {
+	if pre := ifMatch(r); pre != nil {
+		return pre
+	}
+	if _, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err != nil {
+		return nil
+	}
+	return func(current *Todo) bool {
+		return !modifiedSince(r, api.todoModifiedAt(current))
+	}
+}
+
+// ifMatch returns the precondition expressed by the If-Match header of r,
+// or nil if r has none. It holds when the header is "*" or lists an ETag
+// of the todo's current state in the media type negotiated for r. Only the
+// todoState part of the tags is compared, strongly as RFC 9110 requires for
+// If-Match, so the language of the request and the subtasks added since
+// the todo was fetched do not matter.
+func ifMatch(r *http.Request) Precondition {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return nil
+	}
+	return func(current *Todo) bool {
+		state := todoState(current, mediaTypeFromContext(r.Context()))
+		for _, value := range values {
+			for _, candidate := range strings.Split(value, ",") {
+				candidate = strings.TrimSpace(candidate)
+				if candidate == "*" || tagState(candidate) == state {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// setLastModified sets the Last-Modified header to at, unless it is zero.
+func setLastModified(w http.ResponseWriter, at time.Time) {
+	if !at.IsZero() {
+		w.Header().Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+	}
+}
+
+// todoModifiedAt returns when todo last changed: its update time, or the
+// time of the latest retained restore if that is later, since restores
+// can bring back older versions.
+func (api *TodoAPI) todoModifiedAt(todo *Todo) time.Time {
+	if at, ok := api.changes.lastChangedAt(events.TypeTodosRestored); ok && at.After(todo.UpdatedAt) {
+		return at
+	}
+	return todo.UpdatedAt
+}
+
+// collectionModifiedAt returns when the collection last changed: the time
+// of the latest retained change, such as a deletion, or the latest update
+// time of todos if that is later.
+func (api *TodoAPI) collectionModifiedAt(todos []*Todo) time.Time {
+	latest, _ := api.changes.lastChangedAt("")
+	for _, todo := range todos {
+		if todo.UpdatedAt.After(latest) {
+			latest = todo.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// lastChangedAt returns the time of the latest retained change of type typ,
+// or of any type if typ is empty.
+func (l *changeLog) lastChangedAt(typ events.Type) (time.Time, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		if e := l.changes[i].event; typ == "" || e.Type() == typ {
+			return e.Time(), true
+		}
+	}
+	return time.Time{}, false
+}
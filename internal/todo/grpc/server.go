@@ -0,0 +1,205 @@
+// Package grpc serves the todo API over gRPC, as defined in todopb/todo.proto,
+// for internal consumers that prefer generated, typed clients. It wraps the
+// same todo.Service as the HTTP router, so both transports share the store,
+// the change feed and its events. It covers basic CRUD only: the status,
+// archived flag, tags, parent, version and update time of todos, and
+// archiving and reopening them, are only available over HTTP. Calls are not
+// authenticated, so the server should only listen where its callers are
+// trusted.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative todopb/todo.proto
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/grpc/todopb"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements todopb.TodoServiceServer on top of a todo.Service.
+type Server struct {
+	todopb.UnimplementedTodoServiceServer
+
+	service todo.Service
+}
+
+var _ todopb.TodoServiceServer = (*Server)(nil)
+
+// NewServer returns a Server backed by service.
+func NewServer(service todo.Service) *Server {
+	return &Server{service: service}
+}
+
+// Register creates a gRPC server with the todo service registered on it.
+func Register(service todo.Service, opts ...grpclib.ServerOption) *grpclib.Server {
+	s := grpclib.NewServer(opts...)
+	todopb.RegisterTodoServiceServer(s, NewServer(service))
+	return s
+}
+
+// toProto converts a todo to its wire representation.
+func toProto(t *todo.Todo) *todopb.Todo {
+	return &todopb.Todo{
+		Id:          int64(t.ID),
+		Title:       t.Title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		CreatedAt:   timestamppb.New(t.CreatedAt),
+		Source:      t.Source,
+		ExternalId:  t.ExternalID,
+	}
+}
+
+// todoID converts a request ID to a todo ID. IDs outside the range the HTTP
+// API accepts are reported as not found.
+func todoID(id int64) (int, error) {
+	if id < 1 || id > math.MaxInt32 {
+		return 0, status.Errorf(codes.NotFound, "todo with ID %d does not exist", id)
+	}
+	return int(id), nil
+}
+
+// notFound returns the error for a missing todo, naming the survivor if id
+// was merged into another todo.
+func (s *Server) notFound(ctx context.Context, id int) error {
+	if survivor, err := s.service.MergedInto(ctx, id); err == nil {
+		return status.Errorf(codes.NotFound, "todo with ID %d was merged into todo %d", id, survivor)
+	}
+	return status.Errorf(codes.NotFound, "todo with ID %d does not exist", id)
+}
+
+// statusError converts an error returned by the service for the todo with
+// the given ID to a gRPC status, as the HTTP API maps it to a status code.
+func (s *Server) statusError(ctx context.Context, id int, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, todo.ErrNotFound):
+		return s.notFound(ctx, id)
+	case errors.Is(err, todo.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, todo.ErrConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, todo.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// ListTodos returns all todos ordered by ID.
+func (s *Server) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	todos, err := s.service.ListTodos(ctx)
+	if err != nil {
+		return nil, s.statusError(ctx, 0, err)
+	}
+	resp := &todopb.ListTodosResponse{Todos: make([]*todopb.Todo, 0, len(todos))}
+	for _, t := range todos {
+		resp.Todos = append(resp.Todos, toProto(t))
+	}
+	return resp, nil
+}
+
+// GetTodo returns a todo by ID.
+func (s *Server) GetTodo(ctx context.Context, req *todopb.GetTodoRequest) (*todopb.Todo, error) {
+	id, err := todoID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.service.GetTodo(ctx, id)
+	if err != nil {
+		return nil, s.statusError(ctx, id, err)
+	}
+	return toProto(t), nil
+}
+
+// CreateTodo creates a todo. A todo with the same source and external ID is
+// not duplicated; the call fails with AlreadyExists instead.
+func (s *Server) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.Todo, error) {
+	input := todo.TodoInput{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Source:      req.GetSource(),
+		ExternalID:  req.GetExternalId(),
+	}
+	if err := todo.ValidateInput(&input, s.service.Limits()); err != nil {
+		return nil, s.statusError(ctx, 0, err)
+	}
+
+	t, outcome, err := s.service.UpsertTodo(ctx, input, todo.ConflictSkip)
+	if err != nil {
+		return nil, s.statusError(ctx, 0, err)
+	}
+	if outcome == todo.UpsertSkipped {
+		return nil, status.Errorf(codes.AlreadyExists, "todo %d was already imported from %s with external_id %s", t.ID, req.GetSource(), req.GetExternalId())
+	}
+	return toProto(t), nil
+}
+
+// UpdateTodo replaces the title and description of a todo.
+func (s *Server) UpdateTodo(ctx context.Context, req *todopb.UpdateTodoRequest) (*todopb.Todo, error) {
+	id, err := todoID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.service.UpdateTodo(ctx, id, todo.TodoInput{Title: req.GetTitle(), Description: req.GetDescription()})
+	if err != nil {
+		return nil, s.statusError(ctx, id, err)
+	}
+	return toProto(t), nil
+}
+
+// CompleteTodo marks a todo as completed.
+func (s *Server) CompleteTodo(ctx context.Context, req *todopb.CompleteTodoRequest) (*todopb.Todo, error) {
+	id, err := todoID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.service.TransitionTodo(ctx, id, todo.TransitionComplete)
+	if err != nil {
+		return nil, s.statusError(ctx, id, err)
+	}
+	return toProto(t), nil
+}
+
+// DeleteTodo removes a todo.
+func (s *Server) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*emptypb.Empty, error) {
+	id, err := todoID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.DeleteTodo(ctx, id); err != nil {
+		return nil, s.statusError(ctx, id, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// MergeTodos folds the source todo into the target and returns the target.
+func (s *Server) MergeTodos(ctx context.Context, req *todopb.MergeTodosRequest) (*todopb.Todo, error) {
+	if req.GetTargetId() == req.GetSourceId() {
+		return nil, status.Error(codes.InvalidArgument, "a todo cannot be merged into itself")
+	}
+	targetID, err := todoID(req.GetTargetId())
+	if err != nil {
+		return nil, err
+	}
+	sourceID, err := todoID(req.GetSourceId())
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.service.MergeTodos(ctx, targetID, sourceID)
+	if err != nil {
+		if _, getErr := s.service.GetTodo(ctx, targetID); getErr != nil {
+			return nil, s.statusError(ctx, targetID, err)
+		}
+		return nil, s.statusError(ctx, sourceID, err)
+	}
+	return toProto(t), nil
+}
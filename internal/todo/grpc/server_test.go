@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/grpc/todopb"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves service over an in-memory connection and returns a client.
+func newClient(t *testing.T, service todo.Service) todopb.TodoServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := Register(service)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpclib.NewClient("passthrough:///bufconn",
+		grpclib.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return todopb.NewTodoServiceClient(conn)
+}
+
+func TestTodoService(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, todo.NewService(todo.NewTodoStore()))
+
+	created, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Title: "Write proto", Description: "CRUD", Source: "jira", ExternalId: "PROJ-1"})
+	if err != nil {
+		t.Fatalf("CreateTodo failed: %v", err)
+	}
+	if created.GetId() == 0 || created.GetCreatedAt() == nil || created.GetExternalId() != "PROJ-1" {
+		t.Fatalf("unexpected created todo: %v", created)
+	}
+	other, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Title: "Other", Description: "more"})
+	if err != nil {
+		t.Fatalf("CreateTodo failed: %v", err)
+	}
+
+	got, err := client.GetTodo(ctx, &todopb.GetTodoRequest{Id: created.GetId()})
+	if err != nil || got.GetTitle() != "Write proto" {
+		t.Fatalf("unexpected GetTodo result: %v, %v", got, err)
+	}
+
+	updated, err := client.UpdateTodo(ctx, &todopb.UpdateTodoRequest{Id: created.GetId(), Title: "Write todo.proto", Description: "CRUD"})
+	if err != nil || updated.GetTitle() != "Write todo.proto" {
+		t.Fatalf("unexpected UpdateTodo result: %v, %v", updated, err)
+	}
+
+	completed, err := client.CompleteTodo(ctx, &todopb.CompleteTodoRequest{Id: created.GetId()})
+	if err != nil || !completed.GetCompleted() {
+		t.Fatalf("unexpected CompleteTodo result: %v, %v", completed, err)
+	}
+
+	merged, err := client.MergeTodos(ctx, &todopb.MergeTodosRequest{TargetId: created.GetId(), SourceId: other.GetId()})
+	if err != nil || merged.GetDescription() != "CRUD\n\nmore" {
+		t.Fatalf("unexpected MergeTodos result: %v, %v", merged, err)
+	}
+
+	list, err := client.ListTodos(ctx, &todopb.ListTodosRequest{})
+	if err != nil || len(list.GetTodos()) != 1 {
+		t.Fatalf("unexpected ListTodos result: %v, %v", list, err)
+	}
+
+	if _, err := client.DeleteTodo(ctx, &todopb.DeleteTodoRequest{Id: created.GetId()}); err != nil {
+		t.Fatalf("DeleteTodo failed: %v", err)
+	}
+	if _, err := client.GetTodo(ctx, &todopb.GetTodoRequest{Id: created.GetId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound after delete, got %v", err)
+	}
+}
+
+func TestTodoServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, todo.NewService(todo.NewTodoStore()))
+
+	first, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Title: "First", Source: "jira", ExternalId: "PROJ-1"})
+	if err != nil {
+		t.Fatalf("CreateTodo failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"create without title", func() error {
+			_, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"create with external ID but no source", func() error {
+			_, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Title: "x", ExternalId: "PROJ-2"})
+			return err
+		}, codes.InvalidArgument},
+		{"create duplicate external ID", func() error {
+			_, err := client.CreateTodo(ctx, &todopb.CreateTodoRequest{Title: "x", Source: "jira", ExternalId: "PROJ-1"})
+			return err
+		}, codes.AlreadyExists},
+		{"get missing", func() error {
+			_, err := client.GetTodo(ctx, &todopb.GetTodoRequest{Id: 999})
+			return err
+		}, codes.NotFound},
+		{"get invalid ID", func() error {
+			_, err := client.GetTodo(ctx, &todopb.GetTodoRequest{Id: -1})
+			return err
+		}, codes.NotFound},
+		{"update without title", func() error {
+			_, err := client.UpdateTodo(ctx, &todopb.UpdateTodoRequest{Id: first.GetId()})
+			return err
+		}, codes.InvalidArgument},
+		{"complete missing", func() error {
+			_, err := client.CompleteTodo(ctx, &todopb.CompleteTodoRequest{Id: 999})
+			return err
+		}, codes.NotFound},
+		{"delete missing", func() error {
+			_, err := client.DeleteTodo(ctx, &todopb.DeleteTodoRequest{Id: 999})
+			return err
+		}, codes.NotFound},
+		{"merge into itself", func() error {
+			_, err := client.MergeTodos(ctx, &todopb.MergeTodosRequest{TargetId: first.GetId(), SourceId: first.GetId()})
+			return err
+		}, codes.InvalidArgument},
+		{"merge missing source", func() error {
+			_, err := client.MergeTodos(ctx, &todopb.MergeTodosRequest{TargetId: first.GetId(), SourceId: 999})
+			return err
+		}, codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSharesServiceWithRouter(t *testing.T) {
+	var service todo.Service
+	r := todo.NewRouter("http://example.com",
+		todo.WithSeeder(func(todo.Service) {}),
+		todo.WithServiceHook(func(s todo.Service) { service = s }),
+	)
+	client := newClient(t, service)
+
+	if _, err := client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Title: "Over gRPC"}); err != nil {
+		t.Fatalf("CreateTodo failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/changes", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var feed todo.ChangeFeed
+	if err := json.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to unmarshal change feed: %v", err)
+	}
+	if len(feed.Changes) != 1 || feed.Changes[0].Todo.Title != "Over gRPC" {
+		t.Fatalf("expected the gRPC change in the HTTP change feed, got %+v", feed.Changes)
+	}
+}
+
+// downStore is a store whose backend is unreachable.
+type downStore struct {
+	todo.Store
+}
+
+func (downStore) Create(context.Context, todo.TodoInput) (*todo.Todo, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReportsStoreOutage(t *testing.T) {
+	var service todo.Service
+	todo.NewRouter("http://example.com",
+		todo.WithStore(downStore{Store: todo.NewTodoStore()}),
+		todo.WithSeeder(func(todo.Service) {}),
+		todo.WithServiceHook(func(s todo.Service) { service = s }),
+	)
+	client := newClient(t, service)
+
+	_, err := client.CreateTodo(context.Background(), &todopb.CreateTodoRequest{Title: "Over gRPC"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected %v, got %v", codes.Unavailable, err)
+	}
+}
@@ -0,0 +1,125 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// halLinkProperties are the link object properties defined by HAL. Others,
+// such as method, are dropped; HAL-FORMS _templates describe the requests.
+var halLinkProperties = []string{"href", "templated", "type", "deprecation", "name", "profile", "title", "hreflang"}
+
+// halCollections names the arrays that are embedded even when empty, so
+// clients find an empty collection in the usual place.
+var halCollections = map[string]bool{"todos": true, "milestones": true}
+
+// toHAL converts the JSON representation body to HAL (application/hal+json):
+//
+//   - link objects keep only the properties HAL defines;
+//   - arrays of resources, such as the todos of a collection, move to
+//     _embedded under the same name;
+//   - _meta properties become properties of the resource itself.
+func toHAL(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(halResource(doc)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// halResource converts v, and the resources nested in it, to HAL.
+func halResource(v any) any {
+	if items, ok := v.([]any); ok {
+		for i := range items {
+			items[i] = halResource(items[i])
+		}
+		return items
+	}
+	resource, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+
+	embedded := map[string]any{}
+	for key, value := range resource {
+		switch {
+		case key == "_links":
+			resource[key] = halLinks(value)
+		case key == "_meta":
+			if meta, ok := value.(map[string]any); ok {
+				delete(resource, key)
+				for name, property := range meta {
+					resource[name] = property
+				}
+			}
+		case isResourceArray(value) || (halCollections[key] && isEmptyArray(value)):
+			delete(resource, key)
+			embedded[key] = halResource(value)
+		default:
+			resource[key] = halResource(value)
+		}
+	}
+	if len(embedded) > 0 {
+		resource["_embedded"] = embedded
+	}
+	return resource
+}
+
+// halLinks strips the link objects of a _links object down to HAL's
+// link properties and drops absent links.
+func halLinks(v any) any {
+	links, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	for rel, value := range links {
+		if value == nil {
+			delete(links, rel)
+			continue
+		}
+		link, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		stripped := map[string]any{}
+		for _, name := range halLinkProperties {
+			if property, ok := link[name]; ok {
+				stripped[name] = property
+			}
+		}
+		links[rel] = stripped
+	}
+	return links
+}
+
+// isResourceArray reports whether v is a non-empty array of objects that
+// all carry _links.
+func isResourceArray(v any) bool {
+	items, ok := v.([]any)
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := object["_links"]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyArray reports whether v is an empty array.
+func isEmptyArray(v any) bool {
+	items, ok := v.([]any)
+	return ok && len(items) == 0
+}
@@ -0,0 +1,175 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// htmlContentType is the Content-Type of HTML responses.
+const htmlContentType = MediaTypeHTML + "; charset=utf-8"
+
+// htmlNode is one value of a JSON document, decoded in document order for
+// rendering. Kind is "object", "array", "link" or "scalar".
+type htmlNode struct {
+	Key       string
+	Kind      string
+	Value     string
+	Href      string
+	Method    string
+	Templated bool
+	Children  []htmlNode
+}
+
+// htmlPage is the data of htmlTemplate.
+type htmlPage struct {
+	Title string
+	Root  string
+	Body  htmlNode
+	JSON  string
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.4; }
+dl { margin: 0; padding-left: 1rem; border-left: 2px solid #ddd; }
+dt { font-weight: bold; margin-top: .25rem; }
+dd { margin-left: 1rem; }
+small { color: #666; }
+pre { overflow-x: auto; background: #f6f6f6; padding: .5rem; }
+</style>
+</head>
+<body>
+<nav><a href="{{.Root}}">Todo API</a></nav>
+<h1>{{.Title}}</h1>
+{{template "node" .Body}}
+<details><summary>JSON</summary><pre>{{.JSON}}</pre></details>
+</body>
+</html>
+{{define "node"}}
+{{- if and (eq .Kind "link") .Templated}}<code>{{.Href}}</code> <small>URI template</small>
+{{- else if eq .Kind "link"}}<a href="{{.Href}}">{{.Href}}</a>{{if .Method}} <small>{{.Method}}</small>{{end}}
+{{- else if eq .Kind "object"}}<dl>{{range .Children}}<dt>{{.Key}}</dt><dd>{{template "node" .}}</dd>{{end}}</dl>
+{{- else if eq .Kind "array"}}<ol>{{range .Children}}<li>{{template "node" .}}</li>{{end}}</ol>
+{{- else}}{{.Value}}{{end}}
+{{- end}}`))
+
+// decodeHTMLNode reads the next JSON value from dec, keeping object keys in
+// document order. Objects with an href are links; their method is shown
+// unless it is GET, and URI templates are shown as text.
+func decodeHTMLNode(dec *json.Decoder) (htmlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return htmlNode{}, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		node := htmlNode{Kind: "object"}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return htmlNode{}, err
+			}
+			child, err := decodeHTMLNode(dec)
+			if err != nil {
+				return htmlNode{}, err
+			}
+			child.Key = key.(string)
+			node.Children = append(node.Children, child)
+		}
+		if _, err := dec.Token(); err != nil {
+			return htmlNode{}, err
+		}
+		return asLink(node), nil
+	case json.Delim('['):
+		node := htmlNode{Kind: "array"}
+		for dec.More() {
+			child, err := decodeHTMLNode(dec)
+			if err != nil {
+				return htmlNode{}, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		if _, err := dec.Token(); err != nil {
+			return htmlNode{}, err
+		}
+		return node, nil
+	case nil:
+		return htmlNode{Kind: "scalar", Value: "null"}, nil
+	default:
+		return htmlNode{Kind: "scalar", Value: fmt.Sprint(tok)}, nil
+	}
+}
+
+// asLink turns an object holding only an href, a method and the templated
+// flag into a link node.
+func asLink(node htmlNode) htmlNode {
+	link := htmlNode{Kind: "link"}
+	for _, child := range node.Children {
+		switch child.Key {
+		case "href":
+			link.Href = child.Value
+		case "method":
+			if child.Value != http.MethodGet {
+				link.Method = child.Value
+			}
+		case "templated":
+			link.Templated = child.Value == "true"
+		default:
+			return node
+		}
+	}
+	if link.Href == "" {
+		return node
+	}
+	return link
+}
+
+// htmlTitle returns the page heading for payload.
+func htmlTitle(r *http.Request, payload any) string {
+	switch p := payload.(type) {
+	case APIRoot:
+		return "Todo API"
+	case Todo:
+		return p.Title
+	case TodoCollection:
+		return "Todos"
+	case ErrorResponse:
+		return p.Error
+	}
+	return strings.TrimPrefix(r.URL.Path, "/")
+}
+
+// renderHTML renders the JSON encoding of payload as a browsable HTML page
+// whose links can be followed.
+func (api *TodoAPI) renderHTML(r *http.Request, payload any, body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	root, err := decodeHTMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = htmlTemplate.Execute(&buf, htmlPage{
+		Title: htmlTitle(r, payload),
+		Root:  api.base(r),
+		Body:  root,
+		JSON:  indented.String(),
+	})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,281 @@
+package todo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/efrem/windsurf/internal/query"
+)
+
+const (
+	// MediaTypeCSV is accepted by POST /todos/import for spreadsheet uploads.
+	MediaTypeCSV = "text/csv"
+
+	// MaxImportRows is the largest number of rows a single import may contain.
+	MaxImportRows = 1000
+	// MaxImportJobRows is the largest number of rows an import job may contain.
+	MaxImportJobRows = 100000
+)
+
+// ImportRowResult reports the outcome of one row of an import. Rows are
+// numbered from 1 in upload order, not counting a CSV header line.
+type ImportRowResult struct {
+	Row     int           `json:"row"`
+	ID      int           `json:"id,omitempty"`
+	Outcome UpsertOutcome `json:"outcome,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	// Warnings lists findings about the imported todo that did not fail
+	// the row.
+	Warnings []FieldError `json:"warnings,omitempty"`
+	Links    *Links       `json:"_links,omitempty"`
+}
+
+// ImportResult is the response of POST /todos/import.
+type ImportResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Results []ImportRowResult `json:"results"`
+	Links   Links             `json:"_links"`
+}
+
+// tooManyRows is returned by the import decoders when an upload exceeds
+// its limit of rows.
+func tooManyRows(limit int) error {
+	return fmt.Errorf("An import may contain at most %d rows", limit)
+}
+
+// importOptions are the query parameters shared by the import endpoints.
+type importOptions struct {
+	// source is applied to rows that have an external ID but no source.
+	source   string
+	strategy ConflictStrategy
+}
+
+// parseImportOptions reads the source and on_conflict query parameters.
+func parseImportOptions(r *http.Request) (importOptions, error) {
+	params := query.New(r.URL.Query())
+	opts := importOptions{
+		source:   params.String("source", ""),
+		strategy: ConflictStrategy(params.Enum("on_conflict", string(ConflictSkip), ConflictStrategies...)),
+	}
+	return opts, params.Err()
+}
+
+// ImportTodos handles POST /todos/import and creates one todo per row of a
+// JSON array or CSV upload. Valid rows are imported even if other rows fail
+// validation; the per-row results report the outcome or the error. Rows
+// with an external ID that was imported before are handled according to
+// the on_conflict query parameter instead of being duplicated.
+func (api *TodoAPI) ImportTodos(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		api.sendQueryError(w, r, err)
+		return
+	}
+
+	inputs, ok := api.readImport(w, r, MaxImportRows)
+	if !ok {
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.importInputs(r, inputs, opts))
+}
+
+// readImport decodes the rows of a JSON or CSV import body, at most limit.
+// On failure it writes the error response and returns false.
+func (api *TodoAPI) readImport(w http.ResponseWriter, r *http.Request, limit int) ([]TodoInput, bool) {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var inputs []TodoInput
+	var err error
+	switch {
+	case jsonContentType(contentType):
+		inputs, err = decodeJSONImport(r.Body, limit)
+	case mediaType == MediaTypeCSV:
+		inputs, err = decodeCSVImport(r.Body, limit)
+	default:
+		api.sendError(w, r, http.StatusUnsupportedMediaType, "Unsupported media type",
+			"Imports must be sent as "+MediaTypeJSON+" encoded in UTF-8 or as "+MediaTypeCSV)
+		return nil, false
+	}
+	if err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid import", err.Error())
+		return nil, false
+	}
+	return inputs, true
+}
+
+// importInputs upserts a todo for every valid input and reports the
+// outcome of each row.
+func (api *TodoAPI) importInputs(r *http.Request, inputs []TodoInput, opts importOptions) ImportResult {
+	result := ImportResult{
+		Results: make([]ImportRowResult, 0, len(inputs)),
+		Links:   buildErrorLinks(api.base(r)),
+	}
+	for i, input := range inputs {
+		row := api.importRow(r.Context(), api.base(r), i+1, input, opts)
+		result.count(row)
+		result.Results = append(result.Results, row)
+	}
+	return result
+}
+
+// importRow validates input and upserts its todo, reporting the outcome as
+// the given row number. Todo links are built from baseURL.
+func (api *TodoAPI) importRow(ctx context.Context, baseURL string, number int, input TodoInput, opts importOptions) ImportRowResult {
+	row := ImportRowResult{Row: number}
+	if input.ExternalID != "" && input.Source == "" {
+		input.Source = opts.source
+	}
+	if err := ValidateInput(&input, api.service.Limits()); err != nil {
+		row.Error = err.Error()
+	} else if _, ok := ParseState(string(input.Status)); input.Status != "" && !ok {
+		row.Error = fmt.Sprintf("Status must be one of %s, %s or %s", StateOpen, StateCompleted, StateArchived)
+	} else if input.ParentID != nil {
+		row.Error = "Parent_id cannot be imported; set it with PUT /todos/{id}"
+	}
+	if row.Error != "" {
+		return row
+	}
+
+	todo, outcome, err := api.service.UpsertTodo(ctx, input, opts.strategy)
+	if err != nil {
+		_, _, row.Error = describeTodoError(0, err)
+		return row
+	}
+	row.ID = todo.ID
+	row.Outcome = outcome
+	row.Warnings = todoWarnings(todo, api.service.Limits())
+	row.Links = &Links{
+		Self: &Link{Href: fmt.Sprintf("%s/todos/%d", baseURL, todo.ID)},
+	}
+	return row
+}
+
+// count adds the outcome of row to the totals of res.
+func (res *ImportResult) count(row ImportRowResult) {
+	switch {
+	case row.Error != "":
+		res.Failed++
+	case row.Outcome == UpsertCreated:
+		res.Created++
+	case row.Outcome == UpsertUpdated:
+		res.Updated++
+	case row.Outcome == UpsertSkipped:
+		res.Skipped++
+	}
+}
+
+// decodeJSONImport reads a JSON array of at most limit todo inputs.
+func decodeJSONImport(body io.Reader, limit int) ([]TodoInput, error) {
+	var inputs []TodoInput
+	if err := json.NewDecoder(body).Decode(&inputs); err != nil {
+		return nil, errors.New("Request body must be a JSON array of todos")
+	}
+	if len(inputs) > limit {
+		return nil, tooManyRows(limit)
+	}
+	return inputs, nil
+}
+
+// decodeCSVImport reads CSV with a header line naming the columns. A title
+// column is required; description, source, external_id and status are
+// optional and other columns are ignored. At most limit rows are read.
+func decodeCSVImport(body io.Reader, limit int) ([]TodoInput, error) {
+	header, records, err := readCSV(body, limit)
+	if err != nil {
+		return nil, err
+	}
+	cols := importColumns{
+		title:       columnIndex(header, "title"),
+		description: columnIndex(header, "description"),
+		source:      columnIndex(header, "source"),
+		externalID:  columnIndex(header, "external_id"),
+		status:      columnIndex(header, "status"),
+	}
+	if cols.title < 0 {
+		return nil, errors.New("CSV header must contain a title column")
+	}
+	return mapRecords(records, cols), nil
+}
+
+// readCSV reads the header line and at most limit records of a CSV upload.
+// Header names are trimmed and stripped of a UTF-8 byte order mark.
+func readCSV(body io.Reader, limit int) ([]string, [][]string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("CSV upload must start with a header line")
+	}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+	}
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return header, records, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Malformed CSV: %v", err)
+		}
+		if len(records) == limit {
+			return nil, nil, tooManyRows(limit)
+		}
+		records = append(records, record)
+	}
+}
+
+// columnIndex returns the index of the column called name, ignoring case,
+// or -1 if there is none.
+func columnIndex(header []string, name string) int {
+	for i, column := range header {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// importColumns holds the CSV column index of each todo field. A negative
+// index leaves the field empty.
+type importColumns struct {
+	title, description, source, externalID, status int
+}
+
+// mapRecords converts CSV records to todo inputs using the given columns.
+func mapRecords(records [][]string, cols importColumns) []TodoInput {
+	inputs := make([]TodoInput, 0, len(records))
+	for _, record := range records {
+		inputs = append(inputs, TodoInput{
+			Title:       field(record, cols.title),
+			Description: field(record, cols.description),
+			Source:      field(record, cols.source),
+			ExternalID:  field(record, cols.externalID),
+			Status:      State(strings.ToLower(field(record, cols.status))),
+		})
+	}
+	return inputs
+}
+
+// field returns column i of record, or "" if the record is shorter.
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
@@ -0,0 +1,295 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// importBatchSize is the number of rows a worker takes at a time.
+	importBatchSize = 100
+	// importWorkers is the number of workers processing the batches of a job.
+	importWorkers = 4
+	// maxImportJobs is the number of jobs that may run at once.
+	maxImportJobs = 10
+	// importJobTTL is how long a finished job can still be fetched.
+	importJobTTL = time.Hour
+)
+
+// ImportJobStatus is the state of an import job.
+type ImportJobStatus string
+
+const (
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobCancelled ImportJobStatus = "cancelled"
+)
+
+// ImportJob reports the progress of an import running in the background.
+// Errors lists the failed rows so far, ordered by row; rows that were
+// imported are only counted.
+type ImportJob struct {
+	ID         string            `json:"id"`
+	Status     ImportJobStatus   `json:"status"`
+	Total      int               `json:"total"`
+	Processed  int               `json:"processed"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Errors     []ImportRowResult `json:"errors"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Links      ImportJobLinks    `json:"_links"`
+}
+
+type ImportJobLinks struct {
+	Self   *Link `json:"self"`
+	Cancel *Link `json:"cancel,omitempty"`
+	Todos  *Link `json:"todos"`
+}
+
+// importJob is the state of a job shared by its workers and the handlers.
+type importJob struct {
+	mu         sync.Mutex
+	status     ImportJobStatus
+	total      int
+	result     ImportResult
+	startedAt  time.Time
+	finishedAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// record adds the outcome of a processed row. Only failed rows are kept.
+func (j *importJob) record(row ImportRowResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.result.count(row)
+	if row.Error != "" {
+		j.result.Results = append(j.result.Results, row)
+	}
+}
+
+// processed returns the number of rows processed so far. j.mu must be held.
+func (j *importJob) processed() int {
+	return j.result.Created + j.result.Updated + j.result.Skipped + j.result.Failed
+}
+
+// finish marks the job completed, or cancelled if rows were left out.
+func (j *importJob) finish(at time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = ImportJobCompleted
+	if j.processed() < j.total {
+		j.status = ImportJobCancelled
+	}
+	j.finishedAt = at
+}
+
+// importJobs keeps running jobs, and finished jobs until they expire.
+type importJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*importJob
+}
+
+func newImportJobs() *importJobs {
+	return &importJobs{jobs: make(map[string]*importJob)}
+}
+
+// add stores j under a new random ID. The boolean is false if too many
+// jobs are running.
+func (s *importJobs) add(j *importJob, now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := 0
+	for id, job := range s.jobs {
+		job.mu.Lock()
+		switch {
+		case job.status == ImportJobRunning:
+			running++
+		case !now.Before(job.finishedAt.Add(importJobTTL)):
+			delete(s.jobs, id)
+		}
+		job.mu.Unlock()
+	}
+	if running >= maxImportJobs {
+		return "", false
+	}
+
+	id := newImportID()
+	s.jobs[id] = j
+	return id, true
+}
+
+// get returns the job with the given ID, unless it expired.
+func (s *importJobs) get(id string, now time.Time) (*importJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != ImportJobRunning && !now.Before(j.finishedAt.Add(importJobTTL)) {
+		return nil, false
+	}
+	return j, true
+}
+
+// runImportJob imports inputs in batches spread over a pool of workers
+// until they are done or ctx is cancelled. The service serializes the
+// upserts, so rows sharing an external ID are never imported twice.
+func (api *TodoAPI) runImportJob(ctx context.Context, j *importJob, baseURL string, inputs []TodoInput, opts importOptions) {
+	defer close(j.done)
+	defer j.cancel()
+
+	batches := make(chan int)
+	var wg sync.WaitGroup
+	for range importWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				for i := start; i < min(start+importBatchSize, len(inputs)); i++ {
+					if ctx.Err() != nil {
+						break
+					}
+					j.record(api.importRow(ctx, baseURL, i+1, inputs[i], opts))
+				}
+			}
+		}()
+	}
+
+feed:
+	for start := 0; start < len(inputs); start += importBatchSize {
+		select {
+		case batches <- start:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(batches)
+	wg.Wait()
+	j.finish(api.clock.Now().UTC())
+}
+
+// presentImportJob builds the response for the job with the given ID.
+func (api *TodoAPI) presentImportJob(r *http.Request, id string, j *importJob) ImportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	href := fmt.Sprintf("%s/todos/import/jobs/%s", api.base(r), id)
+	job := ImportJob{
+		ID:        id,
+		Status:    j.status,
+		Total:     j.total,
+		Processed: j.processed(),
+		Created:   j.result.Created,
+		Updated:   j.result.Updated,
+		Skipped:   j.result.Skipped,
+		Failed:    j.result.Failed,
+		Errors:    slices.Clone(j.result.Results),
+		StartedAt: j.startedAt,
+		Links: ImportJobLinks{
+			Self:  &Link{Href: href, Method: "GET"},
+			Todos: &Link{Href: fmt.Sprintf("%s/todos", api.base(r)), Method: "GET"},
+		},
+	}
+	// Workers finish batches in any order.
+	slices.SortFunc(job.Errors, func(a, b ImportRowResult) int { return a.Row - b.Row })
+	if job.Errors == nil {
+		job.Errors = []ImportRowResult{}
+	}
+	if j.status == ImportJobRunning {
+		job.Links.Cancel = &Link{Href: href, Method: "DELETE"}
+	} else {
+		finishedAt := j.finishedAt
+		job.FinishedAt = &finishedAt
+	}
+	return job
+}
+
+// sendImportJobNotFound writes the 404 response for unknown or expired jobs.
+func (api *TodoAPI) sendImportJobNotFound(w http.ResponseWriter, r *http.Request, id string) {
+	api.sendError(w, r, http.StatusNotFound, "Import job not found", fmt.Sprintf("Import job %s does not exist or has expired", id))
+}
+
+// CreateImportJob handles POST /todos/import/jobs. It accepts the same
+// bodies and query parameters as POST /todos/import, up to MaxImportJobRows
+// rows, and imports them in the background. The response is the job, whose
+// self link reports the progress.
+func (api *TodoAPI) CreateImportJob(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		api.sendQueryError(w, r, err)
+		return
+	}
+
+	inputs, ok := api.readImport(w, r, MaxImportJobRows)
+	if !ok {
+		return
+	}
+
+	// The job outlives the request, so it must not use its context.
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &importJob{
+		status:    ImportJobRunning,
+		total:     len(inputs),
+		startedAt: api.clock.Now().UTC(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	id, ok := api.jobs.add(j, j.startedAt)
+	if !ok {
+		cancel()
+		api.sendError(w, r, http.StatusServiceUnavailable, "Too many import jobs", "Too many imports are running; try again later")
+		return
+	}
+	go api.runImportJob(ctx, j, api.base(r), inputs, opts)
+
+	job := api.presentImportJob(r, id, j)
+	w.Header().Set("Location", job.Links.Self.Href)
+	api.respond(w, r, http.StatusAccepted, job)
+}
+
+// GetImportJob handles GET /todos/import/jobs/{jobID}.
+func (api *TodoAPI) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "jobID")
+	j, ok := api.jobs.get(id, api.clock.Now())
+	if !ok {
+		api.sendImportJobNotFound(w, r, id)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentImportJob(r, id, j))
+}
+
+// CancelImportJob handles DELETE /todos/import/jobs/{jobID}. It stops a
+// running job once the rows in progress are done; rows already imported
+// are kept. The response is the final state of the job, also when it had
+// finished already.
+func (api *TodoAPI) CancelImportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "jobID")
+	j, ok := api.jobs.get(id, api.clock.Now())
+	if !ok {
+		api.sendImportJobNotFound(w, r, id)
+		return
+	}
+
+	j.cancel()
+	<-j.done
+	api.respond(w, r, http.StatusOK, api.presentImportJob(r, id, j))
+}
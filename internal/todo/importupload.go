@@ -0,0 +1,327 @@
+package todo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// importUploadTTL is how long an upload waits for its mapping to be confirmed.
+	importUploadTTL = time.Hour
+	// maxImportUploads is the number of unconfirmed uploads kept at once.
+	maxImportUploads = 100
+	// importSampleRows is the number of rows returned with an upload preview.
+	importSampleRows = 5
+)
+
+// importColumnSynonyms lists, per todo field, header names that are proposed
+// as its column, in order of preference.
+var importColumnSynonyms = map[string][]string{
+	"title":       {"title", "name", "task", "summary", "subject", "todo"},
+	"description": {"description", "notes", "note", "details", "body", "comment", "comments"},
+	"source":      {"source", "system", "origin"},
+	"external_id": {"external_id", "external id", "key", "id"},
+	"status":      {"status", "state"},
+}
+
+// ImportMapping names the CSV columns used for each todo field. Only the
+// title is required; empty columns leave their field empty.
+type ImportMapping struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+	ExternalID  string `json:"external_id"`
+	Status      string `json:"status"`
+}
+
+// ImportUpload is a CSV upload waiting for its column mapping to be confirmed.
+type ImportUpload struct {
+	ID        string            `json:"id"`
+	Columns   []string          `json:"columns"`
+	Rows      int               `json:"rows"`
+	Sample    [][]string        `json:"sample"`
+	Mapping   ImportMapping     `json:"mapping"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Links     ImportUploadLinks `json:"_links"`
+}
+
+type ImportUploadLinks struct {
+	Self    *Link `json:"self"`
+	Confirm *Link `json:"confirm"`
+	Cancel  *Link `json:"cancel"`
+}
+
+// pendingImport is a stored upload.
+type pendingImport struct {
+	header    []string
+	records   [][]string
+	mapping   ImportMapping
+	expiresAt time.Time
+}
+
+// importUploads keeps unconfirmed uploads in memory until they are
+// confirmed, cancelled, or expire.
+type importUploads struct {
+	mu      sync.Mutex
+	pending map[string]*pendingImport
+}
+
+func newImportUploads() *importUploads {
+	return &importUploads{pending: make(map[string]*pendingImport)}
+}
+
+// add stores p under a new random ID. The boolean is false if too many
+// uploads are pending.
+func (u *importUploads) add(p *pendingImport, now time.Time) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for id, pending := range u.pending {
+		if !now.Before(pending.expiresAt) {
+			delete(u.pending, id)
+		}
+	}
+	if len(u.pending) >= maxImportUploads {
+		return "", false
+	}
+
+	id := newImportID()
+	u.pending[id] = p
+	return id, true
+}
+
+// newImportID returns a random ID for an upload or import job.
+func newImportID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// get returns the unexpired upload with the given ID.
+func (u *importUploads) get(id string, now time.Time) (*pendingImport, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.pending[id]
+	if !ok || !now.Before(p.expiresAt) {
+		return nil, false
+	}
+	return p, true
+}
+
+// take removes and returns the unexpired upload with the given ID.
+func (u *importUploads) take(id string, now time.Time) (*pendingImport, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p, ok := u.pending[id]
+	delete(u.pending, id)
+	if !ok || !now.Before(p.expiresAt) {
+		return nil, false
+	}
+	return p, true
+}
+
+// proposeMapping picks a column for each field from its synonyms, preferring
+// exact header matches over headers that merely contain a synonym as a word
+// ("Task Name"). If no column looks like a title, the first one is proposed.
+func proposeMapping(header []string) ImportMapping {
+	pick := func(field string) string {
+		for _, name := range importColumnSynonyms[field] {
+			if i := columnIndex(header, name); i >= 0 {
+				return header[i]
+			}
+		}
+		for _, name := range importColumnSynonyms[field] {
+			for _, column := range header {
+				words := strings.FieldsFunc(strings.ToLower(column), func(r rune) bool {
+					return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+				})
+				if slices.Contains(words, name) {
+					return column
+				}
+			}
+		}
+		return ""
+	}
+
+	mapping := ImportMapping{
+		Title:       pick("title"),
+		Description: pick("description"),
+		Source:      pick("source"),
+		ExternalID:  pick("external_id"),
+		Status:      pick("status"),
+	}
+	if mapping.Title == "" && len(header) > 0 {
+		mapping.Title = header[0]
+	}
+	// A column feeds at most one field; the title keeps its pick.
+	used := map[string]bool{strings.ToLower(mapping.Title): true}
+	for _, column := range []*string{&mapping.Description, &mapping.Source, &mapping.ExternalID, &mapping.Status} {
+		if used[strings.ToLower(*column)] {
+			*column = ""
+		}
+		if *column != "" {
+			used[strings.ToLower(*column)] = true
+		}
+	}
+	return mapping
+}
+
+// presentImportUpload builds the response for the upload with the given ID.
+func (api *TodoAPI) presentImportUpload(r *http.Request, id string, p *pendingImport) ImportUpload {
+	href := fmt.Sprintf("%s/todos/import/uploads/%s", api.base(r), id)
+	sample := p.records[:min(importSampleRows, len(p.records))]
+	return ImportUpload{
+		ID:        id,
+		Columns:   p.header,
+		Rows:      len(p.records),
+		Sample:    append([][]string{}, sample...),
+		Mapping:   p.mapping,
+		ExpiresAt: p.expiresAt,
+		Links: ImportUploadLinks{
+			Self:    &Link{Href: href, Method: "GET"},
+			Confirm: &Link{Href: href + "/confirm", Method: "POST"},
+			Cancel:  &Link{Href: href, Method: "DELETE"},
+		},
+	}
+}
+
+// sendImportUploadNotFound writes the 404 response for unknown or expired uploads.
+func (api *TodoAPI) sendImportUploadNotFound(w http.ResponseWriter, r *http.Request, id string) {
+	api.sendError(w, r, http.StatusNotFound, "Upload not found", fmt.Sprintf("Import upload %s does not exist or has expired", id))
+}
+
+// CreateImportUpload handles POST /todos/import/uploads. It stores a CSV
+// upload and returns its columns, a sample of rows and a proposed mapping
+// for the client to confirm.
+func (api *TodoAPI) CreateImportUpload(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MediaTypeCSV {
+		api.sendError(w, r, http.StatusUnsupportedMediaType, "Unsupported media type", "Uploads must be sent as "+MediaTypeCSV)
+		return
+	}
+
+	header, records, err := readCSV(r.Body, MaxImportRows)
+	if err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid import", err.Error())
+		return
+	}
+
+	now := api.clock.Now().UTC()
+	p := &pendingImport{
+		header:    header,
+		records:   records,
+		mapping:   proposeMapping(header),
+		expiresAt: now.Add(importUploadTTL),
+	}
+	id, ok := api.imports.add(p, now)
+	if !ok {
+		api.sendError(w, r, http.StatusServiceUnavailable, "Too many uploads", "Too many imports are awaiting confirmation; try again later")
+		return
+	}
+
+	upload := api.presentImportUpload(r, id, p)
+	w.Header().Set("Location", upload.Links.Self.Href)
+	api.respond(w, r, http.StatusCreated, upload)
+}
+
+// GetImportUpload handles GET /todos/import/uploads/{uploadID}.
+func (api *TodoAPI) GetImportUpload(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "uploadID")
+	p, ok := api.imports.get(id, api.clock.Now())
+	if !ok {
+		api.sendImportUploadNotFound(w, r, id)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentImportUpload(r, id, p))
+}
+
+// DeleteImportUpload handles DELETE /todos/import/uploads/{uploadID} and
+// discards an upload without importing it.
+func (api *TodoAPI) DeleteImportUpload(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "uploadID")
+	if _, ok := api.imports.take(id, api.clock.Now()); !ok {
+		api.sendImportUploadNotFound(w, r, id)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfirmImportUpload handles POST /todos/import/uploads/{uploadID}/confirm.
+// The body is the ImportMapping to apply; fields it omits, or an empty body,
+// keep the proposed mapping. The source and on_conflict query parameters
+// work as for POST /todos/import. The upload is imported and discarded.
+func (api *TodoAPI) ConfirmImportUpload(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		api.sendQueryError(w, r, err)
+		return
+	}
+	if r.ContentLength != 0 && !api.requireJSON(w, r) {
+		return
+	}
+
+	id := chi.URLParam(r, "uploadID")
+	p, ok := api.imports.get(id, api.clock.Now())
+	if !ok {
+		api.sendImportUploadNotFound(w, r, id)
+		return
+	}
+
+	mapping := p.mapping
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&mapping); err != nil && !errors.Is(err, io.EOF) {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be a mapping of todo fields to column names")
+		return
+	}
+
+	cols := importColumns{title: -1, description: -1, source: -1, externalID: -1, status: -1}
+	for _, f := range []struct {
+		name   string
+		column string
+		index  *int
+	}{
+		{"title", mapping.Title, &cols.title},
+		{"description", mapping.Description, &cols.description},
+		{"source", mapping.Source, &cols.source},
+		{"external_id", mapping.ExternalID, &cols.externalID},
+		{"status", mapping.Status, &cols.status},
+	} {
+		if f.column == "" {
+			continue
+		}
+		if *f.index = columnIndex(p.header, f.column); *f.index < 0 {
+			api.sendError(w, r, http.StatusBadRequest, "Validation error", fmt.Sprintf("%s must name one of the columns %q", f.name, p.header))
+			return
+		}
+	}
+	if cols.title < 0 {
+		api.sendError(w, r, http.StatusBadRequest, "Validation error", fmt.Sprintf("title must name one of the columns %q", p.header))
+		return
+	}
+
+	if _, ok := api.imports.take(id, api.clock.Now()); !ok {
+		// Confirmed or cancelled concurrently.
+		api.sendImportUploadNotFound(w, r, id)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.importInputs(r, mapRecords(p.records, cols), opts))
+}
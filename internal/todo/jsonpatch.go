@@ -0,0 +1,339 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaTypeJSONPatch is the media type of RFC 6902 JSON Patch documents,
+// accepted by PATCH /todos/{id}.
+const MediaTypeJSONPatch = "application/json-patch+json"
+
+// PatchOperation is one operation of a JSON Patch document.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// patchableFields are the members of a todo that add, replace and remove
+// may change; the others can only be tested.
+var patchableFields = map[string]bool{"title": true, "description": true, "status": true, "tags": true, "parent_id": true}
+
+// patchError is a JSON Patch that cannot be applied, with the status code
+// it is answered with: 400 for malformed operations, 409 for failed tests
+// and 422 for operations the todo does not allow.
+type patchError struct {
+	status  int
+	title   string
+	message string
+}
+
+func (e *patchError) Error() string { return e.message }
+
+func invalidPatch(index int, format string, args ...any) *patchError {
+	return &patchError{http.StatusBadRequest, "Invalid JSON Patch", fmt.Sprintf("Operation %d: ", index) + fmt.Sprintf(format, args...)}
+}
+
+func unprocessablePatch(index int, format string, args ...any) *patchError {
+	return &patchError{http.StatusUnprocessableEntity, "Unprocessable JSON Patch", fmt.Sprintf("Operation %d: ", index) + fmt.Sprintf(format, args...)}
+}
+
+// patchDocument is the JSON document patches are applied to: the fields of
+// a todo, without links or derived display fields.
+type patchDocument struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Status      State     `json:"status"`
+	Completed   bool      `json:"completed"`
+	Archived    bool      `json:"archived"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Source      string    `json:"source"`
+	ExternalID  string    `json:"external_id"`
+	Tags        []string  `json:"tags"`
+	ParentID    int       `json:"parent_id"`
+}
+
+// validatePatch checks the form of every operation before any is applied.
+func validatePatch(ops []PatchOperation) error {
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return invalidPatch(i, "%s requires a value", op.Op)
+			}
+		case "remove":
+		case "move", "copy":
+			return unprocessablePatch(i, "%s is not supported; use add, replace, remove or test", op.Op)
+		default:
+			return invalidPatch(i, "unknown op %q", op.Op)
+		}
+		if _, err := parsePatchPath(op.Path); err != nil {
+			return invalidPatch(i, "%v", err)
+		}
+	}
+	return nil
+}
+
+// patchPath is the target of a JSON Pointer into a todo: a top-level
+// member or, for tags, one of its elements.
+type patchPath struct {
+	member string
+	// element is the index of a tag, or "-" for the end of the tags; it is
+	// empty if the pointer refers to the member itself.
+	element string
+}
+
+// parsePatchPath parses a JSON Pointer into a todo. Todos are flat apart
+// from tags, so only pointers to a top-level member or to a tag are valid.
+func parsePatchPath(path string) (patchPath, error) {
+	if path == "" {
+		return patchPath{}, errors.New("the whole todo cannot be patched; use PUT to replace it")
+	}
+	if !strings.HasPrefix(path, "/") {
+		return patchPath{}, fmt.Errorf("path %q is not a JSON Pointer", path)
+	}
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(path[1:], "/")
+	p := patchPath{member: unescape.Replace(tokens[0])}
+	switch {
+	case len(tokens) == 1:
+		return p, nil
+	case len(tokens) > 2 || p.member != "tags":
+		return patchPath{}, fmt.Errorf("path %q does not exist; only tags has elements", path)
+	}
+	p.element = tokens[1]
+	if !arrayIndex(p.element) && p.element != "-" {
+		return patchPath{}, fmt.Errorf("path %q does not exist; %q is not an array index", path, p.element)
+	}
+	return p, nil
+}
+
+// arrayIndex reports whether token is an RFC 6901 array index: a decimal
+// number without leading zeros.
+func arrayIndex(token string) bool {
+	if token == "" || (token[0] == '0' && len(token) > 1) {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// applyPatch applies ops in order to todo and returns the resulting edit.
+// Either all operations apply or none does.
+func applyPatch(todo *Todo, ops []PatchOperation) (TodoInput, error) {
+	doc := newPatchDocument(todo)
+	for i, op := range ops {
+		p, _ := parsePatchPath(op.Path)
+		if p.element != "" {
+			if err := applyTagPatch(doc, i, op, p.element); err != nil {
+				return TodoInput{}, err
+			}
+			continue
+		}
+		member := p.member
+		current, exists := doc[member]
+		if !exists && (op.Op != "add" || !patchableFields[member]) {
+			return TodoInput{}, unprocessablePatch(i, "path %s does not exist", op.Path)
+		}
+
+		if op.Op == "test" {
+			var want any
+			if err := json.Unmarshal(op.Value, &want); err != nil {
+				return TodoInput{}, invalidPatch(i, "invalid value: %v", err)
+			}
+			if !reflect.DeepEqual(current, want) {
+				got, _ := json.Marshal(current)
+				return TodoInput{}, &patchError{http.StatusConflict, "Patch test failed",
+					fmt.Sprintf("Operation %d: %s is %s, not %s", i, op.Path, got, bytes.TrimSpace(op.Value))}
+			}
+			continue
+		}
+
+		if !patchableFields[member] {
+			return TodoInput{}, unprocessablePatch(i, "%s is read-only; only title, description, tags, parent_id and status can be changed", op.Path)
+		}
+		if op.Op == "remove" {
+			delete(doc, member)
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return TodoInput{}, invalidPatch(i, "invalid value: %v", err)
+		}
+		doc[member] = value
+	}
+
+	return patchedInput(doc)
+}
+
+// applyTagPatch applies op to the tag at element, an index into the tags
+// of doc or "-" for their end, which only add may refer to.
+func applyTagPatch(doc map[string]any, index int, op PatchOperation, element string) error {
+	tags, ok := doc["tags"].([]any)
+	if !ok {
+		return unprocessablePatch(index, "path %s does not exist", op.Path)
+	}
+	at := len(tags)
+	if element != "-" {
+		n, err := strconv.Atoi(element)
+		if err != nil {
+			return unprocessablePatch(index, "path %s does not exist", op.Path)
+		}
+		at = n
+	}
+	if at > len(tags) || (at == len(tags) && op.Op != "add") {
+		return unprocessablePatch(index, "path %s does not exist", op.Path)
+	}
+
+	var value any
+	if op.Op != "remove" {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return invalidPatch(index, "invalid value: %v", err)
+		}
+	}
+	switch op.Op {
+	case "test":
+		if !reflect.DeepEqual(tags[at], value) {
+			got, _ := json.Marshal(tags[at])
+			return &patchError{http.StatusConflict, "Patch test failed",
+				fmt.Sprintf("Operation %d: %s is %s, not %s", index, op.Path, got, bytes.TrimSpace(op.Value))}
+		}
+	case "add":
+		doc["tags"] = slices.Insert(tags, at, value)
+	case "replace":
+		tags[at] = value
+	case "remove":
+		doc["tags"] = slices.Delete(tags, at, at+1)
+	}
+	return nil
+}
+
+// newPatchDocument returns todo as a generic JSON object, so values compare
+// the same way as the decoded operation values.
+func newPatchDocument(todo *Todo) map[string]any {
+	// Encoding and decoding plain strings, numbers and times cannot fail.
+	data, _ := json.Marshal(patchDocument{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.State(),
+		Completed:   todo.Completed,
+		Archived:    todo.Archived,
+		Version:     todo.Version,
+		CreatedAt:   todo.CreatedAt.UTC(),
+		UpdatedAt:   todo.UpdatedAt.UTC(),
+		Source:      todo.Source,
+		ExternalID:  todo.ExternalID,
+		Tags:        append([]string{}, todo.Tags...),
+		ParentID:    todo.ParentID,
+	})
+	var doc map[string]any
+	_ = json.Unmarshal(data, &doc)
+	return doc
+}
+
+// patchedInput validates the patched document and returns the edit it
+// describes. A removed description is empty, removed tags are none, and a
+// removed parent_id is 0, which detaches the todo from its parent.
+func patchedInput(doc map[string]any) (TodoInput, error) {
+	invalid := func(format string, args ...any) error {
+		return &patchError{http.StatusUnprocessableEntity, "Unprocessable JSON Patch", fmt.Sprintf(format, args...)}
+	}
+
+	title, _ := doc["title"].(string)
+	if title == "" {
+		return TodoInput{}, invalid("The patched todo must have a non-empty string title")
+	}
+	description, ok := doc["description"].(string)
+	if _, exists := doc["description"]; exists && !ok {
+		return TodoInput{}, invalid("The patched description must be a string")
+	}
+	tags := []string{}
+	if value, exists := doc["tags"]; exists {
+		items, ok := value.([]any)
+		if !ok {
+			return TodoInput{}, invalid("The patched tags must be an array of strings")
+		}
+		for _, item := range items {
+			tag, ok := item.(string)
+			if !ok {
+				return TodoInput{}, invalid("The patched tags must be an array of strings")
+			}
+			tags = append(tags, tag)
+		}
+	}
+	parentID := 0
+	if value, exists := doc["parent_id"]; exists {
+		number, ok := value.(float64)
+		if !ok || number != float64(int(number)) {
+			return TodoInput{}, invalid("The patched parent_id must be an integer")
+		}
+		parentID = int(number)
+	}
+	status, _ := doc["status"].(string)
+	state, ok := ParseState(status)
+	if !ok {
+		return TodoInput{}, invalid("The patched status must be one of %s, %s or %s", StateOpen, StateCompleted, StateArchived)
+	}
+	return TodoInput{Title: title, Description: description, Tags: tags, ParentID: &parentID, Status: state}, nil
+}
+
+// PatchTodo handles PATCH /todos/{id} with an RFC 6902 JSON Patch body.
+// The operations add, replace and remove change the title, description,
+// tags, parent_id and status, and single tags by index or "-"; test checks
+// any member of the todo or a tag, so a patch can be made conditional on
+// the values it was computed from. The patch is applied atomically: if any
+// operation fails, the todo is left unchanged.
+func (api *TodoAPI) PatchTodo(w http.ResponseWriter, r *http.Request) {
+	id := todoIDFromContext(r.Context())
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MediaTypeJSONPatch {
+		w.Header().Set("Accept-Patch", MediaTypeJSONPatch)
+		api.sendError(w, r, http.StatusUnsupportedMediaType, "Unsupported media type",
+			"PATCH /todos/{id} accepts "+MediaTypeJSONPatch+"; use PUT to replace a todo")
+		return
+	}
+
+	var ops []PatchOperation
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON Patch", "Request body must be a JSON array of patch operations")
+		return
+	}
+	var patchErr *patchError
+	if err := validatePatch(ops); errors.As(err, &patchErr) {
+		api.sendError(w, r, patchErr.status, patchErr.title, patchErr.message)
+		return
+	}
+
+	todo, err := api.service.PatchTodoIf(r.Context(), id, func(current *Todo) (TodoInput, error) {
+		return applyPatch(current, ops)
+	}, api.precondition(r))
+	switch {
+	case errors.As(err, &patchErr):
+		api.sendError(w, r, patchErr.status, patchErr.title, patchErr.message)
+		return
+	case err != nil:
+		api.sendTodoError(w, r, id, err)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentEdited(r, todo))
+}
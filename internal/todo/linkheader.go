@@ -0,0 +1,83 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerLink is one link of an RFC 8288 Link header.
+type headerLink struct {
+	rel  string
+	link *Link
+}
+
+// linkHeaderer is implemented by payloads whose main links are repeated in
+// a Link header, so HTTP-level tooling can follow them without parsing the
+// body.
+type linkHeaderer interface {
+	headerLinks() []headerLink
+}
+
+// setLinkHeader sets the Link header of the response from the header links
+// of payload. Absent links are skipped.
+func setLinkHeader(w http.ResponseWriter, payload any) {
+	headerer, ok := payload.(linkHeaderer)
+	if !ok {
+		return
+	}
+	var values []string
+	for _, l := range headerer.headerLinks() {
+		if l.link != nil {
+			values = append(values, fmt.Sprintf("<%s>; rel=%q", l.link.Href, l.rel))
+		}
+	}
+	if len(values) > 0 {
+		w.Header().Set("Link", strings.Join(values, ", "))
+	}
+}
+
+// headerLinks returns the registered relations of a todo: "edit" is the
+// update (PUT) and delete (DELETE) target, as in RFC 5023.
+func (t Todo) headerLinks() []headerLink {
+	return []headerLink{
+		{"self", t.Links.Self},
+		{"edit", t.Links.Update},
+		{"collection", t.Links.Todos},
+		{"profile", t.Links.Profile},
+	}
+}
+
+func (c TodoCollection) headerLinks() []headerLink {
+	return c.Links.headerLinks()
+}
+
+func (c MilestoneCollection) headerLinks() []headerLink {
+	return c.Links.headerLinks()
+}
+
+// headerLinks returns the navigation links of a paginated collection.
+func (l CollectionLinks) headerLinks() []headerLink {
+	return []headerLink{
+		{"self", l.Self},
+		{"first", l.First},
+		{"prev", l.Prev},
+		{"next", l.Next},
+		{"last", l.Last},
+		{"profile", l.Profile},
+	}
+}
+
+func (f ChangeFeed) headerLinks() []headerLink {
+	return []headerLink{
+		{"self", f.Links.Self},
+		{"next", f.Links.Next},
+	}
+}
+
+func (a APIRoot) headerLinks() []headerLink {
+	return []headerLink{
+		{"self", a.Links.Self},
+		{"profile", a.Links.Profile},
+	}
+}
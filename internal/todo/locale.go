@@ -0,0 +1,251 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/query"
+)
+
+// Date formats accepted by the datefmt query parameter. DateFormatISO turns
+// the human-friendly fields off.
+const (
+	DateFormatISO    = "iso"
+	DateFormatShort  = "short"
+	DateFormatMedium = "medium"
+	DateFormatLong   = "long"
+)
+
+// DateFormats lists the valid values of the datefmt query parameter.
+var DateFormats = []string{DateFormatISO, DateFormatShort, DateFormatMedium, DateFormatLong}
+
+// TodoDisplay holds human-friendly renderings of a todo's timestamps for
+// clients without date libraries. It is only included when the request
+// sends Accept-Language or a datefmt other than iso; the ISO timestamps
+// stay authoritative.
+type TodoDisplay struct {
+	// CreatedAt is created_at formatted in the requested date format.
+	CreatedAt string `json:"created_at"`
+	// Created is created_at relative to now, such as "3 days ago".
+	Created string `json:"created"`
+}
+
+// locale holds the words and patterns used to render dates in one language.
+// Patterns use the placeholders {d}, {dd}, {mm}, {yyyy}, {MMM}, {MMMM} and
+// {time}.
+type locale struct {
+	tag         string
+	months      [12]string
+	shortMonths [12]string
+	patterns    map[string]string
+	justNow     string
+	past        string
+	future      string
+	// units are the singular and plural words for minutes, hours, days,
+	// weeks, months and years.
+	units [6][2]string
+}
+
+// defaultLocale is used when no language of Accept-Language is supported.
+const defaultLocale = "en"
+
+var locales = map[string]*locale{
+	"en": {
+		tag:         "en",
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		patterns: map[string]string{
+			DateFormatShort:  "{mm}/{dd}/{yyyy}",
+			DateFormatMedium: "{MMM} {d}, {yyyy}",
+			DateFormatLong:   "{MMMM} {d}, {yyyy} at {time} UTC",
+		},
+		justNow: "just now",
+		past:    "%d %s ago",
+		future:  "in %d %s",
+		units:   [6][2]string{{"minute", "minutes"}, {"hour", "hours"}, {"day", "days"}, {"week", "weeks"}, {"month", "months"}, {"year", "years"}},
+	},
+	"de": {
+		tag:         "de",
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		patterns: map[string]string{
+			DateFormatShort:  "{dd}.{mm}.{yyyy}",
+			DateFormatMedium: "{d}. {MMM} {yyyy}",
+			DateFormatLong:   "{d}. {MMMM} {yyyy} um {time} UTC",
+		},
+		justNow: "gerade eben",
+		past:    "vor %d %s",
+		future:  "in %d %s",
+		units:   [6][2]string{{"Minute", "Minuten"}, {"Stunde", "Stunden"}, {"Tag", "Tagen"}, {"Woche", "Wochen"}, {"Monat", "Monaten"}, {"Jahr", "Jahren"}},
+	},
+	"fr": {
+		tag:         "fr",
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		patterns: map[string]string{
+			DateFormatShort:  "{dd}/{mm}/{yyyy}",
+			DateFormatMedium: "{d} {MMM} {yyyy}",
+			DateFormatLong:   "{d} {MMMM} {yyyy} à {time} UTC",
+		},
+		justNow: "à l'instant",
+		past:    "il y a %d %s",
+		future:  "dans %d %s",
+		units:   [6][2]string{{"minute", "minutes"}, {"heure", "heures"}, {"jour", "jours"}, {"semaine", "semaines"}, {"mois", "mois"}, {"an", "ans"}},
+	},
+	"es": {
+		tag:         "es",
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		patterns: map[string]string{
+			DateFormatShort:  "{dd}/{mm}/{yyyy}",
+			DateFormatMedium: "{d} {MMM} {yyyy}",
+			DateFormatLong:   "{d} de {MMMM} de {yyyy}, {time} UTC",
+		},
+		justNow: "ahora mismo",
+		past:    "hace %d %s",
+		future:  "dentro de %d %s",
+		units:   [6][2]string{{"minuto", "minutos"}, {"hora", "horas"}, {"día", "días"}, {"semana", "semanas"}, {"mes", "meses"}, {"año", "años"}},
+	},
+}
+
+// formatDate renders t, in UTC, in the given date format.
+func (l *locale) formatDate(t time.Time, format string) string {
+	t = t.UTC()
+	return strings.NewReplacer(
+		"{d}", strconv.Itoa(t.Day()),
+		"{dd}", fmt.Sprintf("%02d", t.Day()),
+		"{mm}", fmt.Sprintf("%02d", int(t.Month())),
+		"{yyyy}", strconv.Itoa(t.Year()),
+		"{MMM}", l.shortMonths[t.Month()-1],
+		"{MMMM}", l.months[t.Month()-1],
+		"{time}", t.Format("15:04"),
+	).Replace(l.patterns[format])
+}
+
+// formatRelative describes t relative to now in the largest whole unit,
+// such as "3 days ago" or "in 2 hours".
+func (l *locale) formatRelative(t, now time.Time) string {
+	d := now.Sub(t)
+	pattern := l.past
+	if d < 0 {
+		d, pattern = -d, l.future
+	}
+	if d < time.Minute {
+		return l.justNow
+	}
+
+	const day = 24 * time.Hour
+	var n, unit int
+	switch {
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), 0
+	case d < day:
+		n, unit = int(d/time.Hour), 1
+	case d < 7*day:
+		n, unit = int(d/day), 2
+	case d < 30*day:
+		n, unit = int(d/(7*day)), 3
+	case d < 365*day:
+		n, unit = int(d/(30*day)), 4
+	default:
+		n, unit = int(d/(365*day)), 5
+	}
+	word := l.units[unit][1]
+	if n == 1 {
+		word = l.units[unit][0]
+	}
+	return fmt.Sprintf(pattern, n, word)
+}
+
+// matchLocale returns the supported locale the Accept-Language header
+// prefers, falling back to defaultLocale. Only the primary language subtag
+// is considered, so "de-CH" selects German.
+func matchLocale(header string) *locale {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary != "" && q > 0 {
+			ranges = append(ranges, languageRange{tag: primary, q: q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, lr := range ranges {
+		if l, ok := locales[lr.tag]; ok {
+			return l
+		}
+	}
+	return locales[defaultLocale]
+}
+
+// displayPrefs are the rendering preferences of a request.
+type displayPrefs struct {
+	locale *locale
+	format string
+}
+
+type displayPrefsKey struct{}
+
+// localize is a middleware that reads the Accept-Language header and the
+// datefmt query parameter. When either asks for human-friendly dates, the
+// preferences are stored in the request context for present, and the
+// response declares its Content-Language. An invalid datefmt is rejected
+// with 400 Bad Request.
+func (api *TodoAPI) localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+
+		params := query.New(r.URL.Query())
+		format := params.Enum("datefmt", "", DateFormats...)
+		if err := params.Err(); err != nil {
+			api.sendQueryError(w, r, err)
+			return
+		}
+
+		acceptLanguage := r.Header.Get("Accept-Language")
+		if format == DateFormatISO || (format == "" && strings.TrimSpace(acceptLanguage) == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if format == "" {
+			format = DateFormatMedium
+		}
+
+		prefs := displayPrefs{locale: matchLocale(acceptLanguage), format: format}
+		w.Header().Set("Content-Language", prefs.locale.tag)
+		ctx := context.WithValue(r.Context(), displayPrefsKey{}, prefs)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// displayTodo returns the human-friendly fields of todo for the preferences
+// of r, or nil if none were requested.
+func (api *TodoAPI) displayTodo(r *http.Request, todo *Todo) *TodoDisplay {
+	prefs, ok := r.Context().Value(displayPrefsKey{}).(displayPrefs)
+	if !ok {
+		return nil
+	}
+	return &TodoDisplay{
+		CreatedAt: prefs.locale.formatDate(todo.CreatedAt, prefs.format),
+		Created:   prefs.locale.formatRelative(todo.CreatedAt, api.clock.Now()),
+	}
+}
@@ -0,0 +1,194 @@
+package todo
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MediaTypeJSON is the generic JSON media type served by default.
+	MediaTypeJSON = "application/json"
+	// MediaTypeVendorV1 is the versioned vendor media type for clients
+	// that want a strict, versioned contract.
+	MediaTypeVendorV1 = "application/vnd.todoapp.v1+json"
+	// mediaTypeVendor is the unversioned vendor media type; the version is
+	// selected through its "version" parameter.
+	mediaTypeVendor = "application/vnd.todoapp+json"
+	// MediaTypeText renders todos, collections and errors as plain text
+	// for screen readers, e-ink devices and terminals.
+	MediaTypeText = "text/plain"
+	// MediaTypeHTML renders every response as a browsable page with
+	// clickable links, for people opening the API in a browser.
+	MediaTypeHTML = "text/html"
+	// MediaTypeHAL is the HAL media type: links keyed by relation and
+	// collection members under _embedded.
+	MediaTypeHAL = "application/hal+json"
+	// MediaTypeHALForms is the HAL-FORMS media type requested by generic
+	// clients that render the _templates of a resource as forms. It is
+	// served as HAL.
+	MediaTypeHALForms = "application/prs.hal-forms+json"
+	// MediaTypeSiren is the Siren media type: classed entities with
+	// sub-entities, actions and links.
+	MediaTypeSiren = "application/vnd.siren+json"
+	// MediaTypeXML renders every response as XML with the same links, for
+	// integrators that cannot consume JSON.
+	MediaTypeXML = "application/xml"
+	// MediaTypeMsgpack encodes every response as MessagePack, for
+	// high-volume machine-to-machine consumers.
+	MediaTypeMsgpack = "application/msgpack"
+)
+
+type mediaTypeKey struct{}
+
+// jsonContentType reports whether a Content-Type header declares a JSON
+// body: the generic or the vendor media type, with no charset parameter or
+// a UTF-8 one, since JSON exchanged between systems must be UTF-8.
+func jsonContentType(header string) bool {
+	mediaType, params, err := mime.ParseMediaType(header)
+	if err != nil || (mediaType != MediaTypeJSON && mediaType != MediaTypeVendorV1) {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+// requireJSON reports whether the body of r is declared as JSON. Otherwise
+// it answers 415 Unsupported Media Type and returns false, so handlers
+// never decode bodies of another type.
+func (api *TodoAPI) requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	if jsonContentType(r.Header.Get("Content-Type")) {
+		return true
+	}
+	api.sendError(w, r, http.StatusUnsupportedMediaType, "Unsupported media type",
+		"Request body must be sent as "+MediaTypeJSON+" encoded in UTF-8")
+	return false
+}
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	typ    string
+	params map[string]string
+	q      float64
+}
+
+// parseAccept parses an Accept header into media ranges sorted by preference.
+// Entries that cannot be parsed are skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		typ, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = v
+			}
+			delete(params, "q")
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, params: params, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	return ranges
+}
+
+// resolveMediaRange maps a single media range to the media type the API
+// will respond with. The boolean is false if the range cannot be served.
+func resolveMediaRange(mr mediaRange) (string, bool) {
+	switch mr.typ {
+	case "*/*", "application/*", MediaTypeJSON:
+		return MediaTypeJSON, true
+	case MediaTypeVendorV1:
+		return MediaTypeVendorV1, true
+	case MediaTypeHAL:
+		return MediaTypeHAL, true
+	case MediaTypeHALForms:
+		return MediaTypeHALForms, true
+	case MediaTypeSiren:
+		return MediaTypeSiren, true
+	case MediaTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+		return MediaTypeMsgpack, true
+	case MediaTypeXML, "text/xml":
+		return MediaTypeXML, true
+	case "text/*", MediaTypeText:
+		return MediaTypeText, true
+	case MediaTypeHTML, "application/xhtml+xml":
+		return MediaTypeHTML, true
+	case mediaTypeVendor:
+		if v, ok := mr.params["version"]; ok && v != "1" {
+			return "", false
+		}
+		return MediaTypeVendorV1, true
+	}
+	return "", false
+}
+
+// negotiateMediaType selects the response media type for the given Accept header.
+// An empty header yields MediaTypeJSON. The boolean is false if none of the
+// acceptable media types can be produced.
+func negotiateMediaType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeJSON, true
+	}
+
+	for _, mr := range parseAccept(accept) {
+		if mr.q <= 0 {
+			continue
+		}
+		if mediaType, ok := resolveMediaRange(mr); ok {
+			return mediaType, true
+		}
+	}
+
+	return "", false
+}
+
+// negotiate is a middleware that performs content negotiation on the Accept
+// header, sets the response Content-Type, and stores the selected media type
+// in the request context. Requests that accept none of the supported media
+// types are rejected with 406 Not Acceptable.
+func (api *TodoAPI) negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, ok := negotiateMediaType(r.Header.Get("Accept"))
+		if !ok {
+			api.sendError(w, r, http.StatusNotAcceptable, "Not acceptable",
+				"Supported media types are "+strings.Join([]string{MediaTypeJSON, MediaTypeVendorV1, MediaTypeHAL, MediaTypeHALForms, MediaTypeSiren, MediaTypeXML, MediaTypeMsgpack, MediaTypeText}, ", ")+" and "+MediaTypeHTML)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeOf(mediaType))
+		w.Header().Add("Vary", "Accept")
+
+		ctx := context.WithValue(r.Context(), mediaTypeKey{}, mediaType)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// contentTypeOf returns the Content-Type header value for mediaType.
+func contentTypeOf(mediaType string) string {
+	switch mediaType {
+	case MediaTypeText:
+		return textContentType
+	case MediaTypeHTML:
+		return htmlContentType
+	case MediaTypeXML:
+		return xmlContentType
+	}
+	return mediaType
+}
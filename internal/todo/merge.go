@@ -0,0 +1,66 @@
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// MergeInput is the request body of POST /todos/{id}/merge.
+type MergeInput struct {
+	SourceID int `json:"source_id"`
+}
+
+// MergeTodo handles POST /todos/{id}/merge and folds the todo identified by
+// source_id into the todo identified by {id}. The source todo is removed and
+// later requests for its ID are redirected to the surviving todo.
+func (api *TodoAPI) MergeTodo(w http.ResponseWriter, r *http.Request) {
+	id := todoIDFromContext(r.Context())
+
+	if !api.requireJSON(w, r) {
+		return
+	}
+	var input MergeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
+		return
+	}
+
+	if input.SourceID == 0 {
+		api.sendError(w, r, http.StatusBadRequest, "Validation error", "source_id is required")
+		return
+	}
+	// A missing source is reported with its own ID; merging a todo into
+	// itself is rejected by the service.
+	if _, err := api.service.GetTodo(r.Context(), input.SourceID); err != nil && input.SourceID != id {
+		api.sendTodoError(w, r, input.SourceID, err)
+		return
+	}
+
+	todo, err := api.service.MergeTodos(r.Context(), id, input.SourceID)
+	if err != nil {
+		api.sendTodoError(w, r, id, err)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.present(r, todo))
+}
+
+// redirectMerged writes a 301 response pointing a merged todo ID at the
+// todo it was merged into.
+func (api *TodoAPI) redirectMerged(w http.ResponseWriter, r *http.Request, id, survivor int) {
+	location := fmt.Sprintf("%s/todos/%d", api.base(r), survivor)
+
+	links := buildErrorLinks(api.base(r))
+	links.MergedInto = &Link{
+		Href:   location,
+		Method: "GET",
+	}
+
+	w.Header().Set("Location", location)
+	api.respond(w, r, http.StatusMovedPermanently, ErrorResponse{
+		Error:   "Todo merged",
+		Message: fmt.Sprintf("Todo with ID %d was merged into todo %d", id, survivor),
+		Links:   links,
+	})
+}
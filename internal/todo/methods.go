@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// routeMethods are the request methods the router may serve, in the order
+// they are listed in Allow headers.
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// allowWriter holds back the bare 405 response the router writes when a
+// path does not serve the request method, keeping the Allow header the
+// router fills with the methods it does serve. Handlers never answer 405
+// themselves, so every 405 comes from the router.
+type allowWriter struct {
+	http.ResponseWriter
+	notAllowed bool
+}
+
+func (w *allowWriter) WriteHeader(status int) {
+	if status == http.StatusMethodNotAllowed {
+		w.notAllowed = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *allowWriter) Write(b []byte) (int, error) {
+	if w.notAllowed {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap, Flush and Hijack keep streaming responses and WebSocket upgrades
+// working through the wrapper.
+func (w *allowWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *allowWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *allowWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// allowMethods answers requests whose method a known path does not serve
+// with a 405 error listing the methods it does, in the Allow header and the
+// message. No route serves OPTIONS, so OPTIONS requests end up here too and
+// are answered with 204 No Content and the same Allow header; with the CORS
+// headers set earlier this is also a valid preflight response.
+func (api *TodoAPI) allowMethods(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aw := &allowWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+		if !aw.notAllowed {
+			return
+		}
+
+		allowed := allowedMethods(w.Header().Values("Allow"))
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		api.sendError(w, r, http.StatusMethodNotAllowed, "Method not allowed",
+			fmt.Sprintf("%s is not supported on %s; use %s", r.Method, r.URL.Path, strings.Join(allowed, ", ")))
+	})
+}
+
+// allowedMethods returns the methods listed by the router in the order of
+// routeMethods, with OPTIONS added.
+func allowedMethods(listed []string) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		if method == http.MethodOptions || slices.Contains(listed, method) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// NotFound handles requests for paths no route matches.
+func (api *TodoAPI) NotFound(w http.ResponseWriter, r *http.Request) {
+	api.sendError(w, r, http.StatusNotFound, "Not found", fmt.Sprintf("No resource exists at %s", r.URL.Path))
+}
@@ -0,0 +1,412 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// milestoneDateLayout is the layout of milestone start and end dates.
+const milestoneDateLayout = "2006-01-02"
+
+// Milestone groups todos into a time-boxed sprint or release.
+type Milestone struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	StartDate string         `json:"start_date"`
+	EndDate   string         `json:"end_date"`
+	TodoIDs   []int          `json:"todo_ids"`
+	Links     MilestoneLinks `json:"_links"`
+}
+
+// MilestoneInput is the request body for creating a milestone.
+type MilestoneInput struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// MilestoneLinks are the HATEOAS links of a milestone.
+type MilestoneLinks struct {
+	Self       *Link `json:"self,omitempty"`
+	Progress   *Link `json:"progress,omitempty"`
+	Milestones *Link `json:"milestones,omitempty"`
+	Todos      *Link `json:"todos,omitempty"`
+}
+
+// MilestoneProgress summarizes how far along the todos of a milestone are.
+type MilestoneProgress struct {
+	MilestoneID     int            `json:"milestone_id"`
+	Total           int            `json:"total"`
+	Completed       int            `json:"completed"`
+	Remaining       int            `json:"remaining"`
+	PercentComplete float64        `json:"percent_complete"`
+	DaysRemaining   int            `json:"days_remaining"`
+	Links           MilestoneLinks `json:"_links"`
+}
+
+// MilestoneCollection is the response of GET /milestones.
+type MilestoneCollection struct {
+	Milestones []Milestone     `json:"milestones"`
+	Links      CollectionLinks `json:"_links"`
+}
+
+// AttachTodoIDs sets the TodoIDs of each of milestones to the IDs, in
+// ascending order, of the todos assigned maps to it. assigned maps the ID
+// of each todo in a milestone to the milestone's. Stores use it to build
+// the milestones they return.
+func AttachTodoIDs(milestones []Milestone, assigned map[int]int) {
+	positions := make(map[int]int, len(milestones))
+	for i := range milestones {
+		milestones[i].TodoIDs = []int{}
+		positions[milestones[i].ID] = i
+	}
+	for _, todoID := range slices.Sorted(maps.Keys(assigned)) {
+		if i, ok := positions[assigned[todoID]]; ok {
+			milestones[i].TodoIDs = append(milestones[i].TodoIDs, todoID)
+		}
+	}
+}
+
+// Milestones returns copies of all milestones ordered by ID.
+func (s *TodoStore) Milestones(ctx context.Context) ([]Milestone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedMilestones(), nil
+}
+
+// sortedMilestones returns copies of all milestones ordered by ID. The
+// caller must hold at least the read lock.
+func (s *TodoStore) sortedMilestones() []Milestone {
+	milestones := make([]Milestone, 0, len(s.milestones))
+	for _, id := range slices.Sorted(maps.Keys(s.milestones)) {
+		milestones = append(milestones, s.milestones[id])
+	}
+	AttachTodoIDs(milestones, s.assigned)
+	return milestones
+}
+
+// CreateMilestone adds a new milestone. The input must already be
+// validated.
+func (s *TodoStore) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := Milestone{
+		ID:        s.nextMilestoneID,
+		Name:      input.Name,
+		StartDate: input.StartDate,
+		EndDate:   input.EndDate,
+	}
+	s.milestones[m.ID] = m
+	s.nextMilestoneID++
+
+	m.TodoIDs = []int{}
+	return m, nil
+}
+
+// peekNextMilestoneID returns the ID the next milestone created will get.
+func (s *TodoStore) peekNextMilestoneID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.nextMilestoneID
+}
+
+// hasMilestone reports whether the milestone with the given ID exists.
+func (s *TodoStore) hasMilestone(id int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.milestones[id]
+	return exists
+}
+
+// AssignMilestone moves the todo into the milestone, removing it from any
+// other milestone. It returns ErrNotFound if the milestone does not exist.
+func (s *TodoStore) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.milestones[milestoneID]; !exists {
+		return ErrNotFound
+	}
+	s.assigned[todoID] = milestoneID
+	return nil
+}
+
+// UnassignMilestone removes the todo from the milestone. It returns
+// ErrNotFound if the milestone does not exist.
+func (s *TodoStore) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.milestones[milestoneID]; !exists {
+		return ErrNotFound
+	}
+	if s.assigned[todoID] == milestoneID {
+		delete(s.assigned, todoID)
+	}
+	return nil
+}
+
+// validateMilestoneInput checks the required name and that the dates are
+// well formed and ordered.
+func validateMilestoneInput(input MilestoneInput) error {
+	if input.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	start, err := time.Parse(milestoneDateLayout, input.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be a date in YYYY-MM-DD format")
+	}
+	end, err := time.Parse(milestoneDateLayout, input.EndDate)
+	if err != nil {
+		return fmt.Errorf("end_date must be a date in YYYY-MM-DD format")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	return nil
+}
+
+// buildMilestoneLinks constructs the HATEOAS links for a milestone.
+func buildMilestoneLinks(baseURL string, id int) MilestoneLinks {
+	return MilestoneLinks{
+		Self: &Link{
+			Href:   fmt.Sprintf("%s/milestones/%d", baseURL, id),
+			Method: "GET",
+		},
+		Progress: &Link{
+			Href:   fmt.Sprintf("%s/milestones/%d/progress", baseURL, id),
+			Method: "GET",
+		},
+		Milestones: &Link{
+			Href:   fmt.Sprintf("%s/milestones", baseURL),
+			Method: "GET",
+		},
+		Todos: &Link{
+			Href:   fmt.Sprintf("%s/todos", baseURL),
+			Method: "GET",
+		},
+	}
+}
+
+type milestoneIDKey struct{}
+
+// parseMilestoneID is a middleware for routes under /milestones/{milestoneID}
+// that parses the ID once and stores it in the request context.
+func (api *TodoAPI) parseMilestoneID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "milestoneID"))
+		if err != nil {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid milestone ID", "The provided ID must be a valid integer")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), milestoneIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// milestoneIDFromContext returns the milestone ID stored by parseMilestoneID.
+func milestoneIDFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(milestoneIDKey{}).(int)
+	return id
+}
+
+// sendMilestoneNotFound writes the standard 404 response for a missing milestone.
+func (api *TodoAPI) sendMilestoneNotFound(w http.ResponseWriter, r *http.Request, id int) {
+	api.sendError(w, r, http.StatusNotFound, "Milestone not found", fmt.Sprintf("Milestone with ID %d does not exist", id))
+}
+
+// presentMilestone returns m decorated with its HATEOAS links.
+func (api *TodoAPI) presentMilestone(r *http.Request, m Milestone) Milestone {
+	m.Links = buildMilestoneLinks(api.base(r), m.ID)
+	return m
+}
+
+// milestoneIndex maps the ID of each todo in a milestone to the milestone's.
+type milestoneIndex map[int]int
+
+// indexMilestones returns the milestones of the todos, or nil if the
+// milestones cannot be listed.
+func (api *TodoAPI) indexMilestones(r *http.Request) milestoneIndex {
+	milestones, err := api.service.ListMilestones(r.Context())
+	if err != nil {
+		return nil
+	}
+	index := milestoneIndex{}
+	for _, m := range milestones {
+		for _, todoID := range m.TodoIDs {
+			index[todoID] = m.ID
+		}
+	}
+	return index
+}
+
+// sendMilestoneError writes the response for an error of a milestone
+// operation: 404 if the milestone does not exist, the response for the
+// failure otherwise.
+func (api *TodoAPI) sendMilestoneError(w http.ResponseWriter, r *http.Request, id int, err error) {
+	if errors.Is(err, ErrNotFound) {
+		api.sendMilestoneNotFound(w, r, id)
+		return
+	}
+	api.sendTodoError(w, r, 0, err)
+}
+
+// GetMilestones handles GET /milestones and lists all milestones.
+func (api *TodoAPI) GetMilestones(w http.ResponseWriter, r *http.Request) {
+	milestones, err := api.service.ListMilestones(r.Context())
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+	for i := range milestones {
+		milestones[i] = api.presentMilestone(r, milestones[i])
+	}
+
+	api.respond(w, r, http.StatusOK, MilestoneCollection{
+		Milestones: milestones,
+		Links: CollectionLinks{
+			Self: &Link{Href: fmt.Sprintf("%s/milestones", api.base(r))},
+			Create: &Link{
+				Href:   fmt.Sprintf("%s/milestones", api.base(r)),
+				Method: "POST",
+			},
+		},
+	})
+}
+
+// CreateMilestone handles POST /milestones and creates a milestone.
+func (api *TodoAPI) CreateMilestone(w http.ResponseWriter, r *http.Request) {
+	if !api.requireJSON(w, r) {
+		return
+	}
+	var input MilestoneInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
+		return
+	}
+
+	if err := validateMilestoneInput(input); err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Validation error", err.Error())
+		return
+	}
+
+	m, err := api.service.CreateMilestone(r.Context(), input)
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/milestones/%d", api.base(r), m.ID))
+	api.respond(w, r, http.StatusCreated, api.presentMilestone(r, m))
+}
+
+// GetMilestone handles GET /milestones/{milestoneID}.
+func (api *TodoAPI) GetMilestone(w http.ResponseWriter, r *http.Request) {
+	id := milestoneIDFromContext(r.Context())
+
+	m, err := api.service.GetMilestone(r.Context(), id)
+	if err != nil {
+		api.sendMilestoneError(w, r, id, err)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentMilestone(r, m))
+}
+
+// AssignTodo handles PUT /milestones/{milestoneID}/todos/{id} and assigns
+// the todo to the milestone, moving it out of any other milestone.
+func (api *TodoAPI) AssignTodo(w http.ResponseWriter, r *http.Request) {
+	milestoneID := milestoneIDFromContext(r.Context())
+	todoID := todoIDFromContext(r.Context())
+
+	if _, err := api.service.GetTodo(r.Context(), todoID); err != nil {
+		api.sendTodoError(w, r, todoID, err)
+		return
+	}
+
+	m, err := api.service.AssignMilestone(r.Context(), milestoneID, todoID)
+	if err != nil {
+		api.sendMilestoneError(w, r, milestoneID, err)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentMilestone(r, m))
+}
+
+// UnassignTodo handles DELETE /milestones/{milestoneID}/todos/{id} and
+// removes the todo from the milestone.
+func (api *TodoAPI) UnassignTodo(w http.ResponseWriter, r *http.Request) {
+	milestoneID := milestoneIDFromContext(r.Context())
+	todoID := todoIDFromContext(r.Context())
+
+	m, err := api.service.UnassignMilestone(r.Context(), milestoneID, todoID)
+	if err != nil {
+		api.sendMilestoneError(w, r, milestoneID, err)
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, api.presentMilestone(r, m))
+}
+
+// GetMilestoneProgress handles GET /milestones/{milestoneID}/progress and
+// reports completion of the milestone's todos, read in a single listing.
+// Todos that have since been deleted are not counted.
+func (api *TodoAPI) GetMilestoneProgress(w http.ResponseWriter, r *http.Request) {
+	id := milestoneIDFromContext(r.Context())
+
+	m, err := api.service.GetMilestone(r.Context(), id)
+	if err != nil {
+		api.sendMilestoneError(w, r, id, err)
+		return
+	}
+	todos, err := api.service.ListTodos(r.Context())
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+
+	progress := MilestoneProgress{
+		MilestoneID: m.ID,
+		Links:       buildMilestoneLinks(api.base(r), m.ID),
+	}
+	for _, todo := range todos {
+		if !slices.Contains(m.TodoIDs, todo.ID) {
+			continue
+		}
+		progress.Total++
+		if todo.Completed {
+			progress.Completed++
+		}
+	}
+	progress.Remaining = progress.Total - progress.Completed
+	if progress.Total > 0 {
+		percent := float64(progress.Completed) / float64(progress.Total) * 100
+		progress.PercentComplete = math.Round(percent*10) / 10
+	}
+
+	if end, err := time.Parse(milestoneDateLayout, m.EndDate); err == nil {
+		now := api.clock.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if days := int(end.Sub(today).Hours() / 24); days > 0 {
+			progress.DaysRemaining = days
+		}
+	}
+
+	staleResponse(w, r)
+	api.respond(w, r, http.StatusOK, progress)
+}
@@ -0,0 +1,50 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// toMsgpack converts the JSON representation body to MessagePack
+// (application/msgpack). The document keeps the JSON structure, field names
+// and links; integers stay integers and timestamps stay RFC 3339 strings, so
+// a client can switch encodings without changing how it reads responses.
+func toMsgpack(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	if err := enc.Encode(msgpackValue(doc)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// msgpackValue replaces the JSON numbers in v with integers or floats.
+func msgpackValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = msgpackValue(v[i])
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = msgpackValue(value)
+		}
+	}
+	return v
+}
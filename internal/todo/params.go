@@ -0,0 +1,33 @@
+package todo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type todoIDKey struct{}
+
+// parseTodoID is a middleware for routes under /todos/{id}. It parses the
+// {id} URL parameter once, stores it in the request context, and rejects
+// non-integer IDs with the standard 400 error body.
+func (api *TodoAPI) parseTodoID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			api.sendError(w, r, http.StatusBadRequest, "Invalid todo ID", "The provided ID must be a valid integer")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), todoIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// todoIDFromContext returns the todo ID stored by parseTodoID.
+func todoIDFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(todoIDKey{}).(int)
+	return id
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id          BIGSERIAL   PRIMARY KEY,
+	title       TEXT        NOT NULL,
+	description TEXT        NOT NULL DEFAULT '',
+	completed   BOOLEAN     NOT NULL DEFAULT FALSE,
+	created_at  TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS merged_todos (
+	id          BIGINT PRIMARY KEY,
+	survivor_id BIGINT NOT NULL
+);
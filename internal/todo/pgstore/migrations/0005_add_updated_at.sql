@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+UPDATE todos SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE todos ALTER COLUMN updated_at SET NOT NULL;
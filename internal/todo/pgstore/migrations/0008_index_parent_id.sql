@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS todos_parent_id ON todos (parent_id) WHERE parent_id <> 0;
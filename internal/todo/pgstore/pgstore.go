@@ -0,0 +1,758 @@
+// Package pgstore implements todo.Store on top of PostgreSQL so several
+// API replicas can share state. Importing the package registers the
+// "postgres" backend.
+package pgstore
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/efrem/windsurf/internal/migrate"
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Backend is the name under which the store is registered with todo.RegisterStore.
+const Backend = "postgres"
+
+// QueryTimeout bounds every statement issued by the store.
+const QueryTimeout = 5 * time.Second
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const selectColumns = `SELECT id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id FROM todos`
+
+func init() {
+	todo.RegisterStore(Backend, func(cfg todo.StoreConfig) (todo.Store, error) {
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("postgres backend requires a connection URL")
+		}
+		return Open(context.Background(), cfg.DSN, cfg.Clock)
+	})
+}
+
+// Store is a todo.Store backed by a pgx connection pool. Database failures
+// are returned as errors wrapping the failed operation.
+type Store struct {
+	pool  *pgxpool.Pool
+	clock todo.Clock
+}
+
+var (
+	_ todo.BackupStore = (*Store)(nil)
+	_ todo.ClockSource = (*Store)(nil)
+)
+
+// Open connects to the database at dsn and applies pending schema
+// migrations; concurrent replicas serialize on an advisory lock. Pool
+// sizing is taken from the DSN (for example pool_max_conns=10). A nil clock
+// selects todo.SystemClock.
+func Open(ctx context.Context, dsn string, clock todo.Clock) (*Store, error) {
+	if clock == nil {
+		clock = todo.SystemClock{}
+	}
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres pool: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+
+	s := &Store{pool: pool, clock: clock}
+	if _, err := s.Migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate applies the embedded schema migrations that have not run yet and
+// returns how many were applied.
+func (s *Store) Migrate(ctx context.Context) (int, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	db := stdlib.OpenDBFromPool(s.pool)
+	defer db.Close()
+
+	n, err := migrate.Apply(ctx, db, migrate.Postgres, migrations)
+	if err != nil {
+		return n, fmt.Errorf("migrate postgres schema: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes all connections in the pool.
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+// ServerTime returns the current time of the PostgreSQL server.
+func (s *Store) ServerTime(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	err := s.pool.QueryRow(ctx, `SELECT now()`).Scan(&now)
+	return now, err
+}
+
+// queryContext returns a context derived from ctx and bounded by
+// QueryTimeout, so a query stops when either the caller gives up or the
+// timeout expires.
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+func scanTodo(row pgx.Row) (*todo.Todo, error) {
+	var (
+		t  todo.Todo
+		id int64
+	)
+	if err := row.Scan(&id, &t.Title, &t.Description, &t.Completed, &t.Archived, &t.Version, &t.CreatedAt, &t.UpdatedAt, &t.Source, &t.ExternalID, &t.Tags, &t.ParentID); err != nil {
+		return nil, err
+	}
+	t.ID = int(id)
+	t.CreatedAt = t.CreatedAt.UTC()
+	t.UpdatedAt = t.UpdatedAt.UTC()
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+	return &t, nil
+}
+
+// storedTags returns tags as written to the NOT NULL tags column: a nil
+// slice would be written as NULL.
+func storedTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getByID(ctx context.Context, q querier, id int) (*todo.Todo, error) {
+	t, err := scanTodo(q.QueryRow(ctx, selectColumns+` WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get todo: %w", err)
+	}
+	return t, nil
+}
+
+// GetAll returns all todos ordered by ID.
+func (s *Store) GetAll(ctx context.Context) ([]*todo.Todo, error) {
+	return s.list(ctx, selectColumns+` ORDER BY id`)
+}
+
+// ListSubtasks returns the todos whose parent is parentID, ordered by ID,
+// through the index on parent_id.
+func (s *Store) ListSubtasks(ctx context.Context, parentID int) ([]*todo.Todo, error) {
+	return s.list(ctx, selectColumns+` WHERE parent_id = $1 ORDER BY id`, parentID)
+}
+
+// list returns the todos query selects.
+func (s *Store) list(ctx context.Context, query string, args ...any) ([]*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []*todo.Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan todo: %w", err)
+		}
+		todos = append(todos, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+
+	return todos, nil
+}
+
+// GetByID returns a todo by its ID, or todo.ErrNotFound.
+func (s *Store) GetByID(ctx context.Context, id int) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	return getByID(ctx, s.pool, id)
+}
+
+// Create adds a new todo using the provided input.
+func (s *Store) Create(ctx context.Context, input todo.TodoInput) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	// The times are read back as stored: timestamptz keeps microseconds, so
+	// the clock's nanoseconds would differ from what a later read returns.
+	var id int64
+	var createdAt, updatedAt time.Time
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO todos (title, description, completed, created_at, updated_at, source, external_id, tags, parent_id) VALUES ($1, $2, FALSE, $3, $3, $4, $5, $6, $7)
+		 RETURNING id, created_at, updated_at`,
+		input.Title, input.Description, s.clock.Now().UTC(), input.Source, input.ExternalID, storedTags(input.Tags), input.Parent(),
+	).Scan(&id, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+
+	return &todo.Todo{
+		ID:          int(id),
+		Title:       input.Title,
+		Description: input.Description,
+		Version:     1,
+		CreatedAt:   createdAt.UTC(),
+		UpdatedAt:   updatedAt.UTC(),
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
+	}, nil
+}
+
+// Update modifies an existing todo identified by id.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Update(ctx context.Context, id int, input todo.TodoInput) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	t, err := scanTodo(s.pool.QueryRow(ctx,
+		`UPDATE todos SET title = $1, description = $2, tags = $3, parent_id = $4, version = version + 1, updated_at = $5 WHERE id = $6
+		 RETURNING id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id`,
+		input.Title, input.Description, storedTags(input.Tags), input.Parent(), s.clock.Now().UTC(), id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update todo: %w", err)
+	}
+	return t, nil
+}
+
+// Complete marks the todo with the given ID as completed.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Complete(ctx context.Context, id int) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	t, err := scanTodo(s.pool.QueryRow(ctx,
+		`UPDATE todos SET completed = TRUE, version = version + 1, updated_at = $1 WHERE id = $2
+		 RETURNING id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id`,
+		s.clock.Now().UTC(), id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("complete todo: %w", err)
+	}
+	return t, nil
+}
+
+// SetState moves the todo with the given ID to state.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) SetState(ctx context.Context, id int, state todo.State) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	t, err := scanTodo(s.pool.QueryRow(ctx,
+		`UPDATE todos SET completed = $1, archived = $2, version = version + 1, updated_at = $3 WHERE id = $4
+		 RETURNING id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id`,
+		state != todo.StateOpen, state == todo.StateArchived, s.clock.Now().UTC(), id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("set todo state: %w", err)
+	}
+	return t, nil
+}
+
+// Delete removes the todo with the given ID.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Delete(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM todos WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete todo: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// Merge folds the todo sourceID into the todo targetID, appending the
+// source description to the target's, adding the source tags to the
+// target's and recording the source ID as an
+// alias of the target. It returns todo.ErrNotFound if either todo does not
+// exist and todo.ErrMergeIntoItself if they are the same.
+func (s *Store) Merge(ctx context.Context, targetID, sourceID int) (*todo.Todo, error) {
+	if targetID == sourceID {
+		return nil, todo.ErrMergeIntoItself
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin merge: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock both rows so concurrent merges from other replicas serialize.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM todos WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, targetID, sourceID); err != nil {
+		return nil, fmt.Errorf("lock merge: %w", err)
+	}
+
+	target, err := getByID(ctx, tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := getByID(ctx, tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	target.Description = todo.MergeDescriptions(target.Description, source.Description)
+	target.Tags = todo.MergeTags(target.Tags, source.Tags)
+
+	target.Version++
+	// timestamptz keeps microseconds; match what a later read returns.
+	target.UpdatedAt = s.clock.Now().UTC().Truncate(time.Microsecond)
+	_, err = tx.Exec(ctx, `UPDATE todos SET description = $1, tags = $2, version = $3, updated_at = $4 WHERE id = $5`,
+		target.Description, storedTags(target.Tags), target.Version, target.UpdatedAt, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM todos WHERE id = $1`, sourceID); err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO merged_todos (id, survivor_id) VALUES ($1, $2)
+		 ON CONFLICT (id) DO UPDATE SET survivor_id = EXCLUDED.survivor_id`,
+		sourceID, targetID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit merge: %w", err)
+	}
+	return target, nil
+}
+
+// MergedInto returns the ID of the todo that id was merged into, following
+// chains of merges. It returns todo.ErrNotFound if id was never merged or
+// its survivor no longer exists.
+func (s *Store) MergedInto(ctx context.Context, id int) (int, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var survivor int64
+	err := s.pool.QueryRow(ctx, `
+		WITH RECURSIVE chain(id, survivor_id, depth) AS (
+			SELECT id, survivor_id, 1 FROM merged_todos WHERE id = $1
+			UNION ALL
+			SELECT m.id, m.survivor_id, c.depth + 1
+			FROM merged_todos m JOIN chain c ON m.id = c.survivor_id
+		)
+		SELECT survivor_id FROM chain ORDER BY depth DESC LIMIT 1`, id,
+	).Scan(&survivor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, todo.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("resolve merge: %w", err)
+	}
+
+	if _, err := getByID(ctx, s.pool, int(survivor)); err != nil {
+		return 0, err
+	}
+	return int(survivor), nil
+}
+
+// FindByExternalID returns the todo imported from source with the given
+// external ID, or todo.ErrNotFound.
+func (s *Store) FindByExternalID(ctx context.Context, source, externalID string) (*todo.Todo, error) {
+	if externalID == "" {
+		return nil, todo.ErrNotFound
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	t, err := scanTodo(s.pool.QueryRow(ctx, selectColumns+` WHERE source = $1 AND external_id = $2`, source, externalID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find todo by external id: %w", err)
+	}
+	return t, nil
+}
+
+// listMilestones reads the milestones and their todos through q.
+func listMilestones(ctx context.Context, q querier) ([]todo.Milestone, error) {
+	rows, err := q.Query(ctx, `SELECT id, name, start_date, end_date FROM milestones ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	milestones := []todo.Milestone{}
+	for rows.Next() {
+		var id int64
+		var m todo.Milestone
+		if err := rows.Scan(&id, &m.Name, &m.StartDate, &m.EndDate); err != nil {
+			return nil, err
+		}
+		m.ID = int(id)
+		milestones = append(milestones, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	assignments, err := q.Query(ctx, `SELECT todo_id, milestone_id FROM milestone_todos`)
+	if err != nil {
+		return nil, err
+	}
+	defer assignments.Close()
+	assigned := map[int]int{}
+	for assignments.Next() {
+		var todoID, milestoneID int64
+		if err := assignments.Scan(&todoID, &milestoneID); err != nil {
+			return nil, err
+		}
+		assigned[int(todoID)] = int(milestoneID)
+	}
+	if err := assignments.Err(); err != nil {
+		return nil, err
+	}
+
+	todo.AttachTodoIDs(milestones, assigned)
+	return milestones, nil
+}
+
+// Milestones returns all milestones ordered by ID, read from a single
+// snapshot.
+func (s *Store) Milestones(ctx context.Context) ([]todo.Milestone, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("begin list milestones: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	milestones, err := listMilestones(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("list milestones: %w", err)
+	}
+	return milestones, nil
+}
+
+// CreateMilestone adds a milestone. The input must already be validated.
+func (s *Store) CreateMilestone(ctx context.Context, input todo.MilestoneInput) (todo.Milestone, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var id int64
+	err := s.pool.QueryRow(ctx, `INSERT INTO milestones (name, start_date, end_date) VALUES ($1, $2, $3) RETURNING id`,
+		input.Name, input.StartDate, input.EndDate).Scan(&id)
+	if err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	return todo.Milestone{ID: int(id), Name: input.Name, StartDate: input.StartDate, EndDate: input.EndDate, TodoIDs: []int{}}, nil
+}
+
+// AssignMilestone moves the todo into the milestone, out of any other.
+// It returns todo.ErrNotFound if the milestone does not exist.
+func (s *Store) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "assign todo", milestoneID,
+		`INSERT INTO milestone_todos (todo_id, milestone_id) VALUES ($1, $2)
+		 ON CONFLICT (todo_id) DO UPDATE SET milestone_id = EXCLUDED.milestone_id`, todoID, milestoneID)
+}
+
+// UnassignMilestone removes the todo from the milestone. It returns
+// todo.ErrNotFound if the milestone does not exist.
+func (s *Store) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "unassign todo", milestoneID,
+		`DELETE FROM milestone_todos WHERE todo_id = $1 AND milestone_id = $2`, todoID, milestoneID)
+}
+
+// moveTodo runs stmt, which moves a todo into or out of the milestone, in
+// a transaction that first checks that the milestone exists.
+func (s *Store) moveTodo(ctx context.Context, op string, milestoneID int, stmt string, args ...any) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin %s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM milestones WHERE id = $1)`, milestoneID).Scan(&exists); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return todo.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, stmt, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit %s: %w", op, err)
+	}
+	return nil
+}
+
+// Backup returns a dump of the database, read from a single snapshot.
+func (s *Store) Backup(ctx context.Context) (todo.Backup, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("begin backup: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	b := todo.Backup{Todos: []todo.BackupTodo{}, Merged: map[int]int{}}
+
+	var (
+		last   int64
+		called bool
+	)
+	err = tx.QueryRow(ctx, `SELECT last_value, is_called FROM todos_id_seq`).Scan(&last, &called)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("read id sequence: %w", err)
+	}
+	b.NextID = int(last)
+	if called {
+		b.NextID++
+	}
+
+	rows, err := tx.Query(ctx, selectColumns+` ORDER BY id`)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup todos: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return todo.Backup{}, fmt.Errorf("scan todo: %w", err)
+		}
+		b.Todos = append(b.Todos, todo.BackupTodo{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Completed:   t.Completed,
+			Archived:    t.Archived,
+			Version:     t.Version,
+			CreatedAt:   t.CreatedAt,
+			UpdatedAt:   t.UpdatedAt,
+			Source:      t.Source,
+			ExternalID:  t.ExternalID,
+			Tags:        t.Tags,
+			ParentID:    t.ParentID,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return todo.Backup{}, fmt.Errorf("backup todos: %w", err)
+	}
+
+	merged, err := tx.Query(ctx, `SELECT id, survivor_id FROM merged_todos`)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup merges: %w", err)
+	}
+	defer merged.Close()
+	for merged.Next() {
+		var id, survivor int64
+		if err := merged.Scan(&id, &survivor); err != nil {
+			return todo.Backup{}, fmt.Errorf("scan merge: %w", err)
+		}
+		b.Merged[int(id)] = int(survivor)
+	}
+	if err := merged.Err(); err != nil {
+		return todo.Backup{}, fmt.Errorf("backup merges: %w", err)
+	}
+
+	milestones, err := listMilestones(ctx, tx)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup milestones: %w", err)
+	}
+	for _, m := range milestones {
+		b.Milestones = append(b.Milestones, todo.BackupMilestone{
+			ID:        m.ID,
+			Name:      m.Name,
+			StartDate: m.StartDate,
+			EndDate:   m.EndDate,
+			TodoIDs:   m.TodoIDs,
+		})
+	}
+
+	return b, nil
+}
+
+// Restore replaces the content of the database with b in one transaction.
+func (s *Store) Restore(ctx context.Context, b todo.Backup) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `TRUNCATE todos, merged_todos, milestones, milestone_todos`); err != nil {
+		return fmt.Errorf("clear tables: %w", err)
+	}
+
+	// Keep the highest ID handed out so far, even if b predates it.
+	var (
+		last   int64
+		called bool
+	)
+	if err := tx.QueryRow(ctx, `SELECT last_value, is_called FROM todos_id_seq`).Scan(&last, &called); err != nil {
+		return fmt.Errorf("read id sequence: %w", err)
+	}
+	next := max(b.NextID, int(last))
+	if called {
+		next = max(next, int(last)+1)
+	}
+	for _, t := range b.Todos {
+		if err := restoreTodo(ctx, tx, t); err != nil {
+			return fmt.Errorf("restore todo %d: %w", t.ID, err)
+		}
+		next = max(next, t.ID+1)
+	}
+	for id, survivor := range b.Merged {
+		if _, err := tx.Exec(ctx, `INSERT INTO merged_todos (id, survivor_id) VALUES ($1, $2)`, id, survivor); err != nil {
+			return fmt.Errorf("restore merge %d: %w", id, err)
+		}
+		next = max(next, id+1)
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT setval('todos_id_seq', $1, false)`, next); err != nil {
+		return fmt.Errorf("reset id sequence: %w", err)
+	}
+
+	// The sequence only moves forward, so milestone IDs are not reused.
+	lastMilestone := 0
+	for _, m := range b.Milestones {
+		if _, err := tx.Exec(ctx, `INSERT INTO milestones (id, name, start_date, end_date) VALUES ($1, $2, $3, $4)`,
+			m.ID, m.Name, m.StartDate, m.EndDate); err != nil {
+			return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+		}
+		for _, todoID := range m.TodoIDs {
+			if _, err := tx.Exec(ctx, `INSERT INTO milestone_todos (todo_id, milestone_id) VALUES ($1, $2)`, todoID, m.ID); err != nil {
+				return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+			}
+		}
+		lastMilestone = max(lastMilestone, m.ID)
+	}
+	if lastMilestone > 0 {
+		_, err := tx.Exec(ctx, `SELECT setval('milestones_id_seq', GREATEST($1, (SELECT last_value FROM milestones_id_seq)))`, lastMilestone)
+		if err != nil {
+			return fmt.Errorf("reset milestone id sequence: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Seed adds todos in one transaction if no todo ID has been handed out yet.
+// The table lock makes servers that start together wait for each other, so
+// only the first seeds.
+func (s *Store) Seed(ctx context.Context, todos []todo.BackupTodo) (bool, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin seed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE todos IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return false, fmt.Errorf("lock todos: %w", err)
+	}
+	var (
+		last   int64
+		called bool
+	)
+	if err := tx.QueryRow(ctx, `SELECT last_value, is_called FROM todos_id_seq`).Scan(&last, &called); err != nil {
+		return false, fmt.Errorf("read id sequence: %w", err)
+	}
+	if called || last > 1 {
+		return false, nil
+	}
+	next := 1
+	for _, t := range todos {
+		if err := restoreTodo(ctx, tx, t); err != nil {
+			return false, fmt.Errorf("seed todo %d: %w", t.ID, err)
+		}
+		next = max(next, t.ID+1)
+	}
+	if _, err := tx.Exec(ctx, `SELECT setval('todos_id_seq', $1, false)`, next); err != nil {
+		return false, fmt.Errorf("reset id sequence: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit seed: %w", err)
+	}
+	return true, nil
+}
+
+// restoreTodo inserts the todo t with its ID.
+func restoreTodo(ctx context.Context, tx pgx.Tx, t todo.BackupTodo) error {
+	// Backups taken before updates were tracked have no update time.
+	updatedAt := t.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = t.CreatedAt
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO todos (id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		t.ID, t.Title, t.Description, t.Completed, t.Archived, max(t.Version, 1), t.CreatedAt, updatedAt, t.Source, t.ExternalID, storedTags(t.Tags), t.ParentID,
+	)
+	return err
+}
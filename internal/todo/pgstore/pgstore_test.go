@@ -0,0 +1,46 @@
+package pgstore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/storetest"
+)
+
+// dsnEnv names the environment variable holding the connection URL of a
+// disposable test database. Tests are skipped when it is unset.
+const dsnEnv = "TODO_POSTGRES_TEST_DSN"
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+
+	store, err := Open(context.Background(), dsn, nil)
+	if err != nil {
+		t.Fatalf("failed to open postgres store: %v", err)
+	}
+	t.Cleanup(store.Close)
+
+	if _, err := store.pool.Exec(context.Background(), `TRUNCATE todos, merged_todos RESTART IDENTITY`); err != nil {
+		t.Fatalf("failed to reset test database: %v", err)
+	}
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return openTestStore(t)
+	})
+}
+
+func TestRegisteredBackendRequiresDSN(t *testing.T) {
+	if _, err := todo.NewStoreFromConfig(todo.StoreConfig{Backend: Backend}); err == nil {
+		t.Fatalf("expected an error when no connection URL is configured")
+	}
+}
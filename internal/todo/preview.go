@@ -0,0 +1,24 @@
+package todo
+
+import "fmt"
+
+// descriptionPreviewLength is the maximum number of characters of a
+// description included in collection listings.
+const descriptionPreviewLength = 280
+
+// truncateForListing shortens the description of a todo in a collection
+// listing to descriptionPreviewLength characters. Truncated todos are
+// flagged and get a "full" link to the complete representation.
+func truncateForListing(todo *Todo, baseURL string) {
+	runes := []rune(todo.Description)
+	if len(runes) <= descriptionPreviewLength {
+		return
+	}
+
+	todo.Description = string(runes[:descriptionPreviewLength]) + "…"
+	todo.DescriptionTruncated = true
+	todo.Links.Full = &Link{
+		Href:   fmt.Sprintf("%s/todos/%d", baseURL, todo.ID),
+		Method: "GET",
+	}
+}
@@ -0,0 +1,107 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	profileRoot       = "root"
+	profileTodo       = "todo"
+	profileCollection = "todo-collection"
+)
+
+// ProfileField describes a single field of a profiled representation.
+type ProfileField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Profile is a human- and machine-readable description of the semantics of a
+// representation, referenced through "profile" links (RFC 6906).
+type Profile struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	MediaTypes  []string       `json:"media_types"`
+	Fields      []ProfileField `json:"fields"`
+	Links       Links          `json:"_links"`
+}
+
+// profiles holds the profile documents served under /profiles/{name}.
+var profiles = map[string]Profile{
+	profileRoot: {
+		Name:        profileRoot,
+		Description: "Entry point of the Todo API with links to the available resources.",
+		Fields: []ProfileField{
+			{Name: "message", Type: "string", Description: "Welcome message."},
+		},
+	},
+	profileTodo: {
+		Name:        profileTodo,
+		Description: "A single todo item.",
+		Fields: []ProfileField{
+			{Name: "id", Type: "integer", Description: "Unique identifier of the todo."},
+			{Name: "title", Type: "string", Description: "Short title; required."},
+			{Name: "description", Type: "string", Description: "Optional free-form description."},
+			{Name: "completed", Type: "boolean", Description: "Whether the todo has been completed."},
+			{Name: "archived", Type: "boolean", Description: "Whether the completed todo has been archived."},
+			{Name: "status", Type: "string", Description: "Lifecycle state: open, completed or archived. The complete, reopen, archive and unarchive links offer the transitions allowed from it."},
+			{Name: "version", Type: "integer", Description: "Starts at 1 and is incremented by every change; the ETag changes with it. Send the ETag in If-Match to update or delete only the version you fetched."},
+			{Name: "created_at", Type: "string (RFC 3339)", Description: "Creation timestamp."},
+			{Name: "updated_at", Type: "string (RFC 3339)", Description: "Time of the latest change, or the creation time if none; sent as Last-Modified. Send it in If-Unmodified-Since to update or delete only if the todo has not changed since."},
+			{Name: "source", Type: "string", Description: "System the todo was imported from; set together with external_id."},
+			{Name: "external_id", Type: "string", Description: "Identifier of the todo in its source system; unique per source."},
+			{Name: "tags", Type: "array of strings", Description: "Lower-case words the todo is tagged with. Each tag link, named after its tag, lists the todos carrying it."},
+			{Name: "parent_id", Type: "integer", Description: "ID of the todo this one is a subtask of, linked as parent; absent for top-level todos. Send 0 to detach the todo."},
+			{Name: "subtasks.total", Type: "integer", Description: "Number of subtasks, listed by the subtasks link; subtasks is only sent for todos that have any."},
+			{Name: "subtasks.completed", Type: "integer", Description: "Number of completed or archived subtasks."},
+			{Name: "description_truncated", Type: "boolean", Description: "Set in collection listings when description is only a preview; follow the full link for the complete text."},
+			{Name: "_display.created_at", Type: "string", Description: "created_at formatted for the Accept-Language locale in the datefmt format (short, medium or long); only sent when Accept-Language or datefmt is given."},
+			{Name: "_display.created", Type: "string", Description: "created_at relative to now, such as \"3 days ago\"; sent with _display.created_at."},
+		},
+	},
+	profileCollection: {
+		Name:        profileCollection,
+		Description: "A paginated collection of todo items.",
+		Fields: []ProfileField{
+			{Name: "todos", Type: "array", Description: "Todos on the current page, each following the todo profile."},
+			{Name: "_meta.total", Type: "integer", Description: "Total number of todos."},
+			{Name: "_meta.count", Type: "integer", Description: "Number of todos on this page."},
+			{Name: "_meta.page", Type: "integer", Description: "Current page number, starting at 1."},
+			{Name: "_meta.per_page", Type: "integer", Description: "Page size."},
+			{Name: "_meta.total_pages", Type: "integer", Description: "Total number of pages."},
+			{Name: "_meta.aggregates.by_status", Type: "object", Description: "Number of todos in the whole collection per status; only sent with ?aggregates=true."},
+		},
+	},
+}
+
+// buildProfileLink constructs the "profile" link for the named profile.
+func buildProfileLink(baseURL, name string) *Link {
+	return &Link{
+		Href: fmt.Sprintf("%s/profiles/%s", baseURL, name),
+	}
+}
+
+// GetProfile handles GET /profiles/{name} and returns the profile document.
+func (api *TodoAPI) GetProfile(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	profile, exists := profiles[name]
+	if !exists {
+		api.sendError(w, r, http.StatusNotFound, "Profile not found", fmt.Sprintf("Profile %q does not exist", name))
+		return
+	}
+
+	profile.MediaTypes = []string{MediaTypeJSON, MediaTypeVendorV1}
+	profile.Links = Links{
+		Self: buildProfileLink(api.base(r), name),
+		Todos: &Link{
+			Href:   fmt.Sprintf("%s/todos", api.base(r)),
+			Method: "GET",
+		},
+	}
+
+	api.respond(w, r, http.StatusOK, profile)
+}
@@ -0,0 +1,663 @@
+// Package redisstore implements todo.Store on top of Redis so several server
+// instances behind a load balancer can share one todo set without a
+// relational database. Importing the package registers the "redis" backend.
+//
+// Each todo is a hash at <prefix>:todo:<id>; the sorted set <prefix>:ids
+// (scored by ID) keeps the listing order, <prefix>:next_id allocates IDs and
+// the hash <prefix>:merged maps merged IDs to their survivors and the hash
+// <prefix>:external maps "<source>\x00<external ID>" to the ID of the todo
+// imported under that external ID. The hash <prefix>:milestones maps
+// milestone IDs, allocated from <prefix>:next_milestone_id, to their JSON
+// form, and the hash <prefix>:milestone_todos maps the ID of each todo in a
+// milestone to the milestone's. Mutations that touch more than one key run
+// as Lua scripts so they are atomic.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend is the name under which the store is registered with todo.RegisterStore.
+const Backend = "redis"
+
+// DefaultURL is the server used when no DSN is configured.
+const DefaultURL = "redis://localhost:6379/0"
+
+// DefaultPrefix namespaces the keys written by the store.
+const DefaultPrefix = "todos"
+
+// QueryTimeout bounds every command issued by the store.
+const QueryTimeout = 5 * time.Second
+
+func init() {
+	todo.RegisterStore(Backend, func(cfg todo.StoreConfig) (todo.Store, error) {
+		url := cfg.DSN
+		if url == "" {
+			url = DefaultURL
+		}
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, fmt.Errorf("parse redis URL: %w", err)
+		}
+		return Open(redis.NewClient(opts), DefaultPrefix, cfg.Clock)
+	})
+}
+
+var (
+	// setFieldsScript sets hash fields and increments the version only if
+	// the todo still exists. Todos stored before versioning count as
+	// version 1.
+	setFieldsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+redis.call('HSETNX', KEYS[1], 'version', 1)
+redis.call('HINCRBY', KEYS[1], 'version', 1)
+return 1`)
+
+	// deleteScript removes a todo, its position in the ordering set and its
+	// external ID.
+	// KEYS: todo, ids, external. ARGV: id.
+	deleteScript = redis.NewScript(`
+local ref = redis.call('HMGET', KEYS[1], 'source', 'external_id')
+if redis.call('DEL', KEYS[1]) == 0 then return 0 end
+redis.call('ZREM', KEYS[2], ARGV[1])
+if ref[2] and ref[2] ~= '' then redis.call('HDEL', KEYS[3], (ref[1] or '') .. '\0' .. ref[2]) end
+return 1`)
+
+	// mergeScript folds the source todo into the target, which gains the
+	// tags of the source it lacks.
+	// KEYS: target, source, ids, merged, external. ARGV: targetID, sourceID,
+	// update time.
+	mergeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 or redis.call('EXISTS', KEYS[2]) == 0 then return 0 end
+local ref = redis.call('HMGET', KEYS[2], 'source', 'external_id')
+if ref[2] and ref[2] ~= '' then redis.call('HDEL', KEYS[5], (ref[1] or '') .. '\0' .. ref[2]) end
+local target = redis.call('HGET', KEYS[1], 'description')
+local source = redis.call('HGET', KEYS[2], 'description')
+if source ~= '' then
+	if target ~= '' then target = target .. '\n\n' end
+	target = target .. source
+end
+local tags = redis.call('HGET', KEYS[1], 'tags') or ''
+local seen = {}
+for tag in string.gmatch(tags, '%S+') do seen[tag] = true end
+for tag in string.gmatch(redis.call('HGET', KEYS[2], 'tags') or '', '%S+') do
+	if not seen[tag] then
+		seen[tag] = true
+		if tags ~= '' then tags = tags .. ' ' end
+		tags = tags .. tag
+	end
+end
+redis.call('HSET', KEYS[1], 'description', target, 'tags', tags, 'updated_at', ARGV[3])
+redis.call('HSETNX', KEYS[1], 'version', 1)
+redis.call('HINCRBY', KEYS[1], 'version', 1)
+redis.call('DEL', KEYS[2])
+redis.call('ZREM', KEYS[3], ARGV[2])
+redis.call('HSET', KEYS[4], ARGV[2], ARGV[1])
+return 1`)
+
+	// assignScript moves a todo into a milestone if the milestone exists.
+	// KEYS: milestones, milestone todos. ARGV: milestoneID, todoID.
+	assignScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('HSET', KEYS[2], ARGV[2], ARGV[1])
+return 1`)
+
+	// unassignScript removes a todo from a milestone if the milestone
+	// exists.
+	// KEYS: milestones, milestone todos. ARGV: milestoneID, todoID.
+	unassignScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then return 0 end
+if redis.call('HGET', KEYS[2], ARGV[2]) == ARGV[1] then redis.call('HDEL', KEYS[2], ARGV[2]) end
+return 1`)
+)
+
+// Store is a todo.Store backed by Redis. Redis failures are returned as
+// errors wrapping the failed operation.
+type Store struct {
+	client *redis.Client
+	prefix string
+	clock  todo.Clock
+}
+
+var (
+	_ todo.Store       = (*Store)(nil)
+	_ todo.ClockSource = (*Store)(nil)
+)
+
+// Open checks that the server behind client is reachable and returns a
+// store writing keys under prefix. A nil clock selects todo.SystemClock.
+func Open(client *redis.Client, prefix string, clock todo.Clock) (*Store, error) {
+	if clock == nil {
+		clock = todo.SystemClock{}
+	}
+
+	ctx, cancel := queryContext(context.Background())
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+
+	return &Store{client: client, prefix: prefix, clock: clock}, nil
+}
+
+// Close closes the client.
+func (s *Store) Close() error {
+	return s.client.Close()
+}
+
+// ServerTime returns the current time of the Redis server.
+func (s *Store) ServerTime(ctx context.Context) (time.Time, error) {
+	return s.client.Time(ctx).Result()
+}
+
+// queryContext returns a context derived from ctx and bounded by
+// QueryTimeout, so a query stops when either the caller gives up or the
+// timeout expires.
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+func (s *Store) todoKey(id int) string { return fmt.Sprintf("%s:todo:%d", s.prefix, id) }
+func (s *Store) idsKey() string        { return s.prefix + ":ids" }
+func (s *Store) nextIDKey() string     { return s.prefix + ":next_id" }
+func (s *Store) mergedKey() string     { return s.prefix + ":merged" }
+func (s *Store) externalKey() string   { return s.prefix + ":external" }
+
+func (s *Store) milestonesKey() string      { return s.prefix + ":milestones" }
+func (s *Store) nextMilestoneIDKey() string { return s.prefix + ":next_milestone_id" }
+func (s *Store) milestoneTodosKey() string  { return s.prefix + ":milestone_todos" }
+
+// joinTags returns tags in the stored form. Tags contain no white space,
+// so they are stored separated by spaces.
+func joinTags(tags []string) string {
+	return strings.Join(tags, " ")
+}
+
+// externalField returns the field of the external IDs hash for source and
+// externalID.
+func externalField(source, externalID string) string {
+	return source + "\x00" + externalID
+}
+
+// decode builds a todo from its hash fields; it returns nil for an empty
+// hash, which is how Redis reports a missing key.
+func decode(id int, fields map[string]string) (*todo.Todo, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, fields["created_at"])
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at of todo %d: %w", id, err)
+	}
+	// Todos stored before updates were tracked have no update time.
+	updatedAt := createdAt
+	if v, ok := fields["updated_at"]; ok {
+		if updatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, fmt.Errorf("parse updated_at of todo %d: %w", id, err)
+		}
+	}
+	version := 1
+	if v, ok := fields["version"]; ok {
+		if version, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("parse version of todo %d: %w", id, err)
+		}
+	}
+	// Todos stored before tags were supported have none.
+	var tags []string
+	if v := fields["tags"]; v != "" {
+		tags = strings.Fields(v)
+	}
+	// Todos stored before subtasks were supported have no parent.
+	parentID := 0
+	if v := fields["parent_id"]; v != "" {
+		if parentID, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("parse parent_id of todo %d: %w", id, err)
+		}
+	}
+	return &todo.Todo{
+		ID:          id,
+		Title:       fields["title"],
+		Description: fields["description"],
+		Completed:   fields["completed"] == "1",
+		Archived:    fields["archived"] == "1",
+		Version:     version,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		Source:      fields["source"],
+		ExternalID:  fields["external_id"],
+		Tags:        tags,
+		ParentID:    parentID,
+	}, nil
+}
+
+// GetAll returns all todos ordered by ID.
+func (s *Store) GetAll(ctx context.Context) ([]*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	members, err := s.client.ZRange(ctx, s.idsKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+
+	ids := make([]int, len(members))
+	cmds := make([]*redis.MapStringStringCmd, len(members))
+	_, err = s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, member := range members {
+			id, err := strconv.Atoi(member)
+			if err != nil {
+				return fmt.Errorf("invalid todo ID %q: %w", member, err)
+			}
+			ids[i] = id
+			cmds[i] = p.HGetAll(ctx, s.todoKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+
+	todos := []*todo.Todo{}
+	for i, cmd := range cmds {
+		t, err := decode(ids[i], cmd.Val())
+		if err != nil {
+			return nil, fmt.Errorf("list todos: %w", err)
+		}
+		// A todo deleted between ZRANGE and HGETALL is skipped.
+		if t != nil {
+			todos = append(todos, t)
+		}
+	}
+	return todos, nil
+}
+
+// ListSubtasks returns the todos whose parent is parentID, ordered by ID.
+// There is no index on the parent, so it filters the listing of all todos.
+func (s *Store) ListSubtasks(ctx context.Context, parentID int) ([]*todo.Todo, error) {
+	todos, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subtasks := []*todo.Todo{}
+	for _, t := range todos {
+		if t.ParentID == parentID {
+			subtasks = append(subtasks, t)
+		}
+	}
+	return subtasks, nil
+}
+
+// GetByID returns a todo by its ID, or todo.ErrNotFound.
+func (s *Store) GetByID(ctx context.Context, id int) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	fields, err := s.client.HGetAll(ctx, s.todoKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get todo: %w", err)
+	}
+
+	t, err := decode(id, fields)
+	if err != nil {
+		return nil, fmt.Errorf("get todo: %w", err)
+	}
+	if t == nil {
+		return nil, todo.ErrNotFound
+	}
+	return t, nil
+}
+
+// Create adds a new todo using the provided input.
+func (s *Store) Create(ctx context.Context, input todo.TodoInput) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	id, err := s.client.Incr(ctx, s.nextIDKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+
+	now := s.clock.Now().UTC()
+	t := &todo.Todo{
+		ID:          int(id),
+		Title:       input.Title,
+		Description: input.Description,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
+	}
+	_, err = s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		s.write(ctx, p, t)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+
+	return t, nil
+}
+
+// write queues the commands storing the new todo t on p.
+func (s *Store) write(ctx context.Context, p redis.Pipeliner, t *todo.Todo) {
+	p.HSet(ctx, s.todoKey(t.ID),
+		"title", t.Title,
+		"description", t.Description,
+		"completed", flag(t.Completed),
+		"archived", flag(t.Archived),
+		"version", t.Version,
+		"created_at", t.CreatedAt.Format(time.RFC3339Nano),
+		"updated_at", t.UpdatedAt.Format(time.RFC3339Nano),
+		"source", t.Source,
+		"external_id", t.ExternalID,
+		"tags", joinTags(t.Tags),
+		"parent_id", t.ParentID,
+	)
+	p.ZAdd(ctx, s.idsKey(), redis.Z{Score: float64(t.ID), Member: t.ID})
+	if t.ExternalID != "" {
+		p.HSet(ctx, s.externalKey(), externalField(t.Source, t.ExternalID), t.ID)
+	}
+}
+
+// Seed adds todos if no todo ID has been handed out yet. It watches the ID
+// counter, so the todos are only written if no other server allocated an
+// ID, or seeded, in the meantime.
+func (s *Store) Seed(ctx context.Context, todos []todo.BackupTodo) (bool, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	seeded := false
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		next, err := tx.Get(ctx, s.nextIDKey()).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if next != 0 {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			last := 0
+			for _, bt := range todos {
+				s.write(ctx, p, &todo.Todo{
+					ID:          bt.ID,
+					Title:       bt.Title,
+					Description: bt.Description,
+					Completed:   bt.Completed,
+					Archived:    bt.Archived,
+					Version:     max(bt.Version, 1),
+					CreatedAt:   bt.CreatedAt.UTC(),
+					UpdatedAt:   bt.UpdatedAt.UTC(),
+					Source:      bt.Source,
+					ExternalID:  bt.ExternalID,
+					Tags:        bt.Tags,
+					ParentID:    bt.ParentID,
+				})
+				last = max(last, bt.ID)
+			}
+			p.Set(ctx, s.nextIDKey(), last, 0)
+			return nil
+		})
+		seeded = err == nil
+		return err
+	}, s.nextIDKey())
+	if errors.Is(err, redis.TxFailedErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("seed todos: %w", err)
+	}
+	return seeded, nil
+}
+
+// setFields updates hash fields of an existing todo, stamping its update
+// time, and returns it. It returns todo.ErrNotFound if the todo does not
+// exist.
+func (s *Store) setFields(ctx context.Context, op string, id int, fields ...any) (*todo.Todo, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	fields = append(fields, "updated_at", s.clock.Now().UTC().Format(time.RFC3339Nano))
+	found, err := setFieldsScript.Run(ctx, s.client, []string{s.todoKey(id)}, fields...).Int()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if found == 0 {
+		return nil, todo.ErrNotFound
+	}
+	return s.GetByID(ctx, id)
+}
+
+// Update modifies an existing todo identified by id.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Update(ctx context.Context, id int, input todo.TodoInput) (*todo.Todo, error) {
+	return s.setFields(ctx, "update todo", id, "title", input.Title, "description", input.Description, "tags", joinTags(input.Tags), "parent_id", input.Parent())
+}
+
+// Complete marks the todo with the given ID as completed.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Complete(ctx context.Context, id int) (*todo.Todo, error) {
+	return s.setFields(ctx, "complete todo", id, "completed", "1")
+}
+
+// SetState moves the todo with the given ID to state.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) SetState(ctx context.Context, id int, state todo.State) (*todo.Todo, error) {
+	return s.setFields(ctx, "set todo state", id,
+		"completed", flag(state != todo.StateOpen),
+		"archived", flag(state == todo.StateArchived),
+	)
+}
+
+// flag encodes a boolean hash field.
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// Delete removes the todo with the given ID.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Delete(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	deleted, err := deleteScript.Run(ctx, s.client, []string{s.todoKey(id), s.idsKey(), s.externalKey()}, id).Int()
+	if err != nil {
+		return fmt.Errorf("delete todo: %w", err)
+	}
+	if deleted == 0 {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// Merge folds the todo sourceID into the todo targetID, appending the
+// source description to the target's and recording the source ID as an
+// alias of the target. It returns todo.ErrNotFound if either todo does not
+// exist and todo.ErrMergeIntoItself if they are the same.
+func (s *Store) Merge(ctx context.Context, targetID, sourceID int) (*todo.Todo, error) {
+	if targetID == sourceID {
+		return nil, todo.ErrMergeIntoItself
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	keys := []string{s.todoKey(targetID), s.todoKey(sourceID), s.idsKey(), s.mergedKey(), s.externalKey()}
+	now := s.clock.Now().UTC().Format(time.RFC3339Nano)
+	merged, err := mergeScript.Run(ctx, s.client, keys, targetID, sourceID, now).Int()
+	if err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	if merged == 0 {
+		return nil, todo.ErrNotFound
+	}
+	return s.GetByID(ctx, targetID)
+}
+
+// MergedInto returns the ID of the todo that id was merged into, following
+// chains of merges. It returns todo.ErrNotFound if id was never merged or
+// its survivor no longer exists.
+func (s *Store) MergedInto(ctx context.Context, id int) (int, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	survivor := 0
+	for current := id; ; {
+		next, err := s.client.HGet(ctx, s.mergedKey(), strconv.Itoa(current)).Int()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("resolve merge: %w", err)
+		}
+		survivor, current = next, next
+	}
+
+	if survivor == 0 {
+		return 0, todo.ErrNotFound
+	}
+	exists, err := s.client.Exists(ctx, s.todoKey(survivor)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("resolve merge: %w", err)
+	}
+	if exists == 0 {
+		return 0, todo.ErrNotFound
+	}
+	return survivor, nil
+}
+
+// FindByExternalID returns the todo imported from source with the given
+// external ID, or todo.ErrNotFound.
+func (s *Store) FindByExternalID(ctx context.Context, source, externalID string) (*todo.Todo, error) {
+	if externalID == "" {
+		return nil, todo.ErrNotFound
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	id, err := s.client.HGet(ctx, s.externalKey(), externalField(source, externalID)).Int()
+	if errors.Is(err, redis.Nil) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find todo by external id: %w", err)
+	}
+	return s.GetByID(ctx, id)
+}
+
+// milestoneRecord is the stored form of a milestone.
+type milestoneRecord struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// Milestones returns all milestones ordered by ID, read in one
+// transaction.
+func (s *Store) Milestones(ctx context.Context) ([]todo.Milestone, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var stored, assignments *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		stored = p.HGetAll(ctx, s.milestonesKey())
+		assignments = p.HGetAll(ctx, s.milestoneTodosKey())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list milestones: %w", err)
+	}
+
+	milestones := []todo.Milestone{}
+	for field, value := range stored.Val() {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid milestone ID %q: %w", field, err)
+		}
+		var r milestoneRecord
+		if err := json.Unmarshal([]byte(value), &r); err != nil {
+			return nil, fmt.Errorf("decode milestone %d: %w", id, err)
+		}
+		milestones = append(milestones, todo.Milestone{ID: id, Name: r.Name, StartDate: r.StartDate, EndDate: r.EndDate})
+	}
+	slices.SortFunc(milestones, func(a, b todo.Milestone) int { return a.ID - b.ID })
+
+	assigned := map[int]int{}
+	for field, value := range assignments.Val() {
+		todoID, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid todo ID %q: %w", field, err)
+		}
+		milestoneID, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid milestone ID %q of todo %d: %w", value, todoID, err)
+		}
+		assigned[todoID] = milestoneID
+	}
+	todo.AttachTodoIDs(milestones, assigned)
+	return milestones, nil
+}
+
+// CreateMilestone adds a milestone. The input must already be validated.
+func (s *Store) CreateMilestone(ctx context.Context, input todo.MilestoneInput) (todo.Milestone, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	id, err := s.client.Incr(ctx, s.nextMilestoneIDKey()).Result()
+	if err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	// Encoding strings cannot fail.
+	value, _ := json.Marshal(milestoneRecord{Name: input.Name, StartDate: input.StartDate, EndDate: input.EndDate})
+	if err := s.client.HSet(ctx, s.milestonesKey(), id, value).Err(); err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	return todo.Milestone{ID: int(id), Name: input.Name, StartDate: input.StartDate, EndDate: input.EndDate, TodoIDs: []int{}}, nil
+}
+
+// AssignMilestone moves the todo into the milestone, out of any other.
+// It returns todo.ErrNotFound if the milestone does not exist.
+func (s *Store) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "assign todo", assignScript, milestoneID, todoID)
+}
+
+// UnassignMilestone removes the todo from the milestone. It returns
+// todo.ErrNotFound if the milestone does not exist.
+func (s *Store) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "unassign todo", unassignScript, milestoneID, todoID)
+}
+
+// moveTodo runs script, which moves the todo into or out of the milestone.
+// It returns todo.ErrNotFound if the milestone does not exist.
+func (s *Store) moveTodo(ctx context.Context, op string, script *redis.Script, milestoneID, todoID int) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	found, err := script.Run(ctx, s.client, []string{s.milestonesKey(), s.milestoneTodosKey()}, milestoneID, todoID).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if found == 0 {
+		return todo.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/storetest"
+	"github.com/redis/go-redis/v9"
+)
+
+func openTestStore(t *testing.T, server *miniredis.Miniredis) *Store {
+	t.Helper()
+
+	store, err := Open(redis.NewClient(&redis.Options{Addr: server.Addr()}), DefaultPrefix, nil)
+	if err != nil {
+		t.Fatalf("failed to open redis store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return openTestStore(t, miniredis.RunT(t))
+	})
+}
+
+func TestInstancesShareState(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := openTestStore(t, server)
+	second := openTestStore(t, server)
+
+	created := storetest.Create(t, first, todo.TodoInput{Title: "Shared"})
+	if _, err := second.Complete(t.Context(), created.ID); err != nil {
+		t.Fatalf("expected todo %d created by one instance to be visible to another", created.ID)
+	}
+
+	fetched, err := first.GetByID(t.Context(), created.ID)
+	if err != nil || !fetched.Completed {
+		t.Fatalf("expected completion by the second instance to be visible, got %+v", fetched)
+	}
+	if next := storetest.Create(t, second, todo.TodoInput{Title: "Next"}); next.ID != created.ID+1 {
+		t.Fatalf("expected instances to share ID allocation, got %d after %d", next.ID, created.ID)
+	}
+}
+
+func TestRegisteredBackend(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	store, err := todo.NewStoreFromConfig(todo.StoreConfig{
+		Backend: Backend,
+		DSN:     "redis://" + server.Addr() + "/0",
+	})
+	if err != nil {
+		t.Fatalf("expected redis backend to be registered, got %v", err)
+	}
+	defer store.(*Store).Close()
+}
+
+func TestServerTime(t *testing.T) {
+	server := miniredis.RunT(t)
+	want := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	server.SetTime(want)
+
+	got, err := openTestStore(t, server).ServerTime(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read server time: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Fatalf("expected server time %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,121 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// curiePrefix is the CURIE prefix of the API's own link relations, such as
+// "todo:find".
+const curiePrefix = "todo"
+
+const (
+	relFind = "find"
+	relPage = "page"
+)
+
+// Curie is a compact URI template for link relation names. The relation
+// "todo:find" expands to the documentation at Href with {rel} set to "find".
+type Curie struct {
+	Name      string `json:"name"`
+	Href      string `json:"href"`
+	Templated bool   `json:"templated"`
+}
+
+// Relation documents a link relation of the API, served under /rels/{rel}.
+type Relation struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Method      string         `json:"method"`
+	Variables   []ProfileField `json:"variables"`
+	Links       Links          `json:"_links"`
+}
+
+// relations holds the documentation of the relations named through the
+// curie prefix.
+var relations = map[string]Relation{
+	relFind: {
+		Name:        curiePrefix + ":" + relFind,
+		Description: "Fetches a single todo by ID. The href is an RFC 6570 URI template; expand it instead of building todo URLs by hand.",
+		Method:      http.MethodGet,
+		Variables: []ProfileField{
+			{Name: "id", Type: "integer", Description: "ID of the todo."},
+		},
+	},
+	relPage: {
+		Name:        curiePrefix + ":" + relPage,
+		Description: "Fetches a page of the todo collection. The href is an RFC 6570 URI template; omitted variables take their defaults.",
+		Method:      http.MethodGet,
+		Variables: []ProfileField{
+			{Name: "page", Type: "integer", Description: "Page number, starting at 1."},
+			{Name: "per_page", Type: "integer", Description: "Page size between 1 and 100; defaults to 10."},
+			{Name: "sort", Type: "string", Description: "Order of the todos: id (the default), title (alphabetically), created (newest first) or updated (most recently changed first)."},
+			{Name: "tag", Type: "string", Description: "Lists only the todos carrying this tag."},
+		},
+	},
+}
+
+// HeaderLinkRel is the request header in which clients name the link
+// relation they followed, such as "next" or "complete", so maintainers can
+// learn from opt-in usage reports which affordances are used.
+const HeaderLinkRel = "X-Link-Rel"
+
+// LinkRelations returns the names of the link relations the API serves, in
+// sorted order.
+func LinkRelations() []string {
+	var rels []string
+	linkType, linksType := reflect.TypeFor[*Link](), reflect.TypeFor[[]Link]()
+	for _, links := range []any{Links{}, CollectionLinks{}, APIRootLinks{}, MilestoneLinks{}, ChangeFeedLinks{}, ImportJobLinks{}, ImportUploadLinks{}, TagLinks{}} {
+		for field := range reflect.TypeOf(links).Fields() {
+			if field.Type != linkType && field.Type != linksType {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !slices.Contains(rels, name) {
+				rels = append(rels, name)
+			}
+		}
+	}
+	slices.Sort(rels)
+	return rels
+}
+
+// buildCuries returns the curies of the API's link relations.
+func buildCuries(baseURL string) []Curie {
+	return []Curie{{Name: curiePrefix, Href: fmt.Sprintf("%s/rels/{rel}", baseURL), Templated: true}}
+}
+
+// buildFindLink returns the templated link to a single todo.
+func buildFindLink(baseURL string) *Link {
+	return &Link{Href: fmt.Sprintf("%s/todos{/id}", baseURL), Method: "GET", Templated: true}
+}
+
+// buildPageLink returns the templated link to a page of the collection.
+func buildPageLink(baseURL string) *Link {
+	return &Link{Href: fmt.Sprintf("%s/todos{?page,per_page,sort,tag}", baseURL), Method: "GET", Templated: true}
+}
+
+// GetRelation handles GET /rels/{rel} and documents the link relation.
+func (api *TodoAPI) GetRelation(w http.ResponseWriter, r *http.Request) {
+	rel := chi.URLParam(r, "rel")
+	relation, exists := relations[rel]
+	if !exists {
+		api.sendError(w, r, http.StatusNotFound, "Relation not found", fmt.Sprintf("Relation %q does not exist", rel))
+		return
+	}
+
+	relation.Links = Links{
+		Self: &Link{Href: fmt.Sprintf("%s/rels/%s", api.base(r), rel), Method: "GET"},
+		Todos: &Link{
+			Href:   fmt.Sprintf("%s/todos", api.base(r)),
+			Method: "GET",
+		},
+	}
+
+	api.respond(w, r, http.StatusOK, relation)
+}
@@ -0,0 +1,153 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// mediaTypeFromContext returns the media type negotiated by the negotiate
+// middleware, falling back to MediaTypeJSON.
+func mediaTypeFromContext(ctx context.Context) string {
+	if mediaType, ok := ctx.Value(mediaTypeKey{}).(string); ok {
+		return mediaType
+	}
+	return MediaTypeJSON
+}
+
+// encodePayload encodes payload as JSON, converting panics raised by custom
+// marshalers into errors.
+func encodePayload(payload any) (body []byte, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic while encoding response: %v", rec)
+		}
+	}()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// respond writes payload with the given status code in the negotiated media
+// type. Payloads without a plain-text representation are served as JSON to
+// text/plain clients; HAL documents and HTML pages are derived from the JSON
+// representation. Whatever the media type, the main links of the payload are
+// also sent in a Link header. The payload is encoded before anything is written, so
+// encoding failures are logged and turned into a 500 error instead of a
+// truncated body. A nil payload writes only the status code.
+func (api *TodoAPI) respond(w http.ResponseWriter, r *http.Request, status int, payload any) {
+	if payload == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	setLinkHeader(w, payload)
+	mediaType := mediaTypeFromContext(r.Context())
+	var tag string
+	if todo, ok := payload.(Todo); ok {
+		tag = todoETag(&todo, mediaType)
+	}
+	if text, ok := payload.(textRenderer); ok && mediaType == MediaTypeText {
+		api.write(w, r, status, textContentType, renderText(text), tag)
+		return
+	}
+	if mediaType == MediaTypeText {
+		mediaType = MediaTypeJSON
+	}
+
+	body, err := encodePayload(payload)
+	if err != nil {
+		log.Printf("todo: failed to encode %s %s response: %v", r.Method, r.URL.Path, err)
+
+		status = http.StatusInternalServerError
+		payload = ErrorResponse{
+			Error:   "Internal server error",
+			Message: "The response could not be encoded",
+			Links:   buildErrorLinks(api.base(r)),
+		}
+		body, _ = encodePayload(payload)
+		w.Header().Del("Link")
+	}
+	if mediaType == MediaTypeHAL || mediaType == MediaTypeHALForms {
+		hal, err := toHAL(body)
+		if err == nil {
+			api.write(w, r, status, mediaType, hal, tag)
+			return
+		}
+		log.Printf("todo: failed to render %s %s as HAL: %v", r.Method, r.URL.Path, err)
+		mediaType = MediaTypeJSON
+	}
+	if mediaType == MediaTypeSiren {
+		siren, err := toSiren(body, sirenClass(payload))
+		if err == nil {
+			api.write(w, r, status, mediaType, siren, tag)
+			return
+		}
+		log.Printf("todo: failed to render %s %s as Siren: %v", r.Method, r.URL.Path, err)
+		mediaType = MediaTypeJSON
+	}
+	if mediaType == MediaTypeMsgpack {
+		packed, err := toMsgpack(body)
+		if err == nil {
+			api.write(w, r, status, mediaType, packed, tag)
+			return
+		}
+		log.Printf("todo: failed to render %s %s as MessagePack: %v", r.Method, r.URL.Path, err)
+		mediaType = MediaTypeJSON
+	}
+	if mediaType == MediaTypeXML {
+		doc, err := toXML(body, xmlRootName(payload))
+		if err == nil {
+			api.write(w, r, status, xmlContentType, doc, tag)
+			return
+		}
+		log.Printf("todo: failed to render %s %s as XML: %v", r.Method, r.URL.Path, err)
+		mediaType = MediaTypeJSON
+	}
+	if mediaType == MediaTypeHTML {
+		page, err := api.renderHTML(r, payload, body)
+		if err == nil {
+			api.write(w, r, status, htmlContentType, page, tag)
+			return
+		}
+		log.Printf("todo: failed to render %s %s as HTML: %v", r.Method, r.URL.Path, err)
+		mediaType = MediaTypeJSON
+	}
+
+	api.write(w, r, status, mediaType, body, tag)
+}
+
+// write sends body with the given status code and Content-Type. Successful
+// GET and HEAD responses carry tag as their ETag, or a tag of the body if
+// it is empty, and are answered with
+// 304 Not Modified when If-None-Match or If-Modified-Since shows the
+// client's copy is current; HEAD responses carry the same headers as GET
+// but no body.
+func (api *TodoAPI) write(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte, tag string) {
+	if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		if tag == "" {
+			tag = etag(body)
+		}
+		w.Header().Set("ETag", tag)
+		if noneMatch(r, tag) || notModifiedSince(w, r) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		log.Printf("todo: failed to write %s %s response: %v", r.Method, r.URL.Path, err)
+	}
+}
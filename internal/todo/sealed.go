@@ -0,0 +1,125 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// SealedBackupFormat identifies the plaintext layout inside an encrypted backup.
+const SealedBackupFormat = "todo-backup/v1"
+
+// ageHeader and ageArmorHeader start binary and ASCII-armored age files.
+const (
+	ageHeader      = "age-encryption.org/"
+	ageArmorHeader = armor.Header
+)
+
+// BackupManifest describes the backup it is sealed with, so a restore can
+// verify that the dump is complete and unmodified.
+type BackupManifest struct {
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	Todos     int       `json:"todos"`
+	SHA256    string    `json:"sha256"`
+}
+
+// SealedBackup is the plaintext of an encrypted backup: the dump together
+// with its manifest.
+type SealedBackup struct {
+	Manifest BackupManifest  `json:"manifest"`
+	Backup   json.RawMessage `json:"backup"`
+}
+
+// sealBackup encrypts b with its manifest to recipients.
+func sealBackup(b Backup, at time.Time, recipients []age.Recipient) ([]byte, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	sealed, err := json.Marshal(SealedBackup{
+		Manifest: BackupManifest{
+			Format:    SealedBackupFormat,
+			CreatedAt: at,
+			Todos:     len(b.Todos),
+			SHA256:    hex.EncodeToString(sum[:]),
+		},
+		Backup: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt backup: %w", err)
+	}
+	if _, err := w.Write(sealed); err != nil {
+		return nil, fmt.Errorf("encrypt backup: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("encrypt backup: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// isSealed reports whether the buffered body starts like an age file,
+// binary or armored.
+func isSealed(body *bufio.Reader) bool {
+	for _, header := range []string{ageHeader, ageArmorHeader} {
+		if prefix, _ := body.Peek(len(header)); string(prefix) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// openSealedBackup decrypts an age-encrypted backup with identities and
+// verifies it against its manifest.
+func openSealedBackup(body *bufio.Reader, identities []age.Identity) (Backup, error) {
+	var src io.Reader = body
+	if prefix, _ := body.Peek(len(ageArmorHeader)); string(prefix) == ageArmorHeader {
+		src = armor.NewReader(body)
+	}
+
+	plain, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return Backup{}, fmt.Errorf("decrypt backup: %w", err)
+	}
+
+	var sealed SealedBackup
+	dec := json.NewDecoder(plain)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sealed); err != nil {
+		return Backup{}, fmt.Errorf("read encrypted backup: %w", err)
+	}
+	if sealed.Manifest.Format != SealedBackupFormat {
+		return Backup{}, fmt.Errorf("unsupported backup format %q", sealed.Manifest.Format)
+	}
+	sum := sha256.Sum256(sealed.Backup)
+	if hex.EncodeToString(sum[:]) != sealed.Manifest.SHA256 {
+		return Backup{}, errors.New("backup does not match its manifest checksum")
+	}
+
+	var b Backup
+	dec = json.NewDecoder(bytes.NewReader(sealed.Backup))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		return Backup{}, fmt.Errorf("read backup: %w", err)
+	}
+	if len(b.Todos) != sealed.Manifest.Todos {
+		return Backup{}, fmt.Errorf("backup holds %d todos, manifest lists %d", len(b.Todos), sealed.Manifest.Todos)
+	}
+	return b, nil
+}
@@ -1,64 +1,732 @@
 package todo
 
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/efrem/windsurf/internal/events"
+)
+
 // Service defines a high-level facade for working with Todo entities.
 // It exposes operations for listing, retrieving, creating, updating,
 // completing, and deleting todos without exposing storage details.
+// Failures are reported with errors matching ErrNotFound, ErrValidation,
+// ErrConflict or ErrPreconditionFailed, and failures of the store with
+// errors matching ErrUnavailable. Every method takes the context of the
+// request it serves and passes it on to the Store.
 type Service interface {
-	ListTodos() []*Todo
-	GetTodo(id int) (*Todo, bool)
-	// CreateTodo creates a new todo using the provided input.
-	CreateTodo(input TodoInput) *Todo
-	// UpdateTodo updates an existing todo identified by id.
-	// The boolean indicates whether the todo was found.
-	UpdateTodo(id int, input TodoInput) (*Todo, bool)
-	// CompleteTodo marks the specified todo as completed.
-	// The boolean indicates whether the todo was found.
-	CompleteTodo(id int) (*Todo, bool)
-	// DeleteTodo removes the todo with the given ID from the store.
-	// It returns true if a todo was deleted, or false if none existed.
-	DeleteTodo(id int) bool
-}
-
-// service is the concrete implementation of Service backed by a TodoStore.
+	ListTodos(ctx context.Context) ([]*Todo, error)
+	// SnapshotTodos returns all todos together with the sequence number
+	// identifying this state of the store. The boolean is false if the
+	// store does not support snapshots.
+	SnapshotTodos(ctx context.Context) ([]*Todo, int, bool, error)
+	// ListTodosAt returns the todos as of the given snapshot sequence.
+	// The boolean is false if the snapshot is unknown or has expired.
+	ListTodosAt(ctx context.Context, seq int) ([]*Todo, bool)
+	// GetTodo returns a todo by ID, or ErrNotFound.
+	GetTodo(ctx context.Context, id int) (*Todo, error)
+	// ListSubtasks returns the subtasks of the todo with the given ID,
+	// ordered by ID.
+	ListSubtasks(ctx context.Context, id int) ([]*Todo, error)
+	// CreateTodo creates a new todo using the provided input. It returns
+	// a *ValidationError if the input has no title, an invalid external
+	// ID or an invalid parent.
+	CreateTodo(ctx context.Context, input TodoInput) (*Todo, error)
+	// UpdateTodo updates an existing todo identified by id. Nil tags and
+	// a nil parent keep the todo's. It returns ErrNotFound for unknown
+	// todos and a *ValidationError if the input has no title or an
+	// invalid parent.
+	UpdateTodo(ctx context.Context, id int, input TodoInput) (*Todo, error)
+	// TransitionTodo moves the specified todo through the lifecycle
+	// transition, such as completing or archiving it. It returns
+	// ErrNotFound for unknown todos and a *TransitionError, which matches
+	// ErrConflict, if the transition is not allowed from the todo's
+	// current state. Completing a todo with open subtasks fails with a
+	// *SubtaskError, which also matches ErrConflict, or completes them
+	// first, depending on the subtask policy.
+	TransitionTodo(ctx context.Context, id int, transition Transition) (*Todo, error)
+	// DeleteTodo removes the todo with the given ID from the store, and
+	// the parent of its subtasks. It returns ErrNotFound if none existed.
+	DeleteTodo(ctx context.Context, id int) error
+	// UpdateTodoIf, TransitionTodoIf and DeleteTodoIf are the conditional
+	// forms of UpdateTodo, TransitionTodo and DeleteTodo: pre is checked
+	// against the current todo atomically with the change, which fails with
+	// ErrPreconditionFailed if it does not hold. A nil pre always holds.
+	UpdateTodoIf(ctx context.Context, id int, input TodoInput, pre Precondition) (*Todo, error)
+	TransitionTodoIf(ctx context.Context, id int, transition Transition, pre Precondition) (*Todo, error)
+	DeleteTodoIf(ctx context.Context, id int, pre Precondition) error
+	// PatchTodoIf computes an edit of the todo with edit and applies it
+	// atomically, if pre holds: the title, description, tags and parent of
+	// the returned input replace the todo's, and its status, if set, is
+	// reached through the allowed transitions. Errors returned by edit are
+	// returned as is.
+	PatchTodoIf(ctx context.Context, id int, edit func(current *Todo) (TodoInput, error), pre Precondition) (*Todo, error)
+	// MergeTodos folds the todo sourceID into the todo targetID and returns
+	// the surviving todo. The subtasks of sourceID lose their parent. It
+	// returns ErrNotFound if either todo does not exist,
+	// ErrMergeIntoItself if they are the same, and a *ValidationError
+	// matching ErrTooLong if the joined description exceeds the limits.
+	MergeTodos(ctx context.Context, targetID, sourceID int) (*Todo, error)
+	// MergedInto returns the ID of the todo that id was merged into,
+	// or ErrNotFound if id was never merged.
+	MergedInto(ctx context.Context, id int) (int, error)
+	// FindTodoByExternalID returns the todo imported from source with the
+	// given external ID, or ErrNotFound.
+	FindTodoByExternalID(ctx context.Context, source, externalID string) (*Todo, error)
+	// ListMilestones returns all milestones ordered by ID.
+	ListMilestones(ctx context.Context) ([]Milestone, error)
+	// GetMilestone returns a milestone by ID, or ErrNotFound.
+	GetMilestone(ctx context.Context, id int) (Milestone, error)
+	// CreateMilestone creates a milestone from input, which must already
+	// be validated.
+	CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error)
+	// AssignMilestone moves the todo todoID into the milestone milestoneID,
+	// out of any other milestone, and returns the milestone. It returns
+	// ErrNotFound if the milestone does not exist.
+	AssignMilestone(ctx context.Context, milestoneID, todoID int) (Milestone, error)
+	// UnassignMilestone removes the todo todoID from the milestone
+	// milestoneID and returns the milestone. It returns ErrNotFound if the
+	// milestone does not exist.
+	UnassignMilestone(ctx context.Context, milestoneID, todoID int) (Milestone, error)
+	// UpsertTodo creates a todo from input unless one with the same source
+	// and external ID exists, in which case strategy decides how the
+	// existing todo is updated. Inputs without an external ID are always
+	// created. It returns a *ValidationError if the parent of a todo to
+	// create is invalid; existing todos keep theirs.
+	UpsertTodo(ctx context.Context, input TodoInput, strategy ConflictStrategy) (*Todo, UpsertOutcome, error)
+	// BackupTodos returns a full dump of the store. The boolean is false
+	// if the store does not support backups.
+	BackupTodos(ctx context.Context) (Backup, bool, error)
+	// RestoreTodos replaces the content of the store with b. The boolean
+	// is false if the store does not support restoring backups.
+	RestoreTodos(ctx context.Context, b Backup) (bool, error)
+	// SeedTodos adds todos, keeping their IDs and states, if the store has
+	// never handed out a todo ID, and reports whether it did. Todos without
+	// a creation time are stamped with the current time. It returns a
+	// *ValidationError if a todo exceeds the limits.
+	SeedTodos(ctx context.Context, todos []BackupTodo) (bool, error)
+	// Limits returns the limits the title and description of todos are
+	// held to. Creations and edits exceeding them fail with a
+	// *ValidationError matching ErrTooLong; transports check inputs they
+	// upsert against them with ValidateInput.
+	Limits() Limits
+}
+
+// Precondition reports whether a conditional mutation may change the todo,
+// given its current state.
+type Precondition func(current *Todo) bool
+
+// ErrPreconditionFailed is returned by conditional mutations whose
+// precondition does not hold.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// service is the concrete implementation of Service backed by a Store.
+// Successful mutations are published as domain events. Mutations are
+// serialized with their publication so events are published in the order
+// the changes were applied.
 type service struct {
-	store *TodoStore
+	mu        sync.Mutex
+	store     Store
+	clock     Clock
+	publisher events.Publisher
+	limits    Limits
+	// subtaskPolicy decides what completing a todo with open subtasks
+	// does.
+	subtaskPolicy SubtaskPolicy
 }
 
-// NewService constructs a Service backed by the given TodoStore.
-func NewService(store *TodoStore) Service {
-	return &service{store: store}
+// NewService constructs a Service backed by the given Store.
+func NewService(store Store) Service {
+	return NewPublishingService(store, SystemClock{}, nil)
+}
+
+// NewPublishingService constructs a Service backed by the given Store that
+// publishes an event to publisher after every successful mutation, stamped
+// with the time of clock. A nil publisher disables events.
+func NewPublishingService(store Store, clock Clock, publisher events.Publisher) Service {
+	return newService(store, clock, publisher, DefaultLimits())
+}
+
+// newService is NewPublishingService holding todos to limits.
+func newService(store Store, clock Clock, publisher events.Publisher, limits Limits) *service {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &service{store: store, clock: clock, publisher: publisher, limits: limits, subtaskPolicy: SubtaskPolicyBlock}
+}
+
+// Limits returns the limits of the title and description of todos.
+func (s *service) Limits() Limits {
+	return s.limits
+}
+
+// publish sends the event built by event to the publisher, if any.
+func (s *service) publish(event func(at time.Time) events.Event) {
+	if s.publisher == nil {
+		return
+	}
+	s.publisher.Publish(event(s.clock.Now().UTC()))
+}
+
+// eventTodo converts a todo to its event representation.
+func eventTodo(todo *Todo) events.Todo {
+	return events.Todo{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		Archived:    todo.Archived,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+		Source:      todo.Source,
+		ExternalID:  todo.ExternalID,
+		Tags:        todo.Tags,
+		ParentID:    todo.ParentID,
+	}
 }
 
 // ListTodos returns all todos from the underlying store.
-func (s *service) ListTodos() []*Todo {
-	return s.store.GetAll()
+func (s *service) ListTodos(ctx context.Context) ([]*Todo, error) {
+	return s.store.GetAll(ctx)
+}
+
+// SnapshotTodos returns all todos together with the sequence number
+// identifying this state of the store. The boolean is false if the
+// store does not support snapshots.
+func (s *service) SnapshotTodos(ctx context.Context) ([]*Todo, int, bool, error) {
+	snapshots, ok := s.store.(SnapshotStore)
+	if !ok {
+		todos, err := s.store.GetAll(ctx)
+		return todos, 0, false, err
+	}
+	todos, seq := snapshots.Snapshot(ctx)
+	return todos, seq, true, nil
+}
+
+// ListTodosAt returns the todos as of the given snapshot sequence.
+// The boolean is false if the snapshot is unknown, has expired, or the
+// store does not support snapshots.
+func (s *service) ListTodosAt(ctx context.Context, seq int) ([]*Todo, bool) {
+	snapshots, ok := s.store.(SnapshotStore)
+	if !ok {
+		return nil, false
+	}
+	return snapshots.ListAt(ctx, seq)
+}
+
+// GetTodo returns a todo by ID from the underlying store, or ErrNotFound.
+func (s *service) GetTodo(ctx context.Context, id int) (*Todo, error) {
+	return s.store.GetByID(ctx, id)
 }
 
-// GetTodo returns a todo by ID from the underlying store.
-// The boolean indicates whether a todo with the given ID exists.
-func (s *service) GetTodo(id int) (*Todo, bool) {
-	return s.store.GetByID(id)
+// ListSubtasks returns the subtasks of the todo with the given ID from
+// the underlying store, ordered by ID.
+func (s *service) ListSubtasks(ctx context.Context, id int) ([]*Todo, error) {
+	return s.store.ListSubtasks(ctx, id)
 }
 
-// CreateTodo creates a new todo using the provided input.
-func (s *service) CreateTodo(input TodoInput) *Todo {
-	return s.store.Create(input)
+// CreateTodo validates input and creates a new todo from it.
+func (s *service) CreateTodo(ctx context.Context, input TodoInput) (*Todo, error) {
+	if err := ValidateInput(&input, s.limits); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkParent(ctx, 0, input); err != nil {
+		return nil, err
+	}
+	// Like every change of the store, the create is skipped once the
+	// request's deadline passed: the client got a 504 and must not see
+	// it applied.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	todo, err := s.store.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return events.TodoCreated{At: at, Todo: eventTodo(todo)}
+	})
+	return todo, nil
 }
 
 // UpdateTodo updates an existing todo identified by id.
-// The boolean indicates whether the todo was found.
-func (s *service) UpdateTodo(id int, input TodoInput) (*Todo, bool) {
-	return s.store.Update(id, input)
+func (s *service) UpdateTodo(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	return s.UpdateTodoIf(ctx, id, input, nil)
+}
+
+// UpdateTodoIf updates the todo identified by id if pre holds for it.
+func (s *service) UpdateTodoIf(ctx context.Context, id int, input TodoInput, pre Precondition) (*Todo, error) {
+	if err := validateEdit(&input, s.limits); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.check(ctx, id, pre)
+	if err != nil {
+		return nil, err
+	}
+	if input.Tags == nil {
+		input.Tags = current.Tags
+	}
+	if input.ParentID == nil {
+		input.ParentID = &current.ParentID
+	} else if err := s.checkParent(ctx, id, input); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, id, input)
+}
+
+// update applies input to the todo and publishes the change. The caller
+// must hold s.mu.
+func (s *service) update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	todo, err := s.store.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return events.TodoUpdated{At: at, Todo: eventTodo(todo)}
+	})
+	return todo, nil
+}
+
+// TransitionTodo moves the specified todo through the lifecycle
+// transition. It returns ErrNotFound for unknown todos and a
+// *TransitionError if the transition is not allowed from the todo's
+// current state.
+func (s *service) TransitionTodo(ctx context.Context, id int, transition Transition) (*Todo, error) {
+	return s.TransitionTodoIf(ctx, id, transition, nil)
+}
+
+// TransitionTodoIf moves the todo through transition if pre holds for it.
+func (s *service) TransitionTodoIf(ctx context.Context, id int, transition Transition, pre Precondition) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, err := s.check(ctx, id, pre)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, todo, transition)
+}
+
+// PatchTodoIf applies the edit computed from the current todo if pre holds
+// for it.
+func (s *service) PatchTodoIf(ctx context.Context, id int, edit func(current *Todo) (TodoInput, error), pre Precondition) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, err := s.check(ctx, id, pre)
+	if err != nil {
+		return nil, err
+	}
+	input, err := edit(todo)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEdit(&input, s.limits); err != nil {
+		return nil, err
+	}
+	if input.Parent() != todo.ParentID {
+		if err := s.checkParent(ctx, id, input); err != nil {
+			return nil, err
+		}
+	}
+	// A blocked completion must fail before the other changes apply.
+	if path, _ := TransitionPath(todo.State(), input.Status); slices.Contains(path, TransitionComplete) {
+		if err := s.completable(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+	if input.Title != todo.Title || input.Description != todo.Description || !slices.Equal(input.Tags, todo.Tags) || input.Parent() != todo.ParentID {
+		parentID := input.Parent()
+		if todo, err = s.update(ctx, id, TodoInput{Title: input.Title, Description: input.Description, Tags: input.Tags, ParentID: &parentID}); err != nil {
+			return nil, err
+		}
+	}
+	if input.Status != "" && input.Status != todo.State() {
+		return s.moveTo(ctx, todo, input.Status)
+	}
+	return todo, nil
+}
+
+// check returns the todo with the given ID, or ErrNotFound, or
+// ErrPreconditionFailed if pre does not hold for it. The caller must hold
+// s.mu.
+func (s *service) check(ctx context.Context, id int, pre Precondition) (*Todo, error) {
+	todo, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pre != nil && !pre(todo) {
+		return nil, ErrPreconditionFailed
+	}
+	return todo, nil
+}
+
+// transition applies transition to todo and publishes the matching event.
+// Completions apply the subtask policy first. The caller must hold s.mu.
+func (s *service) transition(ctx context.Context, todo *Todo, transition Transition) (*Todo, error) {
+	next, err := NextState(todo.State(), transition)
+	if err != nil {
+		return todo, err
+	}
+	if transition == TransitionComplete {
+		if err := s.completable(ctx, todo); err != nil {
+			return todo, err
+		}
+		if err := s.completeSubtasks(ctx, todo); err != nil {
+			return todo, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	updated, err := s.store.SetState(ctx, todo.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return transitionEvent(transition, at, eventTodo(updated))
+	})
+	return updated, nil
 }
 
-// CompleteTodo marks the specified todo as completed.
-// The boolean indicates whether the todo was found.
-func (s *service) CompleteTodo(id int) (*Todo, bool) {
-	return s.store.Complete(id)
+// moveTo drives todo to state along the shortest path of allowed
+// transitions, publishing an event for each step. It stops at the first
+// step that fails and returns its error. The caller must hold s.mu.
+func (s *service) moveTo(ctx context.Context, todo *Todo, state State) (*Todo, error) {
+	path, _ := TransitionPath(todo.State(), state)
+	for _, transition := range path {
+		next, err := s.transition(ctx, todo, transition)
+		if err != nil {
+			return nil, err
+		}
+		todo = next
+	}
+	return todo, nil
+}
+
+// transitionEvent returns the event published after transition.
+func transitionEvent(transition Transition, at time.Time, todo events.Todo) events.Event {
+	switch transition {
+	case TransitionReopen:
+		return events.TodoReopened{At: at, Todo: todo}
+	case TransitionArchive:
+		return events.TodoArchived{At: at, Todo: todo}
+	case TransitionUnarchive:
+		return events.TodoUnarchived{At: at, Todo: todo}
+	}
+	return events.TodoCompleted{At: at, Todo: todo}
 }
 
 // DeleteTodo removes the todo with the given ID from the store.
-// It returns true if a todo was deleted, or false if none existed.
-func (s *service) DeleteTodo(id int) bool {
-	return s.store.Delete(id)
+// It returns ErrNotFound if none existed.
+func (s *service) DeleteTodo(ctx context.Context, id int) error {
+	return s.DeleteTodoIf(ctx, id, nil)
+}
+
+// DeleteTodoIf removes the todo with the given ID if pre holds for it.
+func (s *service) DeleteTodoIf(ctx context.Context, id int, pre Precondition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.check(ctx, id, pre); err != nil {
+		return err
+	}
+	return s.delete(ctx, id)
+}
+
+// delete removes the todo, publishes the deletion and detaches its
+// subtasks. The caller must hold s.mu.
+func (s *service) delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return events.TodoDeleted{At: at, ID: id}
+	})
+	return s.detachSubtasks(ctx, id)
+}
+
+// MergeTodos folds the todo sourceID into the todo targetID and returns
+// the surviving todo. It fails like Store.Merge.
+func (s *service) MergeTodos(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkMerge(ctx, targetID, sourceID); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	todo, err := s.store.Merge(ctx, targetID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return events.TodoMerged{At: at, Target: eventTodo(todo), SourceID: sourceID}
+	})
+	if err := s.detachSubtasks(ctx, sourceID); err != nil {
+		return nil, err
+	}
+	if todo.ParentID == sourceID {
+		// The target was a subtask of the source and has been detached.
+		return s.store.GetByID(ctx, targetID)
+	}
+	return todo, nil
+}
+
+// checkMerge rejects merging sourceID into targetID if the joined
+// description would exceed the limits, which the stores do not check.
+func (s *service) checkMerge(ctx context.Context, targetID, sourceID int) error {
+	if targetID == sourceID {
+		return nil
+	}
+	target, err := s.store.GetByID(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	source, err := s.store.GetByID(ctx, sourceID)
+	if err != nil {
+		return err
+	}
+	return validateDescription(MergeDescriptions(target.Description, source.Description), s.limits)
+}
+
+// MergedInto returns the ID of the todo that id was merged into,
+// or ErrNotFound if id was never merged.
+func (s *service) MergedInto(ctx context.Context, id int) (int, error) {
+	return s.store.MergedInto(ctx, id)
+}
+
+// FindTodoByExternalID returns the todo imported from source with the
+// given external ID, or ErrNotFound.
+func (s *service) FindTodoByExternalID(ctx context.Context, source, externalID string) (*Todo, error) {
+	return s.store.FindByExternalID(ctx, source, externalID)
+}
+
+// ListMilestones returns all milestones from the underlying store.
+func (s *service) ListMilestones(ctx context.Context) ([]Milestone, error) {
+	return s.store.Milestones(ctx)
+}
+
+// GetMilestone returns a milestone by ID, or ErrNotFound. Stores hold few
+// milestones, so it picks it from the listing.
+func (s *service) GetMilestone(ctx context.Context, id int) (Milestone, error) {
+	milestones, err := s.store.Milestones(ctx)
+	if err != nil {
+		return Milestone{}, err
+	}
+	for _, m := range milestones {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return Milestone{}, ErrNotFound
+}
+
+// CreateMilestone creates a milestone from validated input.
+func (s *service) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	return s.store.CreateMilestone(ctx, input)
+}
+
+// AssignMilestone moves the todo into the milestone and returns the
+// milestone, or ErrNotFound if it does not exist.
+func (s *service) AssignMilestone(ctx context.Context, milestoneID, todoID int) (Milestone, error) {
+	if err := s.store.AssignMilestone(ctx, milestoneID, todoID); err != nil {
+		return Milestone{}, err
+	}
+	return s.GetMilestone(ctx, milestoneID)
+}
+
+// UnassignMilestone removes the todo from the milestone and returns the
+// milestone, or ErrNotFound if it does not exist.
+func (s *service) UnassignMilestone(ctx context.Context, milestoneID, todoID int) (Milestone, error) {
+	if err := s.store.UnassignMilestone(ctx, milestoneID, todoID); err != nil {
+		return Milestone{}, err
+	}
+	return s.GetMilestone(ctx, milestoneID)
+}
+
+// UpsertTodo creates a todo from input unless one with the same source and
+// external ID exists, in which case strategy decides how the existing todo
+// is updated.
+func (s *service) UpsertTodo(ctx context.Context, input TodoInput, strategy ConflictStrategy) (*Todo, UpsertOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := (*Todo)(nil), ErrNotFound
+	if input.ExternalID != "" {
+		existing, err = s.store.FindByExternalID(ctx, input.Source, input.ExternalID)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, "", err
+		}
+		if err := s.checkParent(ctx, 0, input); err != nil {
+			return nil, "", err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		todo, err := s.store.Create(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+		s.publish(func(at time.Time) events.Event {
+			return events.TodoCreated{At: at, Todo: eventTodo(todo)}
+		})
+		if input.Status != "" {
+			if todo, err = s.moveTo(ctx, todo, input.Status); err != nil {
+				return nil, "", err
+			}
+		}
+		return todo, UpsertCreated, nil
+	}
+
+	update := TodoInput{Title: existing.Title, Description: existing.Description, Tags: existing.Tags, ParentID: &existing.ParentID}
+	switch strategy {
+	case ConflictOverwrite:
+		update.Title, update.Description = input.Title, input.Description
+		if input.Tags != nil {
+			update.Tags = input.Tags
+		}
+	case ConflictMerge:
+		if !strings.Contains(existing.Description, input.Description) {
+			update.Description = MergeDescriptions(existing.Description, input.Description)
+			if err := validateDescription(update.Description, s.limits); err != nil {
+				return nil, "", err
+			}
+		}
+		update.Tags = MergeTags(existing.Tags, input.Tags)
+	}
+	moveState := strategy == ConflictOverwrite && input.Status != "" && input.Status != existing.State()
+	changed := update.Title != existing.Title || update.Description != existing.Description || !slices.Equal(update.Tags, existing.Tags)
+	if !changed && !moveState {
+		return existing, UpsertSkipped, nil
+	}
+
+	todo := existing
+	if changed {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		updated, err := s.store.Update(ctx, existing.ID, update)
+		if err != nil {
+			return nil, "", err
+		}
+		todo = updated
+		s.publish(func(at time.Time) events.Event {
+			return events.TodoUpdated{At: at, Todo: eventTodo(todo)}
+		})
+	}
+	if moveState {
+		if todo, err = s.moveTo(ctx, todo, input.Status); err != nil {
+			return nil, "", err
+		}
+	}
+	return todo, UpsertUpdated, nil
+}
+
+// BackupTodos returns a full dump of the store. The boolean is false if
+// the store does not support backups.
+func (s *service) BackupTodos(ctx context.Context) (Backup, bool, error) {
+	backups, ok := s.store.(BackupStore)
+	if !ok {
+		return Backup{}, false, nil
+	}
+	b, err := backups.Backup(ctx)
+	return b, true, err
+}
+
+// RestoreTodos replaces the content of the store with b. The boolean is
+// false if the store does not support restoring backups.
+func (s *service) RestoreTodos(ctx context.Context, b Backup) (bool, error) {
+	backups, ok := s.store.(BackupStore)
+	if !ok {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+	if err := backups.Restore(ctx, b); err != nil {
+		return true, err
+	}
+	s.publish(func(at time.Time) events.Event {
+		return events.TodosRestored{At: at, Count: len(b.Todos)}
+	})
+	return true, nil
+}
+
+// SeedTodos adds todos if the store has never handed out a todo ID, and
+// publishes their creation if it did.
+func (s *service) SeedTodos(ctx context.Context, todos []BackupTodo) (bool, error) {
+	now := s.clock.Now()
+	seed := make([]BackupTodo, len(todos))
+	for i, t := range todos {
+		input := TodoInput{Title: t.Title, Description: t.Description, Source: t.Source, ExternalID: t.ExternalID, Tags: t.Tags}
+		if err := ValidateInput(&input, s.limits); err != nil {
+			return false, err
+		}
+		t.Title, t.Description, t.Tags = input.Title, input.Description, input.Tags
+		t.Version = max(t.Version, 1)
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		if t.UpdatedAt.IsZero() {
+			t.UpdatedAt = t.CreatedAt
+		}
+		seed[i] = t
+	}
+	if err := (Backup{Todos: seed}).Validate(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seeded, err := s.store.Seed(ctx, seed)
+	if !seeded || err != nil {
+		return false, err
+	}
+	for _, t := range seed {
+		created := &Todo{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Completed:   t.Completed,
+			Archived:    t.Archived,
+			CreatedAt:   t.CreatedAt,
+			UpdatedAt:   t.UpdatedAt,
+			Source:      t.Source,
+			ExternalID:  t.ExternalID,
+			Tags:        t.Tags,
+			ParentID:    t.ParentID,
+		}
+		s.publish(func(at time.Time) events.Event {
+			return events.TodoCreated{At: at, Todo: eventTodo(created)}
+		})
+	}
+	return true, nil
 }
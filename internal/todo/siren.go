@@ -0,0 +1,190 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// sirenEntity is a Siren entity. Sub-entities carry Rel.
+type sirenEntity struct {
+	Class      []string       `json:"class,omitempty"`
+	Rel        []string       `json:"rel,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+	Entities   []sirenEntity  `json:"entities,omitempty"`
+	Actions    []sirenAction  `json:"actions,omitempty"`
+	Links      []sirenLink    `json:"links,omitempty"`
+}
+
+// sirenAction is a state transition of a Siren entity.
+type sirenAction struct {
+	Name   string       `json:"name"`
+	Title  string       `json:"title,omitempty"`
+	Method string       `json:"method"`
+	Href   string       `json:"href"`
+	Type   string       `json:"type,omitempty"`
+	Fields []sirenField `json:"fields,omitempty"`
+}
+
+// sirenField is an input field of a sirenAction.
+type sirenField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// sirenLink is a navigational link of a Siren entity.
+type sirenLink struct {
+	Rel  []string `json:"rel"`
+	Href string   `json:"href"`
+}
+
+// sirenItemClasses names the class of the members of each collection.
+var sirenItemClasses = map[string]string{"todos": "todo", "milestones": "milestone"}
+
+// sirenClass returns the class of the entity representing payload.
+func sirenClass(payload any) []string {
+	switch payload.(type) {
+	case APIRoot:
+		return []string{"root"}
+	case Todo:
+		return []string{"todo"}
+	case TodoCollection:
+		return []string{"todos", "collection"}
+	case ErrorResponse:
+		return []string{"error"}
+	}
+	return nil
+}
+
+// toSiren converts the JSON representation body to a Siren entity
+// (application/vnd.siren+json) of the given class:
+//
+//   - GET links stay links; links with another method become actions,
+//     with the fields of the HAL-FORMS template for the same request;
+//   - templates without such a link become actions named by their key;
+//   - arrays of resources become sub-entities with the "item" relation;
+//   - everything else, including _meta, becomes properties.
+func toSiren(body []byte, class []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(newSirenEntity(doc, class)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newSirenEntity converts the JSON object resource to a Siren entity.
+func newSirenEntity(resource map[string]any, class []string) sirenEntity {
+	entity := sirenEntity{Class: class, Properties: map[string]any{}}
+	var links, templates map[string]any
+	for _, key := range sortedKeys(resource) {
+		value := resource[key]
+		switch {
+		case key == "_links":
+			links, _ = value.(map[string]any)
+		case key == "_templates":
+			templates, _ = value.(map[string]any)
+		case key == "_meta":
+			if meta, ok := value.(map[string]any); ok {
+				for name, property := range meta {
+					entity.Properties[name] = property
+				}
+			}
+		case halCollections[key] && isEmptyArray(value):
+			// An empty collection has no sub-entities.
+		case isResourceArray(value):
+			for _, item := range value.([]any) {
+				sub := newSirenEntity(item.(map[string]any), nil)
+				if class, ok := sirenItemClasses[key]; ok {
+					sub.Class = []string{class}
+				}
+				sub.Rel = []string{"item"}
+				entity.Entities = append(entity.Entities, sub)
+			}
+		default:
+			entity.Properties[key] = value
+		}
+	}
+
+	matched := map[string]bool{}
+	for _, rel := range sortedKeys(links) {
+		link, ok := links[rel].(map[string]any)
+		if !ok || link["templated"] == true {
+			// Siren has no URI templates or curies.
+			continue
+		}
+		href, _ := link["href"].(string)
+		method, _ := link["method"].(string)
+		if method == "" || method == http.MethodGet {
+			entity.Links = append(entity.Links, sirenLink{Rel: []string{rel}, Href: href})
+			continue
+		}
+
+		action := sirenAction{Name: rel, Method: method, Href: href}
+		for _, key := range sortedKeys(templates) {
+			template, _ := templates[key].(map[string]any)
+			if template["method"] == method && template["target"] == href {
+				applyTemplate(&action, template)
+				matched[key] = true
+				break
+			}
+		}
+		entity.Actions = append(entity.Actions, action)
+	}
+	for _, key := range sortedKeys(templates) {
+		template, ok := templates[key].(map[string]any)
+		if !ok || matched[key] {
+			continue
+		}
+		action := sirenAction{Name: key}
+		action.Method, _ = template["method"].(string)
+		action.Href, _ = template["target"].(string)
+		applyTemplate(&action, template)
+		entity.Actions = append(entity.Actions, action)
+	}
+	return entity
+}
+
+// applyTemplate copies the title and input fields of a HAL-FORMS template
+// to action. HTML has no textarea input type, so those become text fields.
+func applyTemplate(action *sirenAction, template map[string]any) {
+	action.Title, _ = template["title"].(string)
+	properties, _ := template["properties"].([]any)
+	for _, p := range properties {
+		property, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		field := sirenField{}
+		field.Name, _ = property["name"].(string)
+		field.Type, _ = property["type"].(string)
+		field.Title, _ = property["prompt"].(string)
+		field.Value, _ = property["value"].(string)
+		if field.Type == "textarea" {
+			field.Type = "text"
+		}
+		action.Fields = append(action.Fields, field)
+	}
+	if len(action.Fields) > 0 {
+		action.Type, _ = template["contentType"].(string)
+	}
+}
+
+// sortedKeys returns the keys of m in order, so Siren documents are stable.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,235 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// slowStore wraps a Store and logs every operation that takes longer than
+// threshold, with its parameters and the number of todos it returned, so
+// operators can spot missing indexes and oversized listings of SQL
+// backends.
+type slowStore struct {
+	store     Store
+	threshold time.Duration
+	logger    *log.Logger
+
+	mu   sync.Mutex
+	slow map[string]int
+}
+
+// instrumentStore wraps store in a slowStore. The result implements
+// SnapshotStore and BackupStore exactly when store does, so Service keeps
+// using snapshots and backups through it.
+func instrumentStore(store Store, threshold time.Duration, logger *log.Logger) Store {
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &slowStore{store: store, threshold: threshold, logger: logger, slow: make(map[string]int)}
+
+	snapshots, isSnapshot := store.(SnapshotStore)
+	backups, isBackup := store.(BackupStore)
+	switch {
+	case isSnapshot && isBackup:
+		return struct {
+			*slowStore
+			slowSnapshots
+			slowBackups
+		}{s, slowSnapshots{s, snapshots}, slowBackups{s, backups}}
+	case isSnapshot:
+		return struct {
+			*slowStore
+			slowSnapshots
+		}{s, slowSnapshots{s, snapshots}}
+	case isBackup:
+		return struct {
+			*slowStore
+			slowBackups
+		}{s, slowBackups{s, backups}}
+	}
+	return s
+}
+
+// observe logs op if it started more than the threshold ago. params
+// describes its arguments and todos is the number of todos it returned or
+// changed.
+func (s *slowStore) observe(op string, start time.Time, params string, todos int) {
+	elapsed := time.Since(start)
+	if elapsed <= s.threshold {
+		return
+	}
+
+	s.mu.Lock()
+	s.slow[op]++
+	count := s.slow[op]
+	s.mu.Unlock()
+
+	if params == "" {
+		params = "-"
+	}
+	s.logger.Printf("todo: slow store %s took %s (threshold %s); params: %s; todos: %d; slow %s calls so far: %d",
+		op, elapsed.Round(time.Microsecond), s.threshold, params, todos, op, count)
+}
+
+// found returns 1 if err is nil, so lookups count the todo they found.
+func found(err error) int {
+	if err == nil {
+		return 1
+	}
+	return 0
+}
+
+func (s *slowStore) GetAll(ctx context.Context) ([]*Todo, error) {
+	start := time.Now()
+	todos, err := s.store.GetAll(ctx)
+	s.observe("GetAll", start, "", len(todos))
+	return todos, err
+}
+
+func (s *slowStore) ListSubtasks(ctx context.Context, parentID int) ([]*Todo, error) {
+	start := time.Now()
+	subtasks, err := s.store.ListSubtasks(ctx, parentID)
+	s.observe("ListSubtasks", start, fmt.Sprintf("parent_id=%d", parentID), len(subtasks))
+	return subtasks, err
+}
+
+func (s *slowStore) GetByID(ctx context.Context, id int) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.GetByID(ctx, id)
+	s.observe("GetByID", start, fmt.Sprintf("id=%d", id), found(err))
+	return todo, err
+}
+
+func (s *slowStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.Create(ctx, input)
+	s.observe("Create", start, fmt.Sprintf("source=%q external_id=%q", input.Source, input.ExternalID), found(err))
+	return todo, err
+}
+
+func (s *slowStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.Update(ctx, id, input)
+	s.observe("Update", start, fmt.Sprintf("id=%d", id), found(err))
+	return todo, err
+}
+
+func (s *slowStore) Complete(ctx context.Context, id int) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.Complete(ctx, id)
+	s.observe("Complete", start, fmt.Sprintf("id=%d", id), found(err))
+	return todo, err
+}
+
+func (s *slowStore) SetState(ctx context.Context, id int, state State) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.SetState(ctx, id, state)
+	s.observe("SetState", start, fmt.Sprintf("id=%d state=%s", id, state), found(err))
+	return todo, err
+}
+
+func (s *slowStore) Delete(ctx context.Context, id int) error {
+	start := time.Now()
+	err := s.store.Delete(ctx, id)
+	s.observe("Delete", start, fmt.Sprintf("id=%d", id), found(err))
+	return err
+}
+
+func (s *slowStore) Merge(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.Merge(ctx, targetID, sourceID)
+	s.observe("Merge", start, fmt.Sprintf("target=%d source=%d", targetID, sourceID), found(err))
+	return todo, err
+}
+
+func (s *slowStore) MergedInto(ctx context.Context, id int) (int, error) {
+	start := time.Now()
+	survivor, err := s.store.MergedInto(ctx, id)
+	s.observe("MergedInto", start, fmt.Sprintf("id=%d", id), found(err))
+	return survivor, err
+}
+
+func (s *slowStore) FindByExternalID(ctx context.Context, source, externalID string) (*Todo, error) {
+	start := time.Now()
+	todo, err := s.store.FindByExternalID(ctx, source, externalID)
+	s.observe("FindByExternalID", start, fmt.Sprintf("source=%q external_id=%q", source, externalID), found(err))
+	return todo, err
+}
+
+func (s *slowStore) Milestones(ctx context.Context) ([]Milestone, error) {
+	start := time.Now()
+	milestones, err := s.store.Milestones(ctx)
+	s.observe("Milestones", start, "", 0)
+	return milestones, err
+}
+
+func (s *slowStore) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	start := time.Now()
+	m, err := s.store.CreateMilestone(ctx, input)
+	s.observe("CreateMilestone", start, fmt.Sprintf("name=%q", input.Name), 0)
+	return m, err
+}
+
+func (s *slowStore) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	start := time.Now()
+	err := s.store.AssignMilestone(ctx, milestoneID, todoID)
+	s.observe("AssignMilestone", start, fmt.Sprintf("milestone=%d todo=%d", milestoneID, todoID), found(err))
+	return err
+}
+
+func (s *slowStore) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	start := time.Now()
+	err := s.store.UnassignMilestone(ctx, milestoneID, todoID)
+	s.observe("UnassignMilestone", start, fmt.Sprintf("milestone=%d todo=%d", milestoneID, todoID), found(err))
+	return err
+}
+
+func (s *slowStore) Seed(ctx context.Context, todos []BackupTodo) (bool, error) {
+	start := time.Now()
+	seeded, err := s.store.Seed(ctx, todos)
+	s.observe("Seed", start, "", len(todos))
+	return seeded, err
+}
+
+// slowSnapshots adds the SnapshotStore methods to a slowStore.
+type slowSnapshots struct {
+	s     *slowStore
+	store SnapshotStore
+}
+
+func (s slowSnapshots) Snapshot(ctx context.Context) ([]*Todo, int) {
+	start := time.Now()
+	todos, seq := s.store.Snapshot(ctx)
+	s.s.observe("Snapshot", start, "", len(todos))
+	return todos, seq
+}
+
+func (s slowSnapshots) ListAt(ctx context.Context, seq int) ([]*Todo, bool) {
+	start := time.Now()
+	todos, ok := s.store.ListAt(ctx, seq)
+	s.s.observe("ListAt", start, fmt.Sprintf("seq=%d", seq), len(todos))
+	return todos, ok
+}
+
+// slowBackups adds the BackupStore methods to a slowStore.
+type slowBackups struct {
+	s     *slowStore
+	store BackupStore
+}
+
+func (s slowBackups) Backup(ctx context.Context) (Backup, error) {
+	start := time.Now()
+	b, err := s.store.Backup(ctx)
+	s.s.observe("Backup", start, "", len(b.Todos))
+	return b, err
+}
+
+func (s slowBackups) Restore(ctx context.Context, b Backup) error {
+	start := time.Now()
+	err := s.store.Restore(ctx, b)
+	s.s.observe("Restore", start, "", len(b.Todos))
+	return err
+}
@@ -0,0 +1,214 @@
+package todo
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxTombstones bounds how many deleted todos are retained for snapshot
+// reads. Snapshots older than the oldest retained tombstone expire.
+const maxTombstones = 1000
+
+// tombstone records a deleted todo together with the sequence numbers at
+// which it was created and deleted.
+type tombstone struct {
+	todo       Todo
+	createdSeq int
+	deletedSeq int
+}
+
+// bury removes the todo with the given ID and keeps a tombstone of it at the
+// current sequence. The caller must hold the write lock.
+func (s *TodoStore) bury(id int) {
+	todo := s.todos[id]
+	s.tombstones = append(s.tombstones, tombstone{
+		todo:       *todo,
+		createdSeq: s.createdSeq[id],
+		deletedSeq: s.seq,
+	})
+
+	if len(s.tombstones) > maxTombstones {
+		s.horizon = s.tombstones[0].deletedSeq
+		s.tombstones = s.tombstones[1:]
+	}
+
+	if key, ok := externalKeyOf(todo); ok && s.external[key] == id {
+		delete(s.external, key)
+	}
+	delete(s.todos, id)
+	delete(s.createdSeq, id)
+}
+
+// sortedTodos returns the live todos ordered by ID. The caller must hold
+// at least the read lock.
+func (s *TodoStore) sortedTodos() []*Todo {
+	todos := make([]*Todo, 0, len(s.todos))
+	for _, todo := range s.todos {
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+	return todos
+}
+
+// Snapshot returns all todos ordered by ID together with the sequence
+// number that identifies this state of the store.
+func (s *TodoStore) Snapshot(ctx context.Context) ([]*Todo, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedTodos(), s.seq
+}
+
+// ListAt returns the todos that existed at the given snapshot sequence,
+// ordered by ID. Todos created after the snapshot are excluded and todos
+// deleted after it are still included, so paging through the result is not
+// affected by concurrent inserts or deletes. Field values reflect the latest
+// state of each todo. The boolean is false if the snapshot is unknown or has
+// expired.
+func (s *TodoStore) ListAt(ctx context.Context, seq int) ([]*Todo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if seq < s.horizon || seq > s.seq {
+		return nil, false
+	}
+
+	todos := make([]*Todo, 0, len(s.todos))
+	for id, todo := range s.todos {
+		if s.createdSeq[id] <= seq {
+			todos = append(todos, todo)
+		}
+	}
+	for i := range s.tombstones {
+		ts := &s.tombstones[i]
+		if ts.createdSeq <= seq && ts.deletedSeq > seq {
+			todo := ts.todo
+			todos = append(todos, &todo)
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID < todos[j].ID
+	})
+	return todos, true
+}
+
+// listCursor identifies a position within a snapshot-pinned listing.
+type listCursor struct {
+	snapshot int
+	afterID  int
+}
+
+// encodeCursor serializes a cursor into an opaque, URL-safe token.
+func encodeCursor(c listCursor) string {
+	raw := fmt.Sprintf("v1:%d:%d", c.snapshot, c.afterID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by encodeCursor.
+func decodeCursor(token string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return listCursor{}, fmt.Errorf("malformed cursor: %w", err)
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != "v1" {
+		return listCursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	snapshot, err := strconv.Atoi(parts[1])
+	if err != nil || snapshot < 0 {
+		return listCursor{}, fmt.Errorf("malformed cursor snapshot")
+	}
+	afterID, err := strconv.Atoi(parts[2])
+	if err != nil || afterID < 0 {
+		return listCursor{}, fmt.Errorf("malformed cursor position")
+	}
+
+	return listCursor{snapshot: snapshot, afterID: afterID}, nil
+}
+
+// buildCursorLink constructs a link to the snapshot-pinned listing at the given cursor.
+func buildCursorLink(baseURL string, c listCursor, perPage int) *Link {
+	return &Link{
+		Href: fmt.Sprintf("%s/todos?cursor=%s&per_page=%d", baseURL, encodeCursor(c), perPage),
+	}
+}
+
+// getTodosAtCursor serves GET /todos?cursor=... by paging through the
+// snapshot embedded in the cursor. With aggregates, the meta summarizes the
+// whole snapshot.
+func (api *TodoAPI) getTodosAtCursor(w http.ResponseWriter, r *http.Request, token string, perPage int, aggregates bool) {
+	cursor, err := decodeCursor(token)
+	if err != nil {
+		api.sendError(w, r, http.StatusBadRequest, "Invalid cursor", "The provided cursor is not valid")
+		return
+	}
+
+	allTodos, ok := api.service.ListTodosAt(r.Context(), cursor.snapshot)
+	if !ok {
+		api.sendError(w, r, http.StatusGone, "Snapshot expired", "The snapshot referenced by the cursor is no longer available; restart the listing")
+		return
+	}
+	total := len(allTodos)
+
+	start := sort.Search(total, func(i int) bool {
+		return allTodos[i].ID > cursor.afterID
+	})
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	subtasks := indexSubtasks(allTodos)
+	milestones := api.indexMilestones(r)
+	var pageTodos []Todo
+	for i := start; i < end; i++ {
+		todo := api.presentWith(r, allTodos[i], subtasks, milestones)
+		truncateForListing(&todo, api.base(r))
+		pageTodos = append(pageTodos, todo)
+	}
+
+	totalPages := (total + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	links := CollectionLinks{
+		Self:    buildCursorLink(api.base(r), cursor, perPage),
+		First:   buildCursorLink(api.base(r), listCursor{snapshot: cursor.snapshot}, perPage),
+		Create:  &Link{Href: fmt.Sprintf("%s/todos", api.base(r)), Method: "POST"},
+		Profile: buildProfileLink(api.base(r), profileCollection),
+		Find:    buildFindLink(api.base(r)),
+		Curies:  buildCuries(api.base(r)),
+	}
+	if end < total {
+		links.Next = buildCursorLink(api.base(r), listCursor{snapshot: cursor.snapshot, afterID: allTodos[end-1].ID}, perPage)
+	}
+
+	collection := TodoCollection{
+		Todos: pageTodos,
+		Meta: CollectionMeta{
+			Total:      total,
+			Count:      len(pageTodos),
+			Page:       start/perPage + 1,
+			PerPage:    perPage,
+			TotalPages: totalPages,
+		},
+		Links:     links,
+		Templates: buildCollectionTemplates(api.base(r), api.service.Limits()),
+	}
+	if aggregates {
+		collection.Meta.Aggregates = aggregateTodos(allTodos)
+	}
+
+	api.respond(w, r, http.StatusOK, collection)
+}
@@ -0,0 +1,61 @@
+package todo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sortPreset is a named order of the todo collection, selected with
+// ?sort= and advertised as a sort-by-{name} link.
+type sortPreset struct {
+	name    string
+	compare func(a, b *Todo) int
+}
+
+// sortPresets lists the orders offered besides the default order by ID.
+// Ties keep the order by ID.
+var sortPresets = []sortPreset{
+	{
+		name: "title",
+		compare: func(a, b *Todo) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		},
+	},
+	{
+		name:    "created",
+		compare: func(a, b *Todo) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	},
+	{
+		name:    "updated",
+		compare: func(a, b *Todo) int { return b.UpdatedAt.Compare(a.UpdatedAt) },
+	},
+}
+
+// sortNames returns the valid values of the sort parameter.
+func sortNames() []string {
+	names := []string{"id"}
+	for _, preset := range sortPresets {
+		names = append(names, preset.name)
+	}
+	return names
+}
+
+// sortTodos returns todos, which are ordered by ID, in the order of the
+// named preset. The default order "id" returns todos unchanged.
+func sortTodos(todos []*Todo, name string) []*Todo {
+	for _, preset := range sortPresets {
+		if preset.name == name {
+			sorted := slices.Clone(todos)
+			slices.SortStableFunc(sorted, preset.compare)
+			return sorted
+		}
+	}
+	return todos
+}
+
+// buildSortLink returns the templated link to the collection in the order
+// of the named preset.
+func buildSortLink(baseURL, name string) *Link {
+	return &Link{Href: fmt.Sprintf("%s/todos?sort=%s{&page,per_page}", baseURL, name), Method: "GET", Templated: true}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	title       TEXT    NOT NULL,
+	description TEXT    NOT NULL DEFAULT '',
+	completed   INTEGER NOT NULL DEFAULT 0,
+	created_at  TEXT    NOT NULL
+);
+CREATE TABLE IF NOT EXISTS merged_todos (
+	id          INTEGER PRIMARY KEY,
+	survivor_id INTEGER NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN source TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN external_id TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS todos_external_id ON todos (source, external_id) WHERE external_id <> '';
@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
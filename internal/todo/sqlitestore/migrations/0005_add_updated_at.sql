@@ -0,0 +1,2 @@
+ALTER TABLE todos ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+UPDATE todos SET updated_at = created_at;
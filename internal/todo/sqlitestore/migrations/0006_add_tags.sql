@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
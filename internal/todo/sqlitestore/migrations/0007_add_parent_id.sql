@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0;
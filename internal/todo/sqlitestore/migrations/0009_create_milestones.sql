@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS milestones (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT    NOT NULL,
+	start_date TEXT    NOT NULL,
+	end_date   TEXT    NOT NULL
+);
+CREATE TABLE IF NOT EXISTS milestone_todos (
+	todo_id      INTEGER PRIMARY KEY,
+	milestone_id INTEGER NOT NULL
+);
@@ -0,0 +1,728 @@
+// Package sqlitestore implements todo.Store on top of SQLite so todos
+// survive restarts. Importing the package registers the "sqlite" backend.
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/efrem/windsurf/internal/migrate"
+	"github.com/efrem/windsurf/internal/todo"
+
+	_ "modernc.org/sqlite"
+)
+
+// Backend is the name under which the store is registered with todo.RegisterStore.
+const Backend = "sqlite"
+
+// DefaultPath is the database file used when no DSN is configured.
+const DefaultPath = "todos.db"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const selectColumns = `SELECT id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id FROM todos`
+
+func init() {
+	todo.RegisterStore(Backend, func(cfg todo.StoreConfig) (todo.Store, error) {
+		path := cfg.DSN
+		if path == "" {
+			path = DefaultPath
+		}
+		return Open(path, cfg.Clock)
+	})
+}
+
+// Store is a todo.Store backed by a SQLite database. Database failures are
+// returned as errors wrapping the failed operation.
+type Store struct {
+	db    *sql.DB
+	path  string
+	clock todo.Clock
+}
+
+var (
+	_ todo.BackupStore  = (*Store)(nil)
+	_ todo.CompactStore = (*Store)(nil)
+)
+
+// Open opens (creating if necessary) the SQLite database at path and
+// applies pending schema migrations. A nil clock selects todo.SystemClock.
+func Open(path string, clock todo.Clock) (*Store, error) {
+	if clock == nil {
+		clock = todo.SystemClock{}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; serializing connections avoids
+	// SQLITE_BUSY errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db, path: path, clock: clock}
+	if _, err := s.Migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate applies the embedded schema migrations that have not run yet and
+// returns how many were applied.
+func (s *Store) Migrate(ctx context.Context) (int, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	n, err := migrate.Apply(ctx, s.db, migrate.SQLite, migrations)
+	if err != nil {
+		return n, fmt.Errorf("migrate sqlite schema: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Compact rebuilds the database without free pages and truncates the
+// write-ahead log into it. Requests wait on the single connection while it
+// runs.
+func (s *Store) Compact() error {
+	ctx := context.Background()
+	if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+		return fmt.Errorf("vacuum sqlite database: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpoint sqlite database: %w", err)
+	}
+	return nil
+}
+
+// DiskSize returns the size of the database file and its write-ahead log.
+func (s *Store) DiskSize() (int64, error) {
+	var size int64
+	for _, name := range []string{s.path, s.path + "-wal"} {
+		info, err := os.Stat(name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return 0, err
+		default:
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(row scanner) (*todo.Todo, error) {
+	var (
+		t         todo.Todo
+		createdAt string
+		updatedAt string
+		tags      string
+	)
+	if err := row.Scan(&t.ID, &t.Title, &t.Description, &t.Completed, &t.Archived, &t.Version, &createdAt, &updatedAt, &t.Source, &t.ExternalID, &tags, &t.ParentID); err != nil {
+		return nil, err
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at of todo %d: %w", t.ID, err)
+	}
+	t.CreatedAt = parsed
+
+	if parsed, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return nil, fmt.Errorf("parse updated_at of todo %d: %w", t.ID, err)
+	}
+	t.UpdatedAt = parsed
+
+	if err := json.Unmarshal([]byte(tags), &t.Tags); err != nil {
+		return nil, fmt.Errorf("parse tags of todo %d: %w", t.ID, err)
+	}
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+
+	return &t, nil
+}
+
+// encodeTags returns tags in the stored form, a JSON array.
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	// Encoding strings cannot fail.
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// getByID loads a todo through q, which is either the database or a transaction.
+func getByID(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, id int) (*todo.Todo, error) {
+	t, err := scanTodo(q.QueryRow(selectColumns+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get todo: %w", err)
+	}
+	return t, nil
+}
+
+// GetAll returns all todos ordered by ID.
+func (s *Store) GetAll(ctx context.Context) ([]*todo.Todo, error) {
+	return s.list(ctx, selectColumns+` ORDER BY id`)
+}
+
+// ListSubtasks returns the todos whose parent is parentID, ordered by ID,
+// through the index on parent_id.
+func (s *Store) ListSubtasks(ctx context.Context, parentID int) ([]*todo.Todo, error) {
+	return s.list(ctx, selectColumns+` WHERE parent_id = ? ORDER BY id`, parentID)
+}
+
+// list returns the todos query selects.
+func (s *Store) list(ctx context.Context, query string, args ...any) ([]*todo.Todo, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []*todo.Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan todo: %w", err)
+		}
+		todos = append(todos, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+
+	return todos, nil
+}
+
+// GetByID returns a todo by its ID, or todo.ErrNotFound.
+func (s *Store) GetByID(ctx context.Context, id int) (*todo.Todo, error) {
+	return getByID(s.db, id)
+}
+
+// Create adds a new todo using the provided input.
+func (s *Store) Create(ctx context.Context, input todo.TodoInput) (*todo.Todo, error) {
+	createdAt := s.clock.Now().UTC()
+	stamp := createdAt.Format(time.RFC3339Nano)
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO todos (title, description, completed, created_at, updated_at, source, external_id, tags, parent_id) VALUES (?, ?, 0, ?, ?, ?, ?, ?, ?)`,
+		input.Title, input.Description, stamp, stamp, input.Source, input.ExternalID, encodeTags(input.Tags), input.Parent(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("create todo: %w", err)
+	}
+
+	return &todo.Todo{
+		ID:          int(id),
+		Title:       input.Title,
+		Description: input.Description,
+		Version:     1,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
+	}, nil
+}
+
+// now returns the current time in the stored format.
+func (s *Store) now() string {
+	return s.clock.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// Update modifies an existing todo identified by id.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Update(ctx context.Context, id int, input todo.TodoInput) (*todo.Todo, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE todos SET title = ?, description = ?, tags = ?, parent_id = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+		input.Title, input.Description, encodeTags(input.Tags), input.Parent(), s.now(), id)
+	if err != nil {
+		return nil, fmt.Errorf("update todo: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("update todo: %w", err)
+	} else if n == 0 {
+		return nil, todo.ErrNotFound
+	}
+	return s.GetByID(ctx, id)
+}
+
+// Complete marks the todo with the given ID as completed.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Complete(ctx context.Context, id int) (*todo.Todo, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE todos SET completed = 1, version = version + 1, updated_at = ? WHERE id = ?`, s.now(), id)
+	if err != nil {
+		return nil, fmt.Errorf("complete todo: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("complete todo: %w", err)
+	} else if n == 0 {
+		return nil, todo.ErrNotFound
+	}
+	return s.GetByID(ctx, id)
+}
+
+// SetState moves the todo with the given ID to state.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) SetState(ctx context.Context, id int, state todo.State) (*todo.Todo, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE todos SET completed = ?, archived = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+		state != todo.StateOpen, state == todo.StateArchived, s.now(), id)
+	if err != nil {
+		return nil, fmt.Errorf("set todo state: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("set todo state: %w", err)
+	} else if n == 0 {
+		return nil, todo.ErrNotFound
+	}
+	return s.GetByID(ctx, id)
+}
+
+// Delete removes the todo with the given ID.
+// It returns todo.ErrNotFound if the todo does not exist.
+func (s *Store) Delete(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM todos WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete todo: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete todo: %w", err)
+	}
+	if n == 0 {
+		return todo.ErrNotFound
+	}
+	return nil
+}
+
+// Merge folds the todo sourceID into the todo targetID, appending the
+// source description to the target's, adding the source tags to the
+// target's and recording the source ID as an
+// alias of the target. It returns todo.ErrNotFound if either todo does not
+// exist and todo.ErrMergeIntoItself if they are the same.
+func (s *Store) Merge(ctx context.Context, targetID, sourceID int) (*todo.Todo, error) {
+	if targetID == sourceID {
+		return nil, todo.ErrMergeIntoItself
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	target, err := getByID(tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := getByID(tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	target.Description = todo.MergeDescriptions(target.Description, source.Description)
+	target.Tags = todo.MergeTags(target.Tags, source.Tags)
+
+	target.Version++
+	target.UpdatedAt = s.clock.Now().UTC()
+	_, err = tx.ExecContext(ctx, `UPDATE todos SET description = ?, tags = ?, version = ?, updated_at = ? WHERE id = ?`,
+		target.Description, encodeTags(target.Tags), target.Version, target.UpdatedAt.Format(time.RFC3339Nano), targetID)
+	if err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE id = ?`, sourceID); err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO merged_todos (id, survivor_id) VALUES (?, ?)`, sourceID, targetID); err != nil {
+		return nil, fmt.Errorf("merge todo: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit merge: %w", err)
+	}
+	return target, nil
+}
+
+// MergedInto returns the ID of the todo that id was merged into, following
+// chains of merges. It returns todo.ErrNotFound if id was never merged or
+// its survivor no longer exists.
+func (s *Store) MergedInto(ctx context.Context, id int) (int, error) {
+	survivor := 0
+	current := id
+	for {
+		var next int
+		err := s.db.QueryRowContext(ctx, `SELECT survivor_id FROM merged_todos WHERE id = ?`, current).Scan(&next)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("resolve merge: %w", err)
+		}
+		survivor, current = next, next
+	}
+
+	if survivor == 0 {
+		return 0, todo.ErrNotFound
+	}
+	if _, err := s.GetByID(ctx, survivor); err != nil {
+		return 0, err
+	}
+	return survivor, nil
+}
+
+// FindByExternalID returns the todo imported from source with the given
+// external ID, or todo.ErrNotFound.
+func (s *Store) FindByExternalID(ctx context.Context, source, externalID string) (*todo.Todo, error) {
+	if externalID == "" {
+		return nil, todo.ErrNotFound
+	}
+	t, err := scanTodo(s.db.QueryRowContext(ctx, selectColumns+` WHERE source = ? AND external_id = ?`, source, externalID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, todo.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find todo by external id: %w", err)
+	}
+	return t, nil
+}
+
+// listMilestones reads the milestones and their todos through q, which is
+// either the database or a transaction.
+func listMilestones(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}) ([]todo.Milestone, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name, start_date, end_date FROM milestones ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	milestones := []todo.Milestone{}
+	for rows.Next() {
+		var m todo.Milestone
+		if err := rows.Scan(&m.ID, &m.Name, &m.StartDate, &m.EndDate); err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	assignments, err := q.QueryContext(ctx, `SELECT todo_id, milestone_id FROM milestone_todos`)
+	if err != nil {
+		return nil, err
+	}
+	defer assignments.Close()
+	assigned := map[int]int{}
+	for assignments.Next() {
+		var todoID, milestoneID int
+		if err := assignments.Scan(&todoID, &milestoneID); err != nil {
+			return nil, err
+		}
+		assigned[todoID] = milestoneID
+	}
+	if err := assignments.Err(); err != nil {
+		return nil, err
+	}
+
+	todo.AttachTodoIDs(milestones, assigned)
+	return milestones, nil
+}
+
+// Milestones returns all milestones ordered by ID.
+func (s *Store) Milestones(ctx context.Context) ([]todo.Milestone, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin list milestones: %w", err)
+	}
+	defer tx.Rollback()
+
+	milestones, err := listMilestones(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("list milestones: %w", err)
+	}
+	return milestones, nil
+}
+
+// CreateMilestone adds a milestone. The input must already be validated.
+func (s *Store) CreateMilestone(ctx context.Context, input todo.MilestoneInput) (todo.Milestone, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO milestones (name, start_date, end_date) VALUES (?, ?, ?)`,
+		input.Name, input.StartDate, input.EndDate)
+	if err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return todo.Milestone{}, fmt.Errorf("create milestone: %w", err)
+	}
+	return todo.Milestone{ID: int(id), Name: input.Name, StartDate: input.StartDate, EndDate: input.EndDate, TodoIDs: []int{}}, nil
+}
+
+// AssignMilestone moves the todo into the milestone, out of any other.
+// It returns todo.ErrNotFound if the milestone does not exist.
+func (s *Store) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "assign todo", milestoneID,
+		`INSERT OR REPLACE INTO milestone_todos (todo_id, milestone_id) VALUES (?, ?)`, todoID, milestoneID)
+}
+
+// UnassignMilestone removes the todo from the milestone. It returns
+// todo.ErrNotFound if the milestone does not exist.
+func (s *Store) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, "unassign todo", milestoneID,
+		`DELETE FROM milestone_todos WHERE todo_id = ? AND milestone_id = ?`, todoID, milestoneID)
+}
+
+// moveTodo runs stmt, which moves a todo into or out of the milestone, in
+// a transaction that first checks that the milestone exists.
+func (s *Store) moveTodo(ctx context.Context, op string, milestoneID int, stmt string, args ...any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM milestones WHERE id = ?)`, milestoneID).Scan(&exists); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return todo.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", op, err)
+	}
+	return nil
+}
+
+// Backup returns a dump of the database, read in a single transaction.
+func (s *Store) Backup(ctx context.Context) (todo.Backup, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("begin backup: %w", err)
+	}
+	defer tx.Rollback()
+
+	b := todo.Backup{Todos: []todo.BackupTodo{}, Merged: map[int]int{}}
+
+	rows, err := tx.QueryContext(ctx, selectColumns+` ORDER BY id`)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup todos: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return todo.Backup{}, fmt.Errorf("backup todos: %w", err)
+		}
+		b.Todos = append(b.Todos, todo.BackupTodo{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Completed:   t.Completed,
+			Archived:    t.Archived,
+			Version:     t.Version,
+			CreatedAt:   t.CreatedAt,
+			UpdatedAt:   t.UpdatedAt,
+			Source:      t.Source,
+			ExternalID:  t.ExternalID,
+			Tags:        t.Tags,
+			ParentID:    t.ParentID,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return todo.Backup{}, fmt.Errorf("backup todos: %w", err)
+	}
+
+	merged, err := tx.QueryContext(ctx, `SELECT id, survivor_id FROM merged_todos`)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup merges: %w", err)
+	}
+	defer merged.Close()
+	for merged.Next() {
+		var id, survivor int
+		if err := merged.Scan(&id, &survivor); err != nil {
+			return todo.Backup{}, fmt.Errorf("backup merges: %w", err)
+		}
+		b.Merged[id] = survivor
+	}
+	if err := merged.Err(); err != nil {
+		return todo.Backup{}, fmt.Errorf("backup merges: %w", err)
+	}
+
+	// AUTOINCREMENT keeps the highest ID ever used in sqlite_sequence.
+	var last sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT seq FROM sqlite_sequence WHERE name = 'todos'`).Scan(&last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return todo.Backup{}, fmt.Errorf("backup sequence: %w", err)
+	}
+	b.NextID = int(last.Int64) + 1
+
+	milestones, err := listMilestones(ctx, tx)
+	if err != nil {
+		return todo.Backup{}, fmt.Errorf("backup milestones: %w", err)
+	}
+	for _, m := range milestones {
+		b.Milestones = append(b.Milestones, todo.BackupMilestone{
+			ID:        m.ID,
+			Name:      m.Name,
+			StartDate: m.StartDate,
+			EndDate:   m.EndDate,
+			TodoIDs:   m.TodoIDs,
+		})
+	}
+
+	return b, nil
+}
+
+// Restore replaces the content of the database with b in one transaction.
+func (s *Store) Restore(ctx context.Context, b todo.Backup) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{`DELETE FROM todos`, `DELETE FROM merged_todos`, `DELETE FROM milestones`, `DELETE FROM milestone_todos`} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("clear tables: %w", err)
+		}
+	}
+
+	// Keep the highest ID handed out so far, even if b predates it.
+	var current sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT seq FROM sqlite_sequence WHERE name = 'todos'`).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("read sequence: %w", err)
+	}
+
+	last := max(b.NextID-1, int(current.Int64))
+	for _, t := range b.Todos {
+		if err := restoreTodo(ctx, tx, t); err != nil {
+			return fmt.Errorf("restore todo %d: %w", t.ID, err)
+		}
+		last = max(last, t.ID)
+	}
+	for id, survivor := range b.Merged {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO merged_todos (id, survivor_id) VALUES (?, ?)`, id, survivor); err != nil {
+			return fmt.Errorf("restore merge %d: %w", id, err)
+		}
+		last = max(last, id)
+	}
+
+	// AUTOINCREMENT keeps milestone IDs from being reused, as for todos.
+	for _, m := range b.Milestones {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO milestones (id, name, start_date, end_date) VALUES (?, ?, ?, ?)`,
+			m.ID, m.Name, m.StartDate, m.EndDate); err != nil {
+			return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+		}
+		for _, todoID := range m.TodoIDs {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO milestone_todos (todo_id, milestone_id) VALUES (?, ?)`, todoID, m.ID); err != nil {
+				return fmt.Errorf("restore milestone %d: %w", m.ID, err)
+			}
+		}
+	}
+
+	// Inserting explicit IDs already advanced sqlite_sequence; reset it so
+	// IDs below next_id that belonged to deleted todos are not reused.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sqlite_sequence WHERE name = 'todos'`); err != nil {
+		return fmt.Errorf("restore sequence: %w", err)
+	}
+	if last > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO sqlite_sequence (name, seq) VALUES ('todos', ?)`, last); err != nil {
+			return fmt.Errorf("restore sequence: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit restore: %w", err)
+	}
+	return nil
+}
+
+// Seed adds todos in one transaction if no todo ID has been handed out yet.
+// Of two processes seeding the same file at once, the second fails to
+// upgrade its transaction to a write and seeds nothing.
+func (s *Store) Seed(ctx context.Context, todos []todo.BackupTodo) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin seed: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT seq FROM sqlite_sequence WHERE name = 'todos'`).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("read sequence: %w", err)
+	}
+	if current.Int64 != 0 {
+		return false, nil
+	}
+	// Inserting explicit IDs advances sqlite_sequence past them.
+	for _, t := range todos {
+		if err := restoreTodo(ctx, tx, t); err != nil {
+			return false, fmt.Errorf("seed todo %d: %w", t.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit seed: %w", err)
+	}
+	return true, nil
+}
+
+// restoreTodo inserts the todo t with its ID.
+func restoreTodo(ctx context.Context, tx *sql.Tx, t todo.BackupTodo) error {
+	// Backups taken before updates were tracked have no update time.
+	updatedAt := t.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = t.CreatedAt
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO todos (id, title, description, completed, archived, version, created_at, updated_at, source, external_id, tags, parent_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.Title, t.Description, t.Completed, t.Archived, max(t.Version, 1),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano), updatedAt.UTC().Format(time.RFC3339Nano), t.Source, t.ExternalID, encodeTags(t.Tags), t.ParentID,
+	)
+	return err
+}
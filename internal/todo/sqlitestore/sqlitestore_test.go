@@ -0,0 +1,77 @@
+package sqlitestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/storetest"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("failed to open sqlite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return openTestStore(t, filepath.Join(t.TempDir(), "todos.db"))
+	})
+}
+
+func TestTodosSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+
+	first := openTestStore(t, path)
+	created := storetest.Create(t, first, todo.TodoInput{Title: "Persisted", Description: "across restarts"})
+	first.Complete(t.Context(), created.ID)
+	deleted := storetest.Create(t, first, todo.TodoInput{Title: "Deleted"})
+	first.Delete(t.Context(), deleted.ID)
+	first.Close()
+
+	second := openTestStore(t, path)
+	fetched, err := second.GetByID(t.Context(), created.ID)
+	if err != nil {
+		t.Fatalf("expected todo %d to survive reopening the database", created.ID)
+	}
+	if fetched.Title != "Persisted" || !fetched.Completed {
+		t.Fatalf("unexpected todo after reopen: %+v", fetched)
+	}
+	if next := storetest.Create(t, second, todo.TodoInput{Title: "Next"}); next.ID != deleted.ID+1 {
+		t.Fatalf("expected deleted ID %d not to be reused, got %d", deleted.ID, next.ID)
+	}
+}
+
+func TestRegisteredBackend(t *testing.T) {
+	store, err := todo.NewStoreFromConfig(todo.StoreConfig{
+		Backend: Backend,
+		DSN:     filepath.Join(t.TempDir(), "todos.db"),
+	})
+	if err != nil {
+		t.Fatalf("expected sqlite backend to be registered, got %v", err)
+	}
+	defer store.(*Store).Close()
+
+	if _, ok := store.(*Store); !ok {
+		t.Fatalf("expected *sqlitestore.Store, got %T", store)
+	}
+}
+
+func TestOpenAppliesMigrations(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "todos.db"))
+
+	n, err := store.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("expected migrations to succeed, got %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected Open to have applied all migrations, %d were pending", n)
+	}
+}
@@ -0,0 +1,128 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State is the lifecycle state of a todo. A todo is created open, is
+// completed when done and may then be archived to put it out of sight.
+type State string
+
+const (
+	StateOpen      State = "open"
+	StateCompleted State = "completed"
+	StateArchived  State = "archived"
+)
+
+// Transition names a change of the lifecycle state of a todo. Each one is
+// exposed as a link relation and a request on the todo.
+type Transition string
+
+const (
+	TransitionComplete  Transition = "complete"
+	TransitionReopen    Transition = "reopen"
+	TransitionArchive   Transition = "archive"
+	TransitionUnarchive Transition = "unarchive"
+)
+
+// Transitions lists every transition in the order they are offered.
+var Transitions = []Transition{TransitionComplete, TransitionReopen, TransitionArchive, TransitionUnarchive}
+
+// stateMachine holds the allowed transitions: open→completed→archived,
+// completed todos may be reopened, and archived todos are unarchived back
+// to completed before they can be reopened.
+var stateMachine = map[Transition]struct{ from, to State }{
+	TransitionComplete:  {StateOpen, StateCompleted},
+	TransitionReopen:    {StateCompleted, StateOpen},
+	TransitionArchive:   {StateCompleted, StateArchived},
+	TransitionUnarchive: {StateArchived, StateCompleted},
+}
+
+// TransitionError reports a transition that is not allowed from the
+// current state of a todo, together with the transitions that are. It
+// matches ErrConflict.
+type TransitionError struct {
+	From       State
+	Transition Transition
+	Allowed    []Transition
+}
+
+func (e *TransitionError) Error() string {
+	allowed := "none"
+	if len(e.Allowed) > 0 {
+		names := make([]string, len(e.Allowed))
+		for i, t := range e.Allowed {
+			names[i] = string(t)
+		}
+		allowed = strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("cannot %s a todo that is %s (allowed: %s)", e.Transition, e.From, allowed)
+}
+
+func (e *TransitionError) Is(target error) bool { return target == ErrConflict }
+
+// State returns the lifecycle state of the todo.
+func (t *Todo) State() State {
+	switch {
+	case t.Archived:
+		return StateArchived
+	case t.Completed:
+		return StateCompleted
+	}
+	return StateOpen
+}
+
+// AllowedTransitions returns the transitions allowed from state, in the
+// order of Transitions.
+func AllowedTransitions(state State) []Transition {
+	var allowed []Transition
+	for _, t := range Transitions {
+		if stateMachine[t].from == state {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// NextState returns the state transition leads to from state, or a
+// *TransitionError if it is not allowed.
+func NextState(state State, transition Transition) (State, error) {
+	edge, ok := stateMachine[transition]
+	if !ok || edge.from != state {
+		return "", &TransitionError{From: state, Transition: transition, Allowed: AllowedTransitions(state)}
+	}
+	return edge.to, nil
+}
+
+// TransitionPath returns the shortest sequence of transitions from one
+// state to another, for imports that set the state of a todo directly. It
+// is empty if the states are equal.
+func TransitionPath(from, to State) ([]Transition, bool) {
+	paths := map[State][]Transition{from: {}}
+	queue := []State{from}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if state == to {
+			return paths[state], true
+		}
+		for _, t := range AllowedTransitions(state) {
+			next := stateMachine[t].to
+			if _, seen := paths[next]; !seen {
+				paths[next] = append(append([]Transition(nil), paths[state]...), t)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil, false
+}
+
+// ParseState returns the state named s.
+func ParseState(s string) (State, bool) {
+	switch state := State(s); state {
+	case StateOpen, StateCompleted, StateArchived:
+		return state, true
+	}
+	return "", false
+}
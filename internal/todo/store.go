@@ -0,0 +1,196 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is the persistence interface behind Service. Implementations must be
+// safe for concurrent use. Todos are returned ordered by ID. Every method
+// takes the context of the request it serves, so backends that talk to a
+// database stop work the caller no longer waits for. Failures of the
+// backend, such as a lost connection, are returned as errors alongside the
+// documented ones.
+type Store interface {
+	// GetAll returns all todos ordered by ID.
+	GetAll(ctx context.Context) ([]*Todo, error)
+	// GetByID returns a todo by its ID, or ErrNotFound if no todo with
+	// that ID exists.
+	GetByID(ctx context.Context, id int) (*Todo, error)
+	// ListSubtasks returns the todos whose parent is parentID, ordered by
+	// ID, without reading the others where the backend can.
+	ListSubtasks(ctx context.Context, parentID int) ([]*Todo, error)
+	// Create adds a new todo using the provided input. IDs are never
+	// reused: the new ID is above every ID the store ever handed out,
+	// including those of deleted and merged todos, across restarts and
+	// restores, so a stale link can never point at a different todo.
+	Create(ctx context.Context, input TodoInput) (*Todo, error)
+	// Update modifies an existing todo identified by id.
+	// It returns ErrNotFound if the todo does not exist.
+	Update(ctx context.Context, id int, input TodoInput) (*Todo, error)
+	// Complete marks the todo with the given ID as completed.
+	// It returns ErrNotFound if the todo does not exist.
+	Complete(ctx context.Context, id int) (*Todo, error)
+	// SetState moves the todo with the given ID to state, setting its
+	// completed and archived flags. Stores do not check the transition;
+	// Service does. It returns ErrNotFound if the todo does not exist.
+	SetState(ctx context.Context, id int, state State) (*Todo, error)
+	// Delete removes the todo with the given ID.
+	// It returns ErrNotFound if the todo does not exist.
+	Delete(ctx context.Context, id int) error
+	// Merge folds the todo sourceID into the todo targetID. It returns
+	// ErrNotFound if either todo does not exist and a *ValidationError if
+	// they are the same todo.
+	Merge(ctx context.Context, targetID, sourceID int) (*Todo, error)
+	// MergedInto returns the ID of the todo that id was merged into.
+	// It returns ErrNotFound if id was never merged or its survivor is gone.
+	MergedInto(ctx context.Context, id int) (int, error)
+	// FindByExternalID returns the todo imported from source with the
+	// given external ID, or ErrNotFound if there is none.
+	FindByExternalID(ctx context.Context, source, externalID string) (*Todo, error)
+	// Milestones returns all milestones ordered by ID, each with the IDs
+	// of its todos in ascending order.
+	Milestones(ctx context.Context) ([]Milestone, error)
+	// CreateMilestone adds a milestone without todos. The input must
+	// already be validated.
+	CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error)
+	// AssignMilestone moves the todo todoID into the milestone milestoneID,
+	// out of any other milestone. It returns ErrNotFound if the milestone
+	// does not exist; the todo is not checked.
+	AssignMilestone(ctx context.Context, milestoneID, todoID int) error
+	// UnassignMilestone removes the todo todoID from the milestone
+	// milestoneID. It returns ErrNotFound if the milestone does not exist.
+	UnassignMilestone(ctx context.Context, milestoneID, todoID int) error
+	// Seed adds todos, keeping their IDs, states and times, if the store
+	// has never handed out a todo ID, and reports whether it did. The
+	// check and the additions are one atomic step, so of several servers
+	// seeding a shared backend at once only one seeds it. The todos must
+	// already be validated.
+	Seed(ctx context.Context, todos []BackupTodo) (bool, error)
+}
+
+// SnapshotStore is implemented by stores that can pin listings to a
+// snapshot. Service uses it for snapshot-isolated pagination when available.
+type SnapshotStore interface {
+	Store
+	// Snapshot returns all todos together with the sequence number that
+	// identifies this state of the store.
+	Snapshot(ctx context.Context) ([]*Todo, int)
+	// ListAt returns the todos that existed at the given snapshot sequence.
+	// The boolean is false if the snapshot is unknown or has expired.
+	ListAt(ctx context.Context, seq int) ([]*Todo, bool)
+}
+
+var _ SnapshotStore = (*TodoStore)(nil)
+
+// MigratingStore is implemented by stores with a versioned schema. Migrate
+// applies pending migrations and returns how many ran.
+type MigratingStore interface {
+	Store
+	Migrate(ctx context.Context) (int, error)
+}
+
+// ClockSource is implemented by stores backed by a database server with its
+// own clock. ServerTime returns the server's current time so diagnostics can
+// detect clock skew between the application and the database.
+type ClockSource interface {
+	ServerTime(ctx context.Context) (time.Time, error)
+}
+
+// StoreConfig selects and configures a Store backend.
+type StoreConfig struct {
+	// Backend is the registered backend name. An empty name selects
+	// the in-memory store.
+	Backend string
+	// DSN is the backend-specific data source, such as a file path or URL.
+	DSN string
+	// Clock supplies timestamps; nil selects SystemClock.
+	Clock Clock
+	// FlushInterval batches writes of backends that persist periodically.
+	// Zero writes on every mutation.
+	FlushInterval time.Duration
+}
+
+// StoreFactory constructs a Store from a configuration.
+type StoreFactory func(cfg StoreConfig) (Store, error)
+
+// ErrUnknownBackend is returned by NewStoreFromConfig for unregistered backends.
+var ErrUnknownBackend = errors.New("unknown store backend")
+
+// MemoryBackend is the name of the built-in in-memory backend.
+const MemoryBackend = "memory"
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]StoreFactory{
+		MemoryBackend: func(cfg StoreConfig) (Store, error) {
+			return NewTodoStoreWithClock(cfg.Clock), nil
+		},
+		JSONFileBackend: func(cfg StoreConfig) (Store, error) {
+			path := cfg.DSN
+			if path == "" {
+				path = DefaultJSONFilePath
+			}
+			return OpenFileStore(path, cfg.Clock, cfg.FlushInterval)
+		},
+		WALBackend: func(cfg StoreConfig) (Store, error) {
+			dir := cfg.DSN
+			if dir == "" {
+				dir = DefaultWALDir
+			}
+			return RecoverFrom(dir, cfg.Clock)
+		},
+	}
+)
+
+// RegisterStore makes a store backend available under the given name.
+// Backend packages call it from init. It panics if the name is already
+// registered or the factory is nil.
+func RegisterStore(name string, factory StoreFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("todo: RegisterStore factory is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic("todo: RegisterStore called twice for backend " + name)
+	}
+	backends[name] = factory
+}
+
+// Backends returns the names of the registered store backends in sorted order.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStoreFromConfig constructs the store backend selected by cfg.Backend.
+func NewStoreFromConfig(cfg StoreConfig) (Store, error) {
+	if cfg.Backend == "" {
+		cfg.Backend = MemoryBackend
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock{}
+	}
+
+	backendsMu.RLock()
+	factory, ok := backends[cfg.Backend]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownBackend, cfg.Backend, Backends())
+	}
+
+	return factory(cfg)
+}
@@ -0,0 +1,208 @@
+package todo_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+	"github.com/efrem/windsurf/internal/todo/storetest"
+)
+
+func TestTodoStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return todo.NewTodoStore()
+	})
+}
+
+func openFileStore(t *testing.T, path string, interval time.Duration) *todo.FileStore {
+	t.Helper()
+
+	store, err := todo.OpenFileStore(path, nil, interval)
+	if err != nil {
+		t.Fatalf("failed to open file store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		return openFileStore(t, filepath.Join(t.TempDir(), "todos.json"), 0)
+	})
+}
+
+func TestFileStoreSurvivesReopen(t *testing.T) {
+	for _, interval := range []time.Duration{0, time.Hour} {
+		path := filepath.Join(t.TempDir(), "todos.json")
+
+		first := openFileStore(t, path, interval)
+		kept := storetest.Create(t, first, todo.TodoInput{Title: "Persisted", Description: "across restarts"})
+		merged := storetest.Create(t, first, todo.TodoInput{Title: "Duplicate"})
+		deleted := storetest.Create(t, first, todo.TodoInput{Title: "Deleted"})
+		first.Complete(t.Context(), kept.ID)
+		first.Merge(t.Context(), kept.ID, merged.ID)
+		first.Delete(t.Context(), deleted.ID)
+		milestone, _ := first.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 1", StartDate: "2024-03-01", EndDate: "2024-03-15"})
+		first.AssignMilestone(t.Context(), milestone.ID, kept.ID)
+		if err := first.Close(); err != nil {
+			t.Fatalf("interval %v: failed to close store: %v", interval, err)
+		}
+
+		second := openFileStore(t, path, interval)
+		fetched, err := second.GetByID(t.Context(), kept.ID)
+		if err != nil || fetched.Title != "Persisted" || !fetched.Completed {
+			t.Fatalf("interval %v: unexpected todo after reopen: %+v", interval, fetched)
+		}
+		if survivor, err := second.MergedInto(t.Context(), merged.ID); err != nil || survivor != kept.ID {
+			t.Fatalf("interval %v: expected merge alias to survive reopen, got %d, %v", interval, survivor, err)
+		}
+		if milestones, err := second.Milestones(t.Context()); err != nil || len(milestones) != 1 || !slices.Equal(milestones[0].TodoIDs, []int{kept.ID}) {
+			t.Fatalf("interval %v: expected the milestone and its todo to survive reopen, got %+v, %v", interval, milestones, err)
+		}
+		if created := storetest.Create(t, second, todo.TodoInput{Title: "Next"}); created.ID != deleted.ID+1 {
+			t.Fatalf("interval %v: expected deleted ID %d not to be reused after reopen, got %d", interval, deleted.ID, created.ID)
+		}
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			t.Fatalf("failed to list store directory: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("interval %v: expected only the state file, found %d entries", interval, len(entries))
+		}
+	}
+}
+
+func TestWALStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) todo.Store {
+		store, err := todo.RecoverFrom(t.TempDir(), nil)
+		if err != nil {
+			t.Fatalf("failed to open write-ahead log: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestWALStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	clock := todo.NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	first, err := todo.RecoverFrom(dir, clock)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	kept := storetest.Create(t, first, todo.TodoInput{Title: "Kept"})
+	source := storetest.Create(t, first, todo.TodoInput{Title: "Source", Description: "details"})
+	milestone, _ := first.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 1", StartDate: "2024-03-01", EndDate: "2024-03-15"})
+	if err := first.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	clock.Advance(time.Hour)
+	first.Complete(t.Context(), kept.ID)
+	first.AssignMilestone(t.Context(), milestone.ID, kept.ID)
+	clock.Advance(time.Hour)
+	merged, _ := first.Merge(t.Context(), kept.ID, source.ID)
+	deleted := storetest.Create(t, first, todo.TodoInput{Title: "Deleted"})
+	first.Delete(t.Context(), deleted.ID)
+	if _, err := todo.RecoverFrom(dir, clock); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expected a log in use to be refused, got %v", err)
+	}
+
+	// Simulate a crash: a copy of the log as the store left it, without
+	// Close, and with a torn record at the end.
+	crashed := t.TempDir()
+	if err := os.CopyFS(crashed, os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(crashed, "wal.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	logFile.WriteString(`{"seq":99,"op":"cre`)
+	logFile.Close()
+
+	clock.Advance(time.Hour)
+	second, err := todo.RecoverFrom(crashed, clock)
+	if err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	defer second.Close()
+
+	all := storetest.GetAll(t, second)
+	if len(all) != 1 || all[0].ID != kept.ID || !all[0].Completed || all[0].Description != "details" {
+		t.Fatalf("unexpected recovered todos: %+v", all)
+	}
+	if !all[0].UpdatedAt.Equal(merged.UpdatedAt) {
+		t.Fatalf("expected update time %v to be recovered, got %v", merged.UpdatedAt, all[0].UpdatedAt)
+	}
+	if survivor, err := second.MergedInto(t.Context(), source.ID); err != nil || survivor != kept.ID {
+		t.Fatalf("expected merge to be recovered, got %d, %v", survivor, err)
+	}
+	if milestones, err := second.Milestones(t.Context()); err != nil || len(milestones) != 1 || !slices.Equal(milestones[0].TodoIDs, []int{kept.ID}) {
+		t.Fatalf("expected the milestone and its todo to be recovered, got %+v, %v", milestones, err)
+	}
+	if next := storetest.Create(t, second, todo.TodoInput{Title: "Next"}); next.ID != deleted.ID+1 {
+		t.Fatalf("expected IDs to continue after %d, got %d", deleted.ID, next.ID)
+	}
+}
+
+func TestWALStorePointInTimeRestore(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := todo.NewManualClock(start)
+	store, err := todo.RecoverFrom(dir, clock)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	defer store.Close()
+
+	edited := storetest.Create(t, store, todo.TodoInput{Title: "Edited"})
+	deleted := storetest.Create(t, store, todo.TodoInput{Title: "Deleted"})
+	clock.Advance(time.Hour)
+	if err := store.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	clock.Advance(time.Hour)
+	store.Update(t.Context(), edited.ID, todo.TodoInput{Title: "Edited once"})
+	target := clock.Now()
+	clock.Advance(time.Hour)
+	store.Update(t.Context(), edited.ID, todo.TodoInput{Title: "Edited twice"})
+	store.Delete(t.Context(), deleted.ID)
+	created := storetest.Create(t, store, todo.TodoInput{Title: "Created later"})
+
+	_, plan, err := todo.PlanPointInTime(dir, target)
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+	if !plan.Base.Equal(start.Add(time.Hour)) || plan.Replayed != 1 || plan.Discarded != 3 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if !slices.Equal(plan.Removed, []int{created.ID}) || !slices.Equal(plan.Reverted, []int{edited.ID}) || !slices.Equal(plan.Recovered, []int{deleted.ID}) {
+		t.Fatalf("unexpected changes in plan: %+v", plan)
+	}
+	if len(storetest.GetAll(t, store)) != 2 {
+		t.Fatalf("expected planning to leave the store unchanged")
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := store.RestorePointInTime(t.Context(), target); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	all := storetest.GetAll(t, store)
+	if len(all) != 2 || all[0].Title != "Edited once" || all[1].ID != deleted.ID {
+		t.Fatalf("unexpected todos after restore: %+v", all)
+	}
+	if next := storetest.Create(t, store, todo.TodoInput{Title: "Next"}); next.ID != created.ID+1 {
+		t.Fatalf("expected IDs to continue after %d, got %d", created.ID, next.ID)
+	}
+
+	// The replaced state is archived, so the restore can be undone.
+	if _, undo, err := todo.PlanPointInTime(dir, target.Add(90*time.Minute)); err != nil || !slices.Contains(undo.Recovered, created.ID) {
+		t.Fatalf("expected the restore to be undoable, got %+v, %v", undo, err)
+	}
+}
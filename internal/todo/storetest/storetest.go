@@ -0,0 +1,572 @@
+// Package storetest provides a conformance suite that every todo.Store
+// implementation runs to verify it matches the in-memory store's semantics.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efrem/windsurf/internal/todo"
+)
+
+// Run exercises the Store returned by newStore. newStore must return a new,
+// empty store on every call. Optional interfaces such as todo.BackupStore
+// are exercised when the store implements them.
+func Run(t *testing.T, newStore func(t *testing.T) todo.Store) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		store := newStore(t)
+
+		created := Create(t, store, todo.TodoInput{Title: "Test", Description: "Desc"})
+		if created.ID == 0 {
+			t.Fatalf("expected created todo to have a non-zero ID")
+		}
+		if created.Completed {
+			t.Fatalf("expected new todo to be not completed")
+		}
+		if created.CreatedAt.IsZero() {
+			t.Fatalf("expected created todo to have a creation time")
+		}
+
+		fetched, err := store.GetByID(t.Context(), created.ID)
+		if err != nil {
+			t.Fatalf("expected todo with ID %d to exist", created.ID)
+		}
+		if fetched.Title != "Test" || fetched.Description != "Desc" {
+			t.Fatalf("unexpected fetched todo: %+v", fetched)
+		}
+		if !fetched.CreatedAt.Equal(created.CreatedAt) {
+			t.Fatalf("expected creation time %v, got %v", created.CreatedAt, fetched.CreatedAt)
+		}
+	})
+
+	t.Run("GetAllOrderedByID", func(t *testing.T) {
+		store := newStore(t)
+
+		for _, title := range []string{"a", "b", "c"} {
+			Create(t, store, todo.TodoInput{Title: title})
+		}
+
+		all := GetAll(t, store)
+		if len(all) != 3 {
+			t.Fatalf("expected 3 todos, got %d", len(all))
+		}
+		for i := 1; i < len(all); i++ {
+			if all[i-1].ID >= all[i].ID {
+				t.Fatalf("expected todos ordered by ID, got %d before %d", all[i-1].ID, all[i].ID)
+			}
+		}
+	})
+
+	t.Run("UpdateCompleteDelete", func(t *testing.T) {
+		store := newStore(t)
+		created := Create(t, store, todo.TodoInput{Title: "Original", Description: "Original desc"})
+
+		updated, err := store.Update(t.Context(), created.ID, todo.TodoInput{Title: "Updated", Description: "Updated desc"})
+		if err != nil || updated.Title != "Updated" || updated.Description != "Updated desc" {
+			t.Fatalf("unexpected update result: %+v, %v", updated, err)
+		}
+
+		completed, err := store.Complete(t.Context(), created.ID)
+		if err != nil || !completed.Completed {
+			t.Fatalf("unexpected complete result: %+v, %v", completed, err)
+		}
+		if fetched, _ := store.GetByID(t.Context(), created.ID); !fetched.Completed || fetched.Title != "Updated" {
+			t.Fatalf("expected changes to be persisted, got %+v", fetched)
+		}
+
+		if err := store.Delete(t.Context(), created.ID); err != nil {
+			t.Fatalf("expected delete to succeed, got %v", err)
+		}
+		if _, err := store.GetByID(t.Context(), created.ID); err == nil {
+			t.Fatalf("expected todo to be removed after delete")
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		store := newStore(t)
+		created := Create(t, store, todo.TodoInput{Title: "Versioned", Description: "first"})
+		source := Create(t, store, todo.TodoInput{Title: "Source", Description: "second"})
+		if created.Version != 1 {
+			t.Fatalf("expected a new todo to be at version 1, got %d", created.Version)
+		}
+
+		steps := []func() (*todo.Todo, error){
+			func() (*todo.Todo, error) {
+				return store.Update(t.Context(), created.ID, todo.TodoInput{Title: "Renamed"})
+			},
+			func() (*todo.Todo, error) { return store.Complete(t.Context(), created.ID) },
+			func() (*todo.Todo, error) { return store.SetState(t.Context(), created.ID, todo.StateArchived) },
+			func() (*todo.Todo, error) { return store.Merge(t.Context(), created.ID, source.ID) },
+		}
+		if !created.UpdatedAt.Equal(created.CreatedAt) {
+			t.Fatalf("expected a new todo to be updated at its creation time, got %v and %v", created.UpdatedAt, created.CreatedAt)
+		}
+		updatedAt := created.UpdatedAt
+		for i, step := range steps {
+			got, err := step()
+			if err != nil || got.Version != i+2 {
+				t.Fatalf("expected change %d to yield version %d, got %+v, %v", i+1, i+2, got, err)
+			}
+			if got.UpdatedAt.Before(updatedAt) {
+				t.Fatalf("expected change %d to advance the update time from %v, got %v", i+1, updatedAt, got.UpdatedAt)
+			}
+			updatedAt = got.UpdatedAt
+			fetched, _ := store.GetByID(t.Context(), created.ID)
+			if fetched.Version != i+2 {
+				t.Fatalf("expected version %d to be persisted, got %d", i+2, fetched.Version)
+			}
+			if !fetched.UpdatedAt.Equal(updatedAt) {
+				t.Fatalf("expected update time %v to be persisted, got %v", updatedAt, fetched.UpdatedAt)
+			}
+		}
+
+		backup, ok := store.(todo.BackupStore)
+		if !ok {
+			return
+		}
+		dump := Backup(t, backup)
+		target := newStore(t).(todo.BackupStore)
+		if err := target.Restore(t.Context(), dump); err != nil {
+			t.Fatalf("failed to restore backup: %v", err)
+		}
+		restored, _ := target.GetByID(t.Context(), created.ID)
+		if restored.Version != 5 {
+			t.Fatalf("expected the version to be restored, got %d", restored.Version)
+		}
+		if !restored.UpdatedAt.Equal(updatedAt) {
+			t.Fatalf("expected update time %v to be restored, got %v", updatedAt, restored.UpdatedAt)
+		}
+	})
+
+	t.Run("SetState", func(t *testing.T) {
+		store := newStore(t)
+		created := Create(t, store, todo.TodoInput{Title: "Stateful"})
+
+		for _, want := range []todo.State{todo.StateCompleted, todo.StateArchived, todo.StateCompleted, todo.StateOpen} {
+			got, err := store.SetState(t.Context(), created.ID, want)
+			if err != nil || got.State() != want {
+				t.Fatalf("expected state %s, got %+v, %v", want, got, err)
+			}
+			if fetched, _ := store.GetByID(t.Context(), created.ID); fetched.State() != want || fetched.Title != "Stateful" {
+				t.Fatalf("expected state %s to be persisted, got %+v", want, fetched)
+			}
+		}
+	})
+
+	t.Run("NegativePaths", func(t *testing.T) {
+		store := newStore(t)
+
+		if got, err := store.GetByID(t.Context(), 999); !errors.Is(err, todo.ErrNotFound) || got != nil {
+			t.Fatalf("expected GetByID on missing ID to return ErrNotFound, got (%+v, %v)", got, err)
+		}
+		if got, err := store.Update(t.Context(), 999, todo.TodoInput{Title: "X"}); !errors.Is(err, todo.ErrNotFound) || got != nil {
+			t.Fatalf("expected Update on missing ID to return ErrNotFound, got (%+v, %v)", got, err)
+		}
+		if got, err := store.Complete(t.Context(), 999); !errors.Is(err, todo.ErrNotFound) || got != nil {
+			t.Fatalf("expected Complete on missing ID to return ErrNotFound, got (%+v, %v)", got, err)
+		}
+		if got, err := store.SetState(t.Context(), 999, todo.StateArchived); !errors.Is(err, todo.ErrNotFound) || got != nil {
+			t.Fatalf("expected SetState on missing ID to return ErrNotFound, got (%+v, %v)", got, err)
+		}
+		if err := store.Delete(t.Context(), 999); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("expected Delete on missing ID to return ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("IDsNeverReused", func(t *testing.T) {
+		store := newStore(t)
+		kept := Create(t, store, todo.TodoInput{Title: "Kept"})
+		merged := Create(t, store, todo.TodoInput{Title: "Merged"})
+		store.Merge(t.Context(), kept.ID, merged.ID)
+		if next := Create(t, store, todo.TodoInput{Title: "After merge"}); next.ID <= merged.ID {
+			t.Fatalf("expected merged ID %d not to be reused, got %d", merged.ID, next.ID)
+		}
+
+		backup, ok := store.(todo.BackupStore)
+		if !ok {
+			return
+		}
+		older := Backup(t, backup)
+		deleted := Create(t, store, todo.TodoInput{Title: "Deleted"})
+		store.Delete(t.Context(), deleted.ID)
+		if next := Create(t, store, todo.TodoInput{Title: "After delete"}); next.ID <= deleted.ID {
+			t.Fatalf("expected deleted ID %d not to be reused, got %d", deleted.ID, next.ID)
+		}
+		last := Create(t, store, todo.TodoInput{Title: "Last"})
+
+		// Restoring an older backup must not hand out IDs issued since.
+		if err := backup.Restore(t.Context(), older); err != nil {
+			t.Fatalf("failed to restore backup: %v", err)
+		}
+		if next := Create(t, store, todo.TodoInput{Title: "After restore"}); next.ID <= last.ID {
+			t.Fatalf("expected IDs up to %d not to be reused after restoring an older backup, got %d", last.ID, next.ID)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		store := newStore(t)
+		target := Create(t, store, todo.TodoInput{Title: "Target", Description: "first"})
+		source := Create(t, store, todo.TodoInput{Title: "Source", Description: "second"})
+		other := Create(t, store, todo.TodoInput{Title: "Other"})
+
+		merged, err := store.Merge(t.Context(), target.ID, source.ID)
+		if err != nil || merged.Description != "first\n\nsecond" {
+			t.Fatalf("unexpected merge result: %+v, %v", merged, err)
+		}
+		if _, err := store.GetByID(t.Context(), source.ID); err == nil {
+			t.Fatalf("expected merged source to be removed")
+		}
+		if survivor, err := store.MergedInto(t.Context(), source.ID); err != nil || survivor != target.ID {
+			t.Fatalf("expected source to resolve to %d, got %d, %v", target.ID, survivor, err)
+		}
+
+		if _, err := store.Merge(t.Context(), other.ID, target.ID); err != nil {
+			t.Fatalf("expected chained merge to succeed")
+		}
+		if survivor, err := store.MergedInto(t.Context(), source.ID); err != nil || survivor != other.ID {
+			t.Fatalf("expected chained merge to resolve to %d, got %d, %v", other.ID, survivor, err)
+		}
+
+		if _, err := store.Merge(t.Context(), other.ID, other.ID); !errors.Is(err, todo.ErrValidation) {
+			t.Fatalf("expected merging a todo into itself to fail validation, got %v", err)
+		}
+		if _, err := store.Merge(t.Context(), other.ID, 999); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("expected merging a missing todo to return ErrNotFound, got %v", err)
+		}
+		if _, err := store.MergedInto(t.Context(), other.ID); err == nil {
+			t.Fatalf("expected unmerged todo not to resolve")
+		}
+
+		store.Delete(t.Context(), other.ID)
+		if _, err := store.MergedInto(t.Context(), source.ID); err == nil {
+			t.Fatalf("expected merge alias to a deleted survivor not to resolve")
+		}
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		store := newStore(t)
+		target := Create(t, store, todo.TodoInput{Title: "Target", Tags: []string{"work", "urgent"}})
+		source := Create(t, store, todo.TodoInput{Title: "Source", Tags: []string{"home", "work"}})
+		untagged := Create(t, store, todo.TodoInput{Title: "Untagged"})
+
+		if fetched, _ := store.GetByID(t.Context(), target.ID); !slices.Equal(fetched.Tags, []string{"work", "urgent"}) {
+			t.Fatalf("expected tags to be stored in order, got %v", fetched.Tags)
+		}
+		if fetched, _ := store.GetByID(t.Context(), untagged.ID); fetched.Tags != nil {
+			t.Fatalf("expected an untagged todo to have no tags, got %#v", fetched.Tags)
+		}
+
+		merged, err := store.Merge(t.Context(), target.ID, source.ID)
+		if err != nil || !slices.Equal(merged.Tags, []string{"work", "urgent", "home"}) {
+			t.Fatalf("expected the merged todo to gain the source's tags, got %+v, %v", merged, err)
+		}
+		if fetched, _ := store.GetByID(t.Context(), target.ID); !slices.Equal(fetched.Tags, []string{"work", "urgent", "home"}) {
+			t.Fatalf("expected merged tags to be stored, got %v", fetched.Tags)
+		}
+
+		updated, err := store.Update(t.Context(), target.ID, todo.TodoInput{Title: "Target"})
+		if err != nil || updated.Tags != nil {
+			t.Fatalf("expected an update without tags to clear them, got %+v, %v", updated, err)
+		}
+		if fetched, _ := store.GetByID(t.Context(), target.ID); fetched.Tags != nil {
+			t.Fatalf("expected cleared tags to be stored, got %#v", fetched.Tags)
+		}
+	})
+
+	t.Run("Subtasks", func(t *testing.T) {
+		store := newStore(t)
+		parent := Create(t, store, todo.TodoInput{Title: "Parent"})
+		parentID := parent.ID
+		child := Create(t, store, todo.TodoInput{Title: "Child", ParentID: &parentID})
+		if child.ParentID != parent.ID {
+			t.Fatalf("expected created todo to keep its parent, got %+v", child)
+		}
+		if fetched, _ := store.GetByID(t.Context(), child.ID); fetched.ParentID != parent.ID {
+			t.Fatalf("expected parent to be stored, got %+v", fetched)
+		}
+		if fetched, _ := store.GetByID(t.Context(), parent.ID); fetched.ParentID != 0 {
+			t.Fatalf("expected a todo created without parent to have none, got %+v", fetched)
+		}
+
+		if backups, ok := store.(todo.BackupStore); ok {
+			restored := newStore(t).(todo.BackupStore)
+			if err := restored.Restore(t.Context(), Backup(t, backups)); err != nil {
+				t.Fatalf("failed to restore backup: %v", err)
+			}
+			if fetched, _ := restored.GetByID(t.Context(), child.ID); fetched.ParentID != parent.ID {
+				t.Fatalf("expected parent to be restored, got %+v", fetched)
+			}
+		}
+
+		updated, err := store.Update(t.Context(), child.ID, todo.TodoInput{Title: "Child"})
+		if err != nil || updated.ParentID != 0 {
+			t.Fatalf("expected an update without parent to clear it, got %+v, %v", updated, err)
+		}
+		if fetched, _ := store.GetByID(t.Context(), child.ID); fetched.ParentID != 0 {
+			t.Fatalf("expected cleared parent to be stored, got %+v", fetched)
+		}
+	})
+
+	t.Run("ListSubtasks", func(t *testing.T) {
+		store := newStore(t)
+		parent := Create(t, store, todo.TodoInput{Title: "Parent"})
+		other := Create(t, store, todo.TodoInput{Title: "Other"})
+		parentID, otherID := parent.ID, other.ID
+		first := Create(t, store, todo.TodoInput{Title: "First", ParentID: &parentID})
+		Create(t, store, todo.TodoInput{Title: "Elsewhere", ParentID: &otherID})
+		second := Create(t, store, todo.TodoInput{Title: "Second", ParentID: &parentID})
+
+		subtasks, err := store.ListSubtasks(t.Context(), parent.ID)
+		if err != nil || len(subtasks) != 2 || subtasks[0].ID != first.ID || subtasks[1].ID != second.ID {
+			t.Fatalf("expected the subtasks of the parent ordered by ID, got %+v, %v", subtasks, err)
+		}
+		if _, err := store.Update(t.Context(), first.ID, todo.TodoInput{Title: "First"}); err != nil {
+			t.Fatalf("failed to clear the parent: %v", err)
+		}
+		if subtasks, err := store.ListSubtasks(t.Context(), parent.ID); err != nil || len(subtasks) != 1 || subtasks[0].ID != second.ID {
+			t.Fatalf("expected a detached todo not to be listed, got %+v, %v", subtasks, err)
+		}
+		if subtasks, err := store.ListSubtasks(t.Context(), 9999); err != nil || len(subtasks) != 0 {
+			t.Fatalf("expected no subtasks of a missing todo, got %+v, %v", subtasks, err)
+		}
+	})
+
+	t.Run("ExternalIDs", func(t *testing.T) {
+		store := newStore(t)
+		imported := Create(t, store, todo.TodoInput{Title: "Imported", Source: "jira", ExternalID: "PROJ-1"})
+		merged := Create(t, store, todo.TodoInput{Title: "Merged", Source: "jira", ExternalID: "PROJ-2"})
+		Create(t, store, todo.TodoInput{Title: "Local"})
+
+		if imported.Source != "jira" || imported.ExternalID != "PROJ-1" {
+			t.Fatalf("expected created todo to keep its external ID, got %+v", imported)
+		}
+		found, err := store.FindByExternalID(t.Context(), "jira", "PROJ-1")
+		if err != nil || found.ID != imported.ID || found.ExternalID != "PROJ-1" {
+			t.Fatalf("expected to find todo %d by external ID, got %+v, %v", imported.ID, found, err)
+		}
+		if _, err := store.FindByExternalID(t.Context(), "github", "PROJ-1"); err == nil {
+			t.Fatalf("expected external IDs to be scoped to their source")
+		}
+		if _, err := store.FindByExternalID(t.Context(), "", ""); err == nil {
+			t.Fatalf("expected todos without an external ID not to be found")
+		}
+
+		store.Merge(t.Context(), imported.ID, merged.ID)
+		if _, err := store.FindByExternalID(t.Context(), "jira", "PROJ-2"); err == nil {
+			t.Fatalf("expected a merged todo's external ID to be released")
+		}
+		store.Delete(t.Context(), imported.ID)
+		if _, err := store.FindByExternalID(t.Context(), "jira", "PROJ-1"); err == nil {
+			t.Fatalf("expected a deleted todo's external ID to be released")
+		}
+		if again := Create(t, store, todo.TodoInput{Title: "Again", Source: "jira", ExternalID: "PROJ-1"}); again.ID == imported.ID {
+			t.Fatalf("expected a released external ID to be reusable by a new todo")
+		}
+	})
+
+	t.Run("Milestones", func(t *testing.T) {
+		store := newStore(t)
+		first, err := store.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 1", StartDate: "2024-03-01", EndDate: "2024-03-15"})
+		if err != nil {
+			t.Fatalf("failed to create milestone: %v", err)
+		}
+		second, err := store.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 2", StartDate: "2024-03-16", EndDate: "2024-03-31"})
+		if err != nil {
+			t.Fatalf("failed to create milestone: %v", err)
+		}
+		if first.ID <= 0 || second.ID <= first.ID || first.Name != "Sprint 1" || first.EndDate != "2024-03-15" {
+			t.Fatalf("unexpected milestones: %+v, %+v", first, second)
+		}
+
+		a := Create(t, store, todo.TodoInput{Title: "A"})
+		b := Create(t, store, todo.TodoInput{Title: "B"})
+		for _, id := range []int{b.ID, a.ID} {
+			if err := store.AssignMilestone(t.Context(), first.ID, id); err != nil {
+				t.Fatalf("failed to assign todo %d: %v", id, err)
+			}
+		}
+		if err := store.AssignMilestone(t.Context(), second.ID, b.ID); err != nil {
+			t.Fatalf("failed to move todo %d: %v", b.ID, err)
+		}
+		milestones, err := store.Milestones(t.Context())
+		if err != nil || len(milestones) != 2 {
+			t.Fatalf("expected 2 milestones, got %+v, %v", milestones, err)
+		}
+		if !slices.Equal(milestones[0].TodoIDs, []int{a.ID}) || !slices.Equal(milestones[1].TodoIDs, []int{b.ID}) {
+			t.Fatalf("expected the assigned todo to move to the other milestone, got %+v", milestones)
+		}
+
+		if err := store.UnassignMilestone(t.Context(), first.ID, a.ID); err != nil {
+			t.Fatalf("failed to unassign todo %d: %v", a.ID, err)
+		}
+		if milestones, _ := store.Milestones(t.Context()); len(milestones[0].TodoIDs) != 0 {
+			t.Fatalf("expected the unassigned todo to leave the milestone, got %+v", milestones[0])
+		}
+		if err := store.AssignMilestone(t.Context(), second.ID+1, a.ID); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound assigning to a missing milestone, got %v", err)
+		}
+		if err := store.UnassignMilestone(t.Context(), second.ID+1, a.ID); !errors.Is(err, todo.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound unassigning from a missing milestone, got %v", err)
+		}
+	})
+
+	t.Run("Seed", func(t *testing.T) {
+		store := newStore(t)
+		created := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+		seed := []todo.BackupTodo{
+			{ID: 1, Title: "Open", Version: 1, CreatedAt: created, UpdatedAt: created},
+			{ID: 2, Title: "Done", Completed: true, Version: 1, CreatedAt: created, UpdatedAt: created},
+		}
+		if seeded, err := store.Seed(t.Context(), seed); err != nil || !seeded {
+			t.Fatalf("expected a new store to be seeded, got %v, %v", seeded, err)
+		}
+		all := GetAll(t, store)
+		if len(all) != 2 || all[0].Title != "Open" || all[0].Completed || !all[1].Completed || !all[1].CreatedAt.Equal(created) {
+			t.Fatalf("unexpected seeded todos: %+v", all)
+		}
+		if next := Create(t, store, todo.TodoInput{Title: "Next"}); next.ID != 3 {
+			t.Fatalf("expected IDs to continue after the seed, got %d", next.ID)
+		}
+		if seeded, err := store.Seed(t.Context(), seed); err != nil || seeded {
+			t.Fatalf("expected a seeded store not to be seeded again, got %v, %v", seeded, err)
+		}
+
+		emptied := newStore(t)
+		deleted := Create(t, emptied, todo.TodoInput{Title: "Deleted"})
+		emptied.Delete(t.Context(), deleted.ID)
+		if seeded, err := emptied.Seed(t.Context(), seed); err != nil || seeded {
+			t.Fatalf("expected a store emptied by its users not to be seeded, got %v, %v", seeded, err)
+		}
+		if len(GetAll(t, emptied)) != 0 {
+			t.Fatalf("expected the emptied store to stay empty")
+		}
+	})
+
+	t.Run("BackupRestore", func(t *testing.T) {
+		source, ok := newStore(t).(todo.BackupStore)
+		if !ok {
+			t.Skip("store does not implement todo.BackupStore")
+		}
+		kept := Create(t, source, todo.TodoInput{Title: "Kept", Description: "body", Source: "jira", ExternalID: "PROJ-1", Tags: []string{"work"}})
+		merged := Create(t, source, todo.TodoInput{Title: "Merged"})
+		deleted := Create(t, source, todo.TodoInput{Title: "Deleted"})
+		source.SetState(t.Context(), kept.ID, todo.StateArchived)
+		source.Merge(t.Context(), kept.ID, merged.ID)
+		source.Delete(t.Context(), deleted.ID)
+		milestone, err := source.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 1", StartDate: "2024-03-01", EndDate: "2024-03-15"})
+		if err != nil {
+			t.Fatalf("failed to create milestone: %v", err)
+		}
+		source.AssignMilestone(t.Context(), milestone.ID, kept.ID)
+
+		backup := Backup(t, source)
+		if len(backup.Todos) != 1 || backup.Merged[merged.ID] != kept.ID || backup.NextID <= deleted.ID {
+			t.Fatalf("unexpected backup: %+v", backup)
+		}
+
+		target := newStore(t).(todo.BackupStore)
+		Create(t, target, todo.TodoInput{Title: "Replaced"})
+		Create(t, target, todo.TodoInput{Title: "Replaced too"})
+		if err := target.Restore(t.Context(), backup); err != nil {
+			t.Fatalf("failed to restore backup: %v", err)
+		}
+
+		all := GetAll(t, target)
+		if len(all) != 1 || all[0].ID != kept.ID || all[0].State() != todo.StateArchived || all[0].Description != "body" || !slices.Equal(all[0].Tags, []string{"work"}) {
+			t.Fatalf("unexpected todos after restore: %+v", all)
+		}
+		if !all[0].CreatedAt.Equal(kept.CreatedAt) {
+			t.Fatalf("expected creation time %v to be restored, got %v", kept.CreatedAt, all[0].CreatedAt)
+		}
+		if survivor, err := target.MergedInto(t.Context(), merged.ID); err != nil || survivor != kept.ID {
+			t.Fatalf("expected merge alias to be restored, got %d, %v", survivor, err)
+		}
+		if found, err := target.FindByExternalID(t.Context(), "jira", "PROJ-1"); err != nil || found.ID != kept.ID {
+			t.Fatalf("expected external ID to be restored, got %+v, %v", found, err)
+		}
+		if milestones, err := target.Milestones(t.Context()); err != nil || len(milestones) != 1 || milestones[0].Name != "Sprint 1" || !slices.Equal(milestones[0].TodoIDs, []int{kept.ID}) {
+			t.Fatalf("expected the milestone and its todo to be restored, got %+v, %v", milestones, err)
+		}
+		if next, err := target.CreateMilestone(t.Context(), todo.MilestoneInput{Name: "Sprint 2", StartDate: "2024-03-16", EndDate: "2024-03-31"}); err != nil || next.ID <= milestone.ID {
+			t.Fatalf("expected restored store not to reuse milestone ID %d, got %+v, %v", milestone.ID, next, err)
+		}
+		if next := Create(t, target, todo.TodoInput{Title: "Next"}); next.ID <= deleted.ID {
+			t.Fatalf("expected restored store not to reuse ID %d, got %d", deleted.ID, next.ID)
+		}
+
+		invalid := todo.Backup{Todos: []todo.BackupTodo{{ID: 1, Title: "a"}, {ID: 1, Title: "b"}}}
+		if err := target.Restore(t.Context(), invalid); err == nil {
+			t.Fatalf("expected an invalid backup to be rejected")
+		}
+		if len(GetAll(t, target)) != 2 {
+			t.Fatalf("expected a rejected restore to keep the content")
+		}
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		store, ok := newStore(t).(todo.CompactStore)
+		if !ok {
+			t.Skip("store does not implement todo.CompactStore")
+		}
+		description := strings.Repeat("padding ", 500)
+		var created []*todo.Todo
+		for i := range 200 {
+			created = append(created, Create(t, store, todo.TodoInput{Title: fmt.Sprintf("Todo %d", i), Description: description}))
+		}
+		for _, deleted := range created[1:] {
+			store.Delete(t.Context(), deleted.ID)
+		}
+		before, err := store.DiskSize()
+		if err != nil || before == 0 {
+			t.Fatalf("expected the size of the store, got %d, %v", before, err)
+		}
+
+		if err := store.Compact(); err != nil {
+			t.Fatalf("failed to compact the store: %v", err)
+		}
+		after, err := store.DiskSize()
+		if err != nil || after >= before {
+			t.Fatalf("expected compaction to shrink the store from %d bytes, got %d, %v", before, after, err)
+		}
+		if kept, err := store.GetByID(t.Context(), created[0].ID); err != nil || kept.Description != description {
+			t.Fatalf("expected the remaining todo to survive compaction, got %+v, %v", kept, err)
+		}
+		if next := Create(t, store, todo.TodoInput{Title: "Next"}); next.ID <= created[len(created)-1].ID {
+			t.Fatalf("expected the compacted store not to reuse IDs, got %d", next.ID)
+		}
+	})
+}
+
+// Create adds a todo to store, failing the test if the store fails.
+func Create(t *testing.T, store todo.Store, input todo.TodoInput) *todo.Todo {
+	t.Helper()
+	created, err := store.Create(t.Context(), input)
+	if err != nil {
+		t.Fatalf("failed to create todo %q: %v", input.Title, err)
+	}
+	return created
+}
+
+// GetAll returns all todos of store, failing the test if the store fails.
+func GetAll(t *testing.T, store todo.Store) []*todo.Todo {
+	t.Helper()
+	todos, err := store.GetAll(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list todos: %v", err)
+	}
+	return todos
+}
+
+// Backup returns a backup of store, failing the test if the store fails.
+func Backup(t *testing.T, store todo.BackupStore) todo.Backup {
+	t.Helper()
+	b, err := store.Backup(t.Context())
+	if err != nil {
+		t.Fatalf("failed to back up the store: %v", err)
+	}
+	return b
+}
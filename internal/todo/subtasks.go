@@ -0,0 +1,217 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/efrem/windsurf/internal/validate"
+)
+
+// SubtaskPolicy selects what completing a todo with open subtasks does.
+type SubtaskPolicy string
+
+const (
+	// SubtaskPolicyBlock rejects the completion with a *SubtaskError until
+	// every subtask is completed. It is the default.
+	SubtaskPolicyBlock SubtaskPolicy = "block"
+	// SubtaskPolicyCascade completes the open subtasks, and theirs, first.
+	SubtaskPolicyCascade SubtaskPolicy = "cascade"
+)
+
+// ParseSubtaskPolicy returns the policy named s, which is either block or
+// cascade.
+func ParseSubtaskPolicy(s string) (SubtaskPolicy, bool) {
+	switch SubtaskPolicy(s) {
+	case SubtaskPolicyBlock, SubtaskPolicyCascade:
+		return SubtaskPolicy(s), true
+	}
+	return "", false
+}
+
+// SubtaskError reports a todo that cannot be completed because subtasks of
+// it are open, under SubtaskPolicyBlock. It matches ErrConflict.
+type SubtaskError struct {
+	ID   int
+	Open int
+}
+
+func (e *SubtaskError) Error() string {
+	return fmt.Sprintf("todo %d has %d open subtasks; complete them first", e.ID, e.Open)
+}
+
+func (e *SubtaskError) Is(target error) bool { return target == ErrConflict }
+
+// ruleParent is the rule of FieldErrors reporting an invalid parent.
+const ruleParent = "parent"
+
+// Parent returns the ID of the parent the input gives, or 0 for none.
+func (input TodoInput) Parent() int {
+	if input.ParentID == nil {
+		return 0
+	}
+	return *input.ParentID
+}
+
+// SubtaskProgress counts the subtasks of a todo and how many of them are
+// completed, archived ones included.
+type SubtaskProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+}
+
+// subtaskIndex maps the ID of each todo with subtasks to their progress.
+type subtaskIndex map[int]SubtaskProgress
+
+// indexSubtasks returns the progress of the subtasks of todos.
+func indexSubtasks(todos []*Todo) subtaskIndex {
+	index := subtaskIndex{}
+	for _, todo := range todos {
+		if todo.ParentID == 0 {
+			continue
+		}
+		progress := index[todo.ParentID]
+		progress.Total++
+		if todo.Completed {
+			progress.Completed++
+		}
+		index[todo.ParentID] = progress
+	}
+	return index
+}
+
+// SubtaskCollection is the response of GET /todos/{id}/subtasks.
+type SubtaskCollection struct {
+	Todos    []Todo          `json:"todos"`
+	Progress SubtaskProgress `json:"progress"`
+	Links    Links           `json:"_links"`
+}
+
+// GetSubtasks handles GET /todos/{id}/subtasks and lists the subtasks of a
+// todo, ordered by ID, with their progress.
+func (api *TodoAPI) GetSubtasks(w http.ResponseWriter, r *http.Request) {
+	id := todoIDFromContext(r.Context())
+	if _, err := api.service.GetTodo(r.Context(), id); err != nil {
+		api.sendTodoError(w, r, id, err)
+		return
+	}
+	subtasks, err := api.service.ListSubtasks(r.Context(), id)
+	if err != nil {
+		api.sendTodoError(w, r, id, err)
+		return
+	}
+
+	collection := SubtaskCollection{
+		Todos:    []Todo{},
+		Progress: indexSubtasks(subtasks)[id],
+		Links: Links{
+			Self:   &Link{Href: fmt.Sprintf("%s/todos/%d/subtasks", api.base(r), id), Method: "GET"},
+			Parent: &Link{Href: fmt.Sprintf("%s/todos/%d", api.base(r), id), Method: "GET"},
+			Todos:  &Link{Href: fmt.Sprintf("%s/todos", api.base(r)), Method: "GET"},
+		},
+	}
+	for _, subtask := range subtasks {
+		todo := api.present(r, subtask)
+		truncateForListing(&todo, api.base(r))
+		collection.Todos = append(collection.Todos, todo)
+	}
+	staleResponse(w, r)
+	api.respond(w, r, http.StatusOK, collection)
+}
+
+// checkParent validates the parent input gives the todo with the given ID,
+// or a new todo if id is 0: it must exist and must not be the todo or one
+// of its subtasks. The caller must hold s.mu.
+func (s *service) checkParent(ctx context.Context, id int, input TodoInput) error {
+	parentID := input.Parent()
+	if parentID == 0 {
+		return nil
+	}
+	invalid := func(message string) error {
+		return validationError(validate.Errors{{Field: "parent_id", Message: message, Rule: ruleParent}})
+	}
+	if parentID < 0 {
+		return invalid("must be the ID of an existing todo")
+	}
+	// Parents never form cycles, so the walk up from the new parent ends.
+	for ancestorID := parentID; ancestorID != 0; {
+		if ancestorID == id {
+			return invalid("must not be the todo itself or one of its subtasks")
+		}
+		ancestor, err := s.store.GetByID(ctx, ancestorID)
+		switch {
+		case errors.Is(err, ErrNotFound) && ancestorID == parentID:
+			return invalid("must be the ID of an existing todo")
+		case errors.Is(err, ErrNotFound):
+			return nil
+		case err != nil:
+			return err
+		}
+		ancestorID = ancestor.ParentID
+	}
+	return nil
+}
+
+// completable returns a *SubtaskError if the policy blocks completing
+// todo because subtasks of it are open. The caller must hold s.mu.
+func (s *service) completable(ctx context.Context, todo *Todo) error {
+	if s.subtaskPolicy == SubtaskPolicyCascade {
+		return nil
+	}
+	subtasks, err := s.store.ListSubtasks(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	open := 0
+	for _, subtask := range subtasks {
+		if !subtask.Completed {
+			open++
+		}
+	}
+	if open > 0 {
+		return &SubtaskError{ID: todo.ID, Open: open}
+	}
+	return nil
+}
+
+// completeSubtasks completes the open subtasks of todo, and theirs, under
+// SubtaskPolicyCascade. The caller must hold s.mu.
+func (s *service) completeSubtasks(ctx context.Context, todo *Todo) error {
+	if s.subtaskPolicy != SubtaskPolicyCascade {
+		return nil
+	}
+	subtasks, err := s.store.ListSubtasks(ctx, todo.ID)
+	if err != nil {
+		return err
+	}
+	for _, subtask := range subtasks {
+		if subtask.Completed {
+			continue
+		}
+		if _, err := s.transition(ctx, subtask, TransitionComplete); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachSubtasks removes the parent of the subtasks of the todo with the
+// given ID, which was deleted or merged away, publishing their updates.
+// The caller must hold s.mu.
+func (s *service) detachSubtasks(ctx context.Context, id int) error {
+	subtasks, err := s.store.ListSubtasks(ctx, id)
+	if err != nil {
+		return err
+	}
+	none := 0
+	for _, subtask := range subtasks {
+		// The subtask can only be gone if the store was changed behind
+		// the service's back; there is nothing left to detach then.
+		_, err := s.update(ctx, subtask.ID, TodoInput{Title: subtask.Title, Description: subtask.Description, Tags: subtask.Tags, ParentID: &none})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/efrem/windsurf/internal/validate"
+)
+
+// MaxTagLength is the maximum length of a tag, in characters.
+const MaxTagLength = 50
+
+// TagCount is a tag and the number of todos that carry it.
+type TagCount struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Links TagLinks `json:"_links"`
+}
+
+// TagLinks are the HATEOAS links of a tag.
+type TagLinks struct {
+	Todos *Link `json:"todos,omitempty"`
+}
+
+// TagCollection is the response of GET /tags.
+type TagCollection struct {
+	Tags  []TagCount `json:"tags"`
+	Links Links      `json:"_links"`
+}
+
+// tagFields declares the rules of tags: each is trimmed in place, and must
+// be a non-empty word of at most MaxTagLength characters.
+func tagFields(tags []string) []validate.Field {
+	fields := make([]validate.Field, len(tags))
+	for i := range tags {
+		fields[i] = validate.Field{Name: fmt.Sprintf("tags[%d]", i), Value: &tags[i], Trim: true, Rules: []validate.Rule{
+			validate.Required(), validate.MaxLength(MaxTagLength), validate.NoControlCharacters(""), validate.NoWhiteSpace(),
+		}}
+	}
+	return fields
+}
+
+// normalizeTags returns tags in lower case without duplicates, in the order
+// they were first given. Nil stays nil, so edits can tell tags that were not
+// given from an empty list.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// MergeTags returns the tags of target followed by those of source that
+// target lacks. Stores merging todos use it, so the survivor carries the
+// tags of both.
+func MergeTags(target, source []string) []string {
+	merged := slices.Clone(target)
+	for _, tag := range source {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// MergeDescriptions returns the description of target followed by that of
+// source, separated by a blank line. Stores merging todos use it, so every
+// backend joins descriptions alike.
+func MergeDescriptions(target, source string) string {
+	if source == "" {
+		return target
+	}
+	if target == "" {
+		return source
+	}
+	return target + "\n\n" + source
+}
+
+// filterByTag returns the todos that carry tag, keeping their order. An
+// empty tag keeps every todo.
+func filterByTag(todos []*Todo, tag string) []*Todo {
+	if tag == "" {
+		return todos
+	}
+	var tagged []*Todo
+	for _, todo := range todos {
+		if slices.Contains(todo.Tags, tag) {
+			tagged = append(tagged, todo)
+		}
+	}
+	return tagged
+}
+
+// countTags returns the tags of todos with the number of todos carrying
+// each, ordered by name.
+func countTags(todos []*Todo, baseURL string) []TagCount {
+	counts := map[string]int{}
+	for _, todo := range todos {
+		for _, tag := range todo.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, TagCount{Name: name, Count: count, Links: TagLinks{Todos: buildTagLink(baseURL, name)}})
+	}
+	slices.SortFunc(tags, func(a, b TagCount) int { return strings.Compare(a.Name, b.Name) })
+	return tags
+}
+
+// buildTagLink returns the link to the todos carrying tag.
+func buildTagLink(baseURL, tag string) *Link {
+	return &Link{Href: fmt.Sprintf("%s/todos?tag=%s", baseURL, url.QueryEscape(tag)), Method: "GET"}
+}
+
+// buildTagLinks returns the tag links of a todo, one per tag, named after
+// the tag.
+func buildTagLinks(todo *Todo, baseURL string) []Link {
+	var links []Link
+	for _, tag := range todo.Tags {
+		link := buildTagLink(baseURL, tag)
+		link.Name = tag
+		links = append(links, *link)
+	}
+	return links
+}
+
+// GetTags handles GET /tags and lists the tags of all todos with the number
+// of todos carrying each.
+func (api *TodoAPI) GetTags(w http.ResponseWriter, r *http.Request) {
+	todos, err := api.service.ListTodos(r.Context())
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+	staleResponse(w, r)
+
+	api.respond(w, r, http.StatusOK, TagCollection{
+		Tags: countTags(todos, api.base(r)),
+		Links: Links{
+			Self:  &Link{Href: fmt.Sprintf("%s/tags", api.base(r))},
+			Todos: &Link{Href: fmt.Sprintf("%s/todos", api.base(r)), Method: "GET"},
+		},
+	})
+}
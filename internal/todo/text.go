@@ -0,0 +1,114 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// textContentType is the Content-Type of plain-text responses.
+const textContentType = MediaTypeText + "; charset=utf-8"
+
+// textRenderer is implemented by payloads that have a plain-text
+// representation for Accept: text/plain. Payloads without one are served
+// as JSON.
+type textRenderer interface {
+	renderText(b *strings.Builder)
+}
+
+// renderText returns the plain-text representation of payload.
+func renderText(payload textRenderer) []byte {
+	var b strings.Builder
+	payload.renderText(&b)
+	return []byte(b.String())
+}
+
+// statusMarker labels a todo as open, done or archived in plain-text
+// listings.
+func statusMarker(t Todo) string {
+	switch t.State() {
+	case StateArchived:
+		return "[archived]"
+	case StateCompleted:
+		return "[done]"
+	}
+	return "[open]"
+}
+
+// writeIndented writes text with every line prefixed by indent.
+func writeIndented(b *strings.Builder, indent, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(strings.TrimRight(indent+line, " "))
+		b.WriteByte('\n')
+	}
+}
+
+func (t Todo) renderText(b *strings.Builder) {
+	fmt.Fprintf(b, "%s %s (#%d)\n", statusMarker(t), t.Title, t.ID)
+	if t.Description != "" {
+		b.WriteByte('\n')
+		writeIndented(b, "", t.Description)
+	}
+	b.WriteByte('\n')
+	created := t.CreatedAt.UTC().Format(time.RFC3339)
+	if t.Display != nil {
+		created = fmt.Sprintf("%s (%s)", t.Display.CreatedAt, t.Display.Created)
+	}
+	fmt.Fprintf(b, "Created: %s\n", created)
+	if t.Source != "" {
+		fmt.Fprintf(b, "Imported from: %s %s\n", t.Source, t.ExternalID)
+	}
+}
+
+func (c TodoCollection) renderText(b *strings.Builder) {
+	fmt.Fprintf(b, "Todos: page %d of %d, %d of %d shown\n\n", c.Meta.Page, max(c.Meta.TotalPages, 1), c.Meta.Count, c.Meta.Total)
+	if len(c.Todos) == 0 {
+		b.WriteString("No todos.\n")
+	}
+
+	first := (c.Meta.Page-1)*c.Meta.PerPage + 1
+	for i, t := range c.Todos {
+		fmt.Fprintf(b, "%d. %s %s (#%d)\n", first+i, statusMarker(t), t.Title, t.ID)
+		if t.Description != "" {
+			writeIndented(b, "   ", t.Description)
+		}
+	}
+
+	for _, l := range []struct {
+		label string
+		link  *Link
+	}{
+		{"Previous page", c.Links.Prev},
+		{"Next page", c.Links.Next},
+	} {
+		if l.link != nil {
+			fmt.Fprintf(b, "\n%s: %s", l.label, l.link.Href)
+		}
+	}
+	if c.Links.Prev != nil || c.Links.Next != nil {
+		b.WriteByte('\n')
+	}
+}
+
+func (e ErrorResponse) renderText(b *strings.Builder) {
+	fmt.Fprintf(b, "Error: %s\n%s\n", e.Error, e.Message)
+	for _, fe := range e.Errors {
+		fmt.Fprintf(b, "- %s: %s\n", fe.Field, fe.Message)
+	}
+}
+
+func (root APIRoot) renderText(b *strings.Builder) {
+	fmt.Fprintf(b, "%s\n\n", root.Message)
+	for _, l := range []struct {
+		label string
+		link  *Link
+	}{
+		{"Todos", root.Links.Todos},
+		{"Milestones", root.Links.Milestones},
+		{"Changes", root.Links.Changes},
+	} {
+		if l.link != nil {
+			fmt.Fprintf(b, "%s: %s\n", l.label, l.link.Href)
+		}
+	}
+}
@@ -1,48 +1,121 @@
 package todo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"strconv"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"filippo.io/age"
+	"github.com/efrem/windsurf/internal/events"
+	"github.com/efrem/windsurf/internal/query"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 type Todo struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"created_at"`
-	Links       Links     `json:"_links"`
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+	Archived    bool   `json:"archived"`
+	Status      State  `json:"status,omitempty"`
+	// Version starts at 1 and is incremented by every change to the todo.
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time of the latest change, or CreatedAt if none.
+	UpdatedAt  time.Time `json:"updated_at"`
+	Source     string    `json:"source,omitempty"`
+	ExternalID string    `json:"external_id,omitempty"`
+	// Tags are lower-case words, each at most once, in the order they
+	// were given.
+	Tags []string `json:"tags,omitempty"`
+	// ParentID is the ID of the todo this one is a subtask of, or 0.
+	ParentID int `json:"parent_id,omitempty"`
+	// Subtasks is the progress of the todo's subtasks, if it has any.
+	Subtasks             *SubtaskProgress `json:"subtasks,omitempty"`
+	DescriptionTruncated bool             `json:"description_truncated,omitempty"`
+	Display              *TodoDisplay     `json:"_display,omitempty"`
+	Meta                 *TodoMeta        `json:"_meta,omitempty"`
+	// Warnings lists findings about the todo that did not reject the
+	// request that created or edited it, such as a very long title.
+	Warnings  []FieldError `json:"warnings,omitempty"`
+	Links     Links        `json:"_links"`
+	Templates Templates    `json:"_templates,omitempty"`
+}
+
+// TodoMeta describes how a single todo was served. It is only sent when
+// one of its fields is set.
+type TodoMeta struct {
+	// Stale is set when the store is unavailable and the todo is served
+	// as it was last read from it.
+	Stale bool `json:"stale"`
 }
 
 type TodoInput struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	// Source and ExternalID identify the todo in the system it was
+	// imported from. They are set on creation only and are unique together.
+	Source     string `json:"source,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	// Tags replace the tags of the todo. They are trimmed, lower-cased and
+	// deduplicated. Updates without tags keep the todo's; an empty list
+	// removes them.
+	Tags []string `json:"tags,omitempty"`
+	// ParentID makes the todo a subtask of the todo with this ID, which
+	// must exist and must not be the todo or one of its subtasks. Updates
+	// without it keep the todo's parent; 0 removes it.
+	ParentID *int `json:"parent_id,omitempty"`
+	// Status is the state an imported todo is moved to through the
+	// lifecycle transitions. Only imports honour it; create and update
+	// requests use the transition links instead.
+	Status State `json:"status,omitempty"`
 }
 
 type Links struct {
-	Self     *Link `json:"self,omitempty"`
-	Update   *Link `json:"update,omitempty"`
-	Delete   *Link `json:"delete,omitempty"`
-	Complete *Link `json:"complete,omitempty"`
-	Todos    *Link `json:"todos,omitempty"`
+	Self       *Link `json:"self,omitempty"`
+	Update     *Link `json:"update,omitempty"`
+	Delete     *Link `json:"delete,omitempty"`
+	Complete   *Link `json:"complete,omitempty"`
+	Reopen     *Link `json:"reopen,omitempty"`
+	Archive    *Link `json:"archive,omitempty"`
+	Unarchive  *Link `json:"unarchive,omitempty"`
+	Todos      *Link `json:"todos,omitempty"`
+	Profile    *Link `json:"profile,omitempty"`
+	MergedInto *Link `json:"merged_into,omitempty"`
+	Full       *Link `json:"full,omitempty"`
+	Milestone  *Link `json:"milestone,omitempty"`
+	Parent     *Link `json:"parent,omitempty"`
+	Subtasks   *Link `json:"subtasks,omitempty"`
+	// Tag links to the todos carrying each tag of the todo, named after
+	// the tag.
+	Tag []Link `json:"tag,omitempty"`
 }
 
 type Link struct {
 	Href   string `json:"href"`
 	Method string `json:"method,omitempty"`
+	// Templated marks an Href that is an RFC 6570 URI template.
+	Templated bool `json:"templated,omitempty"`
+	// Name tells links of the same relation apart, such as the tag of a
+	// tag link.
+	Name string `json:"name,omitempty"`
 }
 
 type TodoCollection struct {
-	Todos []Todo          `json:"todos"`
-	Meta  CollectionMeta  `json:"_meta"`
-	Links CollectionLinks `json:"_links"`
+	Todos     []Todo          `json:"todos"`
+	Meta      CollectionMeta  `json:"_meta"`
+	Links     CollectionLinks `json:"_links"`
+	Templates Templates       `json:"_templates,omitempty"`
 }
 
 type CollectionMeta struct {
@@ -51,15 +124,43 @@ type CollectionMeta struct {
 	Page       int `json:"page"`
 	PerPage    int `json:"per_page"`
 	TotalPages int `json:"total_pages"`
+	// Stale is set when the store is unavailable and the collection is
+	// served from the todos last read from it.
+	Stale bool `json:"stale,omitempty"`
+	// Aggregates is only sent when requested with ?aggregates=true.
+	Aggregates *CollectionAggregates `json:"aggregates,omitempty"`
+}
+
+// CollectionAggregates summarizes the whole collection, not only the page.
+type CollectionAggregates struct {
+	ByStatus map[State]int `json:"by_status"`
+}
+
+// aggregateTodos computes the aggregates of todos. Every status is listed,
+// also when no todo has it.
+func aggregateTodos(todos []*Todo) *CollectionAggregates {
+	byStatus := map[State]int{StateOpen: 0, StateCompleted: 0, StateArchived: 0}
+	for _, todo := range todos {
+		byStatus[todo.State()]++
+	}
+	return &CollectionAggregates{ByStatus: byStatus}
 }
 
 type CollectionLinks struct {
-	Self   *Link `json:"self,omitempty"`
-	First  *Link `json:"first,omitempty"`
-	Last   *Link `json:"last,omitempty"`
-	Next   *Link `json:"next,omitempty"`
-	Prev   *Link `json:"prev,omitempty"`
-	Create *Link `json:"create,omitempty"`
+	Self          *Link   `json:"self,omitempty"`
+	First         *Link   `json:"first,omitempty"`
+	Last          *Link   `json:"last,omitempty"`
+	Next          *Link   `json:"next,omitempty"`
+	Prev          *Link   `json:"prev,omitempty"`
+	Create        *Link   `json:"create,omitempty"`
+	Import        *Link   `json:"import,omitempty"`
+	Profile       *Link   `json:"profile,omitempty"`
+	Snapshot      *Link   `json:"snapshot,omitempty"`
+	Find          *Link   `json:"todo:find,omitempty"`
+	SortByTitle   *Link   `json:"sort-by-title,omitempty"`
+	SortByCreated *Link   `json:"sort-by-created,omitempty"`
+	SortByUpdated *Link   `json:"sort-by-updated,omitempty"`
+	Curies        []Curie `json:"curies,omitempty"`
 }
 
 type APIRoot struct {
@@ -68,115 +169,303 @@ type APIRoot struct {
 }
 
 type APIRootLinks struct {
-	Self  *Link `json:"self"`
-	Todos *Link `json:"todos"`
+	Self       *Link   `json:"self"`
+	Todos      *Link   `json:"todos"`
+	Milestones *Link   `json:"milestones,omitempty"`
+	Tags       *Link   `json:"tags,omitempty"`
+	Changes    *Link   `json:"changes,omitempty"`
+	Profile    *Link   `json:"profile,omitempty"`
+	Find       *Link   `json:"todo:find,omitempty"`
+	Page       *Link   `json:"todo:page,omitempty"`
+	Curies     []Curie `json:"curies,omitempty"`
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Links   Links        `json:"_links"`
+}
+
+// FieldError describes a single invalid field or parameter of a request.
+type FieldError struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
-	Links   Links  `json:"_links"`
+	// Rule names the rule a body field violates, such as max_length.
+	Rule string `json:"rule,omitempty"`
 }
 
 type TodoStore struct {
-	todos  map[int]*Todo
-	nextID int
-	mu     sync.RWMutex
+	todos    map[int]*Todo
+	merged   map[int]int
+	external map[externalKey]int
+	nextID   int
+	clock    Clock
+	mu       sync.RWMutex
+
+	// seq is incremented on every mutation; createdSeq and tombstones
+	// record when todos appeared and disappeared so listings can be
+	// pinned to a snapshot (see ListAt).
+	seq        int
+	createdSeq map[int]int
+	tombstones []tombstone
+	horizon    int
+
+	// milestones are keyed by ID; assigned maps the ID of each todo in a
+	// milestone to the milestone's.
+	milestones      map[int]Milestone
+	assigned        map[int]int
+	nextMilestoneID int
 }
 
 func NewTodoStore() *TodoStore {
+	return NewTodoStoreWithClock(SystemClock{})
+}
+
+// NewTodoStoreWithClock constructs an empty store that reads timestamps
+// from the given clock.
+func NewTodoStoreWithClock(clock Clock) *TodoStore {
 	return &TodoStore{
-		todos:  make(map[int]*Todo),
-		nextID: 1,
+		todos:      make(map[int]*Todo),
+		merged:     make(map[int]int),
+		external:   make(map[externalKey]int),
+		nextID:     1,
+		clock:      clock,
+		createdSeq: make(map[int]int),
+
+		milestones:      make(map[int]Milestone),
+		assigned:        make(map[int]int),
+		nextMilestoneID: 1,
 	}
 }
 
-// GetAll returns all todos currently stored in memory.
-func (s *TodoStore) GetAll() []*Todo {
+// GetAll returns all todos currently stored in memory, ordered by ID.
+func (s *TodoStore) GetAll(ctx context.Context) ([]*Todo, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	todos := make([]*Todo, 0, len(s.todos))
+	return s.sortedTodos(), nil
+}
+
+// ListSubtasks returns the todos whose parent is parentID, ordered by ID.
+func (s *TodoStore) ListSubtasks(ctx context.Context, parentID int) ([]*Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subtasks := []*Todo{}
 	for _, todo := range s.todos {
-		todos = append(todos, todo)
+		if todo.ParentID == parentID {
+			subtasks = append(subtasks, todo)
+		}
 	}
-	return todos
+	slices.SortFunc(subtasks, func(a, b *Todo) int { return a.ID - b.ID })
+	return subtasks, nil
 }
 
-// GetByID returns a todo by its ID.
-// The boolean indicates whether a todo with that ID exists.
-func (s *TodoStore) GetByID(id int) (*Todo, bool) {
+// GetByID returns a todo by its ID, or ErrNotFound if no todo with that
+// ID exists.
+func (s *TodoStore) GetByID(ctx context.Context, id int) (*Todo, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	todo, exists := s.todos[id]
-	return todo, exists
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return todo, nil
 }
 
 // Create adds a new todo to the store using the provided input.
-func (s *TodoStore) Create(input TodoInput) *Todo {
+func (s *TodoStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.clock.Now()
 	todo := &Todo{
 		ID:          s.nextID,
 		Title:       input.Title,
 		Description: input.Description,
 		Completed:   false,
-		CreatedAt:   time.Now(),
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
 	}
 
+	s.seq++
 	s.todos[s.nextID] = todo
+	s.createdSeq[s.nextID] = s.seq
+	if key, ok := externalKeyOf(todo); ok {
+		s.external[key] = todo.ID
+	}
 	s.nextID++
 
-	return todo
+	return todo, nil
+}
+
+// peekNextID returns the ID the next todo created will get.
+func (s *TodoStore) peekNextID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.nextID
 }
 
 // Update modifies an existing todo identified by id.
-// The boolean indicates whether the todo was found.
-func (s *TodoStore) Update(id int, input TodoInput) (*Todo, bool) {
+// It returns ErrNotFound if the todo does not exist.
+func (s *TodoStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	todo, exists := s.todos[id]
 	if !exists {
-		return nil, false
+		return nil, ErrNotFound
 	}
 
 	todo.Title = input.Title
 	todo.Description = input.Description
+	todo.Tags = input.Tags
+	todo.ParentID = input.Parent()
+	s.touch(todo)
 
-	return todo, true
+	return todo, nil
 }
 
 // Complete marks the todo with the given ID as completed.
-// The boolean indicates whether the todo was found.
-func (s *TodoStore) Complete(id int) (*Todo, bool) {
+// It returns ErrNotFound if the todo does not exist.
+func (s *TodoStore) Complete(ctx context.Context, id int) (*Todo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	todo, exists := s.todos[id]
 	if !exists {
-		return nil, false
+		return nil, ErrNotFound
 	}
 
 	todo.Completed = true
-	return todo, true
+	s.touch(todo)
+	return todo, nil
+}
+
+// SetState moves the todo with the given ID to state.
+// It returns ErrNotFound if the todo does not exist.
+func (s *TodoStore) SetState(ctx context.Context, id int, state State) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, exists := s.todos[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	todo.Completed = state != StateOpen
+	todo.Archived = state == StateArchived
+	s.touch(todo)
+	return todo, nil
+}
+
+// touch records a change to todo: it bumps its version and update time and
+// the sequence of the store. The caller must hold the write lock.
+func (s *TodoStore) touch(todo *Todo) {
+	todo.Version++
+	todo.UpdatedAt = s.clock.Now()
+	s.seq++
+}
+
+// setUpdatedAt sets the update time of the todo with the given ID, if it
+// exists.
+func (s *TodoStore) setUpdatedAt(id int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if todo, ok := s.todos[id]; ok {
+		todo.UpdatedAt = at
+	}
 }
 
 // Delete removes the todo with the given ID from the store.
-// It returns true if a todo was deleted, or false if it did not exist.
-func (s *TodoStore) Delete(id int) bool {
+// It returns ErrNotFound if the todo does not exist.
+func (s *TodoStore) Delete(ctx context.Context, id int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	_, exists := s.todos[id]
 	if !exists {
-		return false
+		return ErrNotFound
+	}
+
+	s.seq++
+	s.bury(id)
+	return nil
+}
+
+// Merge folds the todo identified by sourceID into the todo identified by
+// targetID. The source description is appended to the target's, the target
+// gains the source's tags, the source is removed, and its ID is remembered
+// as an alias of the target.
+// It returns ErrNotFound if either todo does not exist and
+// ErrMergeIntoItself if they are the same todo.
+func (s *TodoStore) Merge(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if targetID == sourceID {
+		return nil, ErrMergeIntoItself
 	}
+	target, targetExists := s.todos[targetID]
+	source, sourceExists := s.todos[sourceID]
+	if !targetExists || !sourceExists {
+		return nil, ErrNotFound
+	}
+
+	target.Description = MergeDescriptions(target.Description, source.Description)
+	target.Tags = MergeTags(target.Tags, source.Tags)
+	s.touch(target)
+	s.bury(sourceID)
+	s.merged[sourceID] = targetID
 
-	delete(s.todos, id)
-	return true
+	return target, nil
+}
+
+// FindByExternalID returns the todo imported from source with the given
+// external ID, or ErrNotFound if there is none.
+func (s *TodoStore) FindByExternalID(ctx context.Context, source, externalID string) (*Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.external[externalKey{Source: source, ExternalID: externalID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.todos[id], nil
+}
+
+// MergedInto returns the ID of the todo that the given ID was merged into,
+// following chains of merges. It returns ErrNotFound if the ID was never
+// merged or its survivor no longer exists.
+func (s *TodoStore) MergedInto(ctx context.Context, id int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	survivor, merged := s.merged[id]
+	if !merged {
+		return 0, ErrNotFound
+	}
+	for {
+		next, ok := s.merged[survivor]
+		if !ok {
+			break
+		}
+		survivor = next
+	}
+
+	if _, exists := s.todos[survivor]; !exists {
+		return 0, ErrNotFound
+	}
+	return survivor, nil
 }
 
 // buildTodoLinks constructs the HATEOAS links for a single todo resource.
@@ -198,56 +487,108 @@ func buildTodoLinks(todo *Todo, baseURL string) Links {
 			Href:   fmt.Sprintf("%s/todos", baseURL),
 			Method: "GET",
 		},
+		Profile: buildProfileLink(baseURL, profileTodo),
+		Subtasks: &Link{
+			Href:   fmt.Sprintf("%s/todos/%d/subtasks", baseURL, todo.ID),
+			Method: "GET",
+		},
+		Tag: buildTagLinks(todo, baseURL),
 	}
-
-	if !todo.Completed {
-		links.Complete = &Link{
-			Href:   fmt.Sprintf("%s/todos/%d/complete", baseURL, todo.ID),
-			Method: "PATCH",
+	if todo.ParentID != 0 {
+		links.Parent = &Link{
+			Href:   fmt.Sprintf("%s/todos/%d", baseURL, todo.ParentID),
+			Method: "GET",
 		}
 	}
 
+	for _, transition := range AllowedTransitions(todo.State()) {
+		*links.transition(transition) = buildTransitionLink(todo.ID, transition, baseURL)
+	}
+
 	return links
 }
 
-// buildCollectionLinks constructs HATEOAS links for a paginated todos collection.
-func buildCollectionLinks(baseURL string, page, perPage, total int) CollectionLinks {
+// transition returns the field holding the link of the lifecycle transition.
+func (l *Links) transition(transition Transition) **Link {
+	switch transition {
+	case TransitionReopen:
+		return &l.Reopen
+	case TransitionArchive:
+		return &l.Archive
+	case TransitionUnarchive:
+		return &l.Unarchive
+	}
+	return &l.Complete
+}
+
+// buildTransitionLink returns the link that applies transition to the todo.
+func buildTransitionLink(id int, transition Transition, baseURL string) *Link {
+	return &Link{
+		Href:   fmt.Sprintf("%s/todos/%d/%s", baseURL, id, transition),
+		Method: "PATCH",
+	}
+}
+
+// buildCollectionLinks constructs HATEOAS links for a paginated todos
+// collection. The navigation links keep the sort preset, unless it is the
+// default order by ID, and the tag filter, if any.
+func buildCollectionLinks(baseURL string, page, perPage, total int, sort, tag string) CollectionLinks {
 	totalPages := 1
 	if total > 0 {
 		totalPages = (total + perPage - 1) / perPage
 	}
+	pageHref := func(page int) string {
+		href := fmt.Sprintf("%s/todos?page=%d&per_page=%d", baseURL, page, perPage)
+		if sort != "" && sort != "id" {
+			href += "&sort=" + sort
+		}
+		if tag != "" {
+			href += "&tag=" + url.QueryEscape(tag)
+		}
+		return href
+	}
 
 	links := CollectionLinks{
 		Self: &Link{
-			Href: fmt.Sprintf("%s/todos?page=%d&per_page=%d", baseURL, page, perPage),
+			Href: pageHref(page),
 		},
 		First: &Link{
-			Href: fmt.Sprintf("%s/todos?page=1&per_page=%d", baseURL, perPage),
+			Href: pageHref(1),
 		},
 		Create: &Link{
 			Href:   fmt.Sprintf("%s/todos", baseURL),
 			Method: "POST",
 		},
-		Last: nil,
-		Next: nil,
-		Prev: nil,
+		Import: &Link{
+			Href:   fmt.Sprintf("%s/todos/import", baseURL),
+			Method: "POST",
+		},
+		Profile:       buildProfileLink(baseURL, profileCollection),
+		Find:          buildFindLink(baseURL),
+		SortByTitle:   buildSortLink(baseURL, "title"),
+		SortByCreated: buildSortLink(baseURL, "created"),
+		SortByUpdated: buildSortLink(baseURL, "updated"),
+		Curies:        buildCuries(baseURL),
+		Last:          nil,
+		Next:          nil,
+		Prev:          nil,
 	}
 
 	if totalPages > 1 {
 		links.Last = &Link{
-			Href: fmt.Sprintf("%s/todos?page=%d&per_page=%d", baseURL, totalPages, perPage),
+			Href: pageHref(totalPages),
 		}
 	}
 
 	if page < totalPages {
 		links.Next = &Link{
-			Href: fmt.Sprintf("%s/todos?page=%d&per_page=%d", baseURL, page+1, perPage),
+			Href: pageHref(page + 1),
 		}
 	}
 
 	if page > 1 {
 		links.Prev = &Link{
-			Href: fmt.Sprintf("%s/todos?page=%d&per_page=%d", baseURL, page-1, perPage),
+			Href: pageHref(page - 1),
 		}
 	}
 
@@ -267,15 +608,75 @@ func buildErrorLinks(baseURL string) Links {
 // TodoAPI provides HTTP handlers for the Todo REST API.
 type TodoAPI struct {
 	service Service
+	changes *changeLog
+	events  events.Subscriber
+	imports *importUploads
+	jobs    *importJobs
+	clock   Clock
 	baseURL string
+
+	backupRecipients []age.Recipient
+	backupIdentities []age.Identity
+
+	// compactor is the store behind the service if it supports
+	// compaction, which bypasses the service.
+	compactor   CompactStore
+	compactions *compactions
 }
 
 // NewTodoAPI constructs a new TodoAPI using the provided base URL and Service facade.
 func NewTodoAPI(baseURL string, service Service) *TodoAPI {
 	return &TodoAPI{
-		service: service,
-		baseURL: baseURL,
+		service:     service,
+		changes:     newChangeLog(changeLogSize),
+		events:      events.NewBus(),
+		imports:     newImportUploads(),
+		jobs:        newImportJobs(),
+		compactions: newCompactions(),
+		clock:       SystemClock{},
+		baseURL:     baseURL,
+	}
+}
+
+// present returns a copy of todo decorated with its HATEOAS links,
+// HAL-FORMS templates and the progress of its subtasks, ready to be
+// rendered. The progress is left out if the subtasks cannot be listed, and
+// the milestone link if the milestones cannot be.
+func (api *TodoAPI) present(r *http.Request, todo *Todo) Todo {
+	var progress subtaskIndex
+	if subtasks, err := api.service.ListSubtasks(r.Context(), todo.ID); err == nil {
+		progress = indexSubtasks(subtasks)
 	}
+	return api.presentWith(r, todo, progress, api.indexMilestones(r))
+}
+
+// presentWith is present taking the progress of subtasks from subtasks and
+// the milestone of the todo from milestones, so listings read them once for
+// all todos. A nil index leaves the progress or the link out.
+func (api *TodoAPI) presentWith(r *http.Request, todo *Todo, subtasks subtaskIndex, milestones milestoneIndex) Todo {
+	representation := *todo
+	if progress, ok := subtasks[todo.ID]; ok {
+		representation.Subtasks = &progress
+	}
+	representation.Status = todo.State()
+	representation.Links = buildTodoLinks(todo, api.base(r))
+	representation.Templates = buildTodoTemplates(todo, api.base(r), api.service.Limits())
+	representation.Display = api.displayTodo(r, todo)
+	if milestoneID, ok := milestones[todo.ID]; ok {
+		representation.Links.Milestone = &Link{
+			Href:   fmt.Sprintf("%s/milestones/%d", api.base(r), milestoneID),
+			Method: "GET",
+		}
+	}
+	return representation
+}
+
+// presentEdited is present for the response to a request that created or
+// edited todo, carrying the warnings about it.
+func (api *TodoAPI) presentEdited(r *http.Request, todo *Todo) Todo {
+	representation := api.present(r, todo)
+	representation.Warnings = todoWarnings(todo, api.service.Limits())
+	return representation
 }
 
 // GetRoot handles GET / and returns the API root document with navigation links.
@@ -284,41 +685,72 @@ func (api *TodoAPI) GetRoot(w http.ResponseWriter, r *http.Request) {
 		Message: "Welcome to the HATEOAS Todo API",
 		Links: APIRootLinks{
 			Self: &Link{
-				Href: api.baseURL,
+				Href: api.base(r),
 			},
 			Todos: &Link{
-				Href:   fmt.Sprintf("%s/todos", api.baseURL),
+				Href:   fmt.Sprintf("%s/todos", api.base(r)),
+				Method: "GET",
+			},
+			Milestones: &Link{
+				Href:   fmt.Sprintf("%s/milestones", api.base(r)),
+				Method: "GET",
+			},
+			Tags: &Link{
+				Href:   fmt.Sprintf("%s/tags", api.base(r)),
+				Method: "GET",
+			},
+			Changes: &Link{
+				Href:   fmt.Sprintf("%s/changes", api.base(r)),
 				Method: "GET",
 			},
+			Profile: buildProfileLink(api.base(r), profileRoot),
+			Find:    buildFindLink(api.base(r)),
+			Page:    buildPageLink(api.base(r)),
+			Curies:  buildCuries(api.base(r)),
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(root)
+	api.respond(w, r, http.StatusOK, root)
 }
 
-// GetTodos handles GET /todos and returns a paginated list of todos.
+// GetTodos handles GET and HEAD /todos and returns a paginated list of todos.
 func (api *TodoAPI) GetTodos(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	perPageStr := r.URL.Query().Get("per_page")
-
-	page := 1
-	perPage := 10
-
-	if pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	params := query.New(r.URL.Query())
+	page := params.Int("page", 1, 1, math.MaxInt32)
+	perPage := params.Int("per_page", 10, 1, 100)
+	cursor := params.String("cursor", "")
+	aggregates := params.Bool("aggregates", false)
+	sort := params.Enum("sort", "id", sortNames()...)
+	tag := strings.ToLower(strings.TrimSpace(params.String("tag", "")))
+	if err := params.Err(); err != nil {
+		api.sendQueryError(w, r, err)
+		return
 	}
 
-	if perPageStr != "" {
-		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 && pp <= 100 {
-			perPage = pp
+	if cursor != "" {
+		if sort != "id" {
+			api.sendQueryError(w, r, query.Errors{{Param: "sort", Message: "cannot be combined with cursor; snapshots are listed by ID"}})
+			return
 		}
+		if tag != "" {
+			api.sendQueryError(w, r, query.Errors{{Param: "tag", Message: "cannot be combined with cursor; snapshots list every todo"}})
+			return
+		}
+		api.getTodosAtCursor(w, r, cursor, perPage, aggregates)
+		return
 	}
 
-	allTodos := api.service.ListTodos()
+	allTodos, snapshot, pinned, err := api.service.SnapshotTodos(r.Context())
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+	subtasks := indexSubtasks(allTodos)
+	milestones := api.indexMilestones(r)
+	allTodos = filterByTag(allTodos, tag)
+	stale := staleResponse(w, r)
 	total := len(allTodos)
+	sorted := sortTodos(allTodos, sort)
 
 	start := (page - 1) * perPage
 	end := start + perPage
@@ -333,8 +765,8 @@ func (api *TodoAPI) GetTodos(w http.ResponseWriter, r *http.Request) {
 	var paginatedTodos []Todo
 	if start < total {
 		for i := start; i < end; i++ {
-			todo := *allTodos[i]
-			todo.Links = buildTodoLinks(&todo, api.baseURL)
+			todo := api.presentWith(r, sorted[i], subtasks, milestones)
+			truncateForListing(&todo, api.base(r))
 			paginatedTodos = append(paginatedTodos, todo)
 		}
 	}
@@ -352,184 +784,507 @@ func (api *TodoAPI) GetTodos(w http.ResponseWriter, r *http.Request) {
 			Page:       page,
 			PerPage:    perPage,
 			TotalPages: totalPages,
+			Stale:      stale,
 		},
-		Links: buildCollectionLinks(api.baseURL, page, perPage, total),
+		Links:     buildCollectionLinks(api.base(r), page, perPage, total, sort, tag),
+		Templates: buildCollectionTemplates(api.base(r), api.service.Limits()),
+	}
+	// Snapshots are listed by ID and unfiltered, so sorted and filtered
+	// pages do not offer one, and stale collections are not pinned to any.
+	if pinned && sort == "id" && tag == "" && !stale {
+		collection.Links.Snapshot = buildCursorLink(api.base(r), listCursor{snapshot: snapshot}, perPage)
+	}
+	if aggregates {
+		collection.Meta.Aggregates = aggregateTodos(allTodos)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
+	modifiedAt := api.collectionModifiedAt(allTodos)
+	if modifiedSince(r, modifiedAt) {
+		api.sendError(w, r, http.StatusPreconditionFailed, "Precondition failed", "The collection has changed since the time in If-Unmodified-Since")
+		return
+	}
+	setLastModified(w, modifiedAt)
+	api.respond(w, r, http.StatusOK, collection)
 }
 
-// GetTodo handles GET /todos/{id} and returns a single todo by ID.
+// GetTodo handles GET and HEAD /todos/{id} and returns a single todo by ID.
 func (api *TodoAPI) GetTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+	id := todoIDFromContext(r.Context())
+
+	todo, err := api.service.GetTodo(r.Context(), id)
 	if err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid todo ID", "The provided ID must be a valid integer")
+		if survivor, err := api.service.MergedInto(r.Context(), id); err == nil {
+			api.redirectMerged(w, r, id, survivor)
+			return
+		}
+		api.sendTodoError(w, r, id, err)
 		return
 	}
 
-	todo, exists := api.service.GetTodo(id)
-	if !exists {
-		api.sendError(w, http.StatusNotFound, "Todo not found", fmt.Sprintf("Todo with ID %d does not exist", id))
+	modifiedAt := api.todoModifiedAt(todo)
+	if modifiedSince(r, modifiedAt) {
+		api.sendTodoError(w, r, id, ErrPreconditionFailed)
 		return
 	}
-
-	todoResponse := *todo
-	todoResponse.Links = buildTodoLinks(todo, api.baseURL)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todoResponse)
+	setLastModified(w, modifiedAt)
+	w.Header().Set("Accept-Patch", MediaTypeJSONPatch)
+	presented := api.present(r, todo)
+	if staleResponse(w, r) {
+		presented.Meta = &TodoMeta{Stale: true}
+	}
+	api.respond(w, r, http.StatusOK, presented)
 }
 
 // CreateTodo handles POST /todos and creates a new todo from the request body.
 func (api *TodoAPI) CreateTodo(w http.ResponseWriter, r *http.Request) {
+	if !api.requireJSON(w, r) {
+		return
+	}
 	var input TodoInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
 		return
 	}
 
-	if input.Title == "" {
-		api.sendError(w, http.StatusBadRequest, "Validation error", "Title is required")
+	if err := ValidateInput(&input, api.service.Limits()); err != nil {
+		api.sendTodoError(w, r, 0, err)
 		return
 	}
 
-	todo := api.service.CreateTodo(input)
-	todo.Links = buildTodoLinks(todo, api.baseURL)
+	todo, outcome, err := api.service.UpsertTodo(r.Context(), input, ConflictSkip)
+	if err != nil {
+		api.sendTodoError(w, r, 0, err)
+		return
+	}
+	if outcome == UpsertSkipped {
+		api.sendError(w, r, http.StatusConflict, "Duplicate external ID",
+			fmt.Sprintf("Todo %d was already imported from %s with external_id %s", todo.ID, input.Source, input.ExternalID))
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Location", fmt.Sprintf("%s/todos/%d", api.baseURL, todo.ID))
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(todo)
+	w.Header().Set("Location", fmt.Sprintf("%s/todos/%d", api.base(r), todo.ID))
+	api.respond(w, r, http.StatusCreated, api.presentEdited(r, todo))
 }
 
 // UpdateTodo handles PUT /todos/{id} and updates an existing todo.
 func (api *TodoAPI) UpdateTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid todo ID", "The provided ID must be a valid integer")
+	id := todoIDFromContext(r.Context())
+
+	if !api.requireJSON(w, r) {
 		return
 	}
-
 	var input TodoInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
+		api.sendError(w, r, http.StatusBadRequest, "Invalid JSON", "Request body must be valid JSON")
 		return
 	}
 
-	if input.Title == "" {
-		api.sendError(w, http.StatusBadRequest, "Validation error", "Title is required")
+	todo, err := api.service.UpdateTodoIf(r.Context(), id, input, api.precondition(r))
+	if err != nil {
+		api.sendTodoError(w, r, id, err)
 		return
 	}
 
-	todo, exists := api.service.UpdateTodo(id, input)
-	if !exists {
-		api.sendError(w, http.StatusNotFound, "Todo not found", fmt.Sprintf("Todo with ID %d does not exist", id))
-		return
-	}
+	api.respond(w, r, http.StatusOK, api.presentEdited(r, todo))
+}
 
-	todo.Links = buildTodoLinks(todo, api.baseURL)
+// TransitionTodo returns the handler of PATCH /todos/{id}/{transition},
+// which moves a todo through the lifecycle transition. Transitions that are
+// not allowed from the todo's current state are rejected with 409 Conflict
+// and links to the transitions that are.
+func (api *TodoAPI) TransitionTodo(transition Transition) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := todoIDFromContext(r.Context())
+
+		todo, err := api.service.TransitionTodoIf(r.Context(), id, transition, api.precondition(r))
+		if err != nil {
+			api.sendTodoError(w, r, id, err)
+			return
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todo)
+		api.respond(w, r, http.StatusOK, api.present(r, todo))
+	}
 }
 
-// CompleteTodo handles PATCH /todos/{id}/complete and marks a todo as completed.
-func (api *TodoAPI) CompleteTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid todo ID", "The provided ID must be a valid integer")
-		return
+// describeTodoError returns the status code, title and message of the
+// response to err, returned by the service for the todo with the given ID.
+// It is the one place service errors are mapped to status codes; errors
+// the service does not document are internal server errors.
+func describeTodoError(id int, err error) (int, string, string) {
+	var transitionErr *TransitionError
+	var subtaskErr *SubtaskError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Deadline exceeded", "The request could not be served before the deadline it set"
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, "Todo not found", fmt.Sprintf("Todo with ID %d does not exist", id)
+	case errors.Is(err, ErrTooLong):
+		return http.StatusUnprocessableEntity, "Validation error", err.Error()
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest, "Validation error", err.Error()
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, "Precondition failed",
+			fmt.Sprintf("Todo %d has changed since it was fetched; get it again and retry with its new ETag or Last-Modified time", id)
+	case errors.As(err, &transitionErr):
+		return http.StatusConflict, "Invalid state transition",
+			fmt.Sprintf("Cannot %s a todo that is %s", transitionErr.Transition, transitionErr.From)
+	case errors.As(err, &subtaskErr):
+		return http.StatusConflict, "Open subtasks",
+			fmt.Sprintf("Todo %d has %d open subtasks; complete them first", subtaskErr.ID, subtaskErr.Open)
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, "Conflict", err.Error()
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable, "Service unavailable", "The todo store is unavailable; try again later"
 	}
+	return http.StatusInternalServerError, "Internal server error", "The request could not be completed"
+}
 
-	todo, exists := api.service.CompleteTodo(id)
-	if !exists {
-		api.sendError(w, http.StatusNotFound, "Todo not found", fmt.Sprintf("Todo with ID %d does not exist", id))
-		return
+// storeRetryAfter is the Retry-After header, in seconds, of responses to
+// requests that failed because the store is unavailable.
+const storeRetryAfter = "5"
+
+// sendTodoError writes the error response for err, returned by the service
+// for the todo with the given ID. Precondition failures link the todo, so
+// it can be fetched again, invalid transitions also link the transitions
+// allowed from its state, and completions blocked by open subtasks link
+// the subtasks.
+func (api *TodoAPI) sendTodoError(w http.ResponseWriter, r *http.Request, id int, err error) {
+	status, title, message := describeTodoError(id, err)
+	links := buildErrorLinks(api.base(r))
+	var transitionErr *TransitionError
+	var subtaskErr *SubtaskError
+	if errors.Is(err, ErrPreconditionFailed) || errors.As(err, &transitionErr) || errors.As(err, &subtaskErr) {
+		links.Self = &Link{Href: fmt.Sprintf("%s/todos/%d", api.base(r), id), Method: "GET"}
 	}
-
-	todo.Links = buildTodoLinks(todo, api.baseURL)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todo)
+	if subtaskErr != nil {
+		links.Subtasks = &Link{Href: fmt.Sprintf("%s/todos/%d/subtasks", api.base(r), id), Method: "GET"}
+	}
+	if transitionErr != nil {
+		for _, transition := range transitionErr.Allowed {
+			*links.transition(transition) = buildTransitionLink(id, transition, api.base(r))
+		}
+	}
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", storeRetryAfter)
+	}
+	response := ErrorResponse{Error: title, Message: message, Links: links}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		response.Errors = validationErr.Fields
+	}
+	api.respond(w, r, status, response)
 }
 
 // DeleteTodo handles DELETE /todos/{id} and removes the todo.
 func (api *TodoAPI) DeleteTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		api.sendError(w, http.StatusBadRequest, "Invalid todo ID", "The provided ID must be a valid integer")
-		return
-	}
+	id := todoIDFromContext(r.Context())
 
-	exists := api.service.DeleteTodo(id)
-	if !exists {
-		api.sendError(w, http.StatusNotFound, "Todo not found", fmt.Sprintf("Todo with ID %d does not exist", id))
+	if err := api.service.DeleteTodoIf(r.Context(), id, api.precondition(r)); err != nil {
+		api.sendTodoError(w, r, id, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	api.respond(w, r, http.StatusNoContent, nil)
 }
 
 // sendError writes a JSON error response with the given status code and message.
-func (api *TodoAPI) sendError(w http.ResponseWriter, statusCode int, error, message string) {
+func (api *TodoAPI) sendError(w http.ResponseWriter, r *http.Request, statusCode int, error, message string) {
 	errorResponse := ErrorResponse{
 		Error:   error,
 		Message: message,
-		Links:   buildErrorLinks(api.baseURL),
+		Links:   buildErrorLinks(api.base(r)),
+	}
+
+	api.respond(w, r, statusCode, errorResponse)
+}
+
+// sendQueryError writes a 400 response listing every invalid query parameter
+// reported by a query.Binder.
+func (api *TodoAPI) sendQueryError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors []FieldError
+	var queryErrors query.Errors
+	if errors.As(err, &queryErrors) {
+		for _, qe := range queryErrors {
+			fieldErrors = append(fieldErrors, FieldError{Field: qe.Param, Message: qe.Message})
+		}
+	}
+
+	api.respond(w, r, http.StatusBadRequest, ErrorResponse{
+		Error:   "Invalid query parameters",
+		Message: "One or more query parameters are invalid",
+		Errors:  fieldErrors,
+		Links:   buildErrorLinks(api.base(r)),
+	})
+}
+
+// RouterOption configures optional behavior of NewRouter.
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	seed           func(Service)
+	clock          Clock
+	store          Store
+	trustForwarded bool
+	adminToken     string
+	adminListener  func(http.Handler)
+	publisher      events.Publisher
+	serviceHooks   []func(Service)
+	limits         Limits
+	subtaskPolicy  SubtaskPolicy
+
+	slowThreshold time.Duration
+	slowLogger    *log.Logger
+
+	backupRecipients []age.Recipient
+	backupIdentities []age.Identity
+}
+
+// WithSeeder replaces the built-in sample data with the given seed function,
+// which is called once with the Service before the router is returned.
+func WithSeeder(seed func(Service)) RouterOption {
+	return func(c *routerConfig) {
+		c.seed = seed
+	}
+}
+
+// WithClock sets the clock used by the store for timestamps.
+func WithClock(clock Clock) RouterOption {
+	return func(c *routerConfig) {
+		c.clock = clock
+	}
+}
+
+// WithStore sets the store backing the router's Service. By default a new
+// in-memory TodoStore is used.
+func WithStore(store Store) RouterOption {
+	return func(c *routerConfig) {
+		c.store = store
+	}
+}
+
+// WithSlowStoreLog logs every store operation that takes longer than
+// threshold to logger, or to the standard logger if it is nil, with its
+// parameters and the number of todos involved.
+func WithSlowStoreLog(threshold time.Duration, logger *log.Logger) RouterOption {
+	return func(c *routerConfig) {
+		c.slowThreshold = threshold
+		c.slowLogger = logger
+	}
+}
+
+// WithForwardedBaseURL derives the base URL of links from each request's
+// X-Forwarded-* headers and Host instead of the fixed baseURL. Only enable it
+// behind a reverse proxy that sets these headers.
+func WithForwardedBaseURL() RouterOption {
+	return func(c *routerConfig) {
+		c.trustForwarded = true
+	}
+}
+
+// WithAdminToken enables the /admin endpoints, which require the given
+// token as a bearer token. Without it the endpoints are not mounted.
+func WithAdminToken(token string) RouterOption {
+	return func(c *routerConfig) {
+		c.adminToken = token
+	}
+}
+
+// WithAdminListener moves the /admin endpoints off the public router onto
+// a separate handler, which is passed to serve so it can be bound to its own
+// (typically loopback) address. The handler also serves the runtime
+// profiler under /debug/pprof/, behind the same token. It has no effect
+// without WithAdminToken.
+func WithAdminListener(serve func(http.Handler)) RouterOption {
+	return func(c *routerConfig) {
+		c.adminListener = serve
+	}
+}
+
+// WithBackupEncryption encrypts GET /admin/backup dumps to recipients and
+// makes POST /admin/restore accept only dumps encrypted to one of
+// identities. Either list may be empty to configure only one direction.
+func WithBackupEncryption(recipients []age.Recipient, identities []age.Identity) RouterOption {
+	return func(c *routerConfig) {
+		c.backupRecipients = recipients
+		c.backupIdentities = identities
+	}
+}
+
+// WithPublisher publishes a domain event for every change made through the
+// router's Service, including seeding.
+func WithPublisher(publisher events.Publisher) RouterOption {
+	return func(c *routerConfig) {
+		c.publisher = publisher
+	}
+}
+
+// WithLimits holds the title and description of todos to limits instead of
+// DefaultLimits. A zero field keeps its default.
+func WithLimits(limits Limits) RouterOption {
+	return func(c *routerConfig) {
+		if limits.Title > 0 {
+			c.limits.Title = limits.Title
+		}
+		if limits.Description > 0 {
+			c.limits.Description = limits.Description
+		}
+		if limits.LongTitle > 0 {
+			c.limits.LongTitle = limits.LongTitle
+		}
+		if limits.Strictness != "" {
+			c.limits.Strictness = limits.Strictness
+		}
+	}
+}
+
+// WithSubtaskPolicy sets what completing a todo with open subtasks does
+// instead of SubtaskPolicyBlock.
+func WithSubtaskPolicy(policy SubtaskPolicy) RouterOption {
+	return func(c *routerConfig) {
+		c.subtaskPolicy = policy
 	}
+}
+
+// WithServiceHook calls hook with the router's Service before the router is
+// returned, so other transports such as gRPC can serve the same store with
+// the same event publication and change feed.
+func WithServiceHook(hook func(Service)) RouterOption {
+	return func(c *routerConfig) {
+		c.serviceHooks = append(c.serviceHooks, hook)
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(errorResponse)
+// seedSampleTodos seeds the store with the built-in sample data.
+func seedSampleTodos(service Service) {
+	service.CreateTodo(context.Background(), TodoInput{Title: "Learn Go", Description: "Master the Go programming language"})
+	service.CreateTodo(context.Background(), TodoInput{Title: "Build REST API", Description: "Create a HATEOAS-compliant REST API"})
+	service.CreateTodo(context.Background(), TodoInput{Title: "Write Tests", Description: "Add comprehensive test coverage"})
 }
 
 // NewRouter constructs and configures the chi router for the Todo API.
-// It wires the in-memory store, Service facade, middleware, routes,
-// and seeds the store with some sample data.
-func NewRouter(baseURL string) http.Handler {
-	store := NewTodoStore()
-	service := NewService(store)
-	api := NewTodoAPI(baseURL, service)
+// It wires the store (in-memory unless WithStore is given), Service facade,
+// middleware, and routes, and seeds the store with sample data unless a
+// seeder is configured.
+func NewRouter(baseURL string, opts ...RouterOption) http.Handler {
+	cfg := routerConfig{seed: seedSampleTodos, clock: SystemClock{}, limits: DefaultLimits(), subtaskPolicy: SubtaskPolicyBlock}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	store := cfg.store
+	if store == nil {
+		store = NewTodoStoreWithClock(cfg.clock)
+	}
+	// The wrappers below hide the optional interfaces they do not forward.
+	compactor, _ := store.(CompactStore)
+	if cfg.slowThreshold > 0 {
+		store = instrumentStore(store, cfg.slowThreshold, cfg.slowLogger)
+	}
+	store = withFallback(store)
+	bus := events.NewBus()
+	changes := newChangeLog(changeLogSize)
+	bus.Subscribe(changes.record)
+	if cfg.publisher != nil {
+		bus.Subscribe(cfg.publisher.Publish)
+	}
 
-	service.CreateTodo(TodoInput{Title: "Learn Go", Description: "Master the Go programming language"})
-	service.CreateTodo(TodoInput{Title: "Build REST API", Description: "Create a HATEOAS-compliant REST API"})
-	service.CreateTodo(TodoInput{Title: "Write Tests", Description: "Add comprehensive test coverage"})
+	service := newService(store, cfg.clock, bus, cfg.limits)
+	service.subtaskPolicy = cfg.subtaskPolicy
+	api := NewTodoAPI(baseURL, service)
+	api.clock = cfg.clock
+	api.compactor = compactor
+	api.changes = changes
+	api.events = bus
+	api.backupRecipients = cfg.backupRecipients
+	api.backupIdentities = cfg.backupIdentities
+
+	cfg.seed(service)
+	for _, hook := range cfg.serviceHooks {
+		hook(service)
+	}
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.SetHeader("Content-Type", "application/json"))
 
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-			if r.Method == "OPTIONS" {
-				return
-			}
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-None-Match, If-Match, If-Modified-Since, If-Unmodified-Since, X-Consistency-Token, X-Link-Rel, X-Request-Deadline, Request-Timeout")
+			w.Header().Set("Access-Control-Expose-Headers", "Link, Location, ETag, X-Consistency-Token")
 
 			next.ServeHTTP(w, r)
 		})
 	})
+	if cfg.trustForwarded {
+		r.Use(resolveBaseURL)
+	}
+	r.Use(api.negotiate)
+	r.Use(api.localize)
+	r.Use(api.deadline)
+	r.Use(api.consistent)
+	r.Use(api.degrade)
+	r.Use(api.allowMethods)
+	r.NotFound(api.NotFound)
 
 	r.Get("/", api.GetRoot)
+	r.Get("/profiles/{name}", api.GetProfile)
+	r.Get("/rels/{rel}", api.GetRelation)
 	r.Route("/todos", func(r chi.Router) {
 		r.Get("/", api.GetTodos)
+		r.Head("/", api.GetTodos)
 		r.Post("/", api.CreateTodo)
+		r.Route("/import", func(r chi.Router) {
+			r.Post("/", api.ImportTodos)
+			r.Post("/uploads", api.CreateImportUpload)
+			r.Route("/uploads/{uploadID}", func(r chi.Router) {
+				r.Get("/", api.GetImportUpload)
+				r.Delete("/", api.DeleteImportUpload)
+				r.Post("/confirm", api.ConfirmImportUpload)
+			})
+			r.Post("/jobs", api.CreateImportJob)
+			r.Route("/jobs/{jobID}", func(r chi.Router) {
+				r.Get("/", api.GetImportJob)
+				r.Delete("/", api.CancelImportJob)
+			})
+		})
 
 		r.Route("/{id}", func(r chi.Router) {
+			r.Use(api.parseTodoID)
+
 			r.Get("/", api.GetTodo)
+			r.Head("/", api.GetTodo)
 			r.Put("/", api.UpdateTodo)
+			r.Patch("/", api.PatchTodo)
 			r.Delete("/", api.DeleteTodo)
-			r.Patch("/complete", api.CompleteTodo)
+			for _, transition := range Transitions {
+				r.Patch("/"+string(transition), api.TransitionTodo(transition))
+			}
+			r.Post("/merge", api.MergeTodo)
+			r.Get("/subtasks", api.GetSubtasks)
+		})
+	})
+	if cfg.adminToken != "" && cfg.adminListener != nil {
+		cfg.adminListener(api.adminRouter(cfg.adminToken))
+	} else if cfg.adminToken != "" {
+		r.Route("/admin", api.adminRoutes(cfg.adminToken))
+	}
+	r.Get("/changes", api.GetChanges)
+	r.Get("/tags", api.GetTags)
+	r.Get("/ws", api.ServeWebSocket)
+	r.Route("/milestones", func(r chi.Router) {
+		r.Get("/", api.GetMilestones)
+		r.Post("/", api.CreateMilestone)
+
+		r.Route("/{milestoneID}", func(r chi.Router) {
+			r.Use(api.parseMilestoneID)
+
+			r.Get("/", api.GetMilestone)
+			r.Get("/progress", api.GetMilestoneProgress)
+			r.With(api.parseTodoID).Put("/todos/{id}", api.AssignTodo)
+			r.With(api.parseTodoID).Delete("/todos/{id}", api.UnassignTodo)
 		})
 	})
 
@@ -1,12 +1,29 @@
 package todo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/coder/websocket"
+	"github.com/efrem/windsurf/internal/events"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 const (
@@ -19,10 +36,47 @@ const (
 	deleteMsgFormat    = "failed to unmarshal created todo: %v"
 )
 
+// createTodo adds a todo to store, failing the test if the store fails.
+func createTodo(t *testing.T, store Store, input TodoInput) *Todo {
+	t.Helper()
+	todo, err := store.Create(t.Context(), input)
+	if err != nil {
+		t.Fatalf("failed to create todo %q: %v", input.Title, err)
+	}
+	return todo
+}
+
+// allTodos returns all todos of store, failing the test if the store fails.
+func allTodos(t *testing.T, store Store) []*Todo {
+	t.Helper()
+	todos, err := store.GetAll(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list todos: %v", err)
+	}
+	return todos
+}
+
+// serve sends a request with body and header, which may be nil, to r and
+// returns the recorded response. A body is sent as JSON unless header sets
+// another Content-Type; an empty one sends none.
+func serve(r http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+	}
+	maps.Copy(req.Header, header)
+	if req.Header.Get(contentTypeHeader) == "" {
+		req.Header.Del(contentTypeHeader)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
 func TestTodoStoreCreateAndGet(t *testing.T) {
 	store := NewTodoStore()
 
-	created := store.Create(TodoInput{Title: "Test", Description: "Desc"})
+	created := createTodo(t, store, TodoInput{Title: "Test", Description: "Desc"})
 	if created.ID != 1 {
 		t.Fatalf("expected first todo ID to be 1, got %d", created.ID)
 	}
@@ -30,9 +84,9 @@ func TestTodoStoreCreateAndGet(t *testing.T) {
 		t.Fatalf("expected new todo to be not completed")
 	}
 
-	fetched, ok := store.GetByID(created.ID)
-	if !ok {
-		t.Fatalf("expected todo with ID %d to exist", created.ID)
+	fetched, err := store.GetByID(t.Context(), created.ID)
+	if err != nil {
+		t.Fatalf("expected todo with ID %d to exist, got %v", created.ID, err)
 	}
 	if fetched.Title != "Test" || fetched.Description != "Desc" {
 		t.Fatalf("unexpected fetched todo: %+v", fetched)
@@ -42,47 +96,44 @@ func TestTodoStoreCreateAndGet(t *testing.T) {
 func TestTodoStoreNegativePaths(t *testing.T) {
 	store := NewTodoStore()
 
-	if todo, ok := store.Update(999, TodoInput{Title: "X", Description: "Y"}); ok || todo != nil {
-		t.Fatalf("expected Update on missing ID to return (nil, false), got (%+v, %v)", todo, ok)
+	if todo, err := store.Update(t.Context(), 999, TodoInput{Title: "X", Description: "Y"}); !errors.Is(err, ErrNotFound) || todo != nil {
+		t.Fatalf("expected Update on missing ID to return ErrNotFound, got (%+v, %v)", todo, err)
 	}
 
-	if todo, ok := store.Complete(999); ok || todo != nil {
-		t.Fatalf("expected Complete on missing ID to return (nil, false), got (%+v, %v)", todo, ok)
+	if todo, err := store.Complete(t.Context(), 999); !errors.Is(err, ErrNotFound) || todo != nil {
+		t.Fatalf("expected Complete on missing ID to return ErrNotFound, got (%+v, %v)", todo, err)
 	}
 
-	if deleted := store.Delete(999); deleted {
-		t.Fatalf("expected Delete on missing ID to return false")
+	if err := store.Delete(t.Context(), 999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected Delete on missing ID to return ErrNotFound, got %v", err)
 	}
 }
 
 func TestTodoStoreUpdateCompleteDelete(t *testing.T) {
 	store := NewTodoStore()
-	created := store.Create(TodoInput{Title: "Original", Description: "Original desc"})
+	created := createTodo(t, store, TodoInput{Title: "Original", Description: "Original desc"})
 
-	updated, ok := store.Update(created.ID, TodoInput{Title: "Updated", Description: "Updated desc"})
-	if !ok {
-		t.Fatalf("expected update to succeed")
+	updated, err := store.Update(t.Context(), created.ID, TodoInput{Title: "Updated", Description: "Updated desc"})
+	if err != nil {
+		t.Fatalf("expected update to succeed, got %v", err)
 	}
 	if updated.Title != "Updated" || updated.Description != "Updated desc" {
 		t.Fatalf("unexpected updated todo: %+v", updated)
 	}
 
-	completed, ok := store.Complete(created.ID)
-	if !ok {
-		t.Fatalf("expected complete to succeed")
+	completed, err := store.Complete(t.Context(), created.ID)
+	if err != nil {
+		t.Fatalf("expected complete to succeed, got %v", err)
 	}
 	if !completed.Completed {
 		t.Fatalf("expected todo to be marked completed")
 	}
 
-	deleted := store.Delete(created.ID)
-	if !deleted {
-		t.Fatalf("expected delete to succeed")
+	if err := store.Delete(t.Context(), created.ID); err != nil {
+		t.Fatalf("expected delete to succeed, got %v", err)
 	}
-	if _, ok := store.GetByID(created.ID); !ok {
-		// ok, should not exist anymore
-	} else {
-		t.Fatalf("expected todo to be removed after delete")
+	if _, err := store.GetByID(t.Context(), created.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected todo to be removed after delete, got %v", err)
 	}
 }
 
@@ -90,36 +141,36 @@ func TestServiceDelegatesToStore(t *testing.T) {
 	store := NewTodoStore()
 	service := NewService(store)
 
-	created := service.CreateTodo(TodoInput{Title: "Svc", Description: "Svc desc"})
-	if created.ID == 0 {
-		t.Fatalf("expected created todo to have non-zero ID")
+	created, err := service.CreateTodo(t.Context(), TodoInput{Title: "Svc", Description: "Svc desc"})
+	if err != nil || created.ID == 0 {
+		t.Fatalf("expected created todo to have non-zero ID, got %+v, err=%v", created, err)
 	}
 
-	list := service.ListTodos()
-	if len(list) != 1 {
+	list, err := service.ListTodos(t.Context())
+	if err != nil || len(list) != 1 {
 		t.Fatalf("expected 1 todo from ListTodos, got %d", len(list))
 	}
 
-	got, ok := service.GetTodo(created.ID)
-	if !ok || got.ID != created.ID {
-		t.Fatalf("expected to get todo with ID %d, got %+v, ok=%v", created.ID, got, ok)
+	got, err := service.GetTodo(t.Context(), created.ID)
+	if err != nil || got.ID != created.ID {
+		t.Fatalf("expected to get todo with ID %d, got %+v, err=%v", created.ID, got, err)
 	}
 
-	updated, ok := service.UpdateTodo(created.ID, TodoInput{Title: "Svc2", Description: "Svc2 desc"})
-	if !ok || updated.Title != "Svc2" {
-		t.Fatalf("expected UpdateTodo to modify title, got %+v, ok=%v", updated, ok)
+	updated, err := service.UpdateTodo(t.Context(), created.ID, TodoInput{Title: "Svc2", Description: "Svc2 desc"})
+	if err != nil || updated.Title != "Svc2" {
+		t.Fatalf("expected UpdateTodo to modify title, got %+v, err=%v", updated, err)
 	}
 
-	completed, ok := service.CompleteTodo(created.ID)
-	if !ok || !completed.Completed {
-		t.Fatalf("expected CompleteTodo to mark as completed, got %+v, ok=%v", completed, ok)
+	completed, err := service.TransitionTodo(t.Context(), created.ID, TransitionComplete)
+	if err != nil || !completed.Completed {
+		t.Fatalf("expected TransitionTodo to mark as completed, got %+v, err=%v", completed, err)
 	}
 
-	if !service.DeleteTodo(created.ID) {
-		t.Fatalf("expected DeleteTodo to return true")
+	if err := service.DeleteTodo(t.Context(), created.ID); err != nil {
+		t.Fatalf("expected DeleteTodo to succeed, got %v", err)
 	}
-	if _, ok := service.GetTodo(created.ID); ok {
-		t.Fatalf("expected todo to be gone after DeleteTodo")
+	if _, err := service.GetTodo(t.Context(), created.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected todo to be gone after DeleteTodo, got %v", err)
 	}
 }
 
@@ -154,7 +205,7 @@ func TestBuildCollectionLinksPagination(t *testing.T) {
 	perPage := 10
 	total := 35
 
-	links := buildCollectionLinks(baseURL, page, perPage, total)
+	links := buildCollectionLinks(baseURL, page, perPage, total, "", "")
 
 	if links.Self == nil || links.First == nil || links.Last == nil {
 		t.Fatalf("expected self, first, and last links to be set")
@@ -239,6 +290,537 @@ func TestGetTodosPaginationHandler(t *testing.T) {
 	}
 }
 
+func TestLinkHeaders(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		for _, title := range []string{"A", "B", "C"} {
+			s.CreateTodo(t.Context(), TodoInput{Title: title})
+		}
+	}))
+	got := serve(r, http.MethodGet, todosPath+"?page=2&per_page=1", "", nil).Header().Get("Link")
+	for _, want := range []string{
+		`<` + testBaseURL + `/todos?page=2&per_page=1>; rel="self"`,
+		`<` + testBaseURL + `/todos?page=1&per_page=1>; rel="first"`,
+		`<` + testBaseURL + `/todos?page=1&per_page=1>; rel="prev"`,
+		`<` + testBaseURL + `/todos?page=3&per_page=1>; rel="next"`,
+		`<` + testBaseURL + `/todos?page=3&per_page=1>; rel="last"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected collection Link header to contain %s, got %q", want, got)
+		}
+	}
+
+	rec := serve(r, http.MethodGet, "/todos/1", "", nil)
+	got = rec.Header().Get("Link")
+	for _, want := range []string{
+		`<` + testBaseURL + `/todos/1>; rel="self"`,
+		`<` + testBaseURL + `/todos/1>; rel="edit"`,
+		`<` + testBaseURL + `/todos>; rel="collection"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected todo Link header to contain %s, got %q", want, got)
+		}
+	}
+
+	if got := serve(r, http.MethodGet, "/todos/9999", "", nil).Header().Get("Link"); got != "" {
+		t.Fatalf("expected no Link header on errors, got %q", got)
+	}
+}
+
+func TestHeadRequests(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	r := NewRouter(testBaseURL, WithClock(clock))
+
+	for _, target := range []string{todosPath, "/todos/1"} {
+		get := serve(r, http.MethodGet, target, "", nil)
+		head := serve(r, http.MethodHead, target, "", nil)
+		if head.Code != http.StatusOK || head.Body.Len() != 0 {
+			t.Fatalf("expected HEAD %s to answer 200 without a body, got %d with %d bytes", target, head.Code, head.Body.Len())
+		}
+		if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+			t.Fatalf("expected HEAD %s Content-Length %s, got %s", target, want, got)
+		}
+		if head.Header().Get("ETag") == "" || head.Header().Get("ETag") != get.Header().Get("ETag") {
+			t.Fatalf("expected HEAD %s to carry the ETag of GET, got %q and %q", target, head.Header().Get("ETag"), get.Header().Get("ETag"))
+		}
+		if got := head.Header().Get("Last-Modified"); got != "Tue, 05 Mar 2024 12:00:00 GMT" {
+			t.Fatalf("unexpected Last-Modified for HEAD %s: %q", target, got)
+		}
+	}
+
+	before := serve(r, http.MethodHead, "/todos/1", "", nil).Header().Get("ETag")
+	clock.Advance(time.Hour)
+	serve(r, http.MethodPut, "/todos/1", `{"title":"Changed"}`, nil)
+	head := serve(r, http.MethodHead, "/todos/1", "", nil)
+	if head.Header().Get("ETag") == before {
+		t.Fatalf("expected the ETag to change with the todo")
+	}
+	if got := head.Header().Get("Last-Modified"); got != "Tue, 05 Mar 2024 13:00:00 GMT" {
+		t.Fatalf("expected Last-Modified to follow the update, got %q", got)
+	}
+	if got := serve(r, http.MethodHead, todosPath, "", nil).Header().Get("Last-Modified"); got != "Tue, 05 Mar 2024 13:00:00 GMT" {
+		t.Fatalf("expected collection Last-Modified to follow the update, got %q", got)
+	}
+
+	if rec := serve(r, http.MethodHead, "/todos/9999", "", nil); rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Fatalf("expected HEAD on a missing todo to answer 404 without a body, got %d", rec.Code)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	store := NewTodoStoreWithClock(clock)
+	r := NewRouter(testBaseURL, WithClock(clock), WithStore(store))
+
+	for _, target := range []string{todosPath, "/todos/1"} {
+		tag := serve(r, http.MethodGet, target, "", nil).Header().Get("ETag")
+		for _, ifNoneMatch := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+			rec := serve(r, http.MethodGet, target, "", http.Header{"If-None-Match": {ifNoneMatch}})
+			if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+				t.Fatalf("expected GET %s with If-None-Match %s to answer 304 without a body, got %d", target, ifNoneMatch, rec.Code)
+			}
+			if rec.Header().Get("ETag") != tag {
+				t.Fatalf("expected 304 for %s to repeat the ETag %s, got %q", target, tag, rec.Header().Get("ETag"))
+			}
+		}
+		if rec := serve(r, http.MethodGet, target, "", http.Header{"If-None-Match": {`"stale"`}}); rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Fatalf("expected GET %s with a stale tag to answer 200 with the body, got %d", target, rec.Code)
+		}
+	}
+
+	tag := serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+	german := http.Header{"Accept-Language": {"de"}}
+	germanTag := serve(r, http.MethodGet, "/todos/1", "", german).Header().Get("ETag")
+	if germanTag == tag {
+		t.Fatalf("expected each language of a todo to have its own ETag, got %s for both", tag)
+	}
+	clock.Advance(48 * time.Hour)
+	german["If-None-Match"] = []string{germanTag}
+	if rec := serve(r, http.MethodGet, "/todos/1", "", german); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected the ETag not to change as relative times age, got %d", rec.Code)
+	}
+	if got := serve(r, http.MethodGet, "/todos/1", "", http.Header{"Accept": {MediaTypeHAL}}).Header().Get("ETag"); got == tag {
+		t.Fatalf("expected each media type of a todo to have its own ETag, got %s for both", got)
+	}
+
+	// The subtasks and milestone shown and the content a restore brings
+	// back change the representation without a new version of the todo.
+	for _, change := range []func(){
+		func() { serve(r, http.MethodPost, todosPath, `{"title":"Child","parent_id":1}`, nil) },
+		func() {
+			serve(r, http.MethodPost, "/milestones", `{"name":"Sprint 1","start_date":"2024-03-01","end_date":"2024-03-15"}`, nil)
+			serve(r, http.MethodPut, "/milestones/1/todos/1", "", nil)
+		},
+		func() {
+			b, _ := store.Backup(t.Context())
+			b.Todos[0].Title = "Restored"
+			if err := store.Restore(t.Context(), b); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+		},
+	} {
+		tag = serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+		change()
+		if rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{"If-None-Match": {tag}}); rec.Code != http.StatusOK {
+			t.Fatalf("expected a changed representation to be sent again, got %d", rec.Code)
+		}
+	}
+	if rec := serve(r, http.MethodPut, "/todos/1", `{"title":"Lost"}`, http.Header{"If-Match": {tag}}); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected a write based on the todo before the restore to answer 412, got %d", rec.Code)
+	}
+
+	tag = serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+	collectionTag := serve(r, http.MethodGet, todosPath, "", nil).Header().Get("ETag")
+	serve(r, http.MethodPut, "/todos/1", `{"title":"Changed"}`, nil)
+	if rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{"If-None-Match": {tag}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected the todo to be sent again after an update, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodGet, todosPath, "", http.Header{"If-None-Match": {collectionTag}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected the collection to be sent again after an update, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPut, "/todos/1", `{"title":"Again"}`, http.Header{"If-None-Match": {"*"}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected If-None-Match to be ignored on PUT, got %d", rec.Code)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	r := NewRouter(testBaseURL, WithClock(clock))
+	ifMatch := func(tag string) http.Header { return http.Header{"If-Match": {tag}} }
+
+	// Two editors fetch the same version; the second write must not
+	// overwrite the first.
+	stale := serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+	rec := serve(r, http.MethodPut, "/todos/1", `{"title":"First editor"}`, ifMatch(stale))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a write with the current ETag to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("expected the update to bump the version to 2, got %d", updated.Version)
+	}
+	for _, req := range []struct{ method, target, body string }{
+		{http.MethodPut, "/todos/1", `{"title":"Second editor"}`},
+		{http.MethodPatch, "/todos/1/complete", ""},
+		{http.MethodDelete, "/todos/1", ""},
+	} {
+		if rec := serve(r, req.method, req.target, req.body, ifMatch(stale)); rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected %s %s with a stale ETag to answer 412, got %d", req.method, req.target, rec.Code)
+		}
+	}
+	if todo := serve(r, http.MethodGet, "/todos/1", "", nil); !strings.Contains(todo.Body.String(), "First editor") {
+		t.Fatalf("expected the rejected writes to leave the todo unchanged: %s", todo.Body.String())
+	}
+
+	current := serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+	if rec := serve(r, http.MethodPatch, "/todos/1/complete", "", ifMatch("W/"+current)); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected a weak ETag not to match If-Match, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPatch, "/todos/1/complete", "", ifMatch(`"other", `+current)); rec.Code != http.StatusOK {
+		t.Fatalf("expected a list containing the current ETag to match, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPut, "/todos/1", `{"title":"Anyone"}`, ifMatch("*")); rec.Code != http.StatusOK {
+		t.Fatalf("expected If-Match * to match an existing todo, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPut, "/todos/1", `{"title":"Unconditional"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected writes without If-Match to succeed, got %d", rec.Code)
+	}
+
+	current = serve(r, http.MethodGet, "/todos/1", "", nil).Header().Get("ETag")
+	if rec := serve(r, http.MethodDelete, "/todos/1", "", ifMatch(current)); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a delete with the current ETag to succeed, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodDelete, "/todos/1", "", ifMatch(current)); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a conditional delete of a missing todo to answer 404, got %d", rec.Code)
+	}
+
+	// Only changes of the todo itself make its tag stale for If-Match, not
+	// the language, relative times or progress of subtasks a
+	// representation shows.
+	english := http.Header{"Accept-Language": {"en"}}
+	tag := serve(r, http.MethodGet, "/todos/2", "", english).Header().Get("ETag")
+	clock.Advance(48 * time.Hour)
+	german := http.Header{"Accept-Language": {"de"}, "If-Match": {tag}}
+	if rec := serve(r, http.MethodPut, "/todos/2", `{"title":"Translated"}`, german); rec.Code != http.StatusOK {
+		t.Fatalf("expected a write in another language with the current ETag to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	tag = serve(r, http.MethodGet, "/todos/2", "", english).Header().Get("ETag")
+	if rec := serve(r, http.MethodPost, todosPath, `{"title":"Child","parent_id":2}`, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("failed to create subtask: %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodDelete, "/todos/2", "", ifMatch(tag)); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a delete after a subtask was added to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequireJSONContentType(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "application/json; charset=latin1"} {
+		for _, tc := range []struct{ method, target, body string }{
+			{http.MethodPost, todosPath, `{"title":"Rejected"}`},
+			{http.MethodPut, "/todos/1", `{"title":"Rejected"}`},
+			{http.MethodPost, "/todos/1/merge", `{"source_id":2}`},
+			{http.MethodPost, "/milestones", `{"title":"Rejected"}`},
+			{http.MethodPost, "/todos/import", `[{"title":"Rejected"}]`},
+		} {
+			if rec := serve(r, tc.method, tc.target, tc.body, http.Header{contentTypeHeader: {contentType}}); rec.Code != http.StatusUnsupportedMediaType {
+				t.Fatalf("expected %s %s with Content-Type %q to be rejected with 415, got %d", tc.method, tc.target, contentType, rec.Code)
+			}
+		}
+	}
+	if rec := serve(r, http.MethodGet, "/todos/1", "", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"title":"Learn Go"`) {
+		t.Fatalf("expected rejected requests to leave the todo unchanged, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, contentType := range []string{"application/json; charset=UTF-8", MediaTypeVendorV1} {
+		if rec := serve(r, http.MethodPost, todosPath, `{"title":"Accepted"}`, http.Header{contentTypeHeader: {contentType}}); rec.Code != http.StatusCreated {
+			t.Fatalf("expected Content-Type %q to be accepted, got %d: %s", contentType, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := serve(r, http.MethodPatch, "/todos/1/complete", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected transitions without a body to need no Content-Type, got %d", rec.Code)
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	if token := serve(r, http.MethodGet, todosPath, "", nil).Header().Get(HeaderConsistencyToken); token != "" {
+		t.Fatalf("expected reads not to return a consistency token, got %q", token)
+	}
+	if token := serve(r, http.MethodPost, todosPath, `{}`, nil).Header().Get(HeaderConsistencyToken); token != "" {
+		t.Fatalf("expected failed writes not to return a consistency token, got %q", token)
+	}
+
+	first := serve(r, http.MethodPost, todosPath, `{"title":"First"}`, nil).Header().Get(HeaderConsistencyToken)
+	second := serve(r, http.MethodPut, "/todos/1", `{"title":"Second"}`, nil).Header().Get(HeaderConsistencyToken)
+	if first == "" || second == "" || first == second {
+		t.Fatalf("expected every write to return a new consistency token, got %q and %q", first, second)
+	}
+
+	rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{HeaderConsistencyToken: {second}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a read with a valid token to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !slices.Contains(rec.Header().Values("Vary"), HeaderConsistencyToken) {
+		t.Fatalf("expected responses to vary on %s, got %q", HeaderConsistencyToken, rec.Header().Values("Vary"))
+	}
+	if rec.Header().Get("Warning") != "" {
+		t.Fatalf("expected a verified token to carry no warning, got %q", rec.Header().Get("Warning"))
+	}
+	if rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{HeaderConsistencyToken: {"0.1"}}); rec.Code != http.StatusOK || rec.Header().Get("Warning") != unverifiedTokenWarning {
+		t.Fatalf("expected a token from an earlier process to be served with a warning, got %d and %q", rec.Code, rec.Header().Get("Warning"))
+	}
+
+	epoch, _, _ := strings.Cut(second, ".")
+	for _, token := range []string{"garbage", epoch + ".-1", epoch + ".999"} {
+		if rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{HeaderConsistencyToken: {token}}); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected token %q to be rejected, got %d", token, rec.Code)
+		}
+	}
+}
+
+func TestModifiedSince(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	r := NewRouter(testBaseURL, WithClock(clock))
+	const (
+		fetched = "Tue, 05 Mar 2024 12:00:00 GMT"
+		earlier = "Tue, 05 Mar 2024 11:00:00 GMT"
+	)
+
+	for _, target := range []string{todosPath, "/todos/1"} {
+		if rec := serve(r, http.MethodGet, target, "", http.Header{"If-Modified-Since": {fetched}}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Fatalf("expected GET %s since its Last-Modified to answer 304 without a body, got %d", target, rec.Code)
+		}
+		if rec := serve(r, http.MethodGet, target, "", http.Header{"If-Modified-Since": {earlier}}); rec.Code != http.StatusOK {
+			t.Fatalf("expected GET %s modified since the time to answer 200, got %d", target, rec.Code)
+		}
+		if rec := serve(r, http.MethodGet, target, "", http.Header{"If-Unmodified-Since": {earlier}}); rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected GET %s modified since If-Unmodified-Since to answer 412, got %d", target, rec.Code)
+		}
+		if rec := serve(r, http.MethodGet, target, "", http.Header{"If-Unmodified-Since": {"not a date"}}); rec.Code != http.StatusOK {
+			t.Fatalf("expected GET %s to ignore an invalid If-Unmodified-Since, got %d", target, rec.Code)
+		}
+	}
+
+	var todo Todo
+	if err := json.Unmarshal(serve(r, http.MethodGet, "/todos/1", "", nil).Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if !todo.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected updated_at to be the creation time, got %v", todo.UpdatedAt)
+	}
+
+	// A write is only applied if the todo is unchanged since the time sent.
+	clock.Advance(90 * time.Minute)
+	if rec := serve(r, http.MethodPut, "/todos/1", `{"title":"Changed"}`, http.Header{"If-Unmodified-Since": {fetched}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected a write to an unchanged todo to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, req := range []struct{ method, target, body string }{
+		{http.MethodPut, "/todos/1", `{"title":"Stale"}`},
+		{http.MethodPatch, "/todos/1/complete", ""},
+		{http.MethodDelete, "/todos/1", ""},
+	} {
+		if rec := serve(r, req.method, req.target, req.body, http.Header{"If-Unmodified-Since": {fetched}}); rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("expected %s %s on a todo changed since If-Unmodified-Since to answer 412, got %d", req.method, req.target, rec.Code)
+		}
+	}
+
+	head := serve(r, http.MethodHead, "/todos/1", "", nil)
+	if got := head.Header().Get("Last-Modified"); got != "Tue, 05 Mar 2024 13:30:00 GMT" {
+		t.Fatalf("expected Last-Modified to follow the update, got %q", got)
+	}
+	if rec := serve(r, http.MethodGet, "/todos/1", "", http.Header{"If-Modified-Since": {fetched}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected GET after the update to answer 200, got %d", rec.Code)
+	}
+	// If-None-Match takes precedence over If-Modified-Since.
+	req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", head.Header().Get("Last-Modified"))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a mismatched If-None-Match to override If-Modified-Since, got %d", rec.Code)
+	}
+
+	// Deletions change the collection without changing any todo.
+	clock.Advance(time.Hour)
+	serve(r, http.MethodDelete, "/todos/2", "", nil)
+	if got := serve(r, http.MethodHead, todosPath, "", nil).Header().Get("Last-Modified"); got != "Tue, 05 Mar 2024 14:30:00 GMT" {
+		t.Fatalf("expected collection Last-Modified to follow the deletion, got %q", got)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	jsonPatch := http.Header{contentTypeHeader: {MediaTypeJSONPatch}}
+	rec := serve(r, http.MethodPatch, "/todos/1", `[
+		{"op":"test","path":"/version","value":1},
+		{"op":"replace","path":"/title","value":"Patched"},
+		{"op":"remove","path":"/description"},
+		{"op":"replace","path":"/status","value":"archived"}
+	]`, jsonPatch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the patch to apply, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var todo Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if todo.Title != "Patched" || todo.Description != "" || todo.State() != StateArchived {
+		t.Fatalf("unexpected patched todo: %+v", todo)
+	}
+
+	for _, tc := range []struct {
+		body string
+		want []string
+	}{
+		{`[{"op":"add","path":"/tags/-","value":"work"},{"op":"add","path":"/tags/0","value":"home"},{"op":"test","path":"/tags/1","value":"work"}]`, []string{"home", "work"}},
+		{`[{"op":"remove","path":"/tags/0"},{"op":"replace","path":"/tags/0","value":"urgent"},{"op":"add","path":"/tags/1","value":"later"}]`, []string{"urgent", "later"}},
+	} {
+		rec := serve(r, http.MethodPatch, "/todos/1", tc.body, jsonPatch)
+		var tagged Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &tagged); err != nil {
+			t.Fatalf("failed to unmarshal todo: %v", err)
+		}
+		if rec.Code != http.StatusOK || !slices.Equal(tagged.Tags, tc.want) {
+			t.Fatalf("%s: expected tags %v, got %d %v", tc.body, tc.want, rec.Code, tagged.Tags)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, body string
+		want       int
+	}{
+		{"failed test", `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/title","value":"Stale"}]`, http.StatusConflict},
+		{"read-only member", `[{"op":"replace","path":"/id","value":7}]`, http.StatusUnprocessableEntity},
+		{"missing member", `[{"op":"remove","path":"/priority"}]`, http.StatusUnprocessableEntity},
+		{"empty title", `[{"op":"replace","path":"/title","value":""}]`, http.StatusUnprocessableEntity},
+		{"unknown status", `[{"op":"replace","path":"/status","value":"done"}]`, http.StatusUnprocessableEntity},
+		{"unsupported op", `[{"op":"move","from":"/title","path":"/description"}]`, http.StatusUnprocessableEntity},
+		{"unknown op", `[{"op":"rename","path":"/title"}]`, http.StatusBadRequest},
+		{"missing value", `[{"op":"replace","path":"/title"}]`, http.StatusBadRequest},
+		{"nested path", `[{"op":"replace","path":"/title/0","value":"x"}]`, http.StatusBadRequest},
+		{"tag below tags", `[{"op":"remove","path":"/tags/0/x"}]`, http.StatusBadRequest},
+		{"invalid tag index", `[{"op":"remove","path":"/tags/01"}]`, http.StatusBadRequest},
+		{"missing tag", `[{"op":"remove","path":"/tags/2"}]`, http.StatusUnprocessableEntity},
+		{"tag past the end", `[{"op":"test","path":"/tags/-","value":"later"}]`, http.StatusUnprocessableEntity},
+		{"failed tag test", `[{"op":"test","path":"/tags/0","value":"later"}]`, http.StatusConflict},
+		{"non-string tag", `[{"op":"add","path":"/tags/-","value":7}]`, http.StatusUnprocessableEntity},
+		{"not an array", `{"title":"Merge patch"}`, http.StatusBadRequest},
+	} {
+		if rec := serve(r, http.MethodPatch, "/todos/1", tc.body, jsonPatch); rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+	// A failing operation leaves the todo unchanged, even after others applied.
+	serve(r, http.MethodPatch, "/todos/1", `[{"op":"replace","path":"/title","value":"Partial"},{"op":"test","path":"/title","value":"Other"}]`, jsonPatch)
+	get := serve(r, http.MethodGet, "/todos/1", "", nil)
+	if strings.Contains(get.Body.String(), "Partial") {
+		t.Fatalf("expected a failed patch to change nothing: %s", get.Body.String())
+	}
+	if got := get.Header().Get("Accept-Patch"); got != MediaTypeJSONPatch {
+		t.Fatalf("expected GET to advertise JSON Patch, got %q", got)
+	}
+
+	rec = serve(r, http.MethodPatch, "/todos/1", `{"title":"Plain JSON"}`, http.Header{contentTypeHeader: {contentTypeJSON}})
+	if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Accept-Patch") != MediaTypeJSONPatch {
+		t.Fatalf("expected 415 with Accept-Patch for other media types, got %d %q", rec.Code, rec.Header().Get("Accept-Patch"))
+	}
+}
+
+func TestCollectionAggregates(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	get := func(target string) TodoCollection {
+		rec := serve(r, http.MethodGet, target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected GET %s to succeed, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var collection TodoCollection
+		if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+			t.Fatalf("failed to unmarshal collection: %v", err)
+		}
+		return collection
+	}
+
+	if got := get(todosPath).Meta.Aggregates; got != nil {
+		t.Fatalf("expected no aggregates unless requested, got %+v", got)
+	}
+	req := httptest.NewRequest(http.MethodPatch, "/todos/2/complete", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := map[State]int{StateOpen: 2, StateCompleted: 1, StateArchived: 0}
+	collection := get(todosPath + "?aggregates=true&per_page=1")
+	if collection.Meta.Aggregates == nil || !maps.Equal(collection.Meta.Aggregates.ByStatus, want) {
+		t.Fatalf("expected aggregates of the whole collection %v, got %+v", want, collection.Meta.Aggregates)
+	}
+	cursor := get(collection.Links.Snapshot.Href[len(testBaseURL):] + "&aggregates=true")
+	if cursor.Meta.Aggregates == nil || !maps.Equal(cursor.Meta.Aggregates.ByStatus, want) {
+		t.Fatalf("expected aggregates of the snapshot %v, got %+v", want, cursor.Meta.Aggregates)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, todosPath+"?aggregates=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid aggregates flag to answer 400, got %d", rec.Code)
+	}
+}
+
+func TestCollectionSortPresets(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	get := func(target string) TodoCollection {
+		rec := serve(r, http.MethodGet, strings.TrimPrefix(target, testBaseURL), "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected GET %s to succeed, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var collection TodoCollection
+		if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+			t.Fatalf("failed to unmarshal collection: %v", err)
+		}
+		return collection
+	}
+	titles := func(c TodoCollection) []string {
+		var got []string
+		for _, todo := range c.Todos {
+			got = append(got, todo.Title)
+		}
+		return got
+	}
+
+	link := get(todosPath).Links.SortByTitle
+	if link == nil || !link.Templated || link.Href != testBaseURL+"/todos?sort=title{&page,per_page}" {
+		t.Fatalf("expected a templated sort-by-title link, got %+v", link)
+	}
+	first := get(strings.Replace(link.Href, "{&page,per_page}", "&per_page=2", 1))
+	if got := titles(first); !slices.Equal(got, []string{"Build REST API", "Learn Go"}) {
+		t.Fatalf("expected the first page sorted by title, got %v", got)
+	}
+	if first.Links.Snapshot != nil || first.Links.Next == nil || !strings.Contains(first.Links.Next.Href, "sort=title") {
+		t.Fatalf("expected sorted pages to keep the order and offer no snapshot, got %+v", first.Links)
+	}
+	if got := titles(get(first.Links.Next.Href)); !slices.Equal(got, []string{"Write Tests"}) {
+		t.Fatalf("expected the next page sorted by title, got %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/todos/1", strings.NewReader(`{"title":"Learn Go well"}`))
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if got := titles(get(todosPath + "?sort=updated")); got[0] != "Learn Go well" {
+		t.Fatalf("expected the updated todo first, got %v", got)
+	}
+
+	for _, target := range []string{todosPath + "?sort=due", todosPath + "?sort=title&cursor=abc"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected GET %s to answer 400, got %d", target, rec.Code)
+		}
+	}
+}
+
 func TestCreateTodoValidationError(t *testing.T) {
 	r := NewRouter(testBaseURL)
 	body := `{"title":""}`
@@ -261,6 +843,343 @@ func TestCreateTodoValidationError(t *testing.T) {
 	}
 }
 
+func TestCreateTodoReportsEveryInvalidField(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	body := `{"title":"  ","description":"bell\u0007","external_id":"x"}`
+	req := httptest.NewRequest(http.MethodPost, todosPath, strings.NewReader(body))
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	want := []FieldError{
+		{Field: "title", Message: "is required", Rule: "required"},
+		{Field: "description", Message: "must not contain control characters", Rule: "no_control_characters"},
+		{Field: "source", Message: "must be given together with external_id", Rule: "required_with"},
+	}
+	if !slices.Equal(errResp.Errors, want) {
+		t.Fatalf("expected field errors %+v, got %+v", want, errResp.Errors)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, todosPath, strings.NewReader(`{"title":"  Trimmed\t","description":"line one\nline two\n"}`))
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var created Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if created.Title != "Trimmed" || created.Description != "line one\nline two" {
+		t.Fatalf("expected trimmed title and description, got %q and %q", created.Title, created.Description)
+	}
+}
+
+func TestConfiguredLengthLimits(t *testing.T) {
+	r := NewRouter(testBaseURL, WithLimits(Limits{Title: 10}))
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		serve(r, http.MethodPost, todosPath, `{"title":"Eleven char"}`, nil),
+		serve(r, http.MethodPut, todosPath+"/1", `{"title":"Eleven char"}`, nil),
+	} {
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status 422 for a title over the limit, got %d", rec.Code)
+		}
+		var errResp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("failed to unmarshal error response: %v", err)
+		}
+		want := FieldError{Field: "title", Message: "must be at most 10 characters", Rule: "max_length"}
+		if errResp.Message != "Title must be at most 10 characters" || len(errResp.Errors) != 1 || errResp.Errors[0] != want {
+			t.Fatalf("unexpected error response: %+v", errResp)
+		}
+	}
+
+	long := strings.Repeat("d", DefaultMaxDescriptionLength)
+	if rec := serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Ten chars!","description":%q}`, long), nil); rec.Code != http.StatusCreated {
+		t.Fatalf("expected the default description limit to be kept, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Ten chars!","description":%q}`, long+"d"), nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a description over the limit, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, todosPath+"/1", nil))
+	var todo Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if props := todo.Templates["default"].Properties; props[0].MaxLength != 10 || props[1].MaxLength != DefaultMaxDescriptionLength {
+		t.Fatalf("expected the update form to advertise the limits, got %+v", props)
+	}
+}
+
+func TestTags(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	rec := serve(r, http.MethodPost, todosPath, `{"title":"Tagged","tags":[" Work","home","work"]}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if !slices.Equal(created.Tags, []string{"work", "home"}) {
+		t.Fatalf("expected trimmed, lower-cased and deduplicated tags, got %v", created.Tags)
+	}
+	if len(created.Links.Tag) != 2 || created.Links.Tag[0].Name != "work" || created.Links.Tag[0].Href != testBaseURL+"/todos?tag=work" {
+		t.Fatalf("expected a link per tag, got %+v", created.Links.Tag)
+	}
+	serve(r, http.MethodPost, todosPath, `{"title":"Also work","tags":["work"]}`, nil)
+
+	rec = serve(r, http.MethodGet, todosPath+"?tag=work&per_page=1", "", nil)
+	var collection TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal collection: %v", err)
+	}
+	if collection.Meta.Total != 2 || collection.Links.Next == nil || !strings.Contains(collection.Links.Next.Href, "tag=work") {
+		t.Fatalf("expected two todos tagged work with the filter kept in the links, got %+v, %+v", collection.Meta, collection.Links.Next)
+	}
+	if collection.Links.Snapshot != nil {
+		t.Fatalf("expected a filtered collection not to offer a snapshot")
+	}
+
+	rec = serve(r, http.MethodGet, "/tags", "", nil)
+	var tags TagCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &tags); err != nil {
+		t.Fatalf("failed to unmarshal tags: %v", err)
+	}
+	if len(tags.Tags) != 2 || tags.Tags[0].Name != "home" || tags.Tags[0].Count != 1 || tags.Tags[1].Name != "work" || tags.Tags[1].Count != 2 {
+		t.Fatalf("unexpected tag counts: %+v", tags.Tags)
+	}
+	if tags.Tags[1].Links.Todos == nil || tags.Tags[1].Links.Todos.Href != testBaseURL+"/todos?tag=work" {
+		t.Fatalf("expected each tag to link to its todos, got %+v", tags.Tags[1].Links)
+	}
+
+	rec = serve(r, http.MethodPatch, fmt.Sprintf("%s/%d", todosPath, created.ID), `[{"op":"replace","path":"/tags","value":["errands"]}]`, http.Header{contentTypeHeader: {MediaTypeJSONPatch}})
+	var patched Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &patched); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if rec.Code != http.StatusOK || !slices.Equal(patched.Tags, []string{"errands"}) {
+		t.Fatalf("expected the patch to replace the tags, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, tc := range []struct {
+		body string
+		want []string
+	}{
+		{`{"title":"Renamed"}`, []string{"errands"}},
+		{`{"title":"Renamed","tags":[]}`, nil},
+	} {
+		rec = serve(r, http.MethodPut, fmt.Sprintf("%s/%d", todosPath, created.ID), tc.body, nil)
+		var updated Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+			t.Fatalf("failed to unmarshal todo: %v", err)
+		}
+		if !slices.Equal(updated.Tags, tc.want) {
+			t.Fatalf("PUT %s: expected tags %v, got %v", tc.body, tc.want, updated.Tags)
+		}
+	}
+
+	rec = serve(r, http.MethodPost, todosPath, `{"title":"Invalid","tags":["two words",""]}`, nil)
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || len(errResp.Errors) != 2 || errResp.Errors[0].Field != "tags[0]" || errResp.Errors[1].Rule != "required" {
+		t.Fatalf("expected both invalid tags to be reported, got %d: %+v", rec.Code, errResp)
+	}
+}
+
+func TestSubtasks(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	decode := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("failed to unmarshal response %s: %v", rec.Body, err)
+		}
+	}
+
+	var parent, child, other Todo
+	decode(serve(r, http.MethodPost, todosPath, `{"title":"Parent"}`, nil), &parent)
+	rec := serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Child","parent_id":%d}`, parent.ID), nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	decode(rec, &child)
+	if child.ParentID != parent.ID || child.Links.Parent == nil || child.Links.Parent.Href != fmt.Sprintf("%s/todos/%d", testBaseURL, parent.ID) {
+		t.Fatalf("expected the subtask to link to its parent, got %+v", child)
+	}
+	decode(serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Other","parent_id":%d}`, parent.ID), nil), &other)
+
+	decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d", todosPath, parent.ID), "", nil), &parent)
+	if parent.Subtasks == nil || *parent.Subtasks != (SubtaskProgress{Total: 2}) {
+		t.Fatalf("expected the parent to report two open subtasks, got %+v", parent.Subtasks)
+	}
+	if parent.Links.Subtasks == nil || parent.Links.Subtasks.Href != fmt.Sprintf("%s/todos/%d/subtasks", testBaseURL, parent.ID) {
+		t.Fatalf("expected a subtasks link, got %+v", parent.Links.Subtasks)
+	}
+	var subtasks SubtaskCollection
+	decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d/subtasks", todosPath, parent.ID), "", nil), &subtasks)
+	if len(subtasks.Todos) != 2 || subtasks.Todos[0].ID != child.ID || subtasks.Todos[1].ID != other.ID || subtasks.Progress.Total != 2 {
+		t.Fatalf("unexpected subtasks: %+v", subtasks)
+	}
+	if rec := serve(r, http.MethodGet, todosPath+"/9999/subtasks", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for the subtasks of a missing todo, got %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		name, body string
+	}{
+		{"missing parent", `{"title":"Orphan","parent_id":9999}`},
+		{"itself", fmt.Sprintf(`{"title":"Parent","parent_id":%d}`, parent.ID)},
+		{"cycle", fmt.Sprintf(`{"title":"Parent","parent_id":%d}`, child.ID)},
+	} {
+		method, target := http.MethodPost, todosPath
+		if tc.name != "missing parent" {
+			method, target = http.MethodPut, fmt.Sprintf("%s/%d", todosPath, parent.ID)
+		}
+		rec := serve(r, method, target, tc.body, nil)
+		var errResp ErrorResponse
+		decode(rec, &errResp)
+		if rec.Code != http.StatusBadRequest || len(errResp.Errors) != 1 || errResp.Errors[0].Field != "parent_id" || errResp.Errors[0].Rule != ruleParent {
+			t.Fatalf("%s: expected an invalid parent_id, got %d: %s", tc.name, rec.Code, rec.Body)
+		}
+	}
+
+	rec = serve(r, http.MethodPatch, fmt.Sprintf("%s/%d/complete", todosPath, parent.ID), "", nil)
+	var errResp ErrorResponse
+	decode(rec, &errResp)
+	if rec.Code != http.StatusConflict || errResp.Links.Subtasks == nil {
+		t.Fatalf("expected completing a todo with open subtasks to conflict, got %d: %s", rec.Code, rec.Body)
+	}
+	serve(r, http.MethodPatch, fmt.Sprintf("%s/%d/complete", todosPath, child.ID), "", nil)
+	decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d", todosPath, parent.ID), "", nil), &parent)
+	if *parent.Subtasks != (SubtaskProgress{Total: 2, Completed: 1}) {
+		t.Fatalf("expected one completed subtask, got %+v", parent.Subtasks)
+	}
+
+	rec = serve(r, http.MethodPut, fmt.Sprintf("%s/%d", todosPath, child.ID), `{"title":"Renamed"}`, nil)
+	decode(rec, &child)
+	if rec.Code != http.StatusOK || child.ParentID != parent.ID {
+		t.Fatalf("expected an update without parent_id to keep the parent, got %d: %s", rec.Code, rec.Body)
+	}
+	var detached Todo
+	decode(serve(r, http.MethodPut, fmt.Sprintf("%s/%d", todosPath, child.ID), `{"title":"Renamed","parent_id":0}`, nil), &detached)
+	if detached.ParentID != 0 || detached.Links.Parent != nil {
+		t.Fatalf("expected parent_id 0 to detach the subtask, got %+v", detached)
+	}
+	rec = serve(r, http.MethodPatch, fmt.Sprintf("%s/%d", todosPath, child.ID), fmt.Sprintf(`[{"op":"add","path":"/parent_id","value":%d}]`, parent.ID), http.Header{contentTypeHeader: {MediaTypeJSONPatch}})
+	decode(rec, &child)
+	if rec.Code != http.StatusOK || child.ParentID != parent.ID {
+		t.Fatalf("expected the patch to set the parent, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(r, http.MethodDelete, fmt.Sprintf("%s/%d", todosPath, parent.ID), "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body)
+	}
+	var orphan Todo
+	decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d", todosPath, other.ID), "", nil), &orphan)
+	if orphan.ParentID != 0 {
+		t.Fatalf("expected deleting the parent to detach its subtasks, got %+v", orphan)
+	}
+
+	t.Run("Cascade", func(t *testing.T) {
+		r = NewRouter(testBaseURL, WithSubtaskPolicy(SubtaskPolicyCascade))
+		var parent, child, grandchild Todo
+		decode(serve(r, http.MethodPost, todosPath, `{"title":"Parent"}`, nil), &parent)
+		decode(serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Child","parent_id":%d}`, parent.ID), nil), &child)
+		decode(serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Grandchild","parent_id":%d}`, child.ID), nil), &grandchild)
+
+		rec := serve(r, http.MethodPatch, fmt.Sprintf("%s/%d/complete", todosPath, parent.ID), "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected the cascade to complete the todo, got %d: %s", rec.Code, rec.Body)
+		}
+		decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d", todosPath, grandchild.ID), "", nil), &grandchild)
+		if !grandchild.Completed {
+			t.Fatalf("expected the cascade to complete nested subtasks, got %+v", grandchild)
+		}
+	})
+
+	t.Run("WithoutListing", func(t *testing.T) {
+		r = NewRouter(testBaseURL, WithStore(unlistedStore{Store: NewTodoStore(), t: t}), WithSeeder(func(Service) {}))
+		var parent, child Todo
+		decode(serve(r, http.MethodPost, todosPath, `{"title":"Parent"}`, nil), &parent)
+		decode(serve(r, http.MethodPost, todosPath, fmt.Sprintf(`{"title":"Child","parent_id":%d}`, parent.ID), nil), &child)
+
+		decode(serve(r, http.MethodGet, fmt.Sprintf("%s/%d", todosPath, parent.ID), "", nil), &parent)
+		if parent.Subtasks == nil || parent.Subtasks.Total != 1 {
+			t.Fatalf("expected the progress of the subtask, got %+v", parent.Subtasks)
+		}
+		if rec := serve(r, http.MethodGet, fmt.Sprintf("%s/%d/subtasks", todosPath, parent.ID), "", nil); rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+		}
+		if rec := serve(r, http.MethodPatch, fmt.Sprintf("%s/%d/complete", todosPath, parent.ID), "", nil); rec.Code != http.StatusConflict {
+			t.Fatalf("expected the open subtask to block the completion, got %d: %s", rec.Code, rec.Body)
+		}
+		if rec := serve(r, http.MethodDelete, fmt.Sprintf("%s/%d", todosPath, parent.ID), "", nil); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+// unlistedStore is a Store that fails the test when all todos are listed.
+type unlistedStore struct {
+	Store
+	t *testing.T
+}
+
+func (s unlistedStore) GetAll(ctx context.Context) ([]*Todo, error) {
+	s.t.Errorf("unexpected listing of all todos")
+	return s.Store.GetAll(ctx)
+}
+
+func TestLongTitleWarnings(t *testing.T) {
+	long := fmt.Sprintf(`{"title":%q}`, strings.Repeat("t", DefaultLongTitleLength+1))
+
+	lenient := NewRouter(testBaseURL)
+	for _, rec := range []*httptest.ResponseRecorder{
+		serve(lenient, http.MethodPost, todosPath, long, nil),
+		serve(lenient, http.MethodPut, todosPath+"/1", long, nil),
+	} {
+		if rec.Code != http.StatusCreated && rec.Code != http.StatusOK {
+			t.Fatalf("expected a very long title to be accepted, got %d: %s", rec.Code, rec.Body)
+		}
+		var todo Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+			t.Fatalf("failed to unmarshal todo: %v", err)
+		}
+		want := FieldError{Field: "title", Message: fmt.Sprintf("should be at most %d characters", DefaultLongTitleLength), Rule: "max_length"}
+		if len(todo.Warnings) != 1 || todo.Warnings[0] != want {
+			t.Fatalf("expected a warning about the title, got %+v", todo.Warnings)
+		}
+	}
+	if rec := serve(lenient, http.MethodPost, todosPath, `{"title":"Short"}`, nil); strings.Contains(rec.Body.String(), `"warnings"`) {
+		t.Fatalf("expected no warnings for a short title, got %s", rec.Body)
+	}
+
+	strict := NewRouter(testBaseURL, WithLimits(Limits{LongTitle: 5, Strictness: StrictnessStrict}))
+	rec := serve(strict, http.MethodPost, todosPath, `{"title":"Too long"}`, nil)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a very long title in strict mode, got %d", rec.Code)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.Message != "Title should be at most 5 characters" {
+		t.Fatalf("unexpected error response: %+v", errResp)
+	}
+}
+
 func TestGetTodoNotFound(t *testing.T) {
 	r := NewRouter(testBaseURL)
 	req := httptest.NewRequest(http.MethodGet, "/todos/9999", nil)
@@ -395,6 +1314,40 @@ func TestRouterUnknownRouteAndOptions(t *testing.T) {
 	}
 }
 
+func TestMethodNotAllowedAndOptions(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	rec := serve(r, http.MethodPost, "/todos/1", "", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "GET, HEAD, PUT, PATCH, DELETE, OPTIONS" {
+		t.Fatalf("unexpected Allow header %q", got)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil || errResp.Error != "Method not allowed" {
+		t.Fatalf("expected JSON 405 error, got %q (%v)", rec.Body.String(), err)
+	}
+
+	for target, want := range map[string]string{
+		todosPath:               "GET, HEAD, POST, OPTIONS",
+		"/todos/1/complete":     "PATCH, OPTIONS",
+		"/milestones/1/todos/2": "PUT, DELETE, OPTIONS",
+	} {
+		rec := serve(r, http.MethodOptions, target, "", nil)
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != want {
+			t.Fatalf("expected 204 with Allow %q for OPTIONS %s, got %d %q", want, target, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+
+	if rec := serve(r, http.MethodOptions, "/unknown", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for OPTIONS on an unknown path, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodGet, "/unknown", "", nil); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Not found") {
+		t.Fatalf("expected JSON 404 for an unknown path, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
 func TestCompleteTodoHandler(t *testing.T) {
 	r := NewRouter(testBaseURL)
 
@@ -457,6 +1410,87 @@ func TestCompleteTodoHandlerErrors(t *testing.T) {
 	}
 }
 
+func TestTodoLifecycleTransitions(t *testing.T) {
+	store := NewTodoStore()
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}))
+	created := createTodo(t, store, TodoInput{Title: "Lifecycle"})
+
+	transition := func(name string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := serve(r, http.MethodPatch, fmt.Sprintf("/todos/%d/%s", created.ID, name), "", nil)
+		if rec.Code != want {
+			t.Fatalf("expected status %d from %s, got %d; body=%s", want, name, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+	decodeTodo := func(rec *httptest.ResponseRecorder) Todo {
+		t.Helper()
+		var todo Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+			t.Fatalf("failed to unmarshal todo: %v", err)
+		}
+		return todo
+	}
+	decodeError := func(rec *httptest.ResponseRecorder) ErrorResponse {
+		t.Helper()
+		var errResp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("failed to unmarshal error: %v", err)
+		}
+		return errResp
+	}
+
+	errResp := decodeError(transition("archive", http.StatusConflict))
+	if errResp.Error != "Invalid state transition" || errResp.Links.Complete == nil || errResp.Links.Archive != nil {
+		t.Fatalf("expected 409 to link the complete transition only, got %+v", errResp)
+	}
+
+	completed := decodeTodo(transition("complete", http.StatusOK))
+	if completed.Status != StateCompleted || completed.Links.Complete != nil || completed.Links.Reopen == nil || completed.Links.Archive == nil {
+		t.Fatalf("unexpected completed todo: %+v", completed)
+	}
+	if _, ok := completed.Templates["archive"]; !ok {
+		t.Fatalf("expected archive template on completed todo, got %+v", completed.Templates)
+	}
+	transition("complete", http.StatusConflict)
+
+	archived := decodeTodo(transition("archive", http.StatusOK))
+	if archived.Status != StateArchived || !archived.Archived || archived.Links.Unarchive == nil || archived.Links.Reopen != nil {
+		t.Fatalf("unexpected archived todo: %+v", archived)
+	}
+	errResp = decodeError(transition("reopen", http.StatusConflict))
+	if errResp.Links.Unarchive == nil || errResp.Links.Reopen != nil {
+		t.Fatalf("expected 409 to link the unarchive transition, got %+v", errResp.Links)
+	}
+
+	transition("unarchive", http.StatusOK)
+	reopened := decodeTodo(transition("reopen", http.StatusOK))
+	if reopened.Status != StateOpen || reopened.Completed || reopened.Archived || reopened.Links.Complete == nil {
+		t.Fatalf("unexpected reopened todo: %+v", reopened)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/todos/9999/archive", nil)
+	notFound := httptest.NewRecorder()
+	r.ServeHTTP(notFound, req)
+	if notFound.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for missing todo, got %d", notFound.Code)
+	}
+}
+
+func TestTransitionPath(t *testing.T) {
+	path, ok := TransitionPath(StateOpen, StateArchived)
+	if !ok || !slices.Equal(path, []Transition{TransitionComplete, TransitionArchive}) {
+		t.Fatalf("unexpected path from open to archived: %v, %v", path, ok)
+	}
+	path, ok = TransitionPath(StateArchived, StateOpen)
+	if !ok || !slices.Equal(path, []Transition{TransitionUnarchive, TransitionReopen}) {
+		t.Fatalf("unexpected path from archived to open: %v, %v", path, ok)
+	}
+	if path, ok := TransitionPath(StateCompleted, StateCompleted); !ok || len(path) != 0 {
+		t.Fatalf("expected empty path between equal states, got %v, %v", path, ok)
+	}
+}
+
 func TestDeleteTodoHandler(t *testing.T) {
 	r := NewRouter(testBaseURL)
 
@@ -520,3 +1554,2269 @@ func TestDeleteTodoHandlerErrors(t *testing.T) {
 		t.Fatalf("expected status 404 for missing todo, got %d", notFoundRec.Code)
 	}
 }
+
+func TestVendorMediaTypeNegotiation(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	cases := []struct {
+		accept     string
+		wantStatus int
+		wantType   string
+	}{
+		{accept: "", wantStatus: http.StatusOK, wantType: MediaTypeJSON},
+		{accept: MediaTypeVendorV1, wantStatus: http.StatusOK, wantType: MediaTypeVendorV1},
+		{accept: "application/vnd.todoapp+json; version=1", wantStatus: http.StatusOK, wantType: MediaTypeVendorV1},
+		{accept: "application/pdf, */*;q=0.8", wantStatus: http.StatusOK, wantType: MediaTypeJSON},
+		{accept: "application/vnd.todoapp+json; version=2", wantStatus: http.StatusNotAcceptable, wantType: MediaTypeJSON},
+		{accept: "application/vnd.todoapp.v2+json", wantStatus: http.StatusNotAcceptable, wantType: MediaTypeJSON},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, todosPath, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("Accept %q: expected status %d, got %d", tc.accept, tc.wantStatus, rec.Code)
+		}
+		if got := rec.Header().Get(contentTypeHeader); got != tc.wantType {
+			t.Fatalf("Accept %q: expected Content-Type %q, got %q", tc.accept, tc.wantType, got)
+		}
+	}
+}
+
+func TestPlainTextRendering(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "Learn Go", Description: "Master the language\nand its tools"})
+		done, _ := s.CreateTodo(t.Context(), TodoInput{Title: "Build API"})
+		s.TransitionTodo(t.Context(), done.ID, TransitionComplete)
+		s.CreateTodo(t.Context(), TodoInput{Title: "Write docs"})
+	}))
+	plain := http.Header{"Accept": {"text/plain"}}
+
+	rec := serve(r, http.MethodGet, "/todos?per_page=2", "", plain)
+	if rec.Code != http.StatusOK || rec.Header().Get(contentTypeHeader) != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get(contentTypeHeader))
+	}
+	want := "Todos: page 1 of 2, 2 of 3 shown\n\n" +
+		"1. [open] Learn Go (#1)\n   Master the language\n   and its tools\n" +
+		"2. [done] Build API (#2)\n\n" +
+		"Next page: " + testBaseURL + "/todos?page=2&per_page=2\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected collection text:\n%s\nwant:\n%s", got, want)
+	}
+
+	if body := serve(r, http.MethodGet, "/todos?page=2&per_page=2", "", plain).Body.String(); !strings.HasPrefix(body, "Todos: page 2 of 2, 1 of 3 shown\n\n3. [open] Write docs (#3)\n") {
+		t.Fatalf("expected numbering to continue on later pages, got:\n%s", body)
+	}
+
+	body := serve(r, http.MethodGet, "/todos/2", "", http.Header{"Accept": {"text/plain;q=0.9, application/pdf"}}).Body.String()
+	if !strings.HasPrefix(body, "[done] Build API (#2)\n\nCreated: ") {
+		t.Fatalf("unexpected todo text:\n%s", body)
+	}
+
+	rec = serve(r, http.MethodGet, "/todos/99", "", plain)
+	if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Body.String(), "Error: Todo not found\n") {
+		t.Fatalf("expected a plain-text error, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(r, http.MethodGet, "/milestones", "", plain)
+	if rec.Code != http.StatusOK || rec.Header().Get(contentTypeHeader) != MediaTypeJSON {
+		t.Fatalf("expected representations without a text form to fall back to JSON, got %d %q", rec.Code, rec.Header().Get(contentTypeHeader))
+	}
+}
+
+func TestHTMLRendering(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "<b>Learn</b> Go"})
+	}))
+	browser := http.Header{"Accept": {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}}
+
+	rec := serve(r, http.MethodGet, "/todos/1", "", browser)
+	if rec.Code != http.StatusOK || rec.Header().Get(contentTypeHeader) != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get(contentTypeHeader))
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<title>&lt;b&gt;Learn&lt;/b&gt; Go</title>",
+		`<dt>self</dt><dd><a href="` + testBaseURL + `/todos/1">`,
+		`<a href="` + testBaseURL + `/todos/1/complete">` + testBaseURL + `/todos/1/complete</a> <small>PATCH</small>`,
+		`<nav><a href="` + testBaseURL + `">Todo API</a></nav>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected HTML to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<b>Learn</b>") {
+		t.Fatalf("expected todo content to be escaped")
+	}
+
+	rec = serve(r, http.MethodGet, "/todos/99", "", browser)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "<h1>Todo not found</h1>") {
+		t.Fatalf("expected an HTML error page, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(r, http.MethodGet, "/milestones", "", browser)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<h1>milestones</h1>") {
+		t.Fatalf("expected every representation to be browsable, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHALRepresentation(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "A"})
+		s.CreateTodo(t.Context(), TodoInput{Title: "B"})
+	}))
+
+	get := func(target string) map[string]any {
+		t.Helper()
+		rec := serve(r, http.MethodGet, target, "", http.Header{"Accept": {MediaTypeHAL}})
+		if rec.Code != http.StatusOK || rec.Header().Get(contentTypeHeader) != MediaTypeHAL {
+			t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get(contentTypeHeader))
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("failed to unmarshal HAL document: %v", err)
+		}
+		return doc
+	}
+
+	collection := get("/todos?per_page=1")
+	if _, ok := collection["todos"]; ok {
+		t.Fatalf("expected todos to move to _embedded, got %v", collection)
+	}
+	embedded, _ := collection["_embedded"].(map[string]any)
+	todos, _ := embedded["todos"].([]any)
+	if len(todos) != 1 {
+		t.Fatalf("expected one embedded todo, got %v", collection["_embedded"])
+	}
+	if collection["total"] != float64(2) || collection["_meta"] != nil {
+		t.Fatalf("expected _meta to become resource properties, got %v", collection)
+	}
+	next := collection["_links"].(map[string]any)["next"].(map[string]any)
+	if next["href"] != testBaseURL+"/todos?page=2&per_page=1" || next["method"] != nil {
+		t.Fatalf("expected HAL link objects, got %v", next)
+	}
+
+	todo := get("/todos/1")
+	complete := todo["_links"].(map[string]any)["complete"].(map[string]any)
+	if len(complete) != 1 || complete["href"] != testBaseURL+"/todos/1/complete" {
+		t.Fatalf("expected link properties outside HAL to be dropped, got %v", complete)
+	}
+	if todo["_templates"] == nil {
+		t.Fatalf("expected HAL-FORMS templates to be kept")
+	}
+
+	empty := get("/milestones")
+	if embedded, _ := empty["_embedded"].(map[string]any); embedded["milestones"] == nil {
+		t.Fatalf("expected an empty collection to be embedded, got %v", empty)
+	}
+}
+
+func TestSirenRepresentation(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "A"})
+	}))
+
+	get := func(target string) sirenEntity {
+		t.Helper()
+		rec := serve(r, http.MethodGet, target, "", http.Header{"Accept": {MediaTypeSiren}})
+		if rec.Header().Get(contentTypeHeader) != MediaTypeSiren {
+			t.Fatalf("unexpected Content-Type %q", rec.Header().Get(contentTypeHeader))
+		}
+		var entity sirenEntity
+		if err := json.Unmarshal(rec.Body.Bytes(), &entity); err != nil {
+			t.Fatalf("failed to unmarshal Siren entity: %v", err)
+		}
+		return entity
+	}
+	action := func(entity sirenEntity, name string) *sirenAction {
+		for i := range entity.Actions {
+			if entity.Actions[i].Name == name {
+				return &entity.Actions[i]
+			}
+		}
+		return nil
+	}
+
+	collection := get("/todos")
+	if !slices.Equal(collection.Class, []string{"todos", "collection"}) || collection.Properties["total"] != float64(1) {
+		t.Fatalf("unexpected collection entity: %+v", collection)
+	}
+	if len(collection.Entities) != 1 || !slices.Equal(collection.Entities[0].Rel, []string{"item"}) || !slices.Equal(collection.Entities[0].Class, []string{"todo"}) {
+		t.Fatalf("expected the todo as an item sub-entity, got %+v", collection.Entities)
+	}
+	if create := action(collection, "create"); create == nil || create.Method != http.MethodPost || create.Type != MediaTypeJSON || len(create.Fields) != 4 {
+		t.Fatalf("expected a create action with the template's fields, got %+v", create)
+	}
+
+	todo := get("/todos/1")
+	if todo.Properties["title"] != "A" || !slices.Equal(todo.Class, []string{"todo"}) {
+		t.Fatalf("unexpected todo entity: %+v", todo)
+	}
+	update := action(todo, "update")
+	if update == nil || update.Method != http.MethodPut || update.Href != testBaseURL+"/todos/1" || update.Fields[0].Value != "A" {
+		t.Fatalf("expected a pre-filled update action, got %+v", update)
+	}
+	for _, name := range []string{"complete", "delete", "merge"} {
+		if action(todo, name) == nil {
+			t.Fatalf("expected a %s action, got %+v", name, todo.Actions)
+		}
+	}
+	for _, link := range todo.Links {
+		if slices.Contains(link.Rel, "complete") {
+			t.Fatalf("expected transitions as actions only, got link %+v", link)
+		}
+	}
+
+	missing := get("/todos/99")
+	if !slices.Equal(missing.Class, []string{"error"}) || missing.Properties["error"] != "Todo not found" {
+		t.Fatalf("unexpected error entity: %+v", missing)
+	}
+}
+
+func TestXMLRepresentation(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "A & B"})
+	}))
+
+	accept := http.Header{"Accept": {MediaTypeXML}}
+
+	type xmlLink struct {
+		Rel    string `xml:"rel,attr"`
+		Href   string `xml:"href,attr"`
+		Method string `xml:"method,attr"`
+	}
+	type xmlTodo struct {
+		ID    int       `xml:"id"`
+		Title string    `xml:"title"`
+		Links []xmlLink `xml:"links>link"`
+	}
+	var collection struct {
+		XMLName xml.Name
+		Todos   []xmlTodo `xml:"todos>todo"`
+		Total   int       `xml:"meta>total"`
+		Links   []xmlLink `xml:"links>link"`
+	}
+	rec := serve(r, http.MethodGet, "/todos", "", accept)
+	if rec.Header().Get(contentTypeHeader) != xmlContentType {
+		t.Fatalf("unexpected Content-Type %q", rec.Header().Get(contentTypeHeader))
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal XML collection: %v", err)
+	}
+	if collection.XMLName.Local != "todos" || collection.Total != 1 || len(collection.Todos) != 1 || collection.Todos[0].Title != "A & B" {
+		t.Fatalf("unexpected XML collection: %+v", collection)
+	}
+	if !slices.Contains(collection.Links, xmlLink{Rel: "create", Href: testBaseURL + "/todos", Method: http.MethodPost}) {
+		t.Fatalf("expected the create link, got %+v", collection.Links)
+	}
+	if !slices.Contains(collection.Todos[0].Links, xmlLink{Rel: "complete", Href: testBaseURL + "/todos/1/complete", Method: http.MethodPatch}) {
+		t.Fatalf("expected the todo's complete link, got %+v", collection.Todos[0].Links)
+	}
+
+	rec = serve(r, http.MethodGet, "/todos/99", "", accept)
+	if rec.Header().Get(contentTypeHeader) != xmlContentType {
+		t.Fatalf("unexpected Content-Type %q", rec.Header().Get(contentTypeHeader))
+	}
+	var failure struct {
+		XMLName xml.Name
+		Error   string `xml:"error"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &failure); err != nil {
+		t.Fatalf("failed to unmarshal XML error: %v", err)
+	}
+	if rec.Code != http.StatusNotFound || failure.XMLName.Local != "error" || failure.Error != "Todo not found" {
+		t.Fatalf("unexpected XML error: %d %+v", rec.Code, failure)
+	}
+}
+
+func TestMsgpackRepresentation(t *testing.T) {
+	r := NewRouter(testBaseURL, WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "A"})
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	req.Header.Set("Accept", "application/x-msgpack")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get(contentTypeHeader) != MediaTypeMsgpack {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get(contentTypeHeader))
+	}
+
+	var todo struct {
+		ID        int    `msgpack:"id"`
+		Title     string `msgpack:"title"`
+		Completed bool   `msgpack:"completed"`
+		CreatedAt string `msgpack:"created_at"`
+		Links     map[string]struct {
+			Href string `msgpack:"href"`
+		} `msgpack:"_links"`
+	}
+	if err := msgpack.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal MessagePack todo: %v", err)
+	}
+	if todo.ID != 1 || todo.Title != "A" || todo.Completed {
+		t.Fatalf("unexpected todo: %+v", todo)
+	}
+	if todo.Links["self"].Href != testBaseURL+"/todos/1" {
+		t.Fatalf("expected the self link, got %+v", todo.Links)
+	}
+	if _, err := time.Parse(time.RFC3339, todo.CreatedAt); err != nil {
+		t.Fatalf("expected an RFC 3339 timestamp: %v", err)
+	}
+}
+
+func TestProfileLinksAndDocuments(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	var todo Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if todo.Links.Profile == nil || todo.Links.Profile.Href != testBaseURL+"/profiles/todo" {
+		t.Fatalf("expected todo profile link, got %+v", todo.Links.Profile)
+	}
+
+	profileReq := httptest.NewRequest(http.MethodGet, "/profiles/todo", nil)
+	profileRec := httptest.NewRecorder()
+
+	r.ServeHTTP(profileRec, profileReq)
+
+	if profileRec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for profile, got %d", profileRec.Code)
+	}
+
+	missingReq := httptest.NewRequest(http.MethodGet, "/profiles/unknown", nil)
+	missingRec := httptest.NewRecorder()
+
+	r.ServeHTTP(missingRec, missingReq)
+
+	if missingRec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for unknown profile, got %d", missingRec.Code)
+	}
+}
+
+func TestURITemplatesAndCuries(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	var root APIRoot
+	if err := json.Unmarshal(serve(r, http.MethodGet, "/", "", nil).Body.Bytes(), &root); err != nil {
+		t.Fatalf("failed to unmarshal root: %v", err)
+	}
+	if root.Links.Find == nil || !root.Links.Find.Templated || root.Links.Find.Href != testBaseURL+"/todos{/id}" {
+		t.Fatalf("expected templated todo:find link, got %+v", root.Links.Find)
+	}
+	if root.Links.Page == nil || root.Links.Page.Href != testBaseURL+"/todos{?page,per_page,sort,tag}" {
+		t.Fatalf("expected templated todo:page link, got %+v", root.Links.Page)
+	}
+	if len(root.Links.Curies) != 1 || root.Links.Curies[0].Name != "todo" || root.Links.Curies[0].Href != testBaseURL+"/rels/{rel}" {
+		t.Fatalf("unexpected curies: %+v", root.Links.Curies)
+	}
+
+	var collection TodoCollection
+	if err := json.Unmarshal(serve(r, http.MethodGet, todosPath, "", nil).Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal todos collection: %v", err)
+	}
+	if collection.Links.Find == nil || len(collection.Links.Curies) != 1 {
+		t.Fatalf("expected todo:find link and curies on collection, got %+v", collection.Links)
+	}
+
+	item := strings.Replace(collection.Links.Find.Href, "{/id}", "/1", 1)
+	if rec := serve(r, http.MethodGet, strings.TrimPrefix(item, testBaseURL), "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected expanded find template to resolve, got %d", rec.Code)
+	}
+
+	doc := strings.Replace(root.Links.Curies[0].Href, "{rel}", "find", 1)
+	rec := serve(r, http.MethodGet, strings.TrimPrefix(doc, testBaseURL), "", nil)
+	var relation Relation
+	if err := json.Unmarshal(rec.Body.Bytes(), &relation); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected relation document, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if relation.Name != "todo:find" || len(relation.Variables) != 1 {
+		t.Fatalf("unexpected relation document: %+v", relation)
+	}
+	if rec := serve(r, http.MethodGet, "/rels/unknown", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for unknown relation, got %d", rec.Code)
+	}
+}
+
+func TestLinkRelations(t *testing.T) {
+	rels := LinkRelations()
+	for _, want := range []string{"self", "next", "complete", "todo:find", "todo:page", "sort-by-title", "progress", "confirm"} {
+		if !slices.Contains(rels, want) {
+			t.Fatalf("expected relation %q in %v", want, rels)
+		}
+	}
+	if slices.Contains(rels, "curies") || !slices.IsSorted(rels) || len(slices.Compact(slices.Clone(rels))) != len(rels) {
+		t.Fatalf("expected sorted, unique relations without curies, got %v", rels)
+	}
+}
+
+func TestHALFormsTemplates(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	req := httptest.NewRequest(http.MethodGet, todosPath, nil)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	var collection TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal todos collection: %v", err)
+	}
+
+	create, ok := collection.Templates["default"]
+	if !ok || create.Method != http.MethodPost {
+		t.Fatalf("expected default POST template on collection, got %+v", collection.Templates)
+	}
+	if len(create.Properties) == 0 || create.Properties[0].Name != "title" || !create.Properties[0].Required {
+		t.Fatalf("expected required title property, got %+v", create.Properties)
+	}
+
+	if len(collection.Todos) == 0 {
+		t.Fatalf("expected seeded todos in collection")
+	}
+	first := collection.Todos[0]
+	update, ok := first.Templates["default"]
+	if !ok || update.Method != http.MethodPut {
+		t.Fatalf("expected default PUT template on todo, got %+v", first.Templates)
+	}
+	if update.Properties[0].Value != first.Title {
+		t.Fatalf("expected update form to be pre-filled with %q, got %+v", first.Title, update.Properties)
+	}
+	complete, ok := first.Templates["complete"]
+	if !ok || complete.Method != http.MethodPatch || complete.Target != first.Links.Complete.Href || complete.Properties == nil {
+		t.Fatalf("expected PATCH complete template on open todo, got %+v", first.Templates)
+	}
+	if del, ok := first.Templates["delete"]; !ok || del.Method != http.MethodDelete {
+		t.Fatalf("expected DELETE template on todo, got %+v", first.Templates)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, first.Links.Complete.Href, nil)
+	req.Header.Set("Accept", MediaTypeHALForms)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Header().Get(contentTypeHeader) != MediaTypeHALForms {
+		t.Fatalf("expected %s response, got %q", MediaTypeHALForms, rec.Header().Get(contentTypeHeader))
+	}
+	var completed Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &completed); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if _, ok := completed.Templates["complete"]; ok {
+		t.Fatalf("expected no complete template on a completed todo, got %+v", completed.Templates)
+	}
+}
+
+func TestMergeTodoHandler(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	req := httptest.NewRequest(http.MethodPost, "/todos/1/merge", strings.NewReader(`{"source_id":2}`))
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 from merge, got %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	var merged Todo
+	if err := json.Unmarshal(rec.Body.Bytes(), &merged); err != nil {
+		t.Fatalf("failed to unmarshal merged todo: %v", err)
+	}
+	if !strings.Contains(merged.Description, "HATEOAS") {
+		t.Fatalf("expected merged description to include the source description, got %q", merged.Description)
+	}
+
+	getReq := httptest.NewRequest(http.MethodGet, "/todos/2", nil)
+	getRec := httptest.NewRecorder()
+
+	r.ServeHTTP(getRec, getReq)
+
+	if getRec.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected status 301 for merged todo, got %d", getRec.Code)
+	}
+	if got := getRec.Header().Get("Location"); got != testBaseURL+"/todos/1" {
+		t.Fatalf("expected Location to point at survivor, got %q", got)
+	}
+}
+
+func TestMergeTodoHandlerErrors(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	cases := []struct {
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{path: "/todos/bad-id/merge", body: `{"source_id":2}`, wantStatus: http.StatusBadRequest},
+		{path: "/todos/1/merge", body: "{invalid-json", wantStatus: http.StatusBadRequest},
+		{path: "/todos/1/merge", body: `{}`, wantStatus: http.StatusBadRequest},
+		{path: "/todos/1/merge", body: `{"source_id":1}`, wantStatus: http.StatusBadRequest},
+		{path: "/todos/1/merge", body: `{"source_id":9999}`, wantStatus: http.StatusNotFound},
+		{path: "/todos/9999/merge", body: `{"source_id":2}`, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("POST %s %s: expected status %d, got %d", tc.path, tc.body, tc.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestMergeRespectsDescriptionLimit(t *testing.T) {
+	store := NewTodoStore()
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}), WithLimits(Limits{Description: 20}))
+
+	for _, body := range []string{
+		`{"title":"Target","description":"twelve chars"}`,
+		`{"title":"Source","description":"twelve chars"}`,
+		`{"title":"Imported","description":"ten chars!","source":"jira","external_id":"PROJ-1"}`,
+	} {
+		if rec := serve(r, http.MethodPost, todosPath, body, nil); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := serve(r, http.MethodPost, "/todos/1/merge", `{"source_id":2}`, nil)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a merged description over the limit, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if todos := allTodos(t, store); len(todos) != 3 || todos[0].Description != "twelve chars" {
+		t.Fatalf("expected the rejected merge to leave both todos unchanged, got %+v", todos)
+	}
+
+	rec = serve(r, http.MethodPost, "/todos/import?source=jira&on_conflict=merge", "external_id,title,description\nPROJ-1,Imported,more text!\n", http.Header{contentTypeHeader: {"text/csv"}})
+	var result ImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal import result: %v", err)
+	}
+	if result.Failed != 1 || !strings.Contains(result.Results[0].Error, "at most 20 characters") {
+		t.Fatalf("expected the merged row to fail over the limit, got %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), 3); got.Description != "ten chars!" {
+		t.Fatalf("expected the failed row to leave the todo unchanged, got %+v", got)
+	}
+}
+
+func TestSnapshotCursorPagination(t *testing.T) {
+	r := NewRouter(testBaseURL)
+
+	req := httptest.NewRequest(http.MethodGet, todosPath+"?per_page=2", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var first TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil {
+		t.Fatalf("failed to unmarshal todos collection: %v", err)
+	}
+	if first.Links.Snapshot == nil {
+		t.Fatalf("expected snapshot link on collection")
+	}
+	snapshotPath := strings.TrimPrefix(first.Links.Snapshot.Href, testBaseURL)
+
+	// Mutate the store after pinning the snapshot.
+	createReq := httptest.NewRequest(http.MethodPost, todosPath, strings.NewReader(`{"title":"Late"}`))
+	createReq.Header.Set(contentTypeHeader, contentTypeJSON)
+	r.ServeHTTP(httptest.NewRecorder(), createReq)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/todos/1", nil))
+
+	var ids []int
+	path := snapshotPath
+	for path != "" {
+		pageReq := httptest.NewRequest(http.MethodGet, path, nil)
+		pageRec := httptest.NewRecorder()
+		r.ServeHTTP(pageRec, pageReq)
+
+		if pageRec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d; body=%s", path, pageRec.Code, pageRec.Body.String())
+		}
+
+		var page TodoCollection
+		if err := json.Unmarshal(pageRec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("failed to unmarshal snapshot page: %v", err)
+		}
+		for _, todo := range page.Todos {
+			ids = append(ids, todo.ID)
+		}
+
+		path = ""
+		if page.Links.Next != nil {
+			path = strings.TrimPrefix(page.Links.Next.Href, testBaseURL)
+		}
+	}
+
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Fatalf("expected snapshot listing to contain the original todos [1 2 3], got %v", ids)
+	}
+
+	badReq := httptest.NewRequest(http.MethodGet, todosPath+"?cursor=not-a-cursor", nil)
+	badRec := httptest.NewRecorder()
+	r.ServeHTTP(badRec, badReq)
+
+	if badRec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for malformed cursor, got %d", badRec.Code)
+	}
+}
+
+func TestTodoStoreListAtExpiredSnapshot(t *testing.T) {
+	store := NewTodoStore()
+	createTodo(t, store, TodoInput{Title: "Pinned"})
+	_, snapshot := store.Snapshot(t.Context())
+
+	for i := 0; i <= maxTombstones; i++ {
+		created := createTodo(t, store, TodoInput{Title: "Churn"})
+		store.Delete(t.Context(), created.ID)
+	}
+
+	if _, ok := store.ListAt(t.Context(), snapshot); ok {
+		t.Fatalf("expected snapshot %d to have expired", snapshot)
+	}
+	if _, ok := store.ListAt(t.Context(), snapshot+1_000_000); ok {
+		t.Fatalf("expected future snapshot to be rejected")
+	}
+}
+
+func TestCollectionTruncatesLongDescriptions(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	long := strings.Repeat("x", descriptionPreviewLength+50)
+
+	body := fmt.Sprintf(`{"title":"Long","description":%q}`, long)
+	createReq := httptest.NewRequest(http.MethodPost, todosPath, strings.NewReader(body))
+	createReq.Header.Set(contentTypeHeader, contentTypeJSON)
+	createRec := httptest.NewRecorder()
+	r.ServeHTTP(createRec, createReq)
+
+	var created Todo
+	if err := json.Unmarshal(createRec.Body.Bytes(), &created); err != nil {
+		t.Fatalf(deleteMsgFormat, err)
+	}
+	if created.Description != long || created.DescriptionTruncated {
+		t.Fatalf("expected full description on item representation")
+	}
+
+	listReq := httptest.NewRequest(http.MethodGet, todosPath, nil)
+	listRec := httptest.NewRecorder()
+	r.ServeHTTP(listRec, listReq)
+
+	var collection TodoCollection
+	if err := json.Unmarshal(listRec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal todos collection: %v", err)
+	}
+
+	for _, todo := range collection.Todos {
+		if todo.ID != created.ID {
+			if todo.DescriptionTruncated || todo.Links.Full != nil {
+				t.Fatalf("expected short description of todo %d to be untouched", todo.ID)
+			}
+			continue
+		}
+		if !todo.DescriptionTruncated {
+			t.Fatalf("expected long description to be truncated in listing")
+		}
+		if len([]rune(todo.Description)) != descriptionPreviewLength+1 {
+			t.Fatalf("expected preview of %d characters plus ellipsis, got %d", descriptionPreviewLength, len([]rune(todo.Description)))
+		}
+		if todo.Links.Full == nil || todo.Links.Full.Href != fmt.Sprintf(testBaseURL+todosIDFormat, created.ID) {
+			t.Fatalf("expected full link to the item, got %+v", todo.Links.Full)
+		}
+		return
+	}
+	t.Fatalf("created todo %d not found in listing", created.ID)
+}
+
+func TestGetTodosInvalidQueryParameters(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	req := httptest.NewRequest(http.MethodGet, todosPath+"?page=abc&per_page=500", nil)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid query parameters, got %d", rec.Code)
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if len(errResp.Errors) != 2 || errResp.Errors[0].Field != "page" || errResp.Errors[1].Field != "per_page" {
+		t.Fatalf("expected errors for page and per_page, got %+v", errResp.Errors)
+	}
+}
+
+type panickingPayload struct{}
+
+func (panickingPayload) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+func TestRespondHandlesEncodeFailures(t *testing.T) {
+	api := NewTodoAPI(testBaseURL, NewService(NewTodoStore()))
+
+	payloads := map[string]any{
+		"unsupported type": map[string]any{"ch": make(chan int)},
+		"panic":            panickingPayload{},
+	}
+
+	for name, payload := range payloads {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		api.respond(rec, req, http.StatusOK, payload)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: expected status 500, got %d", name, rec.Code)
+		}
+
+		var errResp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("%s: expected JSON error body, got %q", name, rec.Body.String())
+		}
+	}
+}
+
+func TestStoreUsesInjectedClock(t *testing.T) {
+	start := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+	clock := NewManualClock(start)
+	store := NewTodoStoreWithClock(clock)
+
+	first := createTodo(t, store, TodoInput{Title: "First"})
+	clock.Advance(time.Hour)
+	second := createTodo(t, store, TodoInput{Title: "Second"})
+
+	if !first.CreatedAt.Equal(start) {
+		t.Fatalf("expected first todo to be created at %v, got %v", start, first.CreatedAt)
+	}
+	if !second.CreatedAt.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected second todo to be created an hour later, got %v", second.CreatedAt)
+	}
+}
+
+// listOnlyStore wraps a Store without exposing snapshot support.
+type listOnlyStore struct {
+	Store
+}
+
+func TestNewStoreFromConfig(t *testing.T) {
+	store, err := NewStoreFromConfig(StoreConfig{})
+	if err != nil {
+		t.Fatalf("expected default backend to be available, got %v", err)
+	}
+	if _, ok := store.(*TodoStore); !ok {
+		t.Fatalf("expected in-memory TodoStore by default, got %T", store)
+	}
+
+	if _, err := NewStoreFromConfig(StoreConfig{Backend: "does-not-exist"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("expected ErrUnknownBackend, got %v", err)
+	}
+
+	RegisterStore("test-list-only", func(cfg StoreConfig) (Store, error) {
+		return listOnlyStore{NewTodoStore()}, nil
+	})
+	custom, err := NewStoreFromConfig(StoreConfig{Backend: "test-list-only"})
+	if err != nil {
+		t.Fatalf("expected registered backend to be constructed, got %v", err)
+	}
+
+	r := NewRouter(testBaseURL, WithStore(custom))
+	req := httptest.NewRequest(http.MethodGet, todosPath, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var collection TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal todos collection: %v", err)
+	}
+	if collection.Meta.Total != 3 {
+		t.Fatalf("expected seeded todos in custom store, got %d", collection.Meta.Total)
+	}
+	if collection.Links.Snapshot != nil {
+		t.Fatalf("expected no snapshot link for a store without snapshot support")
+	}
+}
+
+func TestMilestoneProgress(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	r := NewRouter(testBaseURL, WithClock(clock))
+
+	createReq := httptest.NewRequest(http.MethodPost, "/milestones", strings.NewReader(`{"name":"Sprint 1","start_date":"2024-03-01","end_date":"2024-03-15"}`))
+	createReq.Header.Set(contentTypeHeader, contentTypeJSON)
+	createRec := httptest.NewRecorder()
+	r.ServeHTTP(createRec, createReq)
+
+	if createRec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d; body=%s", createRec.Code, createRec.Body.String())
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/todos/3/complete", nil))
+	for _, id := range []int{1, 2, 3} {
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/milestones/1/todos/%d", id), nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 assigning todo %d, got %d", id, rec.Code)
+		}
+	}
+
+	todoRec := httptest.NewRecorder()
+	r.ServeHTTP(todoRec, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	var todo Todo
+	if err := json.Unmarshal(todoRec.Body.Bytes(), &todo); err != nil {
+		t.Fatalf("failed to unmarshal todo: %v", err)
+	}
+	if todo.Links.Milestone == nil || todo.Links.Milestone.Href != testBaseURL+"/milestones/1" {
+		t.Fatalf("expected milestone link on member todo, got %+v", todo.Links.Milestone)
+	}
+
+	progressRec := httptest.NewRecorder()
+	r.ServeHTTP(progressRec, httptest.NewRequest(http.MethodGet, "/milestones/1/progress", nil))
+	var progress MilestoneProgress
+	if err := json.Unmarshal(progressRec.Body.Bytes(), &progress); err != nil {
+		t.Fatalf("failed to unmarshal progress: %v", err)
+	}
+	if progress.Total != 3 || progress.Completed != 1 || progress.Remaining != 2 {
+		t.Fatalf("unexpected progress counts: %+v", progress)
+	}
+	if progress.PercentComplete != 33.3 {
+		t.Fatalf("expected 33.3 percent complete, got %v", progress.PercentComplete)
+	}
+	if progress.DaysRemaining != 10 {
+		t.Fatalf("expected 10 days remaining, got %d", progress.DaysRemaining)
+	}
+
+	cases := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{http.MethodPost, "/milestones", `{"start_date":"2024-03-01","end_date":"2024-03-15"}`, http.StatusBadRequest},
+		{http.MethodPost, "/milestones", `{"name":"Backwards","start_date":"2024-03-15","end_date":"2024-03-01"}`, http.StatusBadRequest},
+		{http.MethodPost, "/milestones", `{"name":"Bad date","start_date":"March 1","end_date":"2024-03-15"}`, http.StatusBadRequest},
+		{http.MethodGet, "/milestones/99/progress", "", http.StatusNotFound},
+		{http.MethodPut, "/milestones/1/todos/99", "", http.StatusNotFound},
+		{http.MethodPut, "/milestones/99/todos/1", "", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.wantStatus, rec.Code)
+		}
+	}
+}
+
+// unlistableStore is a Store whose listings of all todos fail with err.
+type unlistableStore struct {
+	Store
+	err error
+}
+
+func (s unlistableStore) GetAll(context.Context) ([]*Todo, error) {
+	return nil, s.err
+}
+
+func TestMilestoneProgressReportsListingErrors(t *testing.T) {
+	r := NewRouter(testBaseURL, WithStore(unlistableStore{Store: NewTodoStore(), err: context.DeadlineExceeded}), WithSeeder(func(Service) {}))
+	if rec := serve(r, http.MethodPost, "/milestones", `{"name":"Sprint 1","start_date":"2024-03-01","end_date":"2024-03-15"}`, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(r, http.MethodGet, "/milestones/1/progress", "", nil); rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status 504 when the todos cannot be listed in time, got %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	store := downStore{Store: NewTodoStore(), down: new(atomic.Bool)}
+	r = NewRouter(testBaseURL, WithStore(store))
+	serve(r, http.MethodPost, "/milestones", `{"name":"Sprint 1","start_date":"2024-03-01","end_date":"2024-03-15"}`, nil)
+	serve(r, http.MethodPut, "/milestones/1/todos/1", "", nil)
+	serve(r, http.MethodGet, todosPath, "", nil)
+
+	store.down.Store(true)
+	rec := serve(r, http.MethodGet, "/milestones/1/progress", "", nil)
+	var progress MilestoneProgress
+	if err := json.Unmarshal(rec.Body.Bytes(), &progress); err != nil {
+		t.Fatalf("failed to unmarshal progress: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("Warning") != staleWarning || progress.Total != 1 {
+		t.Fatalf("expected progress from the remembered todos marked stale, got %d %q %+v", rec.Code, rec.Header().Get("Warning"), progress)
+	}
+}
+
+func TestLocalizedDisplay(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC))
+	r := NewRouter(testBaseURL, WithClock(clock), WithSeeder(func(s Service) {
+		s.CreateTodo(t.Context(), TodoInput{Title: "A"})
+	}))
+	clock.Advance(3*24*time.Hour + time.Hour)
+
+	get := func(target, acceptLanguage string) (*httptest.ResponseRecorder, Todo) {
+		t.Helper()
+		rec := serve(r, http.MethodGet, target, "", http.Header{"Accept-Language": {acceptLanguage}})
+		var todo Todo
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+				t.Fatalf("failed to unmarshal todo: %v", err)
+			}
+		}
+		return rec, todo
+	}
+
+	if _, todo := get("/todos/1", ""); todo.Display != nil {
+		t.Fatalf("expected no display fields without preferences, got %+v", todo.Display)
+	}
+
+	tests := []struct {
+		target, acceptLanguage  string
+		language, date, created string
+	}{
+		{"/todos/1", "de-CH, en;q=0.8", "de", "5. März 2024", "vor 3 Tagen"},
+		{"/todos/1?datefmt=short", "", "en", "03/05/2024", "3 days ago"},
+		{"/todos/1?datefmt=long", "fr", "fr", "5 mars 2024 à 14:30 UTC", "il y a 3 jours"},
+		{"/todos/1?datefmt=medium", "ja, es;q=0.5", "es", "5 mar 2024", "hace 3 días"},
+		{"/todos/1", "ja", "en", "Mar 5, 2024", "3 days ago"},
+	}
+	for _, tt := range tests {
+		rec, todo := get(tt.target, tt.acceptLanguage)
+		if todo.Display == nil || todo.Display.CreatedAt != tt.date || todo.Display.Created != tt.created {
+			t.Fatalf("%s with %q: unexpected display fields %+v", tt.target, tt.acceptLanguage, todo.Display)
+		}
+		if got := rec.Header().Get("Content-Language"); got != tt.language {
+			t.Fatalf("%s with %q: expected Content-Language %q, got %q", tt.target, tt.acceptLanguage, tt.language, got)
+		}
+	}
+
+	if _, todo := get("/todos/1?datefmt=iso", "de"); todo.Display != nil {
+		t.Fatalf("expected datefmt=iso to omit display fields, got %+v", todo.Display)
+	}
+	if rec, _ := get("/todos/1?datefmt=fancy", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid datefmt to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	en := locales["en"]
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(-30 * time.Second), "just now"},
+		{now.Add(-time.Minute), "1 minute ago"},
+		{now.Add(-5 * time.Hour), "5 hours ago"},
+		{now.Add(-15 * 24 * time.Hour), "2 weeks ago"},
+		{now.Add(-400 * 24 * time.Hour), "1 year ago"},
+		{now.Add(3 * 24 * time.Hour), "in 3 days"},
+	}
+	for _, tt := range tests {
+		if got := en.formatRelative(tt.at, now); got != tt.want {
+			t.Fatalf("formatRelative(%v): expected %q, got %q", tt.at, tt.want, got)
+		}
+	}
+}
+
+func TestForwardedBaseURL(t *testing.T) {
+	r := NewRouter(testBaseURL, WithForwardedBaseURL())
+
+	cases := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{nil, "http://example.com/todos/1"},
+		{map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "todos.example.org"}, "https://todos.example.org/todos/1"},
+		{map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Prefix": "/api/"}, "https://example.com/api/todos/1"},
+		{map[string]string{"X-Forwarded-Proto": "gopher", "X-Forwarded-Host": "evil.example/x", "X-Forwarded-Prefix": "//evil"}, "http://example.com/todos/1"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		var todo Todo
+		if err := json.Unmarshal(rec.Body.Bytes(), &todo); err != nil {
+			t.Fatalf("failed to unmarshal todo: %v", err)
+		}
+		if todo.Links.Self.Href != tc.want {
+			t.Fatalf("headers %v: expected self link %q, got %q", tc.headers, tc.want, todo.Links.Self.Href)
+		}
+	}
+}
+
+// brokenConnection is a ResponseWriter whose client went away: writes of
+// the body fail.
+type brokenConnection struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenConnection) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestAdminBackupAbortsFailedStream(t *testing.T) {
+	r := NewRouter(testBaseURL, WithAdminToken("s3cret"))
+	req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("expected a failed stream to abort the response, got %v", p)
+		}
+	}()
+	r.ServeHTTP(brokenConnection{httptest.NewRecorder()}, req)
+	t.Fatalf("expected a failed stream to abort the response")
+}
+
+func TestAdminBackupRestore(t *testing.T) {
+	const token = "s3cret"
+	authorized := http.Header{"Authorization": {"Bearer " + token}}
+	if rec := serve(NewRouter(testBaseURL), http.MethodGet, "/admin/backup", "", authorized); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected admin endpoints to be disabled without a token, got %d", rec.Code)
+	}
+
+	source := NewRouter(testBaseURL, WithAdminToken(token))
+	if rec := serve(source, http.MethodGet, "/admin/backup", "", http.Header{"Authorization": {"Bearer wrong"}}); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 with a challenge for a wrong token, got %d", rec.Code)
+	}
+
+	backupRec := serve(source, http.MethodGet, "/admin/backup", "", authorized)
+	if backupRec.Code != http.StatusOK {
+		t.Fatalf("expected backup status 200, got %d", backupRec.Code)
+	}
+	if !strings.HasPrefix(backupRec.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("expected backup to be served as an attachment")
+	}
+	var backup Backup
+	if err := json.Unmarshal(backupRec.Body.Bytes(), &backup); err != nil {
+		t.Fatalf("failed to unmarshal backup: %v", err)
+	}
+	if len(backup.Todos) != 3 {
+		t.Fatalf("expected seeded todos in backup, got %d", len(backup.Todos))
+	}
+
+	store := NewTodoStore()
+	target := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}), WithAdminToken(token))
+	createTodo(t, store, TodoInput{Title: "Replaced"})
+
+	restoreRec := serve(target, http.MethodPost, "/admin/restore", backupRec.Body.String(), authorized)
+	if restoreRec.Code != http.StatusOK {
+		t.Fatalf("expected restore status 200, got %d: %s", restoreRec.Code, restoreRec.Body.String())
+	}
+	all := allTodos(t, store)
+	if len(all) != 3 || all[0].Title != backup.Todos[0].Title {
+		t.Fatalf("expected backup to replace store content, got %+v", all)
+	}
+
+	if rec := serve(target, http.MethodPost, "/admin/restore", `{"todos":[{"id":1}]}`, authorized); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid backup to be rejected with 400, got %d", rec.Code)
+	}
+	if rec := serve(target, http.MethodPost, "/admin/restore", `{"items":[]}`, authorized); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown fields to be rejected with 400, got %d", rec.Code)
+	}
+
+	unsupported := NewRouter(testBaseURL, WithStore(listOnlyStore{NewTodoStore()}), WithAdminToken(token))
+	if rec := serve(unsupported, http.MethodGet, "/admin/backup", "", authorized); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for a store without backup support, got %d", rec.Code)
+	}
+}
+
+func TestAdminCompact(t *testing.T) {
+	const token = "s3cret"
+	admin := func(r http.Handler, method, path string) (*httptest.ResponseRecorder, CompactJob) {
+		rec := serve(r, method, path, "", http.Header{"Authorization": {"Bearer " + token}})
+		var job CompactJob
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		return rec, job
+	}
+
+	store, err := RecoverFrom(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}), WithAdminToken(token), WithSlowStoreLog(time.Hour, nil))
+	for i := range 50 {
+		created := createTodo(t, store, TodoInput{Title: fmt.Sprintf("Todo %d", i), Description: strings.Repeat("x", 1000)})
+		store.Delete(t.Context(), created.ID)
+	}
+
+	rec, job := admin(r, http.MethodPost, "/admin/compact")
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != job.Links.Self.Href || job.SizeBefore == 0 {
+		t.Fatalf("expected 202 with the job, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	path := strings.TrimPrefix(job.Links.Self.Href, testBaseURL)
+	for deadline := time.Now().Add(5 * time.Second); job.Status == CompactJobRunning; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("compaction did not finish: %+v", job)
+		}
+		_, job = admin(r, http.MethodGet, path)
+	}
+	if job.Status != CompactJobCompleted || job.SizeAfter == nil || *job.SizeAfter >= job.SizeBefore || *job.Reclaimed != job.SizeBefore-*job.SizeAfter || job.FinishedAt == nil {
+		t.Fatalf("unexpected finished compaction: %+v", job)
+	}
+
+	if rec, _ := admin(r, http.MethodGet, "/admin/compact/unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown compaction, got %d", rec.Code)
+	}
+	unsupported := NewRouter(testBaseURL, WithAdminToken(token))
+	if rec, _ := admin(unsupported, http.MethodPost, "/admin/compact"); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for a store without compaction, got %d", rec.Code)
+	}
+}
+
+func TestWALStoreLogsBeforeApplying(t *testing.T) {
+	store, err := RecoverFrom(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	created := createTodo(t, store, TodoInput{Title: "Logged"})
+	store.log.Close()
+
+	if _, err := store.Update(t.Context(), created.ID, TodoInput{Title: "Not logged"}); err == nil {
+		t.Fatalf("expected a failed log write to fail the update")
+	}
+	if _, err := store.Create(t.Context(), TodoInput{Title: "Not logged"}); err == nil {
+		t.Fatalf("expected a failed log write to fail the creation")
+	}
+	if todo, _ := store.GetByID(t.Context(), created.ID); todo.Title != "Logged" || todo.Version != 1 {
+		t.Fatalf("expected a change that was not logged not to apply, got %+v", todo)
+	}
+	if all := allTodos(t, store); len(all) != 1 {
+		t.Fatalf("expected no todo to be added, got %+v", all)
+	}
+}
+
+// failingLog is a write-ahead log whose writes stop halfway through and
+// fail while failing is set, and whose truncation fails with truncateErr.
+type failingLog struct {
+	walFile
+	failing     bool
+	truncateErr error
+}
+
+func (f *failingLog) Write(b []byte) (int, error) {
+	if !f.failing {
+		return f.walFile.Write(b)
+	}
+	n, _ := f.walFile.Write(b[:len(b)/2])
+	return n, errors.New("disk full")
+}
+
+func (f *failingLog) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.walFile.Truncate(size)
+}
+
+func TestWALStoreCutsFailedRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := RecoverFrom(dir, nil)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	failing := &failingLog{walFile: store.log}
+	store.log = failing
+
+	first := createTodo(t, store, TodoInput{Title: "First"})
+	failing.failing = true
+	if _, err := store.Create(t.Context(), TodoInput{Title: "Failed"}); err == nil {
+		t.Fatalf("expected a failed log write to fail the creation")
+	}
+	failing.failing = false
+	second := createTodo(t, store, TodoInput{Title: "Second"})
+
+	// Recover from a copy, as after a crash.
+	crashed := t.TempDir()
+	if err := os.CopyFS(crashed, os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := RecoverFrom(crashed, nil)
+	if err != nil {
+		t.Fatalf("failed to recover after a failed log write: %v", err)
+	}
+	defer recovered.Close()
+	all := allTodos(t, recovered)
+	if len(all) != 2 || all[0].ID != first.ID || all[1].ID != second.ID || all[1].Title != "Second" {
+		t.Fatalf("expected the acknowledged todos and only them to be recovered, got %+v", all)
+	}
+
+	failing.failing, failing.truncateErr = true, errors.New("read-only file system")
+	if _, err := store.Create(t.Context(), TodoInput{Title: "Failed"}); err == nil {
+		t.Fatalf("expected a failed log write to fail the creation")
+	}
+	failing.failing = false
+	if _, err := store.Update(t.Context(), first.ID, TodoInput{Title: "Refused"}); err == nil {
+		t.Fatalf("expected a log that could not be cut to take no more records")
+	}
+}
+
+func TestWALStoreRestoreWritesSnapshotFirst(t *testing.T) {
+	dir := t.TempDir()
+	store, err := RecoverFrom(dir, nil)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	createTodo(t, store, TodoInput{Title: "Kept"})
+	createTodo(t, store, TodoInput{Title: "Also kept"})
+
+	// A directory in the way of the snapshot makes writing it fail.
+	if err := os.MkdirAll(filepath.Join(dir, walSnapshotFile, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	restored := Backup{NextID: 2, Todos: []BackupTodo{{ID: 1, Title: "Restored", CreatedAt: time.Now()}}}
+	if err := store.Restore(t.Context(), restored); err == nil {
+		t.Fatalf("expected the restore to fail when the snapshot cannot be written")
+	}
+	if all := allTodos(t, store); len(all) != 2 || all[0].Title != "Kept" {
+		t.Fatalf("expected a failed restore to keep the content, got %+v", all)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, walSnapshotFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Restore(t.Context(), restored); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	crashed := t.TempDir()
+	if err := os.CopyFS(crashed, os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := RecoverFrom(crashed, nil)
+	if err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	defer recovered.Close()
+	if all := allTodos(t, recovered); len(all) != 1 || all[0].Title != "Restored" {
+		t.Fatalf("expected the restored content to be recovered, got %+v", all)
+	}
+	if next := createTodo(t, recovered, TodoInput{Title: "Next"}); next.ID != 3 {
+		t.Fatalf("expected recovery not to reuse IDs, got %d", next.ID)
+	}
+}
+
+func TestWALStorePrunesArchive(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	store, err := RecoverFrom(dir, clock)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	store.keepArchived = 2
+
+	for i := range 4 {
+		createTodo(t, store, TodoInput{Title: fmt.Sprintf("Todo %d", i)})
+		clock.Advance(time.Hour)
+		if err := store.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+	}
+	generations, err := archivedGenerations(filepath.Join(dir, walArchiveDir))
+	if err != nil || !slices.Equal(generations, []int{3, 4}) {
+		t.Fatalf("expected the last two generations to be kept, got %v, %v", generations, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, walArchiveDir)); len(entries) != 4 {
+		t.Fatalf("expected the logs of pruned generations to be deleted, got %d files", len(entries))
+	}
+
+	if _, _, err := PlanPointInTime(dir, clock.Now().Add(-3*time.Hour)); err == nil || !strings.Contains(err.Error(), "reaches back to") {
+		t.Fatalf("expected a restore before the kept generations to fail, got %v", err)
+	}
+	b, _, err := PlanPointInTime(dir, clock.Now().Add(-90*time.Minute))
+	if err != nil || len(b.Todos) != 3 {
+		t.Fatalf("expected a restore within the kept generations to succeed, got %+v, %v", b, err)
+	}
+}
+
+func TestWALStoreDiskSizeCountsArchive(t *testing.T) {
+	dir := t.TempDir()
+	store, err := RecoverFrom(dir, nil)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for range 2 {
+		createTodo(t, store, TodoInput{Title: "Archived"})
+		if err := store.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+	}
+
+	var want int64
+	err = filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		want += info.Size()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.DiskSize(); err != nil || got != want {
+		t.Fatalf("expected the size of all files including the archive, %d bytes, got %d, %v", want, got, err)
+	}
+}
+
+func TestImportTodos(t *testing.T) {
+	store := NewTodoStore()
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}))
+
+	rec := serve(r, http.MethodPost, "/todos/import", `[{"title":"One"},{"description":"no title"},{"title":"Two","description":"second"}]`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result ImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal import result: %v", err)
+	}
+	if result.Created != 2 || result.Failed != 1 || len(result.Results) != 3 {
+		t.Fatalf("unexpected import counts: %+v", result)
+	}
+	if result.Results[0].ID == 0 || result.Results[1].Error == "" || result.Results[1].Row != 2 {
+		t.Fatalf("unexpected row results: %+v", result.Results)
+	}
+
+	rec = serve(r, http.MethodPost, "/todos/import", "Description,Title\nfrom csv,Three\n,\n\"quoted, desc\",Four\n", http.Header{contentTypeHeader: {"text/csv; charset=utf-8"}})
+	result = ImportResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal import result: %v", err)
+	}
+	if result.Created != 2 || result.Failed != 1 {
+		t.Fatalf("unexpected CSV import counts: %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), result.Results[2].ID); got.Title != "Four" || got.Description != "quoted, desc" {
+		t.Fatalf("unexpected todo from CSV row: %+v", got)
+	}
+	if len(allTodos(t, store)) != 4 {
+		t.Fatalf("expected 4 imported todos, got %d", len(allTodos(t, store)))
+	}
+
+	rec = serve(r, http.MethodPost, "/todos/import", "title,status\nShipped,archived\nDone,Completed\nBogus,closed\n", http.Header{contentTypeHeader: {"text/csv"}})
+	result = ImportResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal import result: %v", err)
+	}
+	if result.Created != 2 || result.Failed != 1 || result.Results[2].Error == "" {
+		t.Fatalf("unexpected status import counts: %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), result.Results[0].ID); got.State() != StateArchived {
+		t.Fatalf("expected imported todo to be archived, got %+v", got)
+	}
+	if got, _ := store.GetByID(t.Context(), result.Results[1].ID); got.State() != StateCompleted {
+		t.Fatalf("expected imported todo to be completed, got %+v", got)
+	}
+
+	if rec := serve(r, http.MethodPost, "/todos/import", "name\nx\n", http.Header{contentTypeHeader: {"text/csv"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected CSV without title column to be rejected, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPost, "/todos/import", `{"title":"not an array"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected non-array JSON to be rejected, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodPost, "/todos/import", "<todos/>", http.Header{contentTypeHeader: {"application/xml"}}); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected unsupported content type to be rejected with 415, got %d", rec.Code)
+	}
+}
+
+func TestImportExternalIDConflicts(t *testing.T) {
+	store := NewTodoStore()
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}))
+
+	importCSV := func(query, body string) ImportResult {
+		t.Helper()
+		rec := serve(r, http.MethodPost, "/todos/import"+query, body, http.Header{contentTypeHeader: {"text/csv"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result ImportResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to unmarshal import result: %v", err)
+		}
+		return result
+	}
+
+	result := importCSV("?source=jira", "external_id,title,description\nPROJ-1,First,original\nPROJ-2,Second,\n")
+	if result.Created != 2 || result.Results[0].Outcome != UpsertCreated {
+		t.Fatalf("unexpected first import: %+v", result)
+	}
+	id := result.Results[0].ID
+	if got, _ := store.GetByID(t.Context(), id); got.Source != "jira" || got.ExternalID != "PROJ-1" {
+		t.Fatalf("expected default source to be applied, got %+v", got)
+	}
+
+	result = importCSV("?source=jira", "external_id,title,description\nPROJ-1,Renamed,changed\nPROJ-3,Third,\n")
+	if result.Created != 1 || result.Skipped != 1 || result.Results[0].ID != id || result.Results[0].Outcome != UpsertSkipped {
+		t.Fatalf("unexpected re-import with skip: %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), id); got.Title != "First" {
+		t.Fatalf("expected skipped row to leave the todo unchanged, got %+v", got)
+	}
+
+	result = importCSV("?source=jira&on_conflict=merge", "external_id,title,description\nPROJ-1,Renamed,changed\n")
+	if result.Updated != 1 {
+		t.Fatalf("unexpected re-import with merge: %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), id); got.Title != "First" || got.Description != "original\n\nchanged" {
+		t.Fatalf("expected merge to append the description, got %+v", got)
+	}
+	if result := importCSV("?source=jira&on_conflict=merge", "external_id,title,description\nPROJ-1,Renamed,changed\n"); result.Skipped != 1 {
+		t.Fatalf("expected repeated merge to be skipped, got %+v", result)
+	}
+
+	result = importCSV("?source=jira&on_conflict=overwrite", "external_id,title,description\nPROJ-1,Renamed,replaced\n")
+	if result.Updated != 1 {
+		t.Fatalf("unexpected re-import with overwrite: %+v", result)
+	}
+	if got, _ := store.GetByID(t.Context(), id); got.Title != "Renamed" || got.Description != "replaced" {
+		t.Fatalf("expected overwrite to replace the todo, got %+v", got)
+	}
+	if len(allTodos(t, store)) != 3 {
+		t.Fatalf("expected re-imports not to duplicate todos, got %d", len(allTodos(t, store)))
+	}
+
+	if result := importCSV("", "external_id,title\nPROJ-9,No source\n"); result.Failed != 1 {
+		t.Fatalf("expected an external ID without source to fail, got %+v", result)
+	}
+	if rec := serve(r, http.MethodPost, "/todos/import?on_conflict=replace", "title\nx\n", http.Header{contentTypeHeader: {"text/csv"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown conflict strategy to be rejected, got %d", rec.Code)
+	}
+
+	rec := serve(r, http.MethodPost, "/todos", `{"title":"Dup","source":"jira","external_id":"PROJ-2"}`, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate external ID to be rejected with 409, got %d", rec.Code)
+	}
+	rec = serve(r, http.MethodPost, "/todos", `{"title":"New","source":"github","external_id":"PROJ-2"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected the same external ID from another source to be created, got %d", rec.Code)
+	}
+}
+
+func TestServicePublishesEvents(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	bus := events.NewBus()
+	var got []events.Event
+	bus.Subscribe(func(e events.Event) { got = append(got, e) })
+
+	service := NewPublishingService(NewTodoStoreWithClock(clock), clock, bus)
+	a, _ := service.CreateTodo(t.Context(), TodoInput{Title: "A"})
+	b, _ := service.CreateTodo(t.Context(), TodoInput{Title: "B", Description: "b"})
+	service.UpdateTodo(t.Context(), a.ID, TodoInput{Title: "A2"})
+	service.TransitionTodo(t.Context(), a.ID, TransitionComplete)
+	service.MergeTodos(t.Context(), a.ID, b.ID)
+	service.DeleteTodo(t.Context(), a.ID)
+
+	service.UpdateTodo(t.Context(), 999, TodoInput{Title: "missing"})
+	service.DeleteTodo(t.Context(), 999)
+
+	want := []events.Type{
+		events.TypeTodoCreated, events.TypeTodoCreated, events.TypeTodoUpdated,
+		events.TypeTodoCompleted, events.TypeTodoMerged, events.TypeTodoDeleted,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(got), got)
+	}
+	for i, e := range got {
+		if e.Type() != want[i] {
+			t.Fatalf("event %d: expected %s, got %s", i, want[i], e.Type())
+		}
+		if !e.Time().Equal(clock.Now()) {
+			t.Fatalf("event %d: expected time %v, got %v", i, clock.Now(), e.Time())
+		}
+	}
+	if merged := got[4].(events.TodoMerged); merged.SourceID != b.ID || merged.Target.Description != "b" {
+		t.Fatalf("unexpected merge event: %+v", merged)
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	r := NewRouter(testBaseURL)
+	feed := func(path string) ChangeFeed {
+		rec := serve(r, http.MethodGet, path, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d", path, rec.Code)
+		}
+		var feed ChangeFeed
+		if err := json.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+			t.Fatalf("failed to unmarshal change feed: %v", err)
+		}
+		return feed
+	}
+
+	seeded := feed("/changes")
+	if seeded.Meta.Latest != 3 || len(seeded.Changes) != 3 || seeded.Changes[0].Type != events.TypeTodoCreated {
+		t.Fatalf("expected the seeded todos as the first changes, got %+v", seeded)
+	}
+
+	serve(r, http.MethodPut, "/todos/1", `{"title":"Renamed"}`, nil)
+	serve(r, http.MethodDelete, "/todos/2", "", nil)
+
+	page := feed("/changes?since=3&limit=1")
+	if len(page.Changes) != 1 || !page.Meta.More || page.Changes[0].Seq != 4 {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	if c := page.Changes[0]; c.Type != events.TypeTodoUpdated || c.Todo == nil || c.Todo.Title != "Renamed" || c.Todo.Links.Self == nil {
+		t.Fatalf("unexpected update change: %+v", c)
+	}
+	if page.Links.Next.Href != testBaseURL+"/changes?since=4&limit=1" {
+		t.Fatalf("unexpected next link %q", page.Links.Next.Href)
+	}
+
+	rest := feed("/changes?since=4")
+	if len(rest.Changes) != 1 || rest.Meta.More || rest.Changes[0].Type != events.TypeTodoDeleted || rest.Changes[0].TodoID != 2 {
+		t.Fatalf("unexpected remaining changes: %+v", rest)
+	}
+	if empty := feed("/changes?since=5"); len(empty.Changes) != 0 || empty.Links.Next.Href != testBaseURL+"/changes?since=5&limit=100" {
+		t.Fatalf("expected an empty feed at the latest sequence, got %+v", empty)
+	}
+
+	if rec := serve(r, http.MethodGet, "/changes?since=99", "", nil); rec.Code != http.StatusGone {
+		t.Fatalf("expected 410 for a sequence ahead of the log, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodGet, "/changes?since=-1", "", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative sequence, got %d", rec.Code)
+	}
+}
+
+func TestChangeLogRetention(t *testing.T) {
+	log := newChangeLog(2)
+	for id := 1; id <= 3; id++ {
+		log.record(events.TodoDeleted{ID: id})
+	}
+
+	if _, _, ok := log.since(0, 10); ok {
+		t.Fatalf("expected trimmed changes to be unavailable")
+	}
+	changes, latest, ok := log.since(1, 10)
+	if !ok || latest != 3 || len(changes) != 2 || changes[0].seq != 2 {
+		t.Fatalf("unexpected retained changes: %+v, %d, %v", changes, latest, ok)
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	const token = "s3cret"
+	var admin http.Handler
+	public := NewRouter(testBaseURL, WithAdminToken(token), WithAdminListener(func(h http.Handler) { admin = h }))
+	if admin == nil {
+		t.Fatalf("expected the admin handler to be passed to the listener")
+	}
+	authorized := http.Header{"Authorization": {"Bearer " + token}}
+
+	if rec := serve(public, http.MethodGet, "/admin/backup", "", authorized); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected /admin to be absent from the public router, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/admin/backup", "", authorized); rec.Code != http.StatusOK {
+		t.Fatalf("expected backup on the admin listener, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/debug/pprof/", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the profiler to require the admin token, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/debug/pprof/", "", authorized); rec.Code != http.StatusOK {
+		t.Fatalf("expected the profiler on the admin listener, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, todosPath, "", authorized); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the public API to be absent from the admin listener, got %d", rec.Code)
+	}
+}
+
+func TestEncryptedBackupRestore(t *testing.T) {
+	const token = "s3cret"
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	sealed := http.Header{"Authorization": {"Bearer " + token}, contentTypeHeader: {"application/octet-stream"}}
+
+	source := NewRouter(testBaseURL, WithAdminToken(token), WithBackupEncryption([]age.Recipient{identity.Recipient()}, nil))
+	backupRec := serve(source, http.MethodGet, "/admin/backup", "", sealed)
+	if backupRec.Code != http.StatusOK || !strings.HasPrefix(backupRec.Header().Get("Content-Digest"), "sha-256=:") {
+		t.Fatalf("expected an encrypted backup with a digest, got %d %v", backupRec.Code, backupRec.Header())
+	}
+	if bytes.Contains(backupRec.Body.Bytes(), []byte("Learn Go")) {
+		t.Fatalf("expected backup not to contain plaintext todos")
+	}
+
+	store := NewTodoStore()
+	target := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}), WithAdminToken(token),
+		WithBackupEncryption(nil, []age.Identity{identity}))
+	if rec := serve(target, http.MethodPost, "/admin/restore", backupRec.Body.String(), sealed); rec.Code != http.StatusOK {
+		t.Fatalf("expected encrypted restore to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(allTodos(t, store)) != 3 {
+		t.Fatalf("expected 3 restored todos, got %d", len(allTodos(t, store)))
+	}
+
+	if rec := serve(target, http.MethodPost, "/admin/restore", `{"next_id":1,"todos":[]}`, sealed); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected plaintext restore to be rejected, got %d", rec.Code)
+	}
+
+	var forged bytes.Buffer
+	w, _ := age.Encrypt(&forged, identity.Recipient())
+	json.NewEncoder(w).Encode(SealedBackup{
+		Manifest: BackupManifest{Format: SealedBackupFormat, Todos: 0, SHA256: "00"},
+		Backup:   json.RawMessage(`{"next_id":1,"todos":[]}`),
+	})
+	w.Close()
+	if rec := serve(target, http.MethodPost, "/admin/restore", forged.String(), sealed); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a backup failing its manifest checksum to be rejected, got %d", rec.Code)
+	}
+	if len(allTodos(t, store)) != 3 {
+		t.Fatalf("expected rejected restores to keep the content")
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	wrongKey := NewRouter(testBaseURL, WithAdminToken(token), WithBackupEncryption(nil, []age.Identity{other}))
+	if rec := serve(wrongKey, http.MethodPost, "/admin/restore", backupRec.Body.String(), sealed); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a backup for another key to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestImportUploadMapping(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	store := NewTodoStoreWithClock(clock)
+	r := NewRouter(testBaseURL, WithStore(store), WithClock(clock), WithSeeder(func(Service) {}))
+	upload := func() ImportUpload {
+		rec := serve(r, http.MethodPost, "/todos/import/uploads", "Priority,Task Name,Notes\nhigh,Pay rent,before the 1st\nlow,Water plants,\n", http.Header{contentTypeHeader: {"text/csv"}})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected upload status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var upload ImportUpload
+		if err := json.Unmarshal(rec.Body.Bytes(), &upload); err != nil {
+			t.Fatalf("failed to unmarshal upload: %v", err)
+		}
+		return upload
+	}
+
+	first := upload()
+	if first.Rows != 2 || len(first.Columns) != 3 || len(first.Sample) != 2 {
+		t.Fatalf("unexpected upload preview: %+v", first)
+	}
+	if first.Mapping != (ImportMapping{Title: "Task Name", Description: "Notes"}) {
+		t.Fatalf("unexpected proposed mapping: %+v", first.Mapping)
+	}
+
+	path := strings.TrimPrefix(first.Links.Confirm.Href, testBaseURL)
+	if rec := serve(r, http.MethodPost, path, `{"title":"Missing"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown title column to be rejected, got %d", rec.Code)
+	}
+	rec := serve(r, http.MethodPost, path, `{"description":"notes"}`, nil)
+	var result ImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal import result: %v", err)
+	}
+	if rec.Code != http.StatusOK || result.Created != 2 {
+		t.Fatalf("expected confirmed import to create 2 todos, got %d: %+v", rec.Code, result)
+	}
+	if got, _ := store.GetByID(t.Context(), result.Results[0].ID); got.Title != "Pay rent" || got.Description != "before the 1st" {
+		t.Fatalf("unexpected imported todo: %+v", got)
+	}
+	if rec := serve(r, http.MethodPost, path, "", http.Header{contentTypeHeader: {contentTypeJSON}}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a confirmed upload to be discarded, got %d", rec.Code)
+	}
+
+	cancelled := upload()
+	if rec := serve(r, http.MethodDelete, strings.TrimPrefix(cancelled.Links.Cancel.Href, testBaseURL), "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected cancel status 204, got %d", rec.Code)
+	}
+
+	expired := upload()
+	clock.Advance(2 * time.Hour)
+	if rec := serve(r, http.MethodGet, strings.TrimPrefix(expired.Links.Self.Href, testBaseURL), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an expired upload to be gone, got %d", rec.Code)
+	}
+	if len(allTodos(t, store)) != 2 {
+		t.Fatalf("expected only the confirmed upload to be imported, got %d todos", len(allTodos(t, store)))
+	}
+
+	if rec := serve(r, http.MethodPost, "/todos/import/uploads", `[]`, nil); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected non-CSV uploads to be rejected with 415, got %d", rec.Code)
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	store := NewTodoStore()
+	r := NewRouter(testBaseURL, WithStore(store), WithSeeder(func(Service) {}))
+	decode := func(rec *httptest.ResponseRecorder) ImportJob {
+		var job ImportJob
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatalf("failed to unmarshal job: %v", err)
+		}
+		return job
+	}
+
+	var csv strings.Builder
+	csv.WriteString("title,description\n")
+	for i := 1; i <= MaxImportRows+500; i++ {
+		if i == 7 || i == 1234 {
+			csv.WriteString(",no title\n")
+			continue
+		}
+		fmt.Fprintf(&csv, "Row %d,\n", i)
+	}
+	rec := serve(r, http.MethodPost, "/todos/import/jobs", csv.String(), http.Header{contentTypeHeader: {"text/csv"}})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	job := decode(rec)
+	if rec.Header().Get("Location") != job.Links.Self.Href || job.Total != MaxImportRows+500 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	path := strings.TrimPrefix(job.Links.Self.Href, testBaseURL)
+	for deadline := time.Now().Add(5 * time.Second); job.Status == ImportJobRunning; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("import job did not finish: %+v", job)
+		}
+		if job.Processed > job.Total || job.Links.Cancel == nil {
+			t.Fatalf("unexpected progress: %+v", job)
+		}
+		job = decode(serve(r, http.MethodGet, path, "", nil))
+	}
+	if job.Status != ImportJobCompleted || job.Processed != job.Total || job.Created != job.Total-2 || job.Failed != 2 {
+		t.Fatalf("unexpected finished job: %+v", job)
+	}
+	if len(job.Errors) != 2 || job.Errors[0].Row != 7 || job.Errors[1].Row != 1234 || job.Errors[0].Error != "Title is required" {
+		t.Fatalf("expected the failed rows in order, got %+v", job.Errors)
+	}
+	if job.FinishedAt == nil || job.Links.Cancel != nil {
+		t.Fatalf("expected a finished job to carry finished_at and no cancel link: %+v", job)
+	}
+	if got := len(allTodos(t, store)); got != job.Created {
+		t.Fatalf("expected %d imported todos, got %d", job.Created, got)
+	}
+
+	inputs := make([]TodoInput, MaxImportJobRows)
+	for i := range inputs {
+		inputs[i] = TodoInput{Title: fmt.Sprintf("Bulk %d", i+1)}
+	}
+	body, _ := json.Marshal(inputs)
+	before := len(allTodos(t, store))
+	job = decode(serve(r, http.MethodPost, "/todos/import/jobs", string(body), nil))
+	rec = serve(r, http.MethodDelete, strings.TrimPrefix(job.Links.Self.Href, testBaseURL), "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected cancellation to answer 200, got %d", rec.Code)
+	}
+	cancelled := decode(rec)
+	switch cancelled.Status {
+	case ImportJobCancelled:
+		if cancelled.Processed >= cancelled.Total {
+			t.Fatalf("expected a cancelled job to stop early: %+v", cancelled)
+		}
+	case ImportJobCompleted:
+		if cancelled.Processed != cancelled.Total {
+			t.Fatalf("unexpected completed job: %+v", cancelled)
+		}
+	default:
+		t.Fatalf("expected the job to be stopped, got %+v", cancelled)
+	}
+	if got := len(allTodos(t, store)) - before; got != cancelled.Created {
+		t.Fatalf("expected the %d rows imported before cancellation to be kept, got %d", cancelled.Created, got)
+	}
+
+	if rec := serve(r, http.MethodPost, "/todos/import/jobs", "[]", nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected an empty job to be accepted, got %d", rec.Code)
+	}
+	if rec := serve(r, http.MethodGet, "/todos/import/jobs/unknown", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown job to answer 404, got %d", rec.Code)
+	}
+}
+
+func TestWebSocketLiveUpdates(t *testing.T) {
+	server := httptest.NewServer(NewRouter(testBaseURL))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.CloseNow()
+
+	read := func() WSMessage {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("failed to read websocket message: %v", err)
+		}
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("failed to unmarshal websocket message: %v", err)
+		}
+		return msg
+	}
+	send := func(cmd string) {
+		if err := conn.Write(ctx, websocket.MessageText, []byte(cmd)); err != nil {
+			t.Fatalf("failed to send command: %v", err)
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/todos", contentTypeJSON, strings.NewReader(`{"title":"From REST"}`))
+	if err != nil {
+		t.Fatalf("failed to create todo: %v", err)
+	}
+	resp.Body.Close()
+	if msg := read(); msg.Type != "change" || msg.Change.Type != events.TypeTodoCreated || msg.Change.Todo.Title != "From REST" || msg.Change.Seq != 4 {
+		t.Fatalf("unexpected broadcast: %+v", msg)
+	}
+
+	send(`{"type":"complete","id":"c1","todo_id":4}`)
+	// The change is broadcast before the command's reply is queued.
+	if msg := read(); msg.Type != "change" || msg.Change.Type != events.TypeTodoCompleted {
+		t.Fatalf("expected completion broadcast, got %+v", msg)
+	}
+	if msg := read(); msg.Type != "result" || msg.ID != "c1" || !msg.Todo.Completed {
+		t.Fatalf("unexpected command result: %+v", msg)
+	}
+
+	send(`{"type":"complete","id":"c2","todo_id":999}`)
+	if msg := read(); msg.Type != "error" || msg.ID != "c2" || msg.Error != "Todo not found" {
+		t.Fatalf("unexpected error reply: %+v", msg)
+	}
+	send(`{"type":"create","id":"c3"}`)
+	if msg := read(); msg.Type != "error" || msg.Error != "Validation error" {
+		t.Fatalf("unexpected validation reply: %+v", msg)
+	}
+	send(`not json`)
+	if msg := read(); msg.Type != "error" || msg.Error != "Invalid JSON" {
+		t.Fatalf("unexpected invalid JSON reply: %+v", msg)
+	}
+}
+
+// sleepyStore is a TodoStore whose lookups take at least delay. Like those
+// of a slow backend, they give up once their context is done.
+type sleepyStore struct {
+	*TodoStore
+	delay time.Duration
+}
+
+func (s sleepyStore) GetByID(ctx context.Context, id int) (*Todo, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.TodoStore.GetByID(ctx, id)
+}
+
+// downStore is a Store whose backend can be taken down: while down, every
+// operation fails like the persistent stores do when their connection is
+// lost.
+type downStore struct {
+	Store
+	down *atomic.Bool
+}
+
+func (s downStore) check() error {
+	if s.down.Load() {
+		return errors.New("downstore: connection refused")
+	}
+	return nil
+}
+
+func (s downStore) GetAll(ctx context.Context) ([]*Todo, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetAll(ctx)
+}
+
+func (s downStore) GetByID(ctx context.Context, id int) (*Todo, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetByID(ctx, id)
+}
+
+func (s downStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.Store.Create(ctx, input)
+}
+
+func (s downStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.Store.Update(ctx, id, input)
+}
+
+func (s downStore) MergedInto(ctx context.Context, id int) (int, error) {
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	return s.Store.MergedInto(ctx, id)
+}
+
+func TestStaleReadsWhileStoreIsDown(t *testing.T) {
+	store := downStore{Store: NewTodoStore(), down: new(atomic.Bool)}
+	r := NewRouter(testBaseURL, WithStore(store))
+
+	if rec := serve(r, http.MethodGet, todosPath, "", nil); rec.Code != http.StatusOK || rec.Header().Get("Warning") != "" {
+		t.Fatalf("expected a fresh listing while the store is up, got %d %q", rec.Code, rec.Header().Get("Warning"))
+	}
+
+	store.down.Store(true)
+	rec := serve(r, http.MethodGet, todosPath, "", nil)
+	var collection TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("failed to unmarshal collection: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("Warning") != staleWarning || !collection.Meta.Stale || collection.Meta.Total != 3 {
+		t.Fatalf("expected the last listing marked stale, got %d %q %+v", rec.Code, rec.Header().Get("Warning"), collection.Meta)
+	}
+	if collection.Links.Snapshot != nil {
+		t.Fatalf("expected a stale listing not to offer a snapshot")
+	}
+	rec = serve(r, http.MethodGet, "/todos/1", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Warning") != staleWarning || !strings.Contains(rec.Body.String(), `"_meta":{"stale":true}`) {
+		t.Fatalf("expected the todo marked stale, got %d %q: %s", rec.Code, rec.Header().Get("Warning"), rec.Body.String())
+	}
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPost, todosPath, `{"title":"New"}`},
+		{http.MethodPut, "/todos/1", `{"title":"Changed"}`},
+	} {
+		rec := serve(r, tc.method, tc.target, tc.body, nil)
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("expected %s %s to fail with 503 and Retry-After, got %d %v", tc.method, tc.target, rec.Code, rec.Header())
+		}
+	}
+
+	store.down.Store(false)
+	if rec := serve(r, http.MethodGet, "/todos/1", "", nil); rec.Code != http.StatusOK || rec.Header().Get("Warning") != "" || strings.Contains(rec.Body.String(), "_meta") {
+		t.Fatalf("expected fresh reads once the store is back, got %d %q: %s", rec.Code, rec.Header().Get("Warning"), rec.Body.String())
+	}
+}
+
+func TestUpsertReportsFailedUpdates(t *testing.T) {
+	store := downStore{Store: NewTodoStore(), down: new(atomic.Bool)}
+	service := NewService(store)
+	input := TodoInput{Title: "Imported", Source: "jira", ExternalID: "PROJ-1"}
+	if _, _, err := service.UpsertTodo(t.Context(), input, ConflictOverwrite); err != nil {
+		t.Fatalf("UpsertTodo failed: %v", err)
+	}
+
+	store.down.Store(true)
+	input.Title = "Renamed"
+	if todo, outcome, err := service.UpsertTodo(t.Context(), input, ConflictOverwrite); err == nil {
+		t.Fatalf("expected the failed update to be returned, got %+v, %s", todo, outcome)
+	}
+}
+
+// stuckStore is a Store whose todos cannot change state.
+type stuckStore struct {
+	Store
+}
+
+func (stuckStore) SetState(context.Context, int, State) (*Todo, error) {
+	return nil, errors.New("stuckstore: connection refused")
+}
+
+func TestStateChangesReportFailedTransitions(t *testing.T) {
+	service := NewService(stuckStore{Store: NewTodoStore()})
+	input := TodoInput{Title: "Imported", Source: "jira", ExternalID: "PROJ-1", Status: StateArchived}
+	if todo, _, err := service.UpsertTodo(t.Context(), input, ConflictOverwrite); err == nil {
+		t.Fatalf("expected the failed transition of a new todo to be returned, got %+v", todo)
+	}
+
+	created, err := service.CreateTodo(t.Context(), TodoInput{Title: "Existing", Source: "jira", ExternalID: "PROJ-2"})
+	if err != nil {
+		t.Fatalf("CreateTodo failed: %v", err)
+	}
+	input = TodoInput{Title: "Existing", Source: "jira", ExternalID: "PROJ-2", Status: StateArchived}
+	if todo, _, err := service.UpsertTodo(t.Context(), input, ConflictOverwrite); err == nil {
+		t.Fatalf("expected the failed transition of an existing todo to be returned, got %+v", todo)
+	}
+	archive := func(current *Todo) (TodoInput, error) {
+		return TodoInput{Title: current.Title, Status: StateArchived}, nil
+	}
+	if todo, err := service.PatchTodoIf(t.Context(), created.ID, archive, nil); err == nil {
+		t.Fatalf("expected the failed transition of a patch to be returned, got %+v", todo)
+	}
+}
+
+func TestStoreOutageFailsRequests(t *testing.T) {
+	store := downStore{Store: NewTodoStore(), down: new(atomic.Bool)}
+	server := httptest.NewServer(NewRouter(testBaseURL, WithStore(store)))
+	defer server.Close()
+	store.down.Store(true)
+
+	resp, err := http.Post(server.URL+"/todos/import", contentTypeJSON, strings.NewReader(`[{"title":"Imported"}]`))
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	var result ImportResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if err != nil || result.Failed != 1 || !strings.Contains(result.Results[0].Error, "unavailable") {
+		t.Fatalf("expected the row to fail while the store is down, got %+v, %v", result, err)
+	}
+
+	resp, err = http.Post(server.URL+"/todos/import/jobs", contentTypeJSON, strings.NewReader(`[{"title":"Queued"}]`))
+	if err != nil {
+		t.Fatalf("failed to start import job: %v", err)
+	}
+	var job ImportJob
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to unmarshal job: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); job.Status == ImportJobRunning; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("import job did not finish: %+v", job)
+		}
+		resp, err := http.Get(server.URL + strings.TrimPrefix(job.Links.Self.Href, testBaseURL))
+		if err != nil {
+			t.Fatalf("failed to get import job: %v", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to unmarshal job: %v", err)
+		}
+	}
+	if job.Status != ImportJobCompleted || job.Failed != 1 {
+		t.Fatalf("expected the job to report the failed row, got %+v", job)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.CloseNow()
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"type":"create","id":"c1","title":"Live"}`)); err != nil {
+		t.Fatalf("failed to send command: %v", err)
+	}
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("failed to read websocket message: %v", err)
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "error" || msg.ID != "c1" || msg.Error != "Service unavailable" {
+		t.Fatalf("expected the command to fail while the store is down, got %+v, %v", msg, err)
+	}
+
+	store.down.Store(false)
+	if resp, err := http.Get(server.URL + "/todos/1"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the server to keep serving once the store is back, got %v, %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+// contextStore is a TodoStore that records the contexts of its lookups.
+type contextStore struct {
+	*TodoStore
+	seen chan context.Context
+}
+
+func (s contextStore) GetByID(ctx context.Context, id int) (*Todo, error) {
+	select {
+	case s.seen <- ctx:
+	default:
+	}
+	return s.TodoStore.GetByID(ctx, id)
+}
+
+func TestStoreReceivesRequestContext(t *testing.T) {
+	type key struct{}
+	store := contextStore{TodoStore: NewTodoStore(), seen: make(chan context.Context, 1)}
+	r := NewRouter(testBaseURL, WithStore(store))
+
+	ctx, cancel := context.WithCancel(context.WithValue(t.Context(), key{}, "request"))
+	req := httptest.NewRequest(http.MethodGet, "/todos/1", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := <-store.seen
+	if got.Value(key{}) != "request" {
+		t.Fatalf("expected the store to receive the request context")
+	}
+	cancel()
+	if got.Err() == nil {
+		t.Fatalf("expected cancelling the request to cancel the store context")
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	store := sleepyStore{TodoStore: NewTodoStore(), delay: 200 * time.Millisecond}
+	createTodo(t, store, TodoInput{Title: "Slow"})
+	r := NewRouter(testBaseURL, WithStore(store))
+
+	rec := serve(r, http.MethodGet, todosPath, "", http.Header{HeaderRequestTimeout: {"5"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Fatalf("expected a request within its deadline to be served as usual, got %d: %v", rec.Code, rec.Header())
+	}
+	var collection TodoCollection
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil || collection.Todos[0].Title != "Slow" {
+		t.Fatalf("expected the collection, got %s", rec.Body)
+	}
+
+	for _, header := range []http.Header{
+		{HeaderRequestTimeout: {"0"}},
+		{HeaderRequestDeadline: {time.Now().Add(-time.Second).Format(time.RFC3339Nano)}},
+		{HeaderRequestDeadline: {time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}, HeaderRequestTimeout: {"5"}},
+	} {
+		if rec := serve(r, http.MethodGet, todosPath, "", header); rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("expected a passed deadline %v to answer 504, got %d: %s", header, rec.Code, rec.Body)
+		}
+	}
+
+	start := time.Now()
+	rec = serve(r, http.MethodGet, "/todos/1", "", http.Header{HeaderRequestTimeout: {"0.02"}})
+	if rec.Code != http.StatusGatewayTimeout || time.Since(start) >= store.delay {
+		t.Fatalf("expected a 504 before the slow lookup finished, got %d after %v", rec.Code, time.Since(start))
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil || errResp.Error != "Deadline exceeded" {
+		t.Fatalf("expected a deadline error, got %s", rec.Body)
+	}
+	if rec.Header().Get("Warning") != "" || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected a store call cut short by the deadline not to count as an outage, got %v", rec.Header())
+	}
+
+	for _, header := range []http.Header{
+		{HeaderRequestTimeout: {"-1"}},
+		{HeaderRequestTimeout: {"soon"}},
+		{HeaderRequestDeadline: {"tomorrow"}},
+	} {
+		if rec := serve(r, http.MethodGet, todosPath, "", header); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected an invalid deadline %v to answer 400, got %d", header, rec.Code)
+		}
+	}
+}
+
+func TestDeadlineStopsMutations(t *testing.T) {
+	store := NewTodoStore()
+	service := NewService(store)
+	parent := createTodo(t, store, TodoInput{Title: "Parent"})
+	source := createTodo(t, store, TodoInput{Title: "Source"})
+	ctx, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, createErr := service.CreateTodo(ctx, TodoInput{Title: "Late"})
+	_, updateErr := service.UpdateTodo(ctx, parent.ID, TodoInput{Title: "Late"})
+	_, transitionErr := service.TransitionTodo(ctx, parent.ID, TransitionComplete)
+	_, mergeErr := service.MergeTodos(ctx, parent.ID, source.ID)
+	_, _, upsertErr := service.UpsertTodo(ctx, TodoInput{Title: "Late", Source: "csv", ExternalID: "1"}, ConflictOverwrite)
+	for name, err := range map[string]error{
+		"create":     createErr,
+		"update":     updateErr,
+		"transition": transitionErr,
+		"merge":      mergeErr,
+		"upsert":     upsertErr,
+		"delete":     service.DeleteTodo(ctx, source.ID),
+	} {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected the passed deadline to stop the change, got %v", name, err)
+		}
+	}
+	todos := allTodos(t, store)
+	if len(todos) != 2 || todos[0].Title != "Parent" || todos[0].Completed || todos[1].Title != "Source" {
+		t.Fatalf("expected no change to be applied after the deadline, got %+v", todos)
+	}
+
+	r := NewRouter(testBaseURL, WithStore(store), WithAdminToken("s3cret"))
+	req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set(HeaderRequestTimeout, "5")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var backup Backup
+	if err := json.Unmarshal(rec.Body.Bytes(), &backup); rec.Code != http.StatusOK || err != nil || len(backup.Todos) != len(allTodos(t, store)) {
+		t.Fatalf("expected a backup within its deadline to be served, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSlowStoreLog(t *testing.T) {
+	var buf bytes.Buffer
+	store := sleepyStore{TodoStore: NewTodoStore(), delay: 20 * time.Millisecond}
+	r := NewRouter(testBaseURL, WithStore(store), WithSlowStoreLog(10*time.Millisecond, log.New(&buf, "", 0)))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, todosPath, nil))
+	if buf.Len() != 0 {
+		t.Fatalf("expected fast operations not to be logged, got %q", buf.String())
+	}
+
+	for range 2 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected both slow lookups to be logged, got %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "todo: slow store GetByID took ") || !strings.HasSuffix(lines[1], "params: id=1; todos: 1; slow GetByID calls so far: 2") {
+		t.Fatalf("unexpected slow store log line %q", lines[1])
+	}
+
+	wrapped := instrumentStore(store, time.Second, nil)
+	if _, ok := wrapped.(SnapshotStore); !ok {
+		t.Fatalf("expected the instrumented store to keep snapshots")
+	}
+	if _, ok := wrapped.(BackupStore); !ok {
+		t.Fatalf("expected the instrumented store to keep backups")
+	}
+}
+
+func TestServiceErrors(t *testing.T) {
+	service := NewService(NewTodoStore())
+	done, _ := service.CreateTodo(t.Context(), TodoInput{Title: "Done"})
+	service.TransitionTodo(t.Context(), done.ID, TransitionComplete)
+
+	_, createErr := service.CreateTodo(t.Context(), TodoInput{})
+	_, updateErr := service.UpdateTodo(t.Context(), done.ID, TodoInput{})
+	_, getErr := service.GetTodo(t.Context(), 999)
+	_, mergeErr := service.MergeTodos(t.Context(), done.ID, done.ID)
+	_, transitionErr := service.TransitionTodo(t.Context(), done.ID, TransitionComplete)
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		status int
+	}{
+		{"create without title", createErr, ErrValidation, http.StatusBadRequest},
+		{"update without title", updateErr, ErrValidation, http.StatusBadRequest},
+		{"get missing", getErr, ErrNotFound, http.StatusNotFound},
+		{"delete missing", service.DeleteTodo(t.Context(), 999), ErrNotFound, http.StatusNotFound},
+		{"merge into itself", mergeErr, ErrValidation, http.StatusBadRequest},
+		{"invalid transition", transitionErr, ErrConflict, http.StatusConflict},
+		{"failed precondition", service.DeleteTodoIf(t.Context(), done.ID, func(*Todo) bool { return false }), ErrPreconditionFailed, http.StatusPreconditionFailed},
+		{"deadline exceeded", fmt.Errorf("list todos: %w", context.DeadlineExceeded), context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"unknown", errors.New("disk on fire"), nil, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if tt.target != nil && !errors.Is(tt.err, tt.target) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.target, tt.err)
+		}
+		if status, _, _ := describeTodoError(done.ID, tt.err); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, status)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package todo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archivedSnapshotFile and archivedLogFile name the files of an archived
+// generation of the write-ahead log: a snapshot and the log records written
+// after it, up to the next snapshot, compressed with gzip.
+func archivedSnapshotFile(generation int) string {
+	return fmt.Sprintf("snapshot-%06d.json", generation)
+}
+
+func archivedLogFile(generation int) string {
+	return fmt.Sprintf("wal-%06d.log.gz", generation)
+}
+
+// archivedGenerations returns the numbers of the generations archived in
+// dir, oldest first.
+func archivedGenerations(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list archive: %w", err)
+	}
+	var generations []int
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "snapshot-")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err == nil {
+			generations = append(generations, n)
+		}
+	}
+	slices.Sort(generations)
+	return generations, nil
+}
+
+// walGeneration is a snapshot and the log records written after it.
+type walGeneration struct {
+	snapshot walSnapshot
+	records  []walRecord
+}
+
+// readGeneration reads the snapshot and the log of a generation, which is
+// decompressed if its name ends in ".gz". A missing snapshot is the empty
+// store the log started from. Records the snapshot
+// already contains, and a record torn by a crash at the end of the log, are
+// left out.
+func readGeneration(snapshotPath, logPath string) (walGeneration, error) {
+	gen := walGeneration{snapshot: walSnapshot{Backup: Backup{NextID: 1}}}
+	data, err := os.ReadFile(snapshotPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return gen, fmt.Errorf("read snapshot: %w", err)
+	default:
+		if err := json.Unmarshal(data, &gen.snapshot); err != nil {
+			return gen, fmt.Errorf("parse snapshot %s: %w", snapshotPath, err)
+		}
+	}
+
+	data, err = os.ReadFile(logPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return gen, fmt.Errorf("read log: %w", err)
+	}
+	if strings.HasSuffix(logPath, ".gz") && len(data) > 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return gen, fmt.Errorf("read log %s: %w", logPath, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return gen, fmt.Errorf("read log %s: %w", logPath, err)
+		}
+	}
+	for len(data) > 0 {
+		line, rest, complete := bytes.Cut(data, []byte("\n"))
+		if !complete {
+			break
+		}
+		data = rest
+		var rec walRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			return gen, fmt.Errorf("parse log record in %s: %w", logPath, err)
+		}
+		if rec.Seq > gen.snapshot.Seq {
+			gen.records = append(gen.records, rec)
+		}
+	}
+	return gen, nil
+}
+
+// readGenerations reads the archived generations of the log in dir, oldest
+// first, followed by the current one.
+func readGenerations(dir string) ([]walGeneration, error) {
+	archive := filepath.Join(dir, walArchiveDir)
+	numbers, err := archivedGenerations(archive)
+	if err != nil {
+		return nil, err
+	}
+	var generations []walGeneration
+	for _, n := range numbers {
+		gen, err := readGeneration(filepath.Join(archive, archivedSnapshotFile(n)), filepath.Join(archive, archivedLogFile(n)))
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, gen)
+	}
+	current, err := readGeneration(filepath.Join(dir, walSnapshotFile), filepath.Join(dir, walLogFile))
+	if err != nil {
+		return nil, err
+	}
+	return append(generations, current), nil
+}
+
+// rebuild replays the records of gen written up to until, or all of them if
+// until is zero, on its snapshot. It returns the resulting state and the
+// sequence of the last record replayed. Records without a time, written
+// before they were stamped, count as written with the change they record,
+// or else with the record before them.
+func (gen walGeneration) rebuild(until time.Time) (Backup, int, error) {
+	replay := &WALStore{TodoStore: NewTodoStore()}
+	replay.load(gen.snapshot.Backup)
+	seq, at := gen.snapshot.Seq, gen.snapshot.At
+	for _, rec := range gen.records {
+		switch {
+		case !rec.At.IsZero():
+			at = rec.At
+		case !rec.UpdatedAt.IsZero():
+			at = rec.UpdatedAt
+		}
+		if !until.IsZero() && at.After(until) {
+			break
+		}
+		if err := replay.apply(rec); err != nil {
+			return Backup{}, 0, fmt.Errorf("replay log record %d: %w", rec.Seq, err)
+		}
+		seq = rec.Seq
+	}
+	b, err := replay.Backup(context.Background())
+	return b, seq, err
+}
+
+// PointInTimeReport describes a point-in-time restore of a write-ahead log:
+// how the state at the target time was rebuilt, and what restoring it
+// would lose and recover compared with the current state.
+type PointInTimeReport struct {
+	// At is the target time.
+	At time.Time
+	// Base is the time of the snapshot the state was rebuilt from; it is
+	// zero if the state was rebuilt from the empty store the log started
+	// from.
+	Base time.Time
+	// Replayed is the number of log records applied to the snapshot.
+	Replayed int
+	// Discarded is the number of log records written after At, whose
+	// changes are lost.
+	Discarded int
+	// Removed lists the todos created after At.
+	Removed []int
+	// Reverted lists the todos changed after At, which return to their
+	// earlier title, description, tags and status.
+	Reverted []int
+	// Recovered lists the todos deleted or merged into another after At.
+	Recovered []int
+}
+
+// PlanPointInTime rebuilds the state the write-ahead log in dir held at the
+// given time from the latest snapshot taken before it, archived or
+// current, and the log records written after that snapshot up to the time.
+// It compares the result with the current state of the log without
+// changing anything, so the plan serves as a dry run of
+// WALStore.RestorePointInTime. It returns an error if the archive does not
+// reach back to at.
+func PlanPointInTime(dir string, at time.Time) (Backup, PointInTimeReport, error) {
+	report := PointInTimeReport{At: at}
+	generations, err := readGenerations(dir)
+	if err != nil {
+		return Backup{}, report, err
+	}
+
+	base := -1
+	var earliest time.Time
+	for i, gen := range generations {
+		// Snapshots taken before they were timed cannot be placed.
+		if gen.snapshot.At.IsZero() && gen.snapshot.Seq > 0 {
+			continue
+		}
+		if earliest.IsZero() {
+			earliest = gen.snapshot.At
+		}
+		if !gen.snapshot.At.After(at) {
+			base = i
+		}
+	}
+	if base < 0 {
+		if earliest.IsZero() {
+			return Backup{}, report, fmt.Errorf("the log in %s has no timed snapshots to restore from", dir)
+		}
+		return Backup{}, report, fmt.Errorf("the log in %s reaches back to %s", dir, earliest.Format(time.RFC3339))
+	}
+
+	target, seq, err := generations[base].rebuild(at)
+	if err != nil {
+		return Backup{}, report, err
+	}
+	current, latest, err := generations[len(generations)-1].rebuild(time.Time{})
+	if err != nil {
+		return Backup{}, report, err
+	}
+	report.Base = generations[base].snapshot.At
+	report.Replayed = seq - generations[base].snapshot.Seq
+	report.Discarded = latest - seq
+	report.compare(current, target)
+	return target, report, nil
+}
+
+// compare records the todos that differ between the current state and the
+// target state.
+func (r *PointInTimeReport) compare(current, target Backup) {
+	then := make(map[int]BackupTodo, len(target.Todos))
+	for _, t := range target.Todos {
+		then[t.ID] = t
+	}
+	for _, t := range current.Todos {
+		old, ok := then[t.ID]
+		switch {
+		case !ok:
+			r.Removed = append(r.Removed, t.ID)
+		case !sameBackupTodo(old, t):
+			r.Reverted = append(r.Reverted, t.ID)
+		}
+		delete(then, t.ID)
+	}
+	for _, t := range target.Todos {
+		if _, ok := then[t.ID]; ok {
+			r.Recovered = append(r.Recovered, t.ID)
+		}
+	}
+}
+
+// sameBackupTodo reports whether a and b are the same version of a todo.
+func sameBackupTodo(a, b BackupTodo) bool {
+	return a.Title == b.Title && a.Description == b.Description && slices.Equal(a.Tags, b.Tags) && a.ParentID == b.ParentID &&
+		a.Completed == b.Completed && a.Archived == b.Archived &&
+		a.Version == b.Version && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+// RestorePointInTime replaces the content of the store with the state its
+// log held at the given time, as planned by PlanPointInTime, and compacts
+// the log. Like Restore, it writes the snapshot first, so a failed restore
+// changes nothing. The replaced state is archived like any other, so the
+// restore can itself be undone by restoring to a time just before it.
+func (s *WALStore) RestorePointInTime(ctx context.Context, at time.Time) (PointInTimeReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, report, err := PlanPointInTime(s.dir, at)
+	if err != nil {
+		return report, err
+	}
+	return report, s.restore(ctx, b)
+}
@@ -0,0 +1,707 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WALBackend is the name of the in-memory backend made durable by a
+// write-ahead log.
+const WALBackend = "wal"
+
+// DefaultWALDir is the log directory used when no DSN is configured.
+const DefaultWALDir = "todos.wal"
+
+// DefaultCompactEvery is how many log records trigger a snapshot and log
+// truncation.
+const DefaultCompactEvery = 1000
+
+// DefaultArchivedGenerations is how many compacted generations of the log
+// the archive keeps; older ones are deleted, and point-in-time restores
+// reach back no further than the oldest kept.
+const DefaultArchivedGenerations = 50
+
+const (
+	walSnapshotFile = "snapshot.json"
+	walLogFile      = "wal.log"
+	walLockFile     = "lock"
+	walArchiveDir   = "archive"
+)
+
+// Operations recorded in the log.
+const (
+	walCreate   = "create"
+	walUpdate   = "update"
+	walComplete = "complete"
+	walSetState = "set_state"
+	walDelete   = "delete"
+	walMerge    = "merge"
+
+	walCreateMilestone   = "create_milestone"
+	walAssignMilestone   = "assign_milestone"
+	walUnassignMilestone = "unassign_milestone"
+)
+
+// walRecord is one line of the operation log.
+type walRecord struct {
+	Seq         int       `json:"seq"`
+	Op          string    `json:"op"`
+	ID          int       `json:"id"`
+	SourceID    int       `json:"source_id,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	// UpdatedAt is the time the operation changed the todo; records
+	// written before updates were tracked have none.
+	UpdatedAt  time.Time `json:"updated_at,omitzero"`
+	Source     string    `json:"source,omitempty"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	ParentID   int       `json:"parent_id,omitempty"`
+	State      State     `json:"state,omitempty"`
+	// Name, StartDate and EndDate describe a created milestone; TodoID is
+	// the todo a milestone record moves. ID is then that of the milestone.
+	Name      string `json:"name,omitempty"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	TodoID    int    `json:"todo_id,omitempty"`
+	// At is the time the record was written; records written before
+	// point-in-time restores were supported have none.
+	At time.Time `json:"at,omitzero"`
+}
+
+// walSnapshot is the snapshot file: the store state plus the sequence of
+// the last log record it includes and the time it was taken.
+type walSnapshot struct {
+	Backup
+	Seq int       `json:"seq"`
+	At  time.Time `json:"at,omitzero"`
+}
+
+// WALStore is an in-memory TodoStore that appends every mutation to an
+// operation log and periodically writes a snapshot, so its state can be
+// rebuilt after a crash by loading the snapshot and replaying the log.
+// Compaction moves the replaced snapshot and log to an archive directory,
+// from which the state at an earlier time can be rebuilt; see
+// PlanPointInTime. The archive keeps the last DefaultArchivedGenerations
+// generations.
+//
+// A mutation is logged and synced before it is applied, exactly as replay
+// applies it, so readers never see a change the log does not hold and every
+// acknowledged change survives a crash. A failed log write is returned as
+// an error before anything changed, and the part of the record it wrote is
+// cut off the log again; if that fails too, the store accepts no further
+// changes until it is reopened.
+type WALStore struct {
+	*TodoStore
+
+	dir          string
+	compactEvery int
+	keepArchived int
+
+	// mu serializes mutations with their log appends and with compaction,
+	// so the log order matches the order changes were applied.
+	// lock holds the lock of the directory while the store is open.
+	lock *os.File
+
+	mu      sync.Mutex
+	log     walFile
+	seq     int
+	pending int
+	// broken is why a failed record could not be cut off the log, which
+	// then takes no more records.
+	broken error
+}
+
+// walFile is the open log. Tests substitute one whose writes fail.
+type walFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+var _ SnapshotStore = (*WALStore)(nil)
+
+// RecoverFrom opens the write-ahead log in dir, creating the directory if
+// needed, and rebuilds the store from the latest snapshot and the log
+// records written after it. A record torn by a crash at the end of the log
+// is discarded. The directory is locked until Close, so it fails if another
+// store, such as that of a running server, has the log open. A nil clock
+// selects SystemClock.
+func RecoverFrom(dir string, clock Clock) (*WALStore, error) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s, err := recoverLocked(dir, clock)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s.lock = lock
+	return s, nil
+}
+
+// recoverLocked is RecoverFrom once dir is locked.
+func recoverLocked(dir string, clock Clock) (*WALStore, error) {
+	s := &WALStore{
+		TodoStore:    NewTodoStoreWithClock(clock),
+		dir:          dir,
+		compactEvery: DefaultCompactEvery,
+		keepArchived: DefaultArchivedGenerations,
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, walSnapshotFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	default:
+		var snapshot walSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("parse snapshot: %w", err)
+		}
+		s.load(snapshot.Backup)
+		s.seq = snapshot.Seq
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(dir, walLogFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open log: %w", err)
+	}
+	if err := s.replay(logFile); err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	s.log = logFile
+
+	return s, nil
+}
+
+// replay applies the records of the log that are newer than the snapshot
+// and truncates a torn final record.
+func (s *WALStore) replay(logFile *os.File) error {
+	reader := bufio.NewReader(logFile)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("todo: discarding torn record at the end of %s", logFile.Name())
+				if err := logFile.Truncate(offset); err != nil {
+					return fmt.Errorf("truncate torn log record: %w", err)
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			return fmt.Errorf("parse log record at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+
+		if rec.Seq <= s.seq {
+			continue
+		}
+		if err := s.apply(rec); err != nil {
+			return fmt.Errorf("replay log record %d: %w", rec.Seq, err)
+		}
+		s.seq = rec.Seq
+		s.pending++
+	}
+}
+
+// apply performs a logged operation on the in-memory store.
+func (s *WALStore) apply(rec walRecord) error {
+	switch rec.Op {
+	case walCreate:
+		s.load(Backup{Todos: []BackupTodo{{
+			ID:          rec.ID,
+			Title:       rec.Title,
+			Description: rec.Description,
+			CreatedAt:   rec.CreatedAt,
+			UpdatedAt:   rec.UpdatedAt,
+			Source:      rec.Source,
+			ExternalID:  rec.ExternalID,
+			Tags:        rec.Tags,
+			ParentID:    rec.ParentID,
+		}}})
+		return nil
+	case walUpdate:
+		s.TodoStore.Update(context.Background(), rec.ID, TodoInput{Title: rec.Title, Description: rec.Description, Tags: rec.Tags, ParentID: &rec.ParentID})
+	case walComplete:
+		s.TodoStore.Complete(context.Background(), rec.ID)
+	case walSetState:
+		s.TodoStore.SetState(context.Background(), rec.ID, rec.State)
+	case walDelete:
+		s.TodoStore.Delete(context.Background(), rec.ID)
+	case walMerge:
+		s.TodoStore.Merge(context.Background(), rec.ID, rec.SourceID)
+	case walCreateMilestone:
+		s.load(Backup{Milestones: []BackupMilestone{{ID: rec.ID, Name: rec.Name, StartDate: rec.StartDate, EndDate: rec.EndDate}}})
+		return nil
+	case walAssignMilestone:
+		s.TodoStore.AssignMilestone(context.Background(), rec.ID, rec.TodoID)
+		return nil
+	case walUnassignMilestone:
+		s.TodoStore.UnassignMilestone(context.Background(), rec.ID, rec.TodoID)
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	// Replaying stamps the todo with the current time; restore the time
+	// of the original change.
+	if !rec.UpdatedAt.IsZero() {
+		s.TodoStore.setUpdatedAt(rec.ID, rec.UpdatedAt)
+	}
+	return nil
+}
+
+// append writes rec to the log and syncs it. The caller must hold s.mu.
+func (s *WALStore) append(rec walRecord) error {
+	if s.broken != nil {
+		return fmt.Errorf("log takes no more records after a failed write: %w", s.broken)
+	}
+	rec.Seq = s.seq + 1
+	rec.At = s.clock.Now()
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode log record: %w", err)
+	}
+	end, err := s.log.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("append log record: %w", err)
+	}
+	if err := s.write(append(line, '\n')); err != nil {
+		// Left in the log, part of the record would be glued to the next
+		// one, and replay would fail on it, or the whole record would be
+		// replayed under the sequence the next one gets.
+		if cutErr := s.cut(end); cutErr != nil {
+			s.broken = cutErr
+			return errors.Join(err, cutErr)
+		}
+		return err
+	}
+
+	s.seq = rec.Seq
+	s.pending++
+	return nil
+}
+
+// write writes line to the end of the log and syncs it.
+func (s *WALStore) write(line []byte) error {
+	if _, err := s.log.Write(line); err != nil {
+		return fmt.Errorf("append log record: %w", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("sync log: %w", err)
+	}
+	return nil
+}
+
+// cut truncates the log back to size, dropping what a failed write left
+// after it.
+func (s *WALStore) cut(size int64) error {
+	if err := s.log.Truncate(size); err != nil {
+		return fmt.Errorf("truncate failed log record: %w", err)
+	}
+	if _, err := s.log.Seek(size, io.SeekStart); err != nil {
+		return fmt.Errorf("truncate failed log record: %w", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("sync log: %w", err)
+	}
+	return nil
+}
+
+// Compact writes a snapshot of the current state and truncates the log.
+func (s *WALStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+// DiskSize returns the size of the snapshot, the log and the archive.
+func (s *WALStore) DiskSize() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := []string{filepath.Join(s.dir, walSnapshotFile), filepath.Join(s.dir, walLogFile)}
+	archived, err := filepath.Glob(filepath.Join(s.dir, walArchiveDir, "*"))
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, path := range append(paths, archived...) {
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return 0, err
+		default:
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+// compact is Compact with s.mu held.
+func (s *WALStore) compact() error {
+	b, err := s.Backup(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := s.writeSnapshot(b); err != nil {
+		return err
+	}
+	return s.truncateLog()
+}
+
+// writeSnapshot archives the current snapshot and log and writes b as the
+// new snapshot. The snapshot records the sequence of the last log record,
+// so once it is written recovery rebuilds b, skipping the records still in
+// the log. The caller must hold s.mu.
+func (s *WALStore) writeSnapshot(b Backup) error {
+	if err := s.archive(); err != nil {
+		return fmt.Errorf("archive log: %w", err)
+	}
+	snapshot := walSnapshot{Backup: b, Seq: s.seq, At: s.clock.Now()}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, walSnapshotFile), data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+// truncateLog empties the log once the snapshot holds its records. The
+// caller must hold s.mu.
+func (s *WALStore) truncateLog() error {
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("truncate log: %w", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("sync log: %w", err)
+	}
+	s.pending = 0
+	return nil
+}
+
+// archive copies the current snapshot and the log written after it, which
+// it compresses, to the archive directory as the next generation, and
+// deletes the generations beyond the last s.keepArchived. A store without a
+// snapshot file started empty, which the archived snapshot records. The
+// caller must hold s.mu.
+func (s *WALStore) archive() error {
+	snapshot, err := os.ReadFile(filepath.Join(s.dir, walSnapshotFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		snapshot, err = json.Marshal(walSnapshot{Backup: Backup{NextID: 1, Todos: []BackupTodo{}}})
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	records, err := os.ReadFile(filepath.Join(s.dir, walLogFile))
+	if err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(records); err != nil {
+		return fmt.Errorf("compress log: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress log: %w", err)
+	}
+
+	dir := filepath.Join(s.dir, walArchiveDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create archive directory: %w", err)
+	}
+	generations, err := archivedGenerations(dir)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(generations) > 0 {
+		next = generations[len(generations)-1] + 1
+	}
+	// The snapshot is written last and deleted first: a generation without
+	// one is ignored.
+	if err := writeFileAtomic(filepath.Join(dir, archivedLogFile(next)), compressed.Bytes()); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, archivedSnapshotFile(next)), snapshot); err != nil {
+		return err
+	}
+	generations = append(generations, next)
+	for _, n := range generations[:max(len(generations)-s.keepArchived, 0)] {
+		for _, name := range []string{archivedSnapshotFile(n), archivedLogFile(n)} {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("prune archive: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close compacts the log, unless it is empty, and closes it. It is safe to
+// call more than once.
+func (s *WALStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	var err error
+	if s.pending > 0 {
+		err = s.compact()
+	}
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+	s.log = nil
+	if s.lock != nil {
+		if closeErr := s.lock.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// commit appends rec to the log and then applies it to the in-memory store,
+// compacting the log once compactEvery records have accumulated. The caller
+// must hold s.mu and have checked that rec applies.
+func (s *WALStore) commit(rec walRecord) error {
+	if err := s.append(rec); err != nil {
+		return err
+	}
+	if err := s.apply(rec); err != nil {
+		return err
+	}
+	if s.pending >= s.compactEvery {
+		// The record is already durable, so a failed compaction only
+		// leaves a longer log to replay.
+		if err := s.compact(); err != nil {
+			log.Printf("todo: compact write-ahead log: %v", err)
+		}
+	}
+	return nil
+}
+
+// Create logs a new todo and adds it.
+func (s *WALStore) Create(ctx context.Context, input TodoInput) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	rec := walRecord{
+		Op:          walCreate,
+		ID:          s.TodoStore.peekNextID(),
+		Title:       input.Title,
+		Description: input.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Source:      input.Source,
+		ExternalID:  input.ExternalID,
+		Tags:        input.Tags,
+		ParentID:    input.Parent(),
+	}
+	if err := s.commit(rec); err != nil {
+		return nil, err
+	}
+	return s.TodoStore.GetByID(ctx, rec.ID)
+}
+
+// change logs and applies rec, a change of the todo with the given ID, and
+// returns the changed todo. It returns ErrNotFound, logging nothing, if the
+// todo does not exist. The caller must hold s.mu.
+func (s *WALStore) change(ctx context.Context, id int, rec walRecord) (*Todo, error) {
+	if _, err := s.TodoStore.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	rec.ID = id
+	rec.UpdatedAt = s.clock.Now()
+	if err := s.commit(rec); err != nil {
+		return nil, err
+	}
+	return s.TodoStore.GetByID(ctx, id)
+}
+
+// Update logs the change of an existing todo and applies it.
+// It returns ErrNotFound if the todo does not exist.
+func (s *WALStore) Update(ctx context.Context, id int, input TodoInput) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.change(ctx, id, walRecord{Op: walUpdate, Title: input.Title, Description: input.Description, Tags: input.Tags, ParentID: input.Parent()})
+}
+
+// Complete logs the completion of the todo and applies it.
+// It returns ErrNotFound if the todo does not exist.
+func (s *WALStore) Complete(ctx context.Context, id int) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.change(ctx, id, walRecord{Op: walComplete})
+}
+
+// SetState logs the move of the todo to state and applies it.
+// It returns ErrNotFound if the todo does not exist.
+func (s *WALStore) SetState(ctx context.Context, id int, state State) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.change(ctx, id, walRecord{Op: walSetState, State: state})
+}
+
+// Delete logs the removal of the todo and applies it.
+// It returns ErrNotFound if the todo does not exist.
+func (s *WALStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.TodoStore.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.commit(walRecord{Op: walDelete, ID: id})
+}
+
+// Merge logs the merge of sourceID into targetID and applies it.
+// It fails like TodoStore.Merge.
+func (s *WALStore) Merge(ctx context.Context, targetID, sourceID int) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if targetID == sourceID {
+		return nil, ErrMergeIntoItself
+	}
+	if _, err := s.TodoStore.GetByID(ctx, sourceID); err != nil {
+		return nil, err
+	}
+	return s.change(ctx, targetID, walRecord{Op: walMerge, SourceID: sourceID})
+}
+
+// CreateMilestone logs a new milestone and adds it.
+func (s *WALStore) CreateMilestone(ctx context.Context, input MilestoneInput) (Milestone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := walRecord{
+		Op:        walCreateMilestone,
+		ID:        s.TodoStore.peekNextMilestoneID(),
+		Name:      input.Name,
+		StartDate: input.StartDate,
+		EndDate:   input.EndDate,
+	}
+	if err := s.commit(rec); err != nil {
+		return Milestone{}, err
+	}
+	return Milestone{ID: rec.ID, Name: rec.Name, StartDate: rec.StartDate, EndDate: rec.EndDate, TodoIDs: []int{}}, nil
+}
+
+// AssignMilestone logs the move of the todo into the milestone and applies
+// it. It returns ErrNotFound if the milestone does not exist.
+func (s *WALStore) AssignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, walAssignMilestone, milestoneID, todoID)
+}
+
+// UnassignMilestone logs the removal of the todo from the milestone and
+// applies it. It returns ErrNotFound if the milestone does not exist.
+func (s *WALStore) UnassignMilestone(ctx context.Context, milestoneID, todoID int) error {
+	return s.moveTodo(ctx, walUnassignMilestone, milestoneID, todoID)
+}
+
+// moveTodo logs and applies op, which moves the todo into or out of the
+// milestone. It returns ErrNotFound, logging nothing, if the milestone does
+// not exist.
+func (s *WALStore) moveTodo(ctx context.Context, op string, milestoneID, todoID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.TodoStore.hasMilestone(milestoneID) {
+		return ErrNotFound
+	}
+	return s.commit(walRecord{Op: op, ID: milestoneID, TodoID: todoID})
+}
+
+// Restore replaces the content of the store with b and compacts the log, so
+// the snapshot holds the restored state and older records are dropped.
+func (s *WALStore) Restore(ctx context.Context, b Backup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.restore(ctx, b)
+}
+
+// Seed adds todos if the store has never handed out an ID. Like Restore it
+// writes them as a snapshot, keeping any milestones, instead of logging a
+// record per todo, so a crash cannot leave the store half seeded.
+func (s *WALStore) Seed(ctx context.Context, todos []BackupTodo) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.TodoStore.peekNextID() != 1 {
+		return false, nil
+	}
+	b, err := s.TodoStore.Backup(ctx)
+	if err != nil {
+		return false, err
+	}
+	b.Todos = todos
+	if err := s.restore(ctx, b); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restore is Restore with s.mu held. The snapshot is written before the
+// content in memory is replaced, so a failed write leaves the store as it
+// was, in memory and on disk alike. Once the snapshot is written the
+// restore is durable: a log that cannot be truncated then only holds
+// records recovery skips.
+func (s *WALStore) restore(ctx context.Context, b Backup) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	// Restoring keeps IDs from being reused, and so must recovery.
+	snapshot := b
+	snapshot.NextID = max(s.TodoStore.peekNextID(), b.nextID())
+	if err := s.writeSnapshot(snapshot); err != nil {
+		return err
+	}
+	if err := s.TodoStore.Restore(ctx, b); err != nil {
+		return err
+	}
+	if err := s.truncateLog(); err != nil {
+		log.Printf("todo: truncate write-ahead log after restore: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package todo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDir opens the lock file of the log directory dir. File locks are
+// only taken on Unix systems; elsewhere nothing keeps two stores from
+// opening the same log.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, walLockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir takes an exclusive lock on the lock file of the log directory
+// dir, held until the returned file is closed. It fails at once if another
+// store, in this process or another, holds it.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, walLockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("log directory %s is in use by another store", dir)
+		}
+		return nil, fmt.Errorf("lock log directory: %w", err)
+	}
+	return f, nil
+}
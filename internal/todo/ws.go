@@ -0,0 +1,150 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/efrem/windsurf/internal/events"
+)
+
+const (
+	// wsSendBuffer is the number of messages queued per connection. A client
+	// that falls further behind is disconnected.
+	wsSendBuffer = 64
+	// wsWriteTimeout bounds how long a single message may take to send.
+	wsWriteTimeout = 10 * time.Second
+)
+
+// WSCommand is a message sent by a client over GET /ws. Type selects the
+// command: "create" uses Title and Description, "complete" uses TodoID. ID is
+// echoed in the reply so clients can correlate them.
+type WSCommand struct {
+	Type        string `json:"type"`
+	ID          string `json:"id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	TodoID      int    `json:"todo_id,omitempty"`
+}
+
+// WSMessage is a message sent by the server over GET /ws: a "change" when a
+// todo changed, and a "result" or "error" in reply to a command.
+type WSMessage struct {
+	Type    string  `json:"type"`
+	ID      string  `json:"id,omitempty"`
+	Change  *Change `json:"change,omitempty"`
+	Todo    *Todo   `json:"todo,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+// ServeWebSocket handles GET /ws. It upgrades the connection to a WebSocket,
+// broadcasts every change as it happens, and runs create and complete
+// commands sent by the client.
+func (api *TodoAPI) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		// Match the API's CORS policy, which allows every origin.
+		OriginPatterns: []string{"*"},
+	})
+	if err != nil {
+		// Accept has already written the error response.
+		return
+	}
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	out := make(chan WSMessage, wsSendBuffer)
+	var tooSlow atomic.Bool
+	unsubscribe := api.events.Subscribe(func(e events.Event) {
+		// The change log is subscribed first, so its latest sequence is e's.
+		change := api.presentChange(r, loggedChange{seq: api.changes.latest(), event: e})
+		select {
+		case out <- WSMessage{Type: "change", Change: &change}:
+		default:
+			tooSlow.Store(true)
+			cancel()
+		}
+	})
+	defer unsubscribe()
+
+	go api.readWebSocket(ctx, cancel, conn, r, out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if tooSlow.Load() {
+				conn.Close(websocket.StatusPolicyViolation, "client is too slow")
+			}
+			return
+		case msg := <-out:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				panic(fmt.Errorf("todo: encode websocket message: %w", err))
+			}
+			writeCtx, done := context.WithTimeout(ctx, wsWriteTimeout)
+			err = conn.Write(writeCtx, websocket.MessageText, data)
+			done()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readWebSocket runs the commands received on conn and queues their replies
+// on out. It cancels ctx when the client disconnects.
+func (api *TodoAPI) readWebSocket(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, r *http.Request, out chan<- WSMessage) {
+	defer cancel()
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		var reply WSMessage
+		var cmd WSCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			reply = WSMessage{Type: "error", Error: "Invalid JSON", Message: "Commands must be JSON objects"}
+		} else {
+			reply = api.runWebSocketCommand(r, cmd)
+		}
+
+		select {
+		case out <- reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runWebSocketCommand executes cmd and returns the reply for the client.
+func (api *TodoAPI) runWebSocketCommand(r *http.Request, cmd WSCommand) WSMessage {
+	fail := func(error, message string) WSMessage {
+		return WSMessage{Type: "error", ID: cmd.ID, Error: error, Message: message}
+	}
+
+	var todo *Todo
+	var err error
+	switch cmd.Type {
+	case "create":
+		todo, err = api.service.CreateTodo(r.Context(), TodoInput{Title: cmd.Title, Description: cmd.Description})
+	case "complete":
+		todo, err = api.service.TransitionTodo(r.Context(), cmd.TodoID, TransitionComplete)
+	default:
+		return fail("Unknown command", fmt.Sprintf("Command type %q is not supported; use create or complete", cmd.Type))
+	}
+	if err != nil {
+		_, title, message := describeTodoError(cmd.TodoID, err)
+		return fail(title, message)
+	}
+
+	presented := api.present(r, todo)
+	return WSMessage{Type: "result", ID: cmd.ID, Todo: &presented}
+}
@@ -0,0 +1,178 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// xmlContentType is the Content-Type of XML responses.
+const xmlContentType = MediaTypeXML + "; charset=utf-8"
+
+// xmlItemNames names the elements of the members of each array. Members of
+// other arrays are item elements.
+var xmlItemNames = map[string]string{
+	"todos":      "todo",
+	"milestones": "milestone",
+	"changes":    "change",
+	"errors":     "error",
+	"tags":       "tag",
+	"results":    "result",
+	"properties": "property",
+	"fields":     "field",
+}
+
+// xmlName matches JSON keys that are usable as XML element names. Other
+// keys become property elements with a name attribute.
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// xmlRootName returns the name of the document element representing payload.
+func xmlRootName(payload any) string {
+	switch payload.(type) {
+	case APIRoot:
+		return "api"
+	case Todo:
+		return "todo"
+	case TodoCollection:
+		return "todos"
+	case ErrorResponse:
+		return "error"
+	}
+	return "resource"
+}
+
+// toXML converts the JSON representation body to XML with the document
+// element root. Object properties become child elements in document order,
+// with the leading underscore of _links and _meta dropped; array members
+// are repeated elements; and every link becomes an element with rel, href
+// and method attributes, so the hypermedia controls match the JSON ones.
+func toXML(body []byte, root string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	doc, err := decodeHTMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := encodeXMLNode(enc, root, doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// encodeXMLNode writes node as an element named after key.
+func encodeXMLNode(enc *xml.Encoder, key string, node htmlNode) error {
+	name := strings.TrimPrefix(key, "_")
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlName.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "property"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: key}},
+		}
+	}
+
+	switch node.Kind {
+	case "object":
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, child := range node.Children {
+			var err error
+			if key == "_links" {
+				err = encodeXMLLink(enc, child.Key, child)
+			} else {
+				err = encodeXMLNode(enc, child.Key, child)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case "array":
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		item, ok := xmlItemNames[name]
+		if !ok {
+			item = "item"
+		}
+		for _, child := range node.Children {
+			if err := encodeXMLNode(enc, item, child); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case "link":
+		start.Attr = append(start.Attr, linkAttrs(node)...)
+		return enc.EncodeElement("", start)
+	}
+	if node.Value == "null" {
+		return enc.EncodeElement("", start)
+	}
+	return enc.EncodeElement(node.Value, start)
+}
+
+// encodeXMLLink writes the link node of relation rel as a link element.
+// Absent links are omitted.
+func encodeXMLLink(enc *xml.Encoder, rel string, node htmlNode) error {
+	switch node.Kind {
+	case "link":
+		start := xml.StartElement{
+			Name: xml.Name{Local: "link"},
+			Attr: append([]xml.Attr{{Name: xml.Name{Local: "rel"}, Value: rel}}, linkAttrs(node)...),
+		}
+		return enc.EncodeElement("", start)
+	case "array":
+		for _, child := range node.Children {
+			if err := encodeXMLLink(enc, rel, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "object":
+		if rel == "curies" {
+			return encodeXMLCurie(enc, node)
+		}
+	case "scalar":
+		if node.Value == "null" {
+			return nil
+		}
+	}
+	return encodeXMLNode(enc, rel, node)
+}
+
+// encodeXMLCurie writes a curie object as a curie element whose attributes
+// are its properties.
+func encodeXMLCurie(enc *xml.Encoder, node htmlNode) error {
+	start := xml.StartElement{Name: xml.Name{Local: "curie"}}
+	for _, child := range node.Children {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: child.Key}, Value: child.Value})
+	}
+	return enc.EncodeElement("", start)
+}
+
+// linkAttrs returns the href and method attributes of a link node, and
+// templated="true" for URI templates.
+func linkAttrs(node htmlNode) []xml.Attr {
+	attrs := []xml.Attr{{Name: xml.Name{Local: "href"}, Value: node.Href}}
+	method := node.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "method"}, Value: method})
+	if node.Templated {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "templated"}, Value: "true"})
+	}
+	return attrs
+}
@@ -0,0 +1,140 @@
+// Package validate checks the fields of decoded request bodies against
+// declared rules, collecting every violation so handlers can report them
+// together in one response.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError describes a single invalid field and the rule it violates.
+type FieldError struct {
+	Field   string
+	Message string
+	Rule    string
+}
+
+// Errors aggregates all invalid fields of a body.
+type Errors []FieldError
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+	return "invalid fields: " + strings.Join(msgs, "; ")
+}
+
+// Names of the built-in rules.
+const (
+	RuleRequired            = "required"
+	RuleMaxLength           = "max_length"
+	RuleMaxBytes            = "max_bytes"
+	RuleNoControlCharacters = "no_control_characters"
+	RuleNoWhiteSpace        = "no_white_space"
+)
+
+// Rule is a named check of the value of a field. Check returns a message
+// describing the violation, such as "is required", or "" if the value is
+// valid.
+type Rule struct {
+	Name  string
+	Check func(value string) string
+}
+
+// Field declares the rules of one string field of a body.
+type Field struct {
+	// Name is the name of the field in the body, reported with its
+	// violations.
+	Name string
+	// Value points at the field. With Trim, it is trimmed in place.
+	Value *string
+	// Trim removes leading and trailing white space before the rules are
+	// checked.
+	Trim bool
+	// Rules are checked in order; only the first violation is reported.
+	Rules []Rule
+}
+
+// Check trims and checks fields in order. It returns their violations as
+// Errors, or nil if every field is valid.
+func Check(fields ...Field) error {
+	var errs Errors
+	for _, f := range fields {
+		if f.Trim {
+			*f.Value = strings.TrimSpace(*f.Value)
+		}
+		for _, rule := range f.Rules {
+			if msg := rule.Check(*f.Value); msg != "" {
+				errs = append(errs, FieldError{Field: f.Name, Message: msg, Rule: rule.Name})
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Required rejects empty values.
+func Required() Rule {
+	return Rule{Name: RuleRequired, Check: func(value string) string {
+		if value == "" {
+			return "is required"
+		}
+		return ""
+	}}
+}
+
+// MaxLength rejects values longer than n characters.
+func MaxLength(n int) Rule {
+	return Rule{Name: RuleMaxLength, Check: func(value string) string {
+		if utf8.RuneCountInString(value) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}}
+}
+
+// MaxBytes rejects values longer than n bytes.
+func MaxBytes(n int) Rule {
+	return Rule{Name: RuleMaxBytes, Check: func(value string) string {
+		if len(value) > n {
+			return fmt.Sprintf("must be at most %d bytes", n)
+		}
+		return ""
+	}}
+}
+
+// NoControlCharacters rejects values containing control characters other
+// than those in allowed, such as "\n" for multi-line text, and invalid
+// UTF-8.
+func NoControlCharacters(allowed string) Rule {
+	return Rule{Name: RuleNoControlCharacters, Check: func(value string) string {
+		if !utf8.ValidString(value) {
+			return "must be valid UTF-8"
+		}
+		for _, r := range value {
+			if unicode.IsControl(r) && !strings.ContainsRune(allowed, r) {
+				return "must not contain control characters"
+			}
+		}
+		return ""
+	}}
+}
+
+// NoWhiteSpace rejects values containing white space, for single words
+// such as tags.
+func NoWhiteSpace() Rule {
+	return Rule{Name: RuleNoWhiteSpace, Check: func(value string) string {
+		if strings.ContainsFunc(value, unicode.IsSpace) {
+			return "must not contain white space"
+		}
+		return ""
+	}}
+}
@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckValidFields(t *testing.T) {
+	title, notes := "  Learn Go ", "line one\nline two"
+	err := Check(
+		Field{Name: "title", Value: &title, Trim: true, Rules: []Rule{Required(), MaxLength(8), NoControlCharacters("")}},
+		Field{Name: "notes", Value: &notes, Rules: []Rule{MaxBytes(100), NoControlCharacters("\n")}},
+	)
+	if err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+	if title != "Learn Go" {
+		t.Fatalf("expected the title to be trimmed in place, got %q", title)
+	}
+}
+
+func TestCheckAggregatesErrors(t *testing.T) {
+	empty, long, control, wide, spaced := " ", strings.Repeat("a", 6), "a\x00b", "ééé", "two words"
+	err := Check(
+		Field{Name: "title", Value: &empty, Trim: true, Rules: []Rule{Required(), MaxLength(5)}},
+		Field{Name: "long", Value: &long, Rules: []Rule{MaxLength(5), NoControlCharacters("")}},
+		Field{Name: "control", Value: &control, Rules: []Rule{NoControlCharacters("\n")}},
+		Field{Name: "wide", Value: &wide, Rules: []Rule{MaxLength(3), MaxBytes(5)}},
+		Field{Name: "spaced", Value: &spaced, Rules: []Rule{MaxLength(10), NoWhiteSpace()}},
+	)
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors, got %T: %v", err, err)
+	}
+	want := []FieldError{
+		{Field: "title", Message: "is required", Rule: RuleRequired},
+		{Field: "long", Message: "must be at most 5 characters", Rule: RuleMaxLength},
+		{Field: "control", Message: "must not contain control characters", Rule: RuleNoControlCharacters},
+		{Field: "wide", Message: "must be at most 5 bytes", Rule: RuleMaxBytes},
+		{Field: "spaced", Message: "must not contain white space", Rule: RuleNoWhiteSpace},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Fatalf("error %d: expected %+v, got %+v", i, want[i], errs[i])
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "invalid fields: title: is required;") {
+		t.Fatalf("unexpected error string %q", err.Error())
+	}
+}